@@ -4,18 +4,35 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"html/template"
 	"log"
 	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
 
 	"github.com/a-bondar/go-url-shortener/internal/app/config"
+	"github.com/a-bondar/go-url-shortener/internal/app/geoip"
 	"github.com/a-bondar/go-url-shortener/internal/app/handlers"
 	"github.com/a-bondar/go-url-shortener/internal/app/logger"
+	"github.com/a-bondar/go-url-shortener/internal/app/policy"
 	"github.com/a-bondar/go-url-shortener/internal/app/router"
 	"github.com/a-bondar/go-url-shortener/internal/app/service"
 	"github.com/a-bondar/go-url-shortener/internal/app/store"
+	"github.com/a-bondar/go-url-shortener/internal/app/tracing"
+	"github.com/a-bondar/go-url-shortener/internal/app/webhooks"
 	"go.uber.org/zap"
 )
 
+const (
+	shutdownTimeout       = 10 * time.Second
+	traceExporterTimeout  = 5 * time.Second
+	traceExporterToStdout = "stdout"
+	policyReloadInterval  = 10 * time.Second
+	reputationTimeout     = 2 * time.Second
+)
+
 func main() {
 	if err := Run(); err != nil {
 		log.Fatal(err)
@@ -36,10 +53,29 @@ func Run() error {
 		}
 	}(l)
 
-	cfg := config.NewConfig()
-	s, err := store.NewStore(context.Background(), store.Config{
+	cfg, err := config.NewConfig()
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+
+	shutdownTracing := setupTracing(cfg.TraceExporter, l)
+	defer shutdownTracing()
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	s, err := store.NewStore(ctx, store.Config{
 		DatabaseDSN:     cfg.DatabaseDSN,
 		FileStoragePath: cfg.FileStoragePath,
+		AutoMigrate:     cfg.AutoMigrate,
+		DB: store.DBOptions{
+			MaxConns:         int32(cfg.DBMaxConns), //nolint:gosec // the range is checked by config.NewConfig
+			MaxConnLifetime:  cfg.DBMaxConnLifetime,
+			MaxConnIdleTime:  cfg.DBMaxConnIdleTime,
+			StatementTimeout: cfg.DBStatementTimeout,
+			QueryTimeout:     cfg.DBQueryTimeout,
+			ConnectTimeout:   cfg.DBConnectTimeout,
+		},
 	}, l)
 	if err != nil {
 		return fmt.Errorf("failed to initialize store: %w", err)
@@ -48,14 +84,79 @@ func Run() error {
 	defer s.Close()
 
 	svc := service.NewService(s, cfg, l)
+
+	destinationPolicy, err := setupPolicy(cfg, l)
+	if err != nil {
+		return fmt.Errorf("failed to load destination policy: %w", err)
+	}
+
+	svc.SetDestinationPolicy(destinationPolicy)
+	go destinationPolicy.Watch(ctx, policyReloadInterval)
+
+	if cfg.GeoIPFile != "" {
+		var geoDB *geoip.DB
+		if geoDB, err = geoip.Open(cfg.GeoIPFile); err != nil {
+			return fmt.Errorf("failed to load GeoIP database: %w", err)
+		}
+
+		svc.SetGeoLocator(geoDB)
+	}
+
+	dispatcher := webhooks.NewDispatcher(s, webhooks.NewClient(), l, webhooks.DefaultOptions())
+	dispatcherCtx, stopDispatcher := context.WithCancel(ctx)
+	dispatcherDone := make(chan struct{})
+	go func() {
+		defer close(dispatcherDone)
+		dispatcher.Run(dispatcherCtx)
+	}()
+	// Отправщик пишет в хранилище, поэтому дожидаемся его до закрытия s.
+	defer func() {
+		stopDispatcher()
+		<-dispatcherDone
+	}()
+
 	svc.StartCleanupJob(context.Background())
 	defer svc.StopCleanupJob()
 
 	h := handlers.NewHandler(svc, l)
+	h.SetInternalToken(cfg.InternalToken)
+	if cfg.PendingPageFile != "" {
+		var page *template.Template
+		if page, err = template.ParseFiles(cfg.PendingPageFile); err != nil {
+			return fmt.Errorf("failed to load pending link page: %w", err)
+		}
+
+		h.SetPendingPage(page)
+	}
+
+	srv := &http.Server{
+		Addr:    cfg.RunAddr,
+		Handler: router.Router(h, svc, l),
+	}
+	srv.RegisterOnShutdown(h.StopStreams)
+
+	shutdownDone := make(chan struct{})
+	go func() {
+		defer close(shutdownDone)
+
+		<-ctx.Done()
+
+		// Сначала отвечаем на /readyz ошибкой, чтобы балансировщик успел снять трафик.
+		l.Info("Shutting down server", zap.Duration("delay", cfg.ShutdownDelay))
+		svc.BeginShutdown()
+		time.Sleep(cfg.ShutdownDelay)
+
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+		defer cancel()
+
+		if err := srv.Shutdown(shutdownCtx); err != nil {
+			l.Error("Failed to shutdown HTTP server", zap.Error(err))
+		}
+	}()
 
 	l.Info("Running server", zap.String("address", cfg.RunAddr))
 
-	if err := http.ListenAndServe(cfg.RunAddr, router.Router(h, l)); err != nil {
+	if err := srv.ListenAndServe(); err != nil {
 		if !errors.Is(err, http.ErrServerClosed) {
 			l.Error("HTTP server has encountered an error", zap.Error(err))
 
@@ -63,5 +164,46 @@ func Run() error {
 		}
 	}
 
+	<-shutdownDone
+
 	return nil
 }
+
+// setupPolicy собирает политику адресов назначения из файла правил и внешнего сервиса репутации.
+func setupPolicy(cfg *config.Config, l *zap.Logger) (*policy.Policy, error) {
+	var checker policy.Checker
+	if cfg.ReputationCheckerURL != "" {
+		checker = policy.NewHTTPChecker(cfg.ReputationCheckerURL, &http.Client{Timeout: reputationTimeout}, l)
+	}
+
+	p, err := policy.New(cfg.PolicyFile, checker, l)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create policy: %w", err)
+	}
+
+	return p, nil
+}
+
+// setupTracing включает экспорт спанов и возвращает функцию, которая
+// дожидается отправки накопленных спанов при остановке.
+func setupTracing(exporter string, l *zap.Logger) func() {
+	switch exporter {
+	case "":
+		return func() {}
+	case traceExporterToStdout:
+		tracing.SetExporter(tracing.NewStdoutExporter(os.Stdout, l))
+		return func() {}
+	}
+
+	e := tracing.NewHTTPExporter(exporter, &http.Client{Timeout: traceExporterTimeout}, l)
+	tracing.SetExporter(e)
+
+	return func() {
+		ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+		defer cancel()
+
+		if err := e.Shutdown(ctx); err != nil {
+			l.Error("Failed to shutdown trace exporter", zap.Error(err))
+		}
+	}
+}
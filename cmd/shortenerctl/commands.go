@@ -0,0 +1,453 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"text/tabwriter"
+	"time"
+
+	"github.com/a-bondar/go-url-shortener/internal/app/config"
+	"github.com/a-bondar/go-url-shortener/internal/app/models"
+	"github.com/a-bondar/go-url-shortener/internal/app/store"
+)
+
+const (
+	defaultListLimit = 100
+	// linksPageSize — сколько ссылок читается одним запросом: выборка всей таблицы
+	// разом не уложилась бы в таймаут запроса к БД.
+	linksPageSize = 1000
+	// maxRecordSize — предел длины строки выгрузки при импорте.
+	maxRecordSize = 1 << 20
+)
+
+func runList(ctx context.Context, s store.Store, _ *config.Config, args []string, out io.Writer) error {
+	var search models.LinkSearch
+	fs := newFlagSet("list")
+	fs.StringVar(&search.UserID, "user", "", "only links of this owner")
+	fs.StringVar(&search.ShortURL, "code", "", "only links with this short code")
+	fs.StringVar(&search.Destination, "destination", "", "only links whose destination contains this text")
+	fs.BoolVar(&search.IncludeDeleted, "deleted", false, "include deleted links")
+	fs.IntVar(&search.Limit, "limit", defaultListLimit, "maximum number of links, 0 for all")
+	if err := fs.Parse(args); err != nil {
+		return fmt.Errorf("list: %w", err)
+	}
+
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "DOMAIN\tCODE\tOWNER\tSTATE\tDESTINATION")
+	err := searchLinks(ctx, s, search, func(link models.Data) error {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n",
+			orDash(link.Domain), link.ShortURL, orDash(link.UserID), linkState(link), link.OriginalURL)
+
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to search links: %w", err)
+	}
+
+	if err = w.Flush(); err != nil {
+		return fmt.Errorf("failed to write links: %w", err)
+	}
+
+	return nil
+}
+
+func runDelete(ctx context.Context, s store.Store, _ *config.Config, args []string, out io.Writer) error {
+	return changeLinks(ctx, s, "delete", args, out)
+}
+
+func runRestore(ctx context.Context, s store.Store, _ *config.Config, args []string, out io.Writer) error {
+	return changeLinks(ctx, s, "restore", args, out)
+}
+
+// changeLinks удаляет или восстанавливает ссылки по коду от имени их владельцев.
+func changeLinks(ctx context.Context, s store.Store, name string, args []string, out io.Writer) error {
+	fs := newFlagSet(name)
+	domain := fs.String("domain", "", "domain of the short codes, empty for the main domain")
+	if err := fs.Parse(args); err != nil {
+		return fmt.Errorf("%s: %w", name, err)
+	}
+
+	if fs.NArg() == 0 {
+		return fmt.Errorf("%s: no short codes given", name)
+	}
+
+	restore := name == "restore"
+	for _, code := range fs.Args() {
+		links, err := s.SearchURLs(ctx, models.LinkSearch{ShortURL: code, IncludeDeleted: true})
+		if err != nil {
+			return fmt.Errorf("failed to find %s: %w", code, err)
+		}
+
+		// Удалённых копий кода может быть несколько, нужна ссылка в противоположном состоянии.
+		var link *models.Data
+		for i := range links {
+			if links[i].Domain == *domain && links[i].Deleted == restore {
+				link = &links[i]
+				break
+			}
+		}
+
+		if link == nil {
+			fmt.Fprintf(out, "%s: not found\n", code)
+			continue
+		}
+
+		if !restore {
+			if err = s.DeleteURLs(ctx, *domain, []string{code}, link.UserID); err != nil {
+				return fmt.Errorf("failed to delete %s: %w", code, err)
+			}
+
+			fmt.Fprintf(out, "%s: deleted\n", code)
+			continue
+		}
+
+		if err = s.RestoreURLs(ctx, *domain, []string{code}, link.UserID); err != nil {
+			return fmt.Errorf("failed to restore %s: %w", code, err)
+		}
+
+		// Код мог занять другой ссылкой, тогда хранилище восстановление пропускает.
+		active, err := s.GetURL(ctx, *domain, code)
+		if err != nil || active.Deleted || active.UserID != link.UserID {
+			fmt.Fprintf(out, "%s: not restored, the code is taken by another link\n", code)
+			continue
+		}
+
+		fmt.Fprintf(out, "%s: restored\n", code)
+	}
+
+	return nil
+}
+
+func runCleanup(ctx context.Context, s store.Store, cfg *config.Config, args []string, out io.Writer) error {
+	fs := newFlagSet("cleanup")
+	olderThan := fs.Duration("older-than", cfg.DeletedURLsRetention, "remove links deleted longer ago than this")
+	if err := fs.Parse(args); err != nil {
+		return fmt.Errorf("cleanup: %w", err)
+	}
+
+	before, err := s.GetStats(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get stats: %w", err)
+	}
+
+	if err = s.CleanupDeletedURLs(ctx, time.Now().Add(-*olderThan)); err != nil {
+		return fmt.Errorf("failed to cleanup deleted links: %w", err)
+	}
+
+	after, err := s.GetStats(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get stats: %w", err)
+	}
+
+	fmt.Fprintf(out, "removed %d deleted links\n", before.DeletedLinks-after.DeletedLinks)
+
+	return nil
+}
+
+// runExport выгружает все ссылки, включая удалённые, по одной JSON-записи на строку.
+func runExport(ctx context.Context, s store.Store, _ *config.Config, args []string, _ io.Writer) error {
+	fs := newFlagSet("export")
+	output := fs.String("o", "-", `output file, "-" for stdout`)
+	if err := fs.Parse(args); err != nil {
+		return fmt.Errorf("export: %w", err)
+	}
+
+	w := io.Writer(os.Stdout)
+	if *output != "-" {
+		file, err := os.Create(*output)
+		if err != nil {
+			return fmt.Errorf("failed to create %s: %w", *output, err)
+		}
+
+		defer func() {
+			if err := file.Close(); err != nil {
+				fmt.Fprintln(os.Stderr, "failed to close export file:", err)
+			}
+		}()
+
+		w = file
+	}
+
+	enc := json.NewEncoder(w)
+	exported := 0
+	err := searchLinks(ctx, s, models.LinkSearch{IncludeDeleted: true}, func(link models.Data) error {
+		if err := enc.Encode(link); err != nil {
+			return fmt.Errorf("failed to write link: %w", err)
+		}
+
+		exported++
+
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to export links: %w", err)
+	}
+
+	// Итог — в stderr, чтобы не смешивать его с выгрузкой в stdout.
+	fmt.Fprintf(os.Stderr, "exported %d links\n", exported)
+
+	return nil
+}
+
+// searchLinks читает ссылки страницами по linksPageSize и передаёт их fn по порядку;
+// search.Limit ограничивает общее число ссылок, 0 — все.
+func searchLinks(ctx context.Context, s store.Store, search models.LinkSearch, fn func(models.Data) error) error {
+	remaining := search.Limit
+	for {
+		page := search
+		page.Limit = linksPageSize
+		if remaining > 0 {
+			page.Limit = min(remaining, linksPageSize)
+		}
+
+		links, err := s.SearchURLs(ctx, page)
+		if err != nil {
+			return fmt.Errorf("failed to read links: %w", err)
+		}
+
+		for _, link := range links {
+			if err = fn(link); err != nil {
+				return err
+			}
+		}
+
+		if remaining > 0 {
+			if remaining -= len(links); remaining == 0 {
+				return nil
+			}
+		}
+
+		if len(links) < page.Limit {
+			return nil
+		}
+
+		last := links[len(links)-1]
+		search.After = &models.LinkCursor{Domain: last.Domain, ShortURL: last.ShortURL, UserID: last.UserID}
+	}
+}
+
+// runImport загружает выгрузку export. Ссылки сохраняются как есть — со счётчиками, историей адресов
+// и временем удаления; занятые коды и уже сокращённые адреса пропускаются и перечисляются в выводе.
+func runImport(ctx context.Context, s store.Store, _ *config.Config, args []string, out io.Writer) error {
+	fs := newFlagSet("import")
+	input := fs.String("i", "-", `input file, "-" for stdin`)
+	if err := fs.Parse(args); err != nil {
+		return fmt.Errorf("import: %w", err)
+	}
+
+	r := io.Reader(os.Stdin)
+	if *input != "-" {
+		file, err := os.Open(*input)
+		if err != nil {
+			return fmt.Errorf("failed to open %s: %w", *input, err)
+		}
+
+		defer func() {
+			if err := file.Close(); err != nil {
+				fmt.Fprintln(os.Stderr, "failed to close import file:", err)
+			}
+		}()
+
+		r = file
+	}
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, bufio.MaxScanTokenSize), maxRecordSize)
+
+	counts := make(map[importResult]int)
+	for line := 1; scanner.Scan(); line++ {
+		var link models.Data
+		if err := json.Unmarshal(scanner.Bytes(), &link); err != nil {
+			return fmt.Errorf("line %d: invalid link record: %w", line, err)
+		}
+
+		result, err := importLink(ctx, s, link)
+		if err != nil {
+			return fmt.Errorf("line %d: %w", line, err)
+		}
+
+		if result == importConflict {
+			fmt.Fprintf(out, "line %d: %s conflicts with an existing link, skipped\n", line, link.ShortURL)
+		}
+
+		counts[result]++
+	}
+
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("failed to read links: %w", err)
+	}
+
+	fmt.Fprintf(out, "imported %d links, %d already present, %d conflicts\n",
+		counts[importSaved], counts[importPresent], counts[importConflict])
+
+	return nil
+}
+
+type importResult int
+
+const (
+	importSaved importResult = iota
+	// importPresent — та же ссылка уже есть, например после прерванного импорта.
+	importPresent
+	// importConflict — код или адрес назначения заняты другой ссылкой.
+	importConflict
+)
+
+// importLink сохраняет ссылку под её прежним кодом так же, как перенос copy: удалённая ссылка
+// сохраняет время удаления, поэтому срок её хранения в корзине не начинается заново.
+func importLink(ctx context.Context, s store.Store, link models.Data) (importResult, error) {
+	res, err := s.WriteRecords(ctx, []models.Record{{Link: &link}})
+	if err != nil {
+		return importConflict, fmt.Errorf("failed to save %s: %w", link.ShortURL, err)
+	}
+
+	switch {
+	case res.Written > 0:
+		return importSaved, nil
+	case res.Present > 0:
+		return importPresent, nil
+	}
+
+	return importConflict, nil
+}
+
+func runStats(ctx context.Context, s store.Store, _ *config.Config, args []string, out io.Writer) error {
+	if err := newFlagSet("stats").Parse(args); err != nil {
+		return fmt.Errorf("stats: %w", err)
+	}
+
+	stats, err := s.GetStats(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get stats: %w", err)
+	}
+
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(w, "links\t%d\n", stats.Links)
+	fmt.Fprintf(w, "deleted links\t%d\n", stats.DeletedLinks)
+	fmt.Fprintf(w, "link owners\t%d\n", stats.Owners)
+	fmt.Fprintf(w, "registered users\t%d\n", stats.Users)
+	fmt.Fprintf(w, "active API keys\t%d\n", stats.APIKeys)
+	fmt.Fprintf(w, "workspaces\t%d\n", stats.Workspaces)
+	fmt.Fprintf(w, "webhooks\t%d\n", stats.Webhooks)
+	fmt.Fprintf(w, "queued deliveries\t%d\n", stats.QueuedDeliveries)
+	fmt.Fprintf(w, "dead deliveries\t%d\n", stats.DeadDeliveries)
+
+	if err = w.Flush(); err != nil {
+		return fmt.Errorf("failed to write stats: %w", err)
+	}
+
+	return nil
+}
+
+func runMigrate(ctx context.Context, cfg *config.Config, args []string, out io.Writer) error {
+	if cfg.DatabaseDSN == "" {
+		return errors.New("migrate: only the database store has migrations, set -d")
+	}
+
+	if len(args) == 0 {
+		return errors.New("migrate: expected up, down [N], to VERSION, force VERSION or version")
+	}
+
+	var err error
+	switch args[0] {
+	case "up":
+		err = store.MigrateUp(ctx, cfg.DatabaseDSN)
+	case "down":
+		steps := 1
+		if len(args) > 1 {
+			if steps, err = strconv.Atoi(args[1]); err != nil || steps < 1 {
+				return fmt.Errorf("migrate down: invalid number of steps %q", args[1])
+			}
+		}
+
+		err = store.MigrateDown(ctx, cfg.DatabaseDSN, steps)
+	case "to":
+		if len(args) < 2 {
+			return errors.New("migrate to: version is required")
+		}
+
+		version, parseErr := strconv.ParseUint(args[1], 10, 0)
+		if parseErr != nil {
+			return fmt.Errorf("migrate to: invalid version %q", args[1])
+		}
+
+		err = store.MigrateTo(ctx, cfg.DatabaseDSN, uint(version))
+	case "force":
+		if len(args) < 2 {
+			return errors.New("migrate force: version is required")
+		}
+
+		// -1 — как у golang-migrate: ни одна миграция не считается применённой.
+		version, parseErr := strconv.Atoi(args[1])
+		if parseErr != nil || version < -1 {
+			return fmt.Errorf("migrate force: invalid version %q", args[1])
+		}
+
+		if err = store.MigrateForce(ctx, cfg.DatabaseDSN, version); err != nil {
+			return fmt.Errorf("migrate force: %w", err)
+		}
+
+		return printMigrationVersion(ctx, cfg.DatabaseDSN, out)
+	case "version":
+		return printMigrationVersion(ctx, cfg.DatabaseDSN, out)
+	default:
+		return fmt.Errorf("migrate: unknown direction %q", args[0])
+	}
+
+	if err != nil {
+		return fmt.Errorf("migrate %s: %w", args[0], err)
+	}
+
+	fmt.Fprintln(out, "migrations applied")
+
+	return printMigrationVersion(ctx, cfg.DatabaseDSN, out)
+}
+
+// printMigrationVersion выводит версию схемы в БД рядом с той, которую ожидает эта сборка.
+func printMigrationVersion(ctx context.Context, dsn string, out io.Writer) error {
+	expected, err := store.LatestMigrationVersion()
+	if err != nil {
+		return fmt.Errorf("migrate version: %w", err)
+	}
+
+	version, dirty, err := store.MigrationVersion(ctx, dsn)
+	if err != nil {
+		return fmt.Errorf("migrate version: %w", err)
+	}
+
+	state := ""
+	if dirty {
+		state = ", dirty"
+	}
+
+	fmt.Fprintf(out, "schema version %d%s, expected %d\n", version, state, expected)
+
+	return nil
+}
+
+func newFlagSet(name string) *flag.FlagSet {
+	return flag.NewFlagSet("shortenerctl "+name, flag.ContinueOnError)
+}
+
+func linkState(link models.Data) string {
+	if link.Deleted {
+		return "deleted"
+	}
+
+	return "active"
+}
+
+func orDash(value string) string {
+	if value == "" {
+		return "-"
+	}
+
+	return value
+}
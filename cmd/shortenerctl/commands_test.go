@@ -0,0 +1,237 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/a-bondar/go-url-shortener/internal/app/config"
+	"github.com/a-bondar/go-url-shortener/internal/app/models"
+	"github.com/a-bondar/go-url-shortener/internal/app/store"
+	"github.com/jackc/pgx/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func newMemoryStore(t *testing.T) store.Store {
+	t.Helper()
+
+	s, err := store.NewStore(context.Background(), store.Config{}, zap.NewNop())
+	require.NoError(t, err)
+	t.Cleanup(s.Close)
+
+	return s
+}
+
+func saveLinks(t *testing.T, s store.Store, userID string, links map[string]string) {
+	t.Helper()
+
+	for code, fullURL := range links {
+		_, err := s.SaveURL(context.Background(), fullURL, code, userID, models.LinkMeta{}, models.LinkOptions{})
+		require.NoError(t, err)
+	}
+}
+
+func runCommand(t *testing.T, cmd command, s store.Store, args ...string) string {
+	t.Helper()
+
+	var out bytes.Buffer
+	require.NoError(t, cmd(context.Background(), s, &config.Config{}, args, &out))
+
+	return out.String()
+}
+
+func TestRunList(t *testing.T) {
+	s := newMemoryStore(t)
+	saveLinks(t, s, copyUserID, map[string]string{"one": "https://a.example/1", "two": "https://a.example/2"})
+	saveLinks(t, s, copyOtherUserID, map[string]string{"three": "https://b.example/3"})
+	require.NoError(t, s.DeleteURLs(context.Background(), "", []string{"two"}, copyUserID))
+
+	out := runCommand(t, runList, s)
+	assert.Contains(t, out, "DOMAIN")
+	assert.Contains(t, out, "one")
+	assert.Contains(t, out, "three")
+	assert.NotContains(t, out, "two")
+
+	out = runCommand(t, runList, s, "-user", copyUserID, "-deleted")
+	assert.Contains(t, out, "one")
+	assert.Regexp(t, `two\s+`+copyUserID+`\s+deleted\s+https://a.example/2`, out)
+	assert.NotContains(t, out, "three")
+
+	out = runCommand(t, runList, s, "-limit", "1")
+	assert.Len(t, strings.Split(strings.TrimSpace(out), "\n"), 2)
+}
+
+func TestRunDeleteRestore(t *testing.T) {
+	ctx := context.Background()
+	s := newMemoryStore(t)
+	saveLinks(t, s, copyUserID, map[string]string{"one": "https://a.example/1", "taken": "https://a.example/t"})
+
+	assert.Equal(t, "one: deleted\nmissing: not found\n", runCommand(t, runDelete, s, "one", "missing"))
+
+	link, err := s.GetURL(ctx, "", "one")
+	require.NoError(t, err)
+	assert.True(t, link.Deleted)
+
+	assert.Equal(t, "one: restored\n", runCommand(t, runRestore, s, "one"))
+	assert.Equal(t, "one: not found\n", runCommand(t, runRestore, s, "one"), "active link has nothing to restore")
+
+	// Пока ссылка лежала в корзине, её код занял другой владелец.
+	runCommand(t, runDelete, s, "taken")
+	saveLinks(t, s, copyOtherUserID, map[string]string{"taken": "https://b.example/t"})
+	assert.Equal(t, "taken: not restored, the code is taken by another link\n", runCommand(t, runRestore, s, "taken"))
+
+	assert.Equal(t, "one: not found\n", runCommand(t, runDelete, s, "-domain", "go.brand.example", "one"))
+
+	err = runDelete(ctx, s, &config.Config{}, nil, io.Discard)
+	assert.ErrorContains(t, err, "no short codes given")
+}
+
+func TestRunCleanupAndStats(t *testing.T) {
+	s := newMemoryStore(t)
+	saveLinks(t, s, copyUserID, map[string]string{"one": "https://a.example/1", "two": "https://a.example/2"})
+	require.NoError(t, s.DeleteURLs(context.Background(), "", []string{"two"}, copyUserID))
+
+	assert.Equal(t, "removed 0 deleted links\n", runCommand(t, runCleanup, s, "-older-than", "1h"))
+
+	stats := runCommand(t, runStats, s)
+	assert.Regexp(t, `(?m)^links\s+1$`, stats)
+	assert.Regexp(t, `(?m)^deleted links\s+1$`, stats)
+
+	assert.Equal(t, "removed 1 deleted links\n", runCommand(t, runCleanup, s, "-older-than", "0s"))
+
+	stats = runCommand(t, runStats, s)
+	assert.Regexp(t, `(?m)^links\s+1$`, stats)
+	assert.Regexp(t, `(?m)^deleted links\s+0$`, stats)
+}
+
+func TestRunExportImport(t *testing.T) {
+	dir := t.TempDir()
+	srcPath, dstPath := filepath.Join(dir, "src.json"), filepath.Join(dir, "dst.json")
+	exportPath, reexportPath := filepath.Join(dir, "links.jsonl"), filepath.Join(dir, "again.jsonl")
+	fillSource(t, srcPath)
+
+	src := openFileStore(t, srcPath)
+	defer src.Close()
+	runCommand(t, runExport, src, "-o", exportPath)
+
+	// Импорт идёт в файловое хранилище, чтобы проверить и то, что оно записало в журнал.
+	dst := openFileStore(t, dstPath)
+	out := runCommand(t, runImport, dst, "-i", exportPath)
+	assert.Equal(t, "imported 3 links, 0 already present, 0 conflicts\n", out)
+	dst.Close()
+
+	dst = openFileStore(t, dstPath)
+	defer dst.Close()
+
+	// Удалённая ссылка сохраняет время удаления, а изменённая — историю адресов.
+	deleted, err := dst.GetURL(context.Background(), "", "two")
+	require.NoError(t, err)
+	assert.True(t, deleted.Deleted)
+	require.NotNil(t, deleted.DeletedAt)
+	assert.Less(t, time.Since(*deleted.DeletedAt), time.Minute)
+
+	updated, err := dst.GetURL(context.Background(), "", "one")
+	require.NoError(t, err)
+	require.Len(t, updated.Revisions, 1)
+	assert.Equal(t, "https://a.example/1", updated.Revisions[0].OriginalURL)
+
+	runCommand(t, runExport, dst, "-o", reexportPath)
+	exported, err := os.ReadFile(exportPath)
+	require.NoError(t, err)
+	reexported, err := os.ReadFile(reexportPath)
+	require.NoError(t, err)
+	assert.Equal(t, string(exported), string(reexported))
+
+	// Повторный импорт ничего не дублирует.
+	out = runCommand(t, runImport, dst, "-i", exportPath)
+	assert.Equal(t, "imported 0 links, 3 already present, 0 conflicts\n", out)
+}
+
+func TestRunImportConflicts(t *testing.T) {
+	s := newMemoryStore(t)
+	saveLinks(t, s, copyOtherUserID, map[string]string{"one": "https://elsewhere.example/"})
+
+	path := filepath.Join(t.TempDir(), "links.jsonl")
+	content := `{"short_url":"one","original_url":"https://a.example/1","user_id":"` + copyUserID + `"}` + "\n" +
+		`{"short_url":"new","original_url":"https://a.example/new","user_id":"` + copyUserID + `"}` + "\n"
+	require.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+
+	out := runCommand(t, runImport, s, "-i", path)
+	assert.Equal(t, "line 1: one conflicts with an existing link, skipped\n"+
+		"imported 1 links, 0 already present, 1 conflicts\n", out)
+
+	require.NoError(t, os.WriteFile(path, []byte("{broken\n"), 0o600))
+	err := runImport(context.Background(), s, &config.Config{}, []string{"-i", path}, io.Discard)
+	assert.ErrorContains(t, err, "line 1: invalid link record")
+}
+
+func TestRunMigrateArguments(t *testing.T) {
+	cfg := &config.Config{DatabaseDSN: "postgres://shortener@127.0.0.1:1/shortener"}
+
+	testCases := []struct {
+		name string
+		cfg  *config.Config
+		args []string
+		err  string
+	}{
+		{name: "No database", cfg: &config.Config{}, args: []string{"up"}, err: "only the database store"},
+		{name: "No direction", cfg: cfg, err: "expected up, down [N]"},
+		{name: "Unknown direction", cfg: cfg, args: []string{"sideways"}, err: `unknown direction "sideways"`},
+		{name: "Bad steps", cfg: cfg, args: []string{"down", "0"}, err: `invalid number of steps "0"`},
+		{name: "Missing target version", cfg: cfg, args: []string{"to"}, err: "version is required"},
+		{name: "Bad target version", cfg: cfg, args: []string{"to", "-1"}, err: `invalid version "-1"`},
+		{name: "Bad forced version", cfg: cfg, args: []string{"force", "-2"}, err: `invalid version "-2"`},
+		{name: "Unavailable database", cfg: cfg, args: []string{"up"}, err: "migrate up"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := runMigrate(context.Background(), tc.cfg, tc.args, io.Discard)
+			assert.ErrorContains(t, err, tc.err)
+		})
+	}
+}
+
+// TestRunMigrate накатывает и откатывает миграции в БД из TEST_DATABASE_DSN; её схема public пересоздаётся.
+func TestRunMigrate(t *testing.T) {
+	dsn := os.Getenv("TEST_DATABASE_DSN")
+	if dsn == "" {
+		t.Skip("TEST_DATABASE_DSN is not set")
+	}
+
+	ctx := context.Background()
+	conn, err := pgx.Connect(ctx, dsn)
+	require.NoError(t, err)
+	_, err = conn.Exec(ctx, "DROP SCHEMA public CASCADE; CREATE SCHEMA public")
+	conn.Close(ctx)
+	require.NoError(t, err)
+
+	latest, err := store.LatestMigrationVersion()
+	require.NoError(t, err)
+
+	cfg := &config.Config{DatabaseDSN: dsn}
+	migrateOut := func(args ...string) string {
+		var out bytes.Buffer
+		require.NoError(t, runMigrate(ctx, cfg, args, &out))
+
+		return out.String()
+	}
+
+	assert.Equal(t, fmt.Sprintf("schema version 0, expected %d\n", latest), migrateOut("version"))
+	assert.Equal(t, fmt.Sprintf("migrations applied\nschema version %[1]d, expected %[1]d\n", latest), migrateOut("up"))
+	assert.Equal(t, fmt.Sprintf("migrations applied\nschema version %d, expected %d\n", latest-2, latest),
+		migrateOut("down", "2"))
+	assert.Equal(t, fmt.Sprintf("migrations applied\nschema version %[1]d, expected %[1]d\n", latest),
+		migrateOut("to", strconv.FormatUint(uint64(latest), 10)))
+	assert.Equal(t, fmt.Sprintf("schema version %[1]d, expected %[1]d\n", latest),
+		migrateOut("force", strconv.FormatUint(uint64(latest), 10)))
+}
@@ -0,0 +1,266 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"text/tabwriter"
+
+	"github.com/a-bondar/go-url-shortener/internal/app/config"
+	"github.com/a-bondar/go-url-shortener/internal/app/models"
+	"github.com/a-bondar/go-url-shortener/internal/app/store"
+	"go.uber.org/zap"
+)
+
+const (
+	defaultCopyBatch = 500
+	// stateFileMode — файл прогресса переноса читает и пишет только владелец.
+	stateFileMode = 0o600
+)
+
+// copyProgress — прогресс переноса записей одного вида.
+type copyProgress struct {
+	// Cursor — курсор источника после последней записанной порции.
+	Cursor  string `json:"cursor"`
+	Started bool   `json:"started"`
+	Done    bool   `json:"done"`
+	// TargetBefore — сколько таких записей было в целевом хранилище до начала переноса.
+	TargetBefore int64 `json:"target_before"`
+	Read         int64 `json:"read"`
+	Written      int64 `json:"written"`
+	Present      int64 `json:"present"`
+	Conflicts    int64 `json:"conflicts"`
+}
+
+// copyState сохраняется после каждой порции, чтобы прерванный перенос продолжился с того же места.
+type copyState struct {
+	Kinds map[string]*copyProgress `json:"kinds"`
+}
+
+// runCopy переносит все записи из настроенного хранилища в другое.
+func runCopy(ctx context.Context, s store.Store, cfg *config.Config, args []string, out io.Writer) error {
+	fs := newFlagSet("copy")
+	toDSN := fs.String("to-dsn", "", "database data source name of the target store")
+	toFile := fs.String("to-file", "", "file storage path of the target store")
+	batch := fs.Int("batch", defaultCopyBatch, "records per read and write")
+	statePath := fs.String("state", "", "progress file to resume an interrupted copy from")
+	if err := fs.Parse(args); err != nil {
+		return fmt.Errorf("copy: %w", err)
+	}
+
+	switch {
+	case (*toDSN == "") == (*toFile == ""):
+		return errors.New("copy: set exactly one of -to-dsn and -to-file")
+	case *batch < 1:
+		return fmt.Errorf("copy: invalid batch size %d", *batch)
+	case *toDSN != "" && *toDSN == cfg.DatabaseDSN,
+		*toFile != "" && cfg.DatabaseDSN == "" && *toFile == cfg.FileStoragePath:
+		return errors.New("copy: target is the source store")
+	}
+
+	state, err := loadCopyState(*statePath)
+	if err != nil {
+		return err
+	}
+
+	target, err := store.NewStore(ctx, storeConfig(cfg, *toDSN, *toFile), zap.NewNop())
+	if err != nil {
+		return fmt.Errorf("failed to initialize target store: %w", err)
+	}
+
+	defer target.Close()
+
+	save := func() error {
+		return saveCopyState(*statePath, state)
+	}
+
+	for _, kind := range models.RecordKinds {
+		progress, ok := state.Kinds[kind]
+		if !ok {
+			progress = &copyProgress{}
+			state.Kinds[kind] = progress
+		}
+
+		if err = copyKind(ctx, s, target, kind, *batch, progress, save, out); err != nil {
+			return err
+		}
+	}
+
+	return verifyCopy(ctx, s, target, state, out)
+}
+
+// copyKind переносит записи одного вида порциями и сохраняет прогресс после каждой записанной порции.
+func copyKind(ctx context.Context, src store.Store, dst store.Store,
+	kind string, batch int, progress *copyProgress, save func() error, out io.Writer) error {
+	if progress.Done {
+		return nil
+	}
+
+	if !progress.Started {
+		before, err := dst.CountRecords(ctx, kind)
+		if err != nil {
+			return fmt.Errorf("failed to count %s in target: %w", kind, err)
+		}
+
+		progress.Started, progress.TargetBefore = true, before
+	}
+
+	for {
+		page, err := src.ReadRecords(ctx, kind, progress.Cursor, batch)
+		if err != nil {
+			return fmt.Errorf("failed to read %s: %w", kind, err)
+		}
+
+		if len(page.Records) > 0 {
+			res, err := dst.WriteRecords(ctx, page.Records)
+			if err != nil {
+				return fmt.Errorf("failed to write %s: %w", kind, err)
+			}
+
+			for _, rec := range res.Conflicts {
+				fmt.Fprintln(out, "conflict:", describeRecord(rec))
+			}
+
+			progress.Read += int64(len(page.Records))
+			progress.Written += int64(res.Written)
+			progress.Present += int64(res.Present)
+			progress.Conflicts += int64(len(res.Conflicts))
+		}
+
+		progress.Cursor, progress.Done = page.Next, page.Next == ""
+		if err = save(); err != nil {
+			return err
+		}
+
+		if progress.Done {
+			return nil
+		}
+	}
+}
+
+// verifyCopy сверяет количество записей в обоих хранилищах с тем, что насчитал перенос.
+// Записи, сохранённые перед самым прерыванием, при продолжении учитываются как уже
+// существующие, поэтому прирост в целевом хранилище может превышать Written на Present.
+func verifyCopy(ctx context.Context, src store.Store, dst store.Store, state *copyState, out io.Writer) error {
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "KIND\tSOURCE\tCOPIED\tPRESENT\tCONFLICTS\tTARGET")
+
+	var mismatches []error
+	for _, kind := range models.RecordKinds {
+		progress := state.Kinds[kind]
+
+		sourceCount, err := src.CountRecords(ctx, kind)
+		if err != nil {
+			return fmt.Errorf("failed to count %s in source: %w", kind, err)
+		}
+
+		targetCount, err := dst.CountRecords(ctx, kind)
+		if err != nil {
+			return fmt.Errorf("failed to count %s in target: %w", kind, err)
+		}
+
+		fmt.Fprintf(w, "%s\t%d\t%d\t%d\t%d\t%d\n",
+			kind, sourceCount, progress.Written, progress.Present, progress.Conflicts, targetCount)
+
+		if sourceCount != progress.Read {
+			mismatches = append(mismatches,
+				fmt.Errorf("%s: source has %d records, copied from %d", kind, sourceCount, progress.Read))
+		}
+
+		if added := targetCount - progress.TargetBefore; added < progress.Written ||
+			added > progress.Written+progress.Present {
+			mismatches = append(mismatches,
+				fmt.Errorf("%s: target gained %d records, copy wrote %d", kind, added, progress.Written))
+		}
+	}
+
+	if err := w.Flush(); err != nil {
+		return fmt.Errorf("failed to write copy summary: %w", err)
+	}
+
+	if len(mismatches) > 0 {
+		return fmt.Errorf("copy verification failed: %w", errors.Join(mismatches...))
+	}
+
+	fmt.Fprintln(out, "counts verified")
+
+	return nil
+}
+
+// describeRecord называет запись так, чтобы её можно было найти в источнике.
+func describeRecord(rec models.Record) string {
+	switch {
+	case rec.Link != nil:
+		return fmt.Sprintf("link %s on domain %s of owner %s to %s",
+			rec.Link.ShortURL, orDash(rec.Link.Domain), orDash(rec.Link.UserID), rec.Link.OriginalURL)
+	case rec.User != nil:
+		return "user " + rec.User.ID
+	case rec.APIKey != nil:
+		return fmt.Sprintf("API key %s of user %s", rec.APIKey.ID, rec.APIKey.UserID)
+	case rec.Session != nil:
+		return "revoked session " + rec.Session.ID
+	case rec.Workspace != nil:
+		return "workspace " + rec.Workspace.ID
+	case rec.Member != nil:
+		return fmt.Sprintf("member %s of workspace %s", rec.Member.UserID, rec.Member.WorkspaceID)
+	case rec.Webhook != nil:
+		return fmt.Sprintf("webhook %s of user %s", rec.Webhook.ID, rec.Webhook.UserID)
+	case rec.Delivery != nil:
+		return fmt.Sprintf("delivery %s of webhook %s", rec.Delivery.ID, rec.Delivery.WebhookID)
+	default:
+		return "empty record"
+	}
+}
+
+// loadCopyState читает прогресс прерванного переноса; без файла перенос начинается сначала.
+func loadCopyState(path string) (*copyState, error) {
+	state := &copyState{Kinds: make(map[string]*copyProgress)}
+	if path == "" {
+		return state, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return state, nil
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to read copy state: %w", err)
+	}
+
+	if err = json.Unmarshal(data, state); err != nil {
+		return nil, fmt.Errorf("failed to parse copy state %s: %w", path, err)
+	}
+
+	if state.Kinds == nil {
+		state.Kinds = make(map[string]*copyProgress)
+	}
+
+	return state, nil
+}
+
+// saveCopyState заменяет файл прогресса целиком, чтобы прерывание не оставило его недописанным.
+func saveCopyState(path string, state *copyState) error {
+	if path == "" {
+		return nil
+	}
+
+	data, err := json.MarshalIndent(state, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode copy state: %w", err)
+	}
+
+	tmpPath := path + ".tmp"
+	if err = os.WriteFile(tmpPath, data, stateFileMode); err != nil {
+		return fmt.Errorf("failed to write copy state: %w", err)
+	}
+
+	if err = os.Rename(tmpPath, path); err != nil {
+		return fmt.Errorf("failed to replace copy state: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,199 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/a-bondar/go-url-shortener/internal/app/config"
+	"github.com/a-bondar/go-url-shortener/internal/app/models"
+	"github.com/a-bondar/go-url-shortener/internal/app/store"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+const (
+	copyUserID      = "6f1c1b7e-3a55-4d0e-9a3e-0b8f4f0c6a01"
+	copyOtherUserID = "6f1c1b7e-3a55-4d0e-9a3e-0b8f4f0c6a02"
+	copyWorkspaceID = "6f1c1b7e-3a55-4d0e-9a3e-0b8f4f0c6a03"
+	copyWebhookID   = "6f1c1b7e-3a55-4d0e-9a3e-0b8f4f0c6a04"
+)
+
+func openFileStore(t *testing.T, path string) store.Store {
+	t.Helper()
+
+	s, err := store.NewStore(context.Background(), store.Config{FileStoragePath: path}, zap.NewNop())
+	require.NoError(t, err)
+
+	return s
+}
+
+func fillSource(t *testing.T, path string) {
+	t.Helper()
+
+	ctx := context.Background()
+	s := openFileStore(t, path)
+	defer s.Close()
+
+	now := time.Now().Truncate(time.Second)
+	require.NoError(t, s.CreateUser(ctx, models.User{ID: copyUserID, Name: "ann", CreatedAt: now}))
+	require.NoError(t, s.CreateUser(ctx, models.User{ID: copyOtherUserID, Name: "bob", CreatedAt: now}))
+	require.NoError(t, s.CreateAPIKey(ctx, models.APIKey{
+		ID: "6f1c1b7e-3a55-4d0e-9a3e-0b8f4f0c6a05", UserID: copyUserID, Name: "ci", Prefix: "sk_1",
+		KeyHash: "hash", Scopes: []string{"links:read"}, CreatedAt: now,
+	}))
+	require.NoError(t, s.RevokeSession(ctx, models.RevokedSession{
+		ID: "6f1c1b7e-3a55-4d0e-9a3e-0b8f4f0c6a06", UserID: copyUserID, RevokedAt: now, ExpiresAt: now.Add(time.Hour),
+	}))
+	require.NoError(t, s.CreateWorkspace(ctx,
+		models.Workspace{ID: copyWorkspaceID, Name: "team", CreatedAt: now},
+		models.WorkspaceMember{WorkspaceID: copyWorkspaceID, UserID: copyUserID, Role: models.RoleOwner, AddedAt: now}))
+	require.NoError(t, s.SaveWorkspaceMember(ctx, models.WorkspaceMember{
+		WorkspaceID: copyWorkspaceID, UserID: copyOtherUserID, Role: models.RoleViewer, AddedAt: now,
+	}))
+
+	for code, fullURL := range map[string]string{"one": "https://a.example/1", "two": "https://a.example/2"} {
+		_, err := s.SaveURL(ctx, fullURL, code, copyUserID, models.LinkMeta{Title: code}, models.LinkOptions{})
+		require.NoError(t, err)
+	}
+
+	_, err := s.SaveURL(ctx, "https://b.example/", "ab", copyOtherUserID, models.LinkMeta{}, models.LinkOptions{
+		Targets:    []models.LinkTarget{{URL: "https://b.example/a", Weight: 1}, {URL: "https://b.example/b", Weight: 1}},
+		MaxClicks:  5,
+		ClicksLeft: 5,
+	})
+	require.NoError(t, err)
+	require.NoError(t, s.IncrementTargetClicks(ctx, "", "ab", 1))
+	require.NoError(t, s.ConsumeClick(ctx, "", "ab"))
+	_, err = s.UpdateURL(ctx, "", "one", copyUserID, "https://a.example/one")
+	require.NoError(t, err)
+	require.NoError(t, s.DeleteURLs(ctx, "", []string{"two"}, copyUserID))
+
+	require.NoError(t, s.CreateWebhook(ctx, models.Webhook{
+		ID: copyWebhookID, UserID: copyUserID, URL: "https://hooks.example/", Events: []string{"link.created"},
+		Secret: "whsec_test", CreatedAt: now,
+	}))
+	require.NoError(t, s.EnqueueDeliveries(ctx, []models.WebhookDelivery{{
+		ID: "6f1c1b7e-3a55-4d0e-9a3e-0b8f4f0c6a07", WebhookID: copyWebhookID, Event: "link.created",
+		Payload: []byte(`{"id":"evt"}`), NextAttemptAt: now, CreatedAt: now,
+	}}))
+}
+
+// readAll читает все записи вида kind порциями по одной.
+func readAll(t *testing.T, s store.Store, kind string) []models.Record {
+	t.Helper()
+
+	var (
+		records []models.Record
+		after   string
+	)
+	for {
+		page, err := s.ReadRecords(context.Background(), kind, after, 1)
+		require.NoError(t, err)
+
+		records = append(records, page.Records...)
+		if page.Next == "" {
+			return records
+		}
+		after = page.Next
+	}
+}
+
+func assertSameRecords(t *testing.T, srcPath string, dstPath string) {
+	t.Helper()
+
+	src, dst := openFileStore(t, srcPath), openFileStore(t, dstPath)
+	defer src.Close()
+	defer dst.Close()
+
+	for _, kind := range models.RecordKinds {
+		srcRecords := readAll(t, src, kind)
+		assert.NotEmpty(t, srcRecords, kind)
+		assert.Equal(t, srcRecords, readAll(t, dst, kind), kind)
+	}
+}
+
+func TestRunCopy(t *testing.T) {
+	dir := t.TempDir()
+	srcPath, dstPath := filepath.Join(dir, "src.json"), filepath.Join(dir, "dst.json")
+	fillSource(t, srcPath)
+
+	src := openFileStore(t, srcPath)
+	defer src.Close()
+
+	cfg := &config.Config{FileStoragePath: srcPath}
+	var out bytes.Buffer
+	require.NoError(t, runCopy(context.Background(), src, cfg, []string{"-to-file", dstPath, "-batch", "1"}, &out))
+	assert.Contains(t, out.String(), "counts verified")
+	assertSameRecords(t, srcPath, dstPath)
+
+	// Повторный перенос ничего не дублирует: всё уже на месте.
+	out.Reset()
+	require.NoError(t, runCopy(context.Background(), src, cfg, []string{"-to-file", dstPath}, &out))
+	assert.Contains(t, out.String(), "counts verified")
+	assert.NotContains(t, out.String(), "conflict:")
+	assertSameRecords(t, srcPath, dstPath)
+}
+
+func TestRunCopyResume(t *testing.T) {
+	dir := t.TempDir()
+	srcPath, dstPath := filepath.Join(dir, "src.json"), filepath.Join(dir, "dst.json")
+	statePath := filepath.Join(dir, "copy.state")
+	fillSource(t, srcPath)
+
+	src := openFileStore(t, srcPath)
+	defer src.Close()
+
+	// Перенос прерывается после записи второй порции ссылок, но до сохранения прогресса.
+	dst := openFileStore(t, dstPath)
+	state, err := loadCopyState(statePath)
+	require.NoError(t, err)
+
+	errInterrupted := errors.New("interrupted")
+	saves := 0
+	save := func() error {
+		if saves++; saves == 2 {
+			return errInterrupted
+		}
+		return saveCopyState(statePath, state)
+	}
+
+	state.Kinds[models.RecordLinks] = &copyProgress{}
+	err = copyKind(context.Background(), src, dst, models.RecordLinks, 1, state.Kinds[models.RecordLinks], save,
+		&bytes.Buffer{})
+	require.ErrorIs(t, err, errInterrupted)
+	dst.Close()
+
+	var out bytes.Buffer
+	args := []string{"-to-file", dstPath, "-batch", "1", "-state", statePath}
+	require.NoError(t, runCopy(context.Background(), src, &config.Config{FileStoragePath: srcPath}, args, &out))
+	assert.Contains(t, out.String(), "counts verified")
+	assertSameRecords(t, srcPath, dstPath)
+}
+
+func TestRunCopyConflicts(t *testing.T) {
+	dir := t.TempDir()
+	srcPath, dstPath := filepath.Join(dir, "src.json"), filepath.Join(dir, "dst.json")
+	fillSource(t, srcPath)
+
+	// В целевом хранилище код one у того же владельца уже ведёт на другой адрес.
+	dst := openFileStore(t, dstPath)
+	_, err := dst.SaveURL(context.Background(), "https://elsewhere.example/", "one", copyUserID,
+		models.LinkMeta{}, models.LinkOptions{})
+	require.NoError(t, err)
+	dst.Close()
+
+	src := openFileStore(t, srcPath)
+	defer src.Close()
+
+	var out bytes.Buffer
+	err = runCopy(context.Background(), src, &config.Config{FileStoragePath: srcPath},
+		[]string{"-to-file", dstPath}, &out)
+	require.NoError(t, err)
+	assert.Contains(t, out.String(), "conflict: link one on domain - of owner "+copyUserID)
+	assert.Contains(t, out.String(), "counts verified")
+}
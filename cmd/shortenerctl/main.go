@@ -0,0 +1,124 @@
+// Command shortenerctl выполняет служебные операции прямо с хранилищем сокращателя.
+// Хранилище выбирается так же, как у сервера, по тем же флагам и переменным окружения:
+//
+//	shortenerctl [флаги сервера] <команда> [флаги команды]
+//
+// Файловое хранилище сервер читает только при старте, поэтому менять его лучше при остановленном сервере.
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/a-bondar/go-url-shortener/internal/app/config"
+	"github.com/a-bondar/go-url-shortener/internal/app/store"
+	"go.uber.org/zap"
+)
+
+const usageText = `Usage: shortenerctl [server flags] <command> [command flags]
+
+Commands:
+  list      list links of all owners (-user, -code, -destination, -deleted, -limit)
+  delete    delete links by short code (-domain)
+  restore   restore deleted links by short code (-domain)
+  cleanup   remove deleted links for good (-older-than)
+  export    write all links as JSON lines (-o)
+  import    read links written by export (-i)
+  stats     print store counters
+  copy      copy every record to another store (-to-dsn or -to-file, -batch, -state)
+  migrate   up | down [N] | to VERSION | force VERSION | version, database store only;
+            going below version 11 fails while the same link exists on several domains
+
+Server flags:
+`
+
+// command — подкоманда, работающая с открытым хранилищем.
+type command func(ctx context.Context, s store.Store, cfg *config.Config, args []string, out io.Writer) error
+
+var commands = map[string]command{
+	"list":    runList,
+	"delete":  runDelete,
+	"restore": runRestore,
+	"cleanup": runCleanup,
+	"export":  runExport,
+	"import":  runImport,
+	"stats":   runStats,
+	"copy":    runCopy,
+}
+
+func main() {
+	if err := run(os.Stdout); err != nil {
+		fmt.Fprintln(os.Stderr, "shortenerctl:", err)
+		os.Exit(1)
+	}
+}
+
+func run(out io.Writer) error {
+	flag.Usage = func() {
+		fmt.Fprint(flag.CommandLine.Output(), usageText)
+		flag.PrintDefaults()
+	}
+
+	cfg, err := config.NewConfig()
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+
+	if flag.NArg() == 0 {
+		flag.Usage()
+		return errors.New("no command given")
+	}
+
+	name, args := flag.Arg(0), flag.Args()[1:]
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	// Миграции работают с БД напрямую: открытие хранилища само накатило бы их.
+	if name == "migrate" {
+		return runMigrate(ctx, cfg, args, out)
+	}
+
+	cmd, ok := commands[name]
+	if !ok {
+		return fmt.Errorf("unknown command %q", name)
+	}
+
+	if cfg.DatabaseDSN == "" && cfg.FileStoragePath == "" {
+		return errors.New("no persistent store configured, set -d or -f")
+	}
+
+	s, err := store.NewStore(ctx, storeConfig(cfg, cfg.DatabaseDSN, cfg.FileStoragePath), zap.NewNop())
+	if err != nil {
+		return fmt.Errorf("failed to initialize store: %w", err)
+	}
+
+	defer s.Close()
+
+	return cmd(ctx, s, cfg, args, out)
+}
+
+// storeConfig собирает настройки хранилища по адресу dsn или файлу fileStoragePath,
+// остальные параметры БД берутся из флагов сервера. Миграции утилита накатывает только
+// командой migrate, поэтому -auto-migrate сервера здесь не действует.
+func storeConfig(cfg *config.Config, dsn string, fileStoragePath string) store.Config {
+	return store.Config{
+		DatabaseDSN:     dsn,
+		FileStoragePath: fileStoragePath,
+		AutoMigrate:     false,
+		DB: store.DBOptions{
+			MaxConns:         int32(cfg.DBMaxConns), //nolint:gosec // the range is checked by config.NewConfig
+			MaxConnLifetime:  cfg.DBMaxConnLifetime,
+			MaxConnIdleTime:  cfg.DBMaxConnIdleTime,
+			StatementTimeout: cfg.DBStatementTimeout,
+			QueryTimeout:     cfg.DBQueryTimeout,
+			ConnectTimeout:   cfg.DBConnectTimeout,
+		},
+	}
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"testing"
+
+	"github.com/a-bondar/go-url-shortener/internal/app/config"
+	"github.com/a-bondar/go-url-shortener/internal/app/models"
+	"github.com/a-bondar/go-url-shortener/internal/app/store"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestStoreConfigNeverMigrates(t *testing.T) {
+	cfg := &config.Config{AutoMigrate: true, DBQueryTimeout: 1}
+
+	storeCfg := storeConfig(cfg, "postgres://localhost/db", "")
+	assert.False(t, storeCfg.AutoMigrate)
+	assert.Equal(t, "postgres://localhost/db", storeCfg.DatabaseDSN)
+	assert.Equal(t, cfg.DBQueryTimeout, storeCfg.DB.QueryTimeout)
+}
+
+// searchCounter считает запросы SearchURLs, чтобы проверить чтение страницами.
+type searchCounter struct {
+	store.Store
+	calls int
+}
+
+func (s *searchCounter) SearchURLs(ctx context.Context, search models.LinkSearch) ([]models.Data, error) {
+	s.calls++
+	return s.Store.SearchURLs(ctx, search)
+}
+
+func TestSearchLinksReadsPages(t *testing.T) {
+	ctx := context.Background()
+	inner, err := store.NewStore(ctx, store.Config{}, zap.NewNop())
+	require.NoError(t, err)
+
+	const total = 2*linksPageSize + 10
+	for i := range total {
+		code := fmt.Sprintf("c%05d", i)
+		_, err = inner.SaveURL(ctx, "https://a.example/"+code, code, copyUserID, models.LinkMeta{}, models.LinkOptions{})
+		require.NoError(t, err)
+	}
+
+	testCases := []struct {
+		name          string
+		limit         int
+		expectedLinks int
+		expectedCalls int
+	}{
+		{name: "All links", limit: 0, expectedLinks: total, expectedCalls: 3},
+		{name: "Limit spans pages", limit: linksPageSize + 5, expectedLinks: linksPageSize + 5, expectedCalls: 2},
+		{name: "Limit within a page", limit: 10, expectedLinks: 10, expectedCalls: 1},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			s := &searchCounter{Store: inner}
+
+			var codes []string
+			err := searchLinks(ctx, s, models.LinkSearch{Limit: tc.limit}, func(link models.Data) error {
+				codes = append(codes, link.ShortURL)
+				return nil
+			})
+			require.NoError(t, err)
+
+			assert.Len(t, codes, tc.expectedLinks)
+			assert.True(t, slices.IsSorted(codes), "pages must follow each other without gaps or repeats")
+			assert.Len(t, slices.Compact(slices.Clone(codes)), tc.expectedLinks)
+			assert.Equal(t, tc.expectedCalls, s.calls)
+		})
+	}
+}
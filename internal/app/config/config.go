@@ -2,7 +2,13 @@ package config
 
 import (
 	"flag"
+	"fmt"
+	"math"
+	"net/url"
 	"os"
+	"strconv"
+	"strings"
+	"time"
 )
 
 type Config struct {
@@ -10,15 +16,98 @@ type Config struct {
 	ShortLinkBaseURL string
 	FileStoragePath  string
 	DatabaseDSN      string
+	// AutoMigrate накатывает миграции БД при старте; без него их применяют через shortenerctl migrate.
+	AutoMigrate bool
+	// Пул соединений и таймауты БД; нулевые значения оставляют умолчания драйвера и снимают ограничения.
+	// Изменения ссылок пишут событие в общий outbox под одной блокировкой и фиксируются по очереди,
+	// поэтому больший пул ускоряет чтение, но не запись ссылок.
+	DBMaxConns           int
+	DBMaxConnLifetime    time.Duration
+	DBMaxConnIdleTime    time.Duration
+	DBStatementTimeout   time.Duration
+	DBQueryTimeout       time.Duration
+	DBConnectTimeout     time.Duration
+	DeletedURLsRetention time.Duration
+	ShutdownDelay        time.Duration
+	TraceExporter        string
+	PolicyFile           string
+	ReputationCheckerURL string
+	// AliasDomains — другие домены, на которых тоже открываются наши короткие ссылки.
+	AliasDomains []string
+	// Domains — базовые URL брендовых доменов, у каждого из которых свои короткие коды.
+	Domains []string
+	// QueryMergePolicy — что делать с параметрами из адреса короткой ссылки при переходе.
+	QueryMergePolicy string
+	// GeoIPFile — база GeoIP для правил переадресации по странам.
+	GeoIPFile string
+	// PendingPageFile — шаблон страницы для ссылок, время запуска которых ещё не наступило.
+	PendingPageFile string
+	// InternalToken открывает служебные эндпоинты /api/internal; пустой токен их отключает.
+	InternalToken string
 }
 
-func NewConfig() *Config {
+const (
+	defaultDeletedURLsRetention = 24 * time.Hour
+	defaultDBQueryTimeout       = 10 * time.Second
+	defaultDBConnectTimeout     = 30 * time.Second
+	// defaultShutdownDelay — сколько /readyz отвечает ошибкой до остановки, чтобы балансировщик
+	// успел заметить это и снять трафик.
+	defaultShutdownDelay = 5 * time.Second
+)
+
+// Политики слияния параметров запроса при переходе по ссылке.
+const (
+	// QueryMergeLink передаёт параметры посетителя, но не даёт перекрыть заданные у ссылки.
+	QueryMergeLink = "link"
+	// QueryMergeRequest передаёт параметры посетителя поверх заданных у ссылки.
+	QueryMergeRequest = "request"
+	// QueryMergeNone не передаёт параметры посетителя вовсе.
+	QueryMergeNone = "none"
+)
+
+func NewConfig() (*Config, error) {
 	config := &Config{}
+	var aliasDomains, domains string
 
 	flag.StringVar(&config.RunAddr, "a", ":8080", "address and port to run server")
 	flag.StringVar(&config.ShortLinkBaseURL, "b", "http://localhost:8080", "short link base URL")
 	flag.StringVar(&config.FileStoragePath, "f", "/tmp/short-url-db.json", "file storage path")
 	flag.StringVar(&config.DatabaseDSN, "d", "", "database data source name")
+	flag.BoolVar(&config.AutoMigrate, "auto-migrate", true,
+		"apply database migrations on startup, disable to run them with shortenerctl migrate")
+	flag.IntVar(&config.DBMaxConns, "db-max-conns", 0, "maximum size of the database connection pool, 0 for the default")
+	flag.DurationVar(&config.DBMaxConnLifetime, "db-conn-lifetime", 0,
+		"how long a database connection lives before it is replaced, 0 for the default")
+	flag.DurationVar(&config.DBMaxConnIdleTime, "db-conn-idle-time", 0,
+		"how long an idle database connection is kept open, 0 for the default")
+	flag.DurationVar(&config.DBStatementTimeout, "db-statement-timeout", 0,
+		"statement_timeout set on database connections, 0 disables it")
+	flag.DurationVar(&config.DBQueryTimeout, "db-query-timeout", defaultDBQueryTimeout,
+		"how long a single store call waits for the database, 0 disables the limit")
+	flag.DurationVar(&config.DBConnectTimeout, "db-connect-timeout", defaultDBConnectTimeout,
+		"how long to retry connecting and migrating on startup while the database is unavailable")
+	flag.DurationVar(&config.DeletedURLsRetention, "r", defaultDeletedURLsRetention,
+		"how long deleted URLs can be restored before cleanup")
+	flag.DurationVar(&config.ShutdownDelay, "shutdown-delay", defaultShutdownDelay,
+		"how long to report not ready before stopping the server")
+	flag.StringVar(&config.TraceExporter, "trace-exporter", "",
+		`where to export spans: "stdout" or collector URL, empty disables export`)
+	flag.StringVar(&config.PolicyFile, "policy-file", "",
+		"destination policy file with block/allow/regex rules, reloaded on change")
+	flag.StringVar(&config.ReputationCheckerURL, "reputation-checker", "",
+		"URL of an external destination reputation checker, empty disables it")
+	flag.StringVar(&aliasDomains, "alias-domains", "",
+		"comma-separated domains that also serve short links")
+	flag.StringVar(&domains, "domains", "",
+		"comma-separated base URLs of extra short link domains with their own namespaces")
+	flag.StringVar(&config.QueryMergePolicy, "query-merge", QueryMergeLink,
+		`how to forward short link query parameters: "link", "request" or "none"`)
+	flag.StringVar(&config.GeoIPFile, "geoip-db", "",
+		"GeoIP database file with network,country lines, empty disables country rules")
+	flag.StringVar(&config.PendingPageFile, "pending-page", "",
+		"HTML template shown for links before their not_before time, empty responds with 404")
+	flag.StringVar(&config.InternalToken, "internal-token", "",
+		"token for /api/internal endpoints sent in X-Internal-Token, empty disables them")
 	flag.Parse()
 
 	if envRunAddr, ok := os.LookupEnv("SERVER_ADDRESS"); ok {
@@ -37,5 +126,133 @@ func NewConfig() *Config {
 		config.DatabaseDSN = databaseDSN
 	}
 
-	return config
+	if autoMigrate, ok := os.LookupEnv("DB_AUTO_MIGRATE"); ok {
+		value, err := strconv.ParseBool(autoMigrate)
+		if err != nil {
+			return nil, fmt.Errorf("invalid DB_AUTO_MIGRATE: %w", err)
+		}
+
+		config.AutoMigrate = value
+	}
+
+	if traceExporter, ok := os.LookupEnv("TRACE_EXPORTER"); ok {
+		config.TraceExporter = traceExporter
+	}
+
+	if policyFile, ok := os.LookupEnv("DESTINATION_POLICY_FILE"); ok {
+		config.PolicyFile = policyFile
+	}
+
+	if reputationCheckerURL, ok := os.LookupEnv("REPUTATION_CHECKER_URL"); ok {
+		config.ReputationCheckerURL = reputationCheckerURL
+	}
+
+	if geoIPFile, ok := os.LookupEnv("GEOIP_DB_FILE"); ok {
+		config.GeoIPFile = geoIPFile
+	}
+
+	if pendingPageFile, ok := os.LookupEnv("PENDING_LINK_PAGE"); ok {
+		config.PendingPageFile = pendingPageFile
+	}
+
+	if internalToken, ok := os.LookupEnv("INTERNAL_API_TOKEN"); ok {
+		config.InternalToken = internalToken
+	}
+
+	if envAliasDomains, ok := os.LookupEnv("ALIAS_DOMAINS"); ok {
+		aliasDomains = envAliasDomains
+	}
+
+	config.AliasDomains = splitList(aliasDomains)
+
+	if envDomains, ok := os.LookupEnv("SHORT_LINK_DOMAINS"); ok {
+		domains = envDomains
+	}
+
+	config.Domains = splitList(domains)
+	for _, domain := range config.Domains {
+		if u, err := url.Parse(domain); err != nil || u.Scheme == "" || u.Host == "" {
+			return nil, fmt.Errorf("invalid short link domain %q: expected base URL like https://go.example.com", domain)
+		}
+	}
+
+	if queryMergePolicy, ok := os.LookupEnv("QUERY_MERGE_POLICY"); ok {
+		config.QueryMergePolicy = queryMergePolicy
+	}
+
+	switch config.QueryMergePolicy {
+	case QueryMergeLink, QueryMergeRequest, QueryMergeNone:
+	default:
+		return nil, fmt.Errorf("invalid query merge policy %q", config.QueryMergePolicy)
+	}
+
+	if dbMaxConns, ok := os.LookupEnv("DB_MAX_CONNS"); ok {
+		value, err := strconv.Atoi(dbMaxConns)
+		if err != nil {
+			return nil, fmt.Errorf("invalid DB_MAX_CONNS: %w", err)
+		}
+
+		config.DBMaxConns = value
+	}
+
+	if config.DBMaxConns < 0 || config.DBMaxConns > math.MaxInt32 {
+		return nil, fmt.Errorf("invalid database pool size %d", config.DBMaxConns)
+	}
+
+	if err := lookupDurationEnv("DB_MAX_CONN_LIFETIME", &config.DBMaxConnLifetime); err != nil {
+		return nil, err
+	}
+
+	if err := lookupDurationEnv("DB_MAX_CONN_IDLE_TIME", &config.DBMaxConnIdleTime); err != nil {
+		return nil, err
+	}
+
+	if err := lookupDurationEnv("DB_STATEMENT_TIMEOUT", &config.DBStatementTimeout); err != nil {
+		return nil, err
+	}
+
+	if err := lookupDurationEnv("DB_QUERY_TIMEOUT", &config.DBQueryTimeout); err != nil {
+		return nil, err
+	}
+
+	if err := lookupDurationEnv("DB_CONNECT_TIMEOUT", &config.DBConnectTimeout); err != nil {
+		return nil, err
+	}
+
+	if err := lookupDurationEnv("DELETED_URLS_RETENTION", &config.DeletedURLsRetention); err != nil {
+		return nil, err
+	}
+
+	if err := lookupDurationEnv("SHUTDOWN_DELAY", &config.ShutdownDelay); err != nil {
+		return nil, err
+	}
+
+	return config, nil
+}
+
+func lookupDurationEnv(name string, target *time.Duration) error {
+	value, ok := os.LookupEnv(name)
+	if !ok {
+		return nil
+	}
+
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		return fmt.Errorf("invalid %s: %w", name, err)
+	}
+
+	*target = d
+
+	return nil
+}
+
+func splitList(value string) []string {
+	var res []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			res = append(res, item)
+		}
+	}
+
+	return res
 }
@@ -0,0 +1,108 @@
+// Package geoip определяет страну по IP-адресу из локальной базы.
+//
+// База — CSV-файл из строк "сеть,код страны", например выгрузка GeoLite2 Country,
+// в которой geoname_id уже заменён на ISO-код:
+//
+//	network,country
+//	1.0.0.0/24,AU
+//	2001:200::/32,JP
+//
+// Строка заголовка, пустые строки и строки с # пропускаются. Сети не должны пересекаться.
+package geoip
+
+import (
+	"bytes"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"net/netip"
+	"os"
+	"slices"
+	"strings"
+)
+
+type network struct {
+	prefix  netip.Prefix
+	country string
+}
+
+// DB хранит сети, отсортированные по начальному адресу, и ищет в них двоичным поиском.
+// После загрузки не изменяется, поэтому безопасна для конкурентного использования.
+type DB struct {
+	networks []network
+}
+
+// Open загружает базу из файла.
+func Open(path string) (*DB, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read GeoIP database: %w", err)
+	}
+
+	r := csv.NewReader(bytes.NewReader(content))
+	r.FieldsPerRecord = -1
+	r.Comment = '#'
+
+	db := &DB{}
+	for records := 1; ; records++ {
+		record, err := r.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse GeoIP database: %w", err)
+		}
+
+		line, _ := r.FieldPos(0)
+		if len(record) < 2 {
+			return nil, fmt.Errorf("GeoIP database line %d: expected \"network,country\"", line)
+		}
+
+		prefix, err := netip.ParsePrefix(strings.TrimSpace(record[0]))
+		if err != nil {
+			// Первая запись может оказаться заголовком, в том числе после строк с комментариями.
+			if records == 1 {
+				continue
+			}
+
+			return nil, fmt.Errorf("GeoIP database line %d: %w", line, err)
+		}
+
+		country := strings.ToUpper(strings.TrimSpace(record[1]))
+		if country == "" {
+			continue
+		}
+
+		db.networks = append(db.networks, network{prefix: prefix.Masked(), country: country})
+	}
+
+	slices.SortFunc(db.networks, func(a, b network) int {
+		return a.prefix.Addr().Compare(b.prefix.Addr())
+	})
+
+	return db, nil
+}
+
+// Country возвращает код страны ISO 3166-1 alpha-2 или пустую строку, если адрес не найден.
+func (db *DB) Country(ip netip.Addr) string {
+	ip = ip.Unmap()
+	if !ip.IsValid() {
+		return ""
+	}
+
+	// Ищем последнюю сеть, начинающуюся не позже адреса: только она может его содержать.
+	i, found := slices.BinarySearchFunc(db.networks, ip, func(n network, ip netip.Addr) int {
+		return n.prefix.Addr().Compare(ip)
+	})
+	if !found {
+		i--
+	}
+
+	if i < 0 || !db.networks[i].prefix.Contains(ip) {
+		return ""
+	}
+
+	return db.networks[i].country
+}
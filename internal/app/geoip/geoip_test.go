@@ -0,0 +1,93 @@
+package geoip
+
+import (
+	"net/netip"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func writeDB(t *testing.T, content string) string {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "geoip.csv")
+	require.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+
+	return path
+}
+
+func TestCountry(t *testing.T) {
+	path := writeDB(t, `# GeoLite2 Country, выгрузка для тестов
+
+network,country
+10.0.0.0/24,au
+10.0.2.0/23,JP
+10.0.5.7/24, de
+192.168.0.0/16,
+2001:db8::/32,NL
+`)
+
+	db, err := Open(path)
+	require.NoError(t, err)
+
+	testCases := []struct {
+		ip       string
+		expected string
+	}{
+		{ip: "9.255.255.255", expected: ""},
+		{ip: "10.0.0.0", expected: "AU"},
+		{ip: "10.0.0.255", expected: "AU"},
+		{ip: "10.0.1.0", expected: ""},
+		{ip: "10.0.2.0", expected: "JP"},
+		{ip: "10.0.3.255", expected: "JP"},
+		{ip: "10.0.4.0", expected: ""},
+		{ip: "10.0.5.0", expected: "DE"},
+		{ip: "10.0.6.0", expected: ""},
+		{ip: "192.168.1.1", expected: ""},
+		{ip: "::ffff:10.0.2.1", expected: "JP"},
+		{ip: "2001:db8::", expected: "NL"},
+		{ip: "2001:db8:ffff:ffff:ffff:ffff:ffff:ffff", expected: "NL"},
+		{ip: "2001:db9::", expected: ""},
+		{ip: "::1", expected: ""},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.ip, func(t *testing.T) {
+			assert.Equal(t, tc.expected, db.Country(netip.MustParseAddr(tc.ip)))
+		})
+	}
+
+	assert.Empty(t, db.Country(netip.Addr{}))
+}
+
+func TestOpenEmptyDatabase(t *testing.T) {
+	db, err := Open(writeDB(t, "network,country\n"))
+	require.NoError(t, err)
+
+	assert.Empty(t, db.Country(netip.MustParseAddr("10.0.0.1")))
+}
+
+func TestOpenRejectsInvalidLines(t *testing.T) {
+	testCases := []struct {
+		name    string
+		content string
+		line    string
+	}{
+		{
+			name:    "Bad network after header",
+			content: "network,country\n10.0.0.0/24,AU\nnot-a-network,JP\n",
+			line:    "line 3",
+		},
+		{name: "Missing country column", content: "10.0.0.0/24,AU\n10.0.1.0/24\n", line: "line 2"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := Open(writeDB(t, tc.content))
+			assert.ErrorContains(t, err, tc.line)
+		})
+	}
+}
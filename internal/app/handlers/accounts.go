@@ -0,0 +1,170 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/a-bondar/go-url-shortener/internal/app/middleware"
+	"github.com/a-bondar/go-url-shortener/internal/app/models"
+	"github.com/a-bondar/go-url-shortener/internal/app/service"
+	"github.com/a-bondar/go-url-shortener/internal/app/store"
+	"github.com/go-chi/chi/v5"
+	"go.uber.org/zap"
+)
+
+func (h *Handler) HandleRegister(w http.ResponseWriter, r *http.Request) {
+	var request models.HandleRegisterRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		h.log(r).Error("Failed to unmarshal request", zap.Error(err))
+		http.Error(w, "", http.StatusBadRequest)
+		return
+	}
+
+	resp, err := h.s.RegisterUser(r.Context(), request.Name)
+	if err != nil {
+		if errors.Is(err, service.ErrInvalidName) {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		h.log(r).Error("Failed to register user", zap.Error(err))
+		http.Error(w, "", http.StatusInternalServerError)
+		return
+	}
+
+	h.writeJSON(w, r, http.StatusCreated, resp)
+}
+
+func (h *Handler) HandleAPIKeys(w http.ResponseWriter, r *http.Request) {
+	userID, err := middleware.GetUserIDFromContext(r.Context())
+	if err != nil {
+		h.log(r).Error(cannotGetUserID, zap.Error(err))
+		http.Error(w, "", http.StatusInternalServerError)
+		return
+	}
+
+	keys, err := h.s.GetAPIKeys(r.Context(), userID)
+	if err != nil {
+		h.log(r).Error("Failed to get API keys", zap.Error(err))
+		http.Error(w, "", http.StatusInternalServerError)
+		return
+	}
+
+	h.writeJSON(w, r, http.StatusOK, keys)
+}
+
+func (h *Handler) HandleCreateAPIKey(w http.ResponseWriter, r *http.Request) {
+	userID, err := middleware.GetUserIDFromContext(r.Context())
+	if err != nil {
+		h.log(r).Error(cannotGetUserID, zap.Error(err))
+		http.Error(w, "", http.StatusInternalServerError)
+		return
+	}
+
+	var request models.HandleCreateAPIKeyRequest
+	if err = json.NewDecoder(r.Body).Decode(&request); err != nil {
+		h.log(r).Error("Failed to unmarshal request", zap.Error(err))
+		http.Error(w, "", http.StatusBadRequest)
+		return
+	}
+
+	key, err := h.s.CreateAPIKey(r.Context(), userID, request.Name, request.Scopes)
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrInvalidName), errors.Is(err, service.ErrUnknownScope):
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		case errors.Is(err, service.ErrNotRegistered):
+			http.Error(w, "forbidden", http.StatusForbidden)
+		default:
+			h.log(r).Error("Failed to create API key", zap.Error(err))
+			http.Error(w, "", http.StatusInternalServerError)
+		}
+
+		return
+	}
+
+	h.writeJSON(w, r, http.StatusCreated, key)
+}
+
+func (h *Handler) HandleRevokeAPIKey(w http.ResponseWriter, r *http.Request) {
+	userID, err := middleware.GetUserIDFromContext(r.Context())
+	if err != nil {
+		h.log(r).Error(cannotGetUserID, zap.Error(err))
+		http.Error(w, "", http.StatusInternalServerError)
+		return
+	}
+
+	err = h.s.RevokeAPIKey(r.Context(), userID, chi.URLParam(r, "keyID"))
+	if err != nil {
+		if errors.Is(err, service.ErrInvalidAPIKey) || errors.Is(err, store.ErrAPIKeyNotFound) {
+			http.Error(w, "API key not found", http.StatusNotFound)
+			return
+		}
+
+		h.log(r).Error("Failed to revoke API key", zap.Error(err))
+		http.Error(w, "", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// HandleClaim переносит в учётную запись ссылки анонимной личности,
+// токен которой клиент сохранил из cookie auth_token или заголовка X-Auth-Token.
+func (h *Handler) HandleClaim(w http.ResponseWriter, r *http.Request) {
+	userID, err := middleware.GetUserIDFromContext(r.Context())
+	if err != nil {
+		h.log(r).Error(cannotGetUserID, zap.Error(err))
+		http.Error(w, "", http.StatusInternalServerError)
+		return
+	}
+
+	var request models.HandleClaimRequest
+	if err = json.NewDecoder(r.Body).Decode(&request); err != nil {
+		h.log(r).Error("Failed to unmarshal request", zap.Error(err))
+		http.Error(w, "", http.StatusBadRequest)
+		return
+	}
+
+	// Токен сессии, из которой вышли или которую отозвали, ссылки не передаёт.
+	anonymousUserID, err := middleware.GetUserID(r.Context(), h.s, request.Token)
+	if err != nil {
+		if errors.Is(err, middleware.ErrInvalidToken) || errors.Is(err, middleware.ErrSessionRevoked) {
+			http.Error(w, "invalid token", http.StatusBadRequest)
+			return
+		}
+
+		h.log(r).Error("Failed to check claimed token", zap.Error(err))
+		http.Error(w, "", http.StatusInternalServerError)
+		return
+	}
+
+	claimed, err := h.s.ClaimURLs(r.Context(), anonymousUserID, userID)
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrNotRegistered):
+			http.Error(w, "forbidden", http.StatusForbidden)
+		case errors.Is(err, service.ErrAlreadyClaimed):
+			http.Error(w, "token belongs to a registered user", http.StatusConflict)
+		default:
+			h.log(r).Error("Failed to claim URLs", zap.Error(err))
+			http.Error(w, "", http.StatusInternalServerError)
+		}
+
+		return
+	}
+
+	h.writeJSON(w, r, http.StatusOK, models.HandleClaimResponse{Claimed: claimed})
+}
+
+func (h *Handler) writeJSON(w http.ResponseWriter, r *http.Request, statusCode int, v any) {
+	w.Header().Set(contentType, applicationJSON)
+	w.WriteHeader(statusCode)
+
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		h.log(r).Error("Failed to encode response", zap.Error(err))
+		http.Error(w, "", http.StatusInternalServerError)
+		return
+	}
+}
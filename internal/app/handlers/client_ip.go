@@ -0,0 +1,17 @@
+package handlers
+
+import (
+	"net/http"
+	"net/netip"
+)
+
+// clientIP возвращает адрес посетителя из соединения. Заголовки прокси не учитываются:
+// подделать их может любой клиент, а доверенных прокси сервис не знает.
+func clientIP(r *http.Request) netip.Addr {
+	addrPort, err := netip.ParseAddrPort(r.RemoteAddr)
+	if err != nil {
+		return netip.Addr{}
+	}
+
+	return addrPort.Addr().Unmap()
+}
@@ -0,0 +1,201 @@
+package handlers
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/a-bondar/go-url-shortener/internal/app/models"
+	"github.com/a-bondar/go-url-shortener/internal/app/service"
+	"github.com/a-bondar/go-url-shortener/internal/app/store"
+	"go.uber.org/zap"
+)
+
+const (
+	InternalTokenHeader = "X-Internal-Token"
+	textEventStream     = "text/event-stream"
+	defaultEventsLimit  = 100
+	defaultEventsWait   = 30 * time.Second
+	// eventsKeepAlive — как часто поток без событий шлёт комментарий, чтобы прокси не закрыли соединение.
+	eventsKeepAlive = 15 * time.Second
+)
+
+// SetInternalToken открывает служебные эндпоинты для запросов с этим токеном в X-Internal-Token.
+func (h *Handler) SetInternalToken(token string) {
+	h.internalToken = token
+}
+
+// StopStreams завершает открытые потоки SSE. Сам http.Server.Shutdown их не прерывает
+// и ждал бы до таймаута, поэтому метод регистрируется через RegisterOnShutdown.
+func (h *Handler) StopStreams() {
+	h.stopStreams()
+}
+
+// streamContext возвращает контекст потока: он отменяется вместе с запросом и при остановке сервера.
+func (h *Handler) streamContext(r *http.Request) (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithCancel(r.Context())
+	stop := context.AfterFunc(h.streams, cancel)
+
+	return ctx, func() {
+		stop()
+		cancel()
+	}
+}
+
+// HandleEvents отдаёт изменения ссылок из outbox с id больше after. С Accept: text/event-stream
+// события идут потоком SSE, и при переподключении курсор берётся из Last-Event-ID;
+// иначе это long-poll, который ждёт новых событий не дольше wait.
+func (h *Handler) HandleEvents(w http.ResponseWriter, r *http.Request) {
+	if h.internalToken == "" {
+		http.Error(w, "", http.StatusNotFound)
+		return
+	}
+
+	token := r.Header.Get(InternalTokenHeader)
+	if subtle.ConstantTimeCompare([]byte(token), []byte(h.internalToken)) != 1 {
+		http.Error(w, "", http.StatusUnauthorized)
+		return
+	}
+
+	after, limit, wait, err := parseEventsQuery(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if strings.Contains(r.Header.Get("Accept"), textEventStream) {
+		h.streamEvents(w, r, after, limit)
+		return
+	}
+
+	events, err := h.s.WaitLinkEvents(r.Context(), after, limit, wait)
+	if err != nil {
+		h.writeEventsError(w, r, err)
+		return
+	}
+
+	if len(events) > 0 {
+		after = events[len(events)-1].ID
+	}
+
+	h.writeJSON(w, r, http.StatusOK, models.HandleEventsResponse{Events: events, Next: after})
+}
+
+func (h *Handler) streamEvents(w http.ResponseWriter, r *http.Request, after int64, limit int) {
+	if lastEventID := r.Header.Get("Last-Event-ID"); lastEventID != "" {
+		var err error
+		if after, err = strconv.ParseInt(lastEventID, 10, 64); err != nil {
+			http.Error(w, "invalid Last-Event-ID", http.StatusBadRequest)
+			return
+		}
+	}
+
+	ctx, cancel := h.streamContext(r)
+	defer cancel()
+
+	// Первую порцию читаем до заголовков, чтобы ошибку ещё можно было вернуть статусом.
+	events, err := h.s.WaitLinkEvents(ctx, after, limit, 0)
+	if err != nil {
+		h.writeEventsError(w, r, err)
+		return
+	}
+
+	rc := http.NewResponseController(w)
+	w.Header().Set(contentType, textEventStream)
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(http.StatusOK)
+
+	for {
+		if err = writeEvents(w, events); err != nil {
+			h.log(r).Error("Failed to write events", zap.Error(err))
+			return
+		}
+
+		if err = rc.Flush(); err != nil {
+			h.log(r).Error("Failed to flush events", zap.Error(err))
+			return
+		}
+
+		if len(events) > 0 {
+			after = events[len(events)-1].ID
+		}
+
+		events, err = h.s.WaitLinkEvents(ctx, after, limit, eventsKeepAlive)
+		if ctx.Err() != nil {
+			return
+		}
+
+		if err != nil {
+			h.log(r).Error("Failed to get link events", zap.Error(err))
+			return
+		}
+	}
+}
+
+// writeEvents пишет события в формате SSE; пустая порция превращается в комментарий keep-alive.
+func writeEvents(w http.ResponseWriter, events []models.LinkEvent) error {
+	if len(events) == 0 {
+		if _, err := fmt.Fprint(w, ": keep-alive\n\n"); err != nil {
+			return fmt.Errorf("failed to write keep-alive: %w", err)
+		}
+
+		return nil
+	}
+
+	for _, event := range events {
+		data, err := json.Marshal(event)
+		if err != nil {
+			return fmt.Errorf("failed to marshal event: %w", err)
+		}
+
+		if _, err = fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", event.ID, event.Type, data); err != nil {
+			return fmt.Errorf("failed to write event: %w", err)
+		}
+	}
+
+	return nil
+}
+
+func (h *Handler) writeEventsError(w http.ResponseWriter, r *http.Request, err error) {
+	switch {
+	case errors.Is(err, service.ErrInvalidEventsQuery):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	case errors.Is(err, store.ErrEventsUnsupported):
+		http.Error(w, "Event stream requires the database store", http.StatusNotImplemented)
+	default:
+		h.log(r).Error("Failed to get link events", zap.Error(err))
+		http.Error(w, "", http.StatusInternalServerError)
+	}
+}
+
+func parseEventsQuery(r *http.Request) (int64, int, time.Duration, error) {
+	query := r.URL.Query()
+	after, limit, wait := int64(0), defaultEventsLimit, defaultEventsWait
+
+	var err error
+	if v := query.Get("after"); v != "" {
+		if after, err = strconv.ParseInt(v, 10, 64); err != nil {
+			return 0, 0, 0, errors.New("after must be an event id")
+		}
+	}
+
+	if v := query.Get("limit"); v != "" {
+		if limit, err = strconv.Atoi(v); err != nil {
+			return 0, 0, 0, errors.New("limit must be a number")
+		}
+	}
+
+	if v := query.Get("wait"); v != "" {
+		if wait, err = time.ParseDuration(v); err != nil {
+			return 0, 0, 0, errors.New(`wait must be a duration like "30s"`)
+		}
+	}
+
+	return after, limit, wait, nil
+}
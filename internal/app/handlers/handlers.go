@@ -5,9 +5,15 @@ import (
 	"context"
 	"encoding/json"
 	"errors"
+	"html/template"
 	"io"
+	"math"
 	"net/http"
+	"strconv"
+	"time"
 
+	"github.com/a-bondar/go-url-shortener/internal/app/live"
+	"github.com/a-bondar/go-url-shortener/internal/app/logger"
 	"github.com/a-bondar/go-url-shortener/internal/app/middleware"
 	"github.com/a-bondar/go-url-shortener/internal/app/models"
 	"github.com/a-bondar/go-url-shortener/internal/app/service"
@@ -24,47 +30,109 @@ const (
 )
 
 type Service interface {
-	SaveURL(ctx context.Context, fullURL string, userID string) (string, error)
-	GetURL(ctx context.Context, shortURL string) (string, bool, error)
-	GetURLs(ctx context.Context, userID string) ([]models.URLsPair, error)
-	DeleteURLs(ctx context.Context, urls []string, userID string) error
+	SaveURL(ctx context.Context, fullURL string, userID string, meta models.LinkMeta,
+		opts models.ShortenOptions) (string, error)
+	ResolveURL(ctx context.Context, shortURL string, visit models.Visit) (models.Redirect, error)
+	GetURLs(ctx context.Context, userID string, filter models.URLsFilter) ([]models.URLsPair, error)
+	UpdateURLMeta(ctx context.Context, domain string, shortURL string, userID string,
+		patch models.LinkMetaPatch) (models.URLsPair, error)
+	UpdateURL(ctx context.Context, domain string, shortURL string, userID string,
+		fullURL string) (models.URLsPair, error)
+	GetURLRevisions(ctx context.Context, domain string, shortURL string, userID string) ([]models.URLRevision, error)
+	GetURLStats(ctx context.Context, domain string, shortURL string, userID string) (models.LinkStats, error)
+	DeleteURLs(ctx context.Context, domain string, urls []string, userID string) error
+	RestoreURLs(ctx context.Context, domain string, urls []string, userID string) error
 	SaveBatchURLs(ctx context.Context, urls []models.OriginalURLCorrelation,
 		userID string) ([]models.ShortURLCorrelation, error)
 	Ping(ctx context.Context) error
+	CheckReadiness(ctx context.Context) (models.Readiness, error)
+	RegisterUser(ctx context.Context, name string) (models.HandleRegisterResponse, error)
+	CreateAPIKey(ctx context.Context, userID string, name string,
+		scopes []string) (models.HandleAPIKeyResponse, error)
+	GetAPIKeys(ctx context.Context, userID string) ([]models.HandleAPIKeyResponse, error)
+	RevokeAPIKey(ctx context.Context, userID string, keyID string) error
+	ClaimURLs(ctx context.Context, anonymousUserID string, userID string) (int64, error)
+	RevokeSession(ctx context.Context, sessionID string, userID string, expiresAt time.Time) error
+	IsSessionRevoked(ctx context.Context, sessionID string) (bool, error)
+	ConsumeRefreshToken(ctx context.Context, tokenID string, userID string, expiresAt time.Time) (bool, error)
+	CreateWorkspace(ctx context.Context, userID string, name string) (models.HandleWorkspaceResponse, error)
+	GetWorkspaces(ctx context.Context, userID string) ([]models.HandleWorkspaceResponse, error)
+	GetWorkspaceMembers(ctx context.Context, userID string, workspaceID string) ([]models.WorkspaceMember, error)
+	SetWorkspaceMember(ctx context.Context, userID string, workspaceID string, memberID string,
+		role string) (models.WorkspaceMember, error)
+	RemoveWorkspaceMember(ctx context.Context, userID string, workspaceID string, memberID string) error
+	TransferURLs(ctx context.Context, userID string, request models.HandleTransferRequest) (int64, error)
+	CreateWebhook(ctx context.Context, userID string,
+		request models.HandleWebhookRequest) (models.HandleWebhookResponse, error)
+	GetWebhooks(ctx context.Context, userID string) ([]models.HandleWebhookResponse, error)
+	DeleteWebhook(ctx context.Context, userID string, webhookID string) error
+	GetDeadDeliveries(ctx context.Context, userID string) ([]models.WebhookDelivery, error)
+	RetryDelivery(ctx context.Context, userID string, deliveryID string) error
+	WaitLinkEvents(ctx context.Context, after int64, limit int, wait time.Duration) ([]models.LinkEvent, error)
+	GetLinkKey(ctx context.Context, domain string, shortURL string, userID string) (models.LinkKey, error)
 }
 
 type Handler struct {
 	s      Service
 	logger *zap.Logger
+	// pendingPage показывается по ссылке до времени её запуска.
+	pendingPage *template.Template
+	// internalToken открывает служебные эндпоинты; пустой — они отключены.
+	internalToken string
+	// live раздаёт переходы подписчикам /live.
+	live *live.Hub
+	// streams отменяется при остановке сервера и завершает открытые потоки SSE.
+	streams     context.Context
+	stopStreams context.CancelFunc
 }
 
 func NewHandler(s Service, logger *zap.Logger) *Handler {
+	streams, stopStreams := context.WithCancel(context.Background())
+
 	return &Handler{
-		s:      s,
-		logger: logger,
+		s:           s,
+		logger:      logger,
+		live:        live.NewHub(liveBufferSize),
+		streams:     streams,
+		stopStreams: stopStreams,
 	}
 }
 
+// log возвращает логгер с идентификаторами запроса, если их проставил middleware.
+func (h *Handler) log(r *http.Request) *zap.Logger {
+	return logger.FromContext(r.Context(), h.logger)
+}
+
 func (h *Handler) HandlePost(w http.ResponseWriter, r *http.Request) {
 	fullURL, err := io.ReadAll(r.Body)
 	if err != nil {
-		h.logger.Error(failedToReadBody, zap.Error(err))
+		h.log(r).Error(failedToReadBody, zap.Error(err))
 		http.Error(w, "", http.StatusInternalServerError)
 		return
 	}
 
 	userID, err := middleware.GetUserIDFromContext(r.Context())
 	if err != nil {
-		h.logger.Error(cannotGetUserID, zap.Error(err))
+		h.log(r).Error(cannotGetUserID, zap.Error(err))
 		http.Error(w, "", http.StatusInternalServerError)
 		return
 	}
 
 	statusCode := http.StatusCreated
-	resURL, err := h.s.SaveURL(r.Context(), string(fullURL), userID)
+	resURL, err := h.s.SaveURL(r.Context(), string(fullURL), userID, models.LinkMeta{}, models.ShortenOptions{})
 	if err != nil {
+		if writeDestinationError(w, err) {
+			return
+		}
+
+		// Адрес держит чужая ссылка в корзине — её код не выдаём.
+		if errors.Is(err, store.ErrURLExists) {
+			http.Error(w, "URL is already shortened", http.StatusConflict)
+			return
+		}
+
 		if !errors.Is(err, service.ErrConflict) {
-			h.logger.Error("Failed to shorten URL", zap.Error(err))
+			h.log(r).Error("Failed to shorten URL", zap.Error(err))
 			http.Error(w, "", http.StatusInternalServerError)
 			return
 		}
@@ -74,7 +142,7 @@ func (h *Handler) HandlePost(w http.ResponseWriter, r *http.Request) {
 
 	w.WriteHeader(statusCode)
 	if _, err := w.Write([]byte(resURL)); err != nil {
-		h.logger.Error("Failed to write result", zap.Error(err))
+		h.log(r).Error("Failed to write result", zap.Error(err))
 		http.Error(w, "", http.StatusInternalServerError)
 		return
 	}
@@ -82,20 +150,66 @@ func (h *Handler) HandlePost(w http.ResponseWriter, r *http.Request) {
 
 func (h *Handler) HandleGet(w http.ResponseWriter, r *http.Request) {
 	linkID := chi.URLParam(r, "linkID")
-	URL, deleted, err := h.s.GetURL(r.Context(), linkID)
+	redirect, err := h.s.ResolveURL(r.Context(), linkID, models.Visit{
+		Password: linkPassword(r),
+		Host:     r.Host,
+		Query:    r.URL.Query(),
+		Variant:  linkVariant(r),
+		// Для правил переадресации ссылки.
+		UserAgent:      r.UserAgent(),
+		AcceptLanguage: r.Header.Get("Accept-Language"),
+		IP:             clientIP(r),
+	})
 
 	if err != nil {
-		h.logger.Error("Failed to get URL", zap.Error(err))
-		http.Error(w, `Link not found`, http.StatusNotFound)
+		var (
+			attemptsErr *service.AttemptsError
+			pendingErr  *service.NotYetActiveError
+		)
+		switch {
+		case errors.Is(err, store.ErrURLNotFound):
+			http.Error(w, `Link not found`, http.StatusNotFound)
+		case errors.As(err, &pendingErr):
+			h.writeNotYetActive(w, r, pendingErr.NotBefore)
+		case errors.Is(err, service.ErrURLDeleted):
+			w.WriteHeader(http.StatusGone)
+		case errors.Is(err, service.ErrClicksExhausted), errors.Is(err, store.ErrNoClicksLeft):
+			http.Error(w, "Link click limit reached", http.StatusGone)
+		case errors.Is(err, service.ErrDestinationBlocked):
+			http.Error(w, "Link is unavailable", http.StatusUnavailableForLegalReasons)
+		case errors.Is(err, service.ErrPasswordRequired):
+			h.writePasswordForm(w, r, "")
+		case errors.Is(err, service.ErrWrongPassword):
+			h.writePasswordForm(w, r, "Wrong password, try again.")
+		case errors.As(err, &attemptsErr):
+			w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(attemptsErr.RetryAfter.Seconds()))))
+			http.Error(w, "too many password attempts", http.StatusTooManyRequests)
+		default:
+			h.log(r).Error("Failed to get URL", zap.Error(err))
+			http.Error(w, "", http.StatusInternalServerError)
+		}
+
 		return
 	}
 
-	if deleted {
-		w.WriteHeader(http.StatusGone)
-		return
+	if redirect.Sticky && redirect.Variant > 0 {
+		setVariantCookie(w, linkID, redirect.Variant)
+	}
+
+	h.live.Publish(redirect.Link, models.LiveClick{
+		At:          time.Now().UTC(),
+		Destination: redirect.URL,
+		Variant:     redirect.Variant,
+		Referrer:    r.Referer(),
+	})
+
+	// После формы пароля переходим GET-запросом: 307 повторил бы POST с паролем на чужой сайт.
+	status := http.StatusTemporaryRedirect
+	if r.Method == http.MethodPost {
+		status = http.StatusSeeOther
 	}
 
-	http.Redirect(w, r, URL, http.StatusTemporaryRedirect)
+	http.Redirect(w, r, redirect.URL, status)
 }
 
 func (h *Handler) HandleShorten(w http.ResponseWriter, r *http.Request) {
@@ -104,29 +218,46 @@ func (h *Handler) HandleShorten(w http.ResponseWriter, r *http.Request) {
 
 	_, err := buf.ReadFrom(r.Body)
 	if err != nil {
-		h.logger.Error(failedToReadBody, zap.Error(err))
+		h.log(r).Error(failedToReadBody, zap.Error(err))
 		http.Error(w, "", http.StatusBadRequest)
 		return
 	}
 
 	if err = json.Unmarshal(buf.Bytes(), &request); err != nil {
-		h.logger.Error("Failed to unmarshal request", zap.Error(err))
+		h.log(r).Error("Failed to unmarshal request", zap.Error(err))
 		http.Error(w, "", http.StatusBadRequest)
 		return
 	}
 
 	userID, err := middleware.GetUserIDFromContext(r.Context())
 	if err != nil {
-		h.logger.Error(cannotGetUserID, zap.Error(err))
+		h.log(r).Error(cannotGetUserID, zap.Error(err))
 		http.Error(w, "", http.StatusInternalServerError)
 		return
 	}
 
 	statusCode := http.StatusCreated
-	resURL, err := h.s.SaveURL(r.Context(), request.URL, userID)
+	resURL, err := h.s.SaveURL(r.Context(), request.URL, userID, request.LinkMeta, request.ShortenOptions)
 	if err != nil {
+		if errors.Is(err, service.ErrInvalidPassword) || errors.Is(err, service.ErrUnknownDomain) ||
+			errors.Is(err, service.ErrInvalidQueryParams) || errors.Is(err, service.ErrInvalidTargets) ||
+			errors.Is(err, service.ErrInvalidRules) || errors.Is(err, service.ErrInvalidMaxClicks) {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		if writeDestinationError(w, err) {
+			return
+		}
+
+		// Адрес держит чужая ссылка в корзине — её код не выдаём.
+		if errors.Is(err, store.ErrURLExists) {
+			http.Error(w, "URL is already shortened", http.StatusConflict)
+			return
+		}
+
 		if !errors.Is(err, service.ErrConflict) {
-			h.logger.Error("Failed to shorten URL", zap.Error(err))
+			h.log(r).Error("Failed to shorten URL", zap.Error(err))
 			http.Error(w, "", http.StatusInternalServerError)
 			return
 		}
@@ -144,7 +275,7 @@ func (h *Handler) HandleShorten(w http.ResponseWriter, r *http.Request) {
 	w.WriteHeader(statusCode)
 
 	if err := enc.Encode(resp); err != nil {
-		h.logger.Error("Failed to encode response", zap.Error(err))
+		h.log(r).Error("Failed to encode response", zap.Error(err))
 		http.Error(w, "", http.StatusInternalServerError)
 		return
 	}
@@ -156,27 +287,31 @@ func (h *Handler) HandleShortenBatch(w http.ResponseWriter, r *http.Request) {
 
 	_, err := buf.ReadFrom(r.Body)
 	if err != nil {
-		h.logger.Error(failedToReadBody, zap.Error(err))
+		h.log(r).Error(failedToReadBody, zap.Error(err))
 		http.Error(w, "", http.StatusBadRequest)
 		return
 	}
 
 	if err = json.Unmarshal(buf.Bytes(), &request); err != nil {
-		h.logger.Error("Failed to unmarshal request", zap.Error(err))
+		h.log(r).Error("Failed to unmarshal request", zap.Error(err))
 		http.Error(w, "", http.StatusInternalServerError)
 		return
 	}
 
 	userID, err := middleware.GetUserIDFromContext(r.Context())
 	if err != nil {
-		h.logger.Error(cannotGetUserID, zap.Error(err))
+		h.log(r).Error(cannotGetUserID, zap.Error(err))
 		http.Error(w, "", http.StatusInternalServerError)
 		return
 	}
 
 	response, err := h.s.SaveBatchURLs(r.Context(), request, userID)
 	if err != nil {
-		h.logger.Error("Failed to shorten URLs", zap.Error(err))
+		if writeDestinationError(w, err) {
+			return
+		}
+
+		h.log(r).Error("Failed to shorten URLs", zap.Error(err))
 		http.Error(w, "", http.StatusInternalServerError)
 		return
 	}
@@ -186,7 +321,7 @@ func (h *Handler) HandleShortenBatch(w http.ResponseWriter, r *http.Request) {
 	w.WriteHeader(http.StatusCreated)
 
 	if err = enc.Encode(response); err != nil {
-		h.logger.Error("Failed to encode response", zap.Error(err))
+		h.log(r).Error("Failed to encode response", zap.Error(err))
 		http.Error(w, "", http.StatusInternalServerError)
 		return
 	}
@@ -195,7 +330,7 @@ func (h *Handler) HandleShortenBatch(w http.ResponseWriter, r *http.Request) {
 func (h *Handler) HandleDatabasePing(w http.ResponseWriter, r *http.Request) {
 	err := h.s.Ping(r.Context())
 	if err != nil {
-		h.logger.Error("Unable to reach DB", zap.Error(err))
+		h.log(r).Error("Unable to reach DB", zap.Error(err))
 		http.Error(w, "", http.StatusInternalServerError)
 		return
 	}
@@ -204,31 +339,58 @@ func (h *Handler) HandleDatabasePing(w http.ResponseWriter, r *http.Request) {
 	w.WriteHeader(http.StatusOK)
 
 	if _, err := w.Write([]byte(`{"status": "ok"}`)); err != nil {
-		h.logger.Error("Failed to write response", zap.Error(err))
+		h.log(r).Error("Failed to write response", zap.Error(err))
 		http.Error(w, "", http.StatusInternalServerError)
 		return
 	}
 }
 
-func (h *Handler) HandleUserURLs(w http.ResponseWriter, r *http.Request) {
-	_, err := r.Cookie("auth_token")
+func (h *Handler) HandleLiveness(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set(contentType, applicationJSON)
+	w.WriteHeader(http.StatusOK)
+
+	if _, err := w.Write([]byte(`{"status": "ok"}`)); err != nil {
+		h.log(r).Error("Failed to write response", zap.Error(err))
+		http.Error(w, "", http.StatusInternalServerError)
+		return
+	}
+}
+
+func (h *Handler) HandleReadiness(w http.ResponseWriter, r *http.Request) {
+	statusCode := http.StatusOK
+	readiness, err := h.s.CheckReadiness(r.Context())
 	if err != nil {
-		h.logger.Error("Cannot get auth cookie", zap.Error(err))
-		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		h.log(r).Warn("Service is not ready", zap.Any("components", readiness.Components))
+		statusCode = http.StatusServiceUnavailable
+	}
+
+	w.Header().Set(contentType, applicationJSON)
+	w.WriteHeader(statusCode)
+
+	if err = json.NewEncoder(w).Encode(readiness); err != nil {
+		h.log(r).Error("Failed to encode response", zap.Error(err))
+		http.Error(w, "", http.StatusInternalServerError)
 		return
 	}
+}
 
+func (h *Handler) HandleUserURLs(w http.ResponseWriter, r *http.Request) {
 	userID, err := middleware.GetUserIDFromContext(r.Context())
 	if err != nil {
-		h.logger.Error(cannotGetUserID, zap.Error(err))
+		h.log(r).Error(cannotGetUserID, zap.Error(err))
 		http.Error(w, "", http.StatusInternalServerError)
 		return
 	}
 
-	userURLs, err := h.s.GetURLs(r.Context(), userID)
+	filter := models.URLsFilter{
+		Tag:     r.URL.Query().Get("tag"),
+		Deleted: r.URL.Query().Get("deleted") == "true",
+	}
+
+	userURLs, err := h.s.GetURLs(r.Context(), userID, filter)
 	if err != nil {
 		if !errors.Is(err, store.ErrUserHasNoURLs) {
-			h.logger.Error("Failed to get user URLs", zap.Error(err))
+			h.log(r).Error("Failed to get user URLs", zap.Error(err))
 			http.Error(w, "Internal server error", http.StatusInternalServerError)
 			return
 		}
@@ -240,16 +402,162 @@ func (h *Handler) HandleUserURLs(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set(contentType, applicationJSON)
 	w.WriteHeader(http.StatusOK)
 	if err = json.NewEncoder(w).Encode(userURLs); err != nil {
-		h.logger.Error("Failed to encode response", zap.Error(err))
+		h.log(r).Error("Failed to encode response", zap.Error(err))
 		http.Error(w, "Internal server error", http.StatusInternalServerError)
 		return
 	}
 }
 
+func (h *Handler) HandleUpdateURL(w http.ResponseWriter, r *http.Request) {
+	userID, err := middleware.GetUserIDFromContext(r.Context())
+	if err != nil {
+		h.log(r).Error(cannotGetUserID, zap.Error(err))
+		http.Error(w, "", http.StatusInternalServerError)
+		return
+	}
+
+	var request models.LinkMetaPatch
+	if err = json.NewDecoder(r.Body).Decode(&request); err != nil {
+		h.log(r).Error("Failed to unmarshal request", zap.Error(err))
+		http.Error(w, "", http.StatusBadRequest)
+		return
+	}
+
+	linkID := chi.URLParam(r, "linkID")
+	userURL, err := h.s.UpdateURLMeta(r.Context(), linkDomain(r), linkID, userID, request)
+	if err != nil {
+		if errors.Is(err, store.ErrURLNotFound) {
+			http.Error(w, "Link not found", http.StatusNotFound)
+			return
+		}
+
+		h.log(r).Error("Failed to update URL", zap.Error(err))
+		http.Error(w, "", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set(contentType, applicationJSON)
+	w.WriteHeader(http.StatusOK)
+	if err = json.NewEncoder(w).Encode(userURL); err != nil {
+		h.log(r).Error("Failed to encode response", zap.Error(err))
+		http.Error(w, "", http.StatusInternalServerError)
+		return
+	}
+}
+
+func (h *Handler) HandleUpdateDestination(w http.ResponseWriter, r *http.Request) {
+	userID, err := middleware.GetUserIDFromContext(r.Context())
+	if err != nil {
+		h.log(r).Error(cannotGetUserID, zap.Error(err))
+		http.Error(w, "", http.StatusInternalServerError)
+		return
+	}
+
+	var request models.HandleUpdateURLRequest
+	if err = json.NewDecoder(r.Body).Decode(&request); err != nil {
+		h.log(r).Error("Failed to unmarshal request", zap.Error(err))
+		http.Error(w, "", http.StatusBadRequest)
+		return
+	}
+
+	linkID := chi.URLParam(r, "linkID")
+	userURL, err := h.s.UpdateURL(r.Context(), linkDomain(r), linkID, userID, request.URL)
+	if err != nil {
+		if writeDestinationError(w, err) {
+			return
+		}
+
+		switch {
+		case errors.Is(err, service.ErrInvalidURL):
+			http.Error(w, "Invalid URL", http.StatusBadRequest)
+		case errors.Is(err, store.ErrURLNotFound):
+			http.Error(w, "Link not found", http.StatusNotFound)
+		case errors.Is(err, store.ErrURLExists):
+			http.Error(w, "URL is already shortened", http.StatusConflict)
+		case errors.Is(err, service.ErrSeveralDestinations):
+			http.Error(w, err.Error(), http.StatusConflict)
+		default:
+			h.log(r).Error("Failed to update URL destination", zap.Error(err))
+			http.Error(w, "", http.StatusInternalServerError)
+		}
+
+		return
+	}
+
+	w.Header().Set(contentType, applicationJSON)
+	w.WriteHeader(http.StatusOK)
+	if err = json.NewEncoder(w).Encode(userURL); err != nil {
+		h.log(r).Error("Failed to encode response", zap.Error(err))
+		http.Error(w, "", http.StatusInternalServerError)
+		return
+	}
+}
+
+func (h *Handler) HandleURLRevisions(w http.ResponseWriter, r *http.Request) {
+	userID, err := middleware.GetUserIDFromContext(r.Context())
+	if err != nil {
+		h.log(r).Error(cannotGetUserID, zap.Error(err))
+		http.Error(w, "", http.StatusInternalServerError)
+		return
+	}
+
+	linkID := chi.URLParam(r, "linkID")
+	revisions, err := h.s.GetURLRevisions(r.Context(), linkDomain(r), linkID, userID)
+	if err != nil {
+		if errors.Is(err, store.ErrURLNotFound) {
+			http.Error(w, "Link not found", http.StatusNotFound)
+			return
+		}
+
+		h.log(r).Error("Failed to get URL revisions", zap.Error(err))
+		http.Error(w, "", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set(contentType, applicationJSON)
+	w.WriteHeader(http.StatusOK)
+	if err = json.NewEncoder(w).Encode(revisions); err != nil {
+		h.log(r).Error("Failed to encode response", zap.Error(err))
+		http.Error(w, "", http.StatusInternalServerError)
+		return
+	}
+}
+
+// HandleURLStats отдаёт переходы по ссылке с разбивкой по адресам A/B-ссылки.
+func (h *Handler) HandleURLStats(w http.ResponseWriter, r *http.Request) {
+	userID, err := middleware.GetUserIDFromContext(r.Context())
+	if err != nil {
+		h.log(r).Error(cannotGetUserID, zap.Error(err))
+		http.Error(w, "", http.StatusInternalServerError)
+		return
+	}
+
+	linkID := chi.URLParam(r, "linkID")
+	stats, err := h.s.GetURLStats(r.Context(), linkDomain(r), linkID, userID)
+	if err != nil {
+		if errors.Is(err, store.ErrURLNotFound) {
+			http.Error(w, "Link not found", http.StatusNotFound)
+			return
+		}
+
+		h.log(r).Error("Failed to get URL stats", zap.Error(err))
+		http.Error(w, "", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set(contentType, applicationJSON)
+	w.WriteHeader(http.StatusOK)
+	if err = json.NewEncoder(w).Encode(stats); err != nil {
+		h.log(r).Error("Failed to encode response", zap.Error(err))
+		http.Error(w, "", http.StatusInternalServerError)
+		return
+	}
+}
+
 func (h *Handler) HandleDelete(w http.ResponseWriter, r *http.Request) {
 	userID, err := middleware.GetUserIDFromContext(r.Context())
 	if err != nil {
-		h.logger.Error(cannotGetUserID, zap.Error(err))
+		h.log(r).Error(cannotGetUserID, zap.Error(err))
 		http.Error(w, "", http.StatusInternalServerError)
 		return
 	}
@@ -257,22 +565,71 @@ func (h *Handler) HandleDelete(w http.ResponseWriter, r *http.Request) {
 	var request []string
 	var buf bytes.Buffer
 	if _, err = buf.ReadFrom(r.Body); err != nil {
-		h.logger.Error(failedToReadBody, zap.Error(err))
+		h.log(r).Error(failedToReadBody, zap.Error(err))
 		http.Error(w, "", http.StatusBadRequest)
 		return
 	}
 
 	if err = json.Unmarshal(buf.Bytes(), &request); err != nil {
-		h.logger.Error("Failed to unmarshal request", zap.Error(err))
+		h.log(r).Error("Failed to unmarshal request", zap.Error(err))
 		http.Error(w, "", http.StatusBadRequest)
 		return
 	}
 
-	if err = h.s.DeleteURLs(r.Context(), request, userID); err != nil {
-		h.logger.Error("Failed to delete urls", zap.Error(err))
+	if err = h.s.DeleteURLs(r.Context(), linkDomain(r), request, userID); err != nil {
+		h.log(r).Error("Failed to delete urls", zap.Error(err))
 		http.Error(w, "", http.StatusInternalServerError)
 		return
 	}
 
 	w.WriteHeader(http.StatusAccepted)
 }
+
+func (h *Handler) HandleRestore(w http.ResponseWriter, r *http.Request) {
+	userID, err := middleware.GetUserIDFromContext(r.Context())
+	if err != nil {
+		h.log(r).Error(cannotGetUserID, zap.Error(err))
+		http.Error(w, "", http.StatusInternalServerError)
+		return
+	}
+
+	var request []string
+	if err = json.NewDecoder(r.Body).Decode(&request); err != nil {
+		h.log(r).Error("Failed to unmarshal request", zap.Error(err))
+		http.Error(w, "", http.StatusBadRequest)
+		return
+	}
+
+	if err = h.s.RestoreURLs(r.Context(), linkDomain(r), request, userID); err != nil {
+		if errors.Is(err, store.ErrUserHasNoURLs) {
+			http.Error(w, "Links not found", http.StatusNotFound)
+			return
+		}
+
+		h.log(r).Error("Failed to restore urls", zap.Error(err))
+		http.Error(w, "", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// linkDomain возвращает домен ссылок из параметра domain; пустой — основной домен.
+func linkDomain(r *http.Request) string {
+	return r.URL.Query().Get("domain")
+}
+
+// writeDestinationError отвечает клиенту, если адрес назначения отклонён.
+// Возвращает false, если ошибка другая и ответ ещё не записан.
+func writeDestinationError(w http.ResponseWriter, err error) bool {
+	switch {
+	case errors.Is(err, service.ErrSelfReference), errors.Is(err, service.ErrRedirectLoop):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	case errors.Is(err, service.ErrDestinationBlocked):
+		http.Error(w, err.Error(), http.StatusForbidden)
+	default:
+		return false
+	}
+
+	return true
+}
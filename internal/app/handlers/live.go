@@ -0,0 +1,87 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/a-bondar/go-url-shortener/internal/app/middleware"
+	"github.com/a-bondar/go-url-shortener/internal/app/store"
+	"github.com/go-chi/chi/v5"
+	"go.uber.org/zap"
+)
+
+const (
+	// liveBufferSize — сколько переходов ждёт медленного подписчика, прежде чем начнут отбрасываться.
+	liveBufferSize = 64
+	liveHeartbeat  = 15 * time.Second
+)
+
+// HandleLiveClicks транслирует переходы по ссылке пользователя потоком SSE. Если клиент
+// не успевает читать, часть переходов пропускается, о чём сообщает событие dropped.
+func (h *Handler) HandleLiveClicks(w http.ResponseWriter, r *http.Request) {
+	userID, err := middleware.GetUserIDFromContext(r.Context())
+	if err != nil {
+		h.log(r).Error(cannotGetUserID, zap.Error(err))
+		http.Error(w, "", http.StatusInternalServerError)
+		return
+	}
+
+	key, err := h.s.GetLinkKey(r.Context(), linkDomain(r), chi.URLParam(r, "linkID"), userID)
+	if err != nil {
+		if errors.Is(err, store.ErrURLNotFound) {
+			http.Error(w, "Link not found", http.StatusNotFound)
+			return
+		}
+
+		h.log(r).Error("Failed to get URL", zap.Error(err))
+		http.Error(w, "", http.StatusInternalServerError)
+		return
+	}
+
+	ctx, cancel := h.streamContext(r)
+	defer cancel()
+
+	sub := h.live.Subscribe(key)
+	defer h.live.Unsubscribe(sub)
+
+	rc := http.NewResponseController(w)
+	w.Header().Set(contentType, textEventStream)
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(http.StatusOK)
+
+	heartbeat := time.NewTicker(liveHeartbeat)
+	defer heartbeat.Stop()
+
+	// Комментарий сразу отправляет заголовки, чтобы клиент знал, что подписка состоялась.
+	message := ": subscribed\n\n"
+	for {
+		if _, err = fmt.Fprint(w, message); err != nil {
+			return
+		}
+
+		if err = rc.Flush(); err != nil {
+			return
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-heartbeat.C:
+			message = ": heartbeat\n\n"
+		case click := <-sub.Clicks():
+			data, marshalErr := json.Marshal(click)
+			if marshalErr != nil {
+				h.log(r).Error("Failed to marshal click", zap.Error(marshalErr))
+				return
+			}
+
+			message = fmt.Sprintf("event: click\ndata: %s\n\n", data)
+			if dropped := sub.TakeDropped(); dropped > 0 {
+				message = fmt.Sprintf("event: dropped\ndata: {\"dropped\":%d}\n\n", dropped) + message
+			}
+		}
+	}
+}
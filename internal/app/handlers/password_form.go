@@ -0,0 +1,51 @@
+package handlers
+
+import (
+	"html/template"
+	"net/http"
+
+	"go.uber.org/zap"
+)
+
+// LinkPasswordHeader — заголовок, в котором API-клиенты передают пароль защищённой ссылки.
+const LinkPasswordHeader = "X-Link-Password"
+
+var passwordForm = template.Must(template.New("password").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>Password required</title>
+</head>
+<body>
+<form method="post">
+<p>This link is password protected.</p>
+{{if .}}<p role="alert">{{.}}</p>{{end}}
+<input type="password" name="password" autofocus required>
+<button type="submit">Continue</button>
+</form>
+</body>
+</html>
+`))
+
+// linkPassword достаёт пароль из заголовка или из отправленной формы.
+func linkPassword(r *http.Request) string {
+	if password := r.Header.Get(LinkPasswordHeader); password != "" {
+		return password
+	}
+
+	if r.Method == http.MethodPost {
+		return r.PostFormValue("password")
+	}
+
+	return ""
+}
+
+func (h *Handler) writePasswordForm(w http.ResponseWriter, r *http.Request, message string) {
+	w.Header().Set(contentType, "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(http.StatusUnauthorized)
+
+	if err := passwordForm.Execute(w, message); err != nil {
+		h.log(r).Error("Failed to render password form", zap.Error(err))
+	}
+}
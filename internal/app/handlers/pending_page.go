@@ -0,0 +1,38 @@
+package handlers
+
+import (
+	"html/template"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// PendingPageData — данные для шаблона страницы ссылки, которая ещё не запущена.
+type PendingPageData struct {
+	NotBefore time.Time
+}
+
+// SetPendingPage задаёт страницу для ссылок до времени запуска. Без неё отвечаем 404,
+// не раскрывая, что ссылка существует.
+func (h *Handler) SetPendingPage(page *template.Template) {
+	h.pendingPage = page
+}
+
+func (h *Handler) writeNotYetActive(w http.ResponseWriter, r *http.Request, notBefore time.Time) {
+	if h.pendingPage == nil {
+		http.Error(w, "Link not found", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set(contentType, "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(time.Until(notBefore).Seconds()))))
+	w.WriteHeader(http.StatusServiceUnavailable)
+
+	if err := h.pendingPage.Execute(w, PendingPageData{NotBefore: notBefore}); err != nil {
+		h.log(r).Error("Failed to render pending link page", zap.Error(err))
+	}
+}
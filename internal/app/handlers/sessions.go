@@ -0,0 +1,75 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/a-bondar/go-url-shortener/internal/app/middleware"
+	"github.com/a-bondar/go-url-shortener/internal/app/models"
+	"go.uber.org/zap"
+)
+
+// HandleRefresh обменивает refresh-токен из тела запроса или cookie на новую пару токенов.
+func (h *Handler) HandleRefresh(w http.ResponseWriter, r *http.Request) {
+	var request models.HandleRefreshRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil && !errors.Is(err, io.EOF) {
+		h.log(r).Error("Failed to unmarshal request", zap.Error(err))
+		http.Error(w, "", http.StatusBadRequest)
+		return
+	}
+
+	if request.RefreshToken == "" {
+		if cookie, err := r.Cookie(middleware.RefreshCookieName); err == nil {
+			request.RefreshToken = cookie.Value
+		}
+	}
+
+	if request.RefreshToken == "" {
+		http.Error(w, "refresh token is required", http.StatusBadRequest)
+		return
+	}
+
+	pair, err := middleware.RefreshSession(r.Context(), h.s, request.RefreshToken)
+	if err != nil {
+		if errors.Is(err, middleware.ErrInvalidToken) || errors.Is(err, middleware.ErrSessionRevoked) {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		h.log(r).Error("Failed to refresh session", zap.Error(err))
+		http.Error(w, "", http.StatusInternalServerError)
+		return
+	}
+
+	middleware.SetSessionCookies(w, pair)
+	h.writeJSON(w, r, http.StatusOK, pair)
+}
+
+// HandleLogout отзывает текущую сессию: её токены больше нельзя обновить.
+func (h *Handler) HandleLogout(w http.ResponseWriter, r *http.Request) {
+	userID, err := middleware.GetUserIDFromContext(r.Context())
+	if err != nil {
+		h.log(r).Error(cannotGetUserID, zap.Error(err))
+		http.Error(w, "", http.StatusInternalServerError)
+		return
+	}
+
+	sessionID := middleware.GetSessionIDFromContext(r.Context())
+	if sessionID == "" {
+		http.Error(w, "request is not bound to a session", http.StatusBadRequest)
+		return
+	}
+
+	err = h.s.RevokeSession(r.Context(), sessionID, userID, time.Now().Add(middleware.RefreshTokenExp))
+	if err != nil {
+		h.log(r).Error("Failed to revoke session", zap.Error(err))
+		http.Error(w, "", http.StatusInternalServerError)
+		return
+	}
+
+	middleware.ClearSessionCookies(w)
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,39 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// VariantCookieName — cookie, в которой запоминается выбранный посетителю адрес A/B-ссылки.
+const VariantCookieName = "ab_variant"
+
+const variantCookieTTL = 30 * 24 * time.Hour
+
+// linkVariant возвращает закреплённый за посетителем адрес A/B-ссылки, 0 — не закреплён.
+func linkVariant(r *http.Request) int {
+	cookie, err := r.Cookie(VariantCookieName)
+	if err != nil {
+		return 0
+	}
+
+	variant, err := strconv.Atoi(cookie.Value)
+	if err != nil || variant < 0 {
+		return 0
+	}
+
+	return variant
+}
+
+// setVariantCookie закрепляет адрес за посетителем только для этой ссылки: путь cookie совпадает с кодом.
+func setVariantCookie(w http.ResponseWriter, linkID string, variant int) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     VariantCookieName,
+		Value:    strconv.Itoa(variant),
+		Path:     "/" + linkID,
+		MaxAge:   int(variantCookieTTL.Seconds()),
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	})
+}
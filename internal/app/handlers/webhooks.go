@@ -0,0 +1,134 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/a-bondar/go-url-shortener/internal/app/middleware"
+	"github.com/a-bondar/go-url-shortener/internal/app/models"
+	"github.com/a-bondar/go-url-shortener/internal/app/service"
+	"github.com/a-bondar/go-url-shortener/internal/app/store"
+	"github.com/go-chi/chi/v5"
+	"go.uber.org/zap"
+)
+
+func (h *Handler) HandleWebhooks(w http.ResponseWriter, r *http.Request) {
+	userID, err := middleware.GetUserIDFromContext(r.Context())
+	if err != nil {
+		h.log(r).Error(cannotGetUserID, zap.Error(err))
+		http.Error(w, "", http.StatusInternalServerError)
+		return
+	}
+
+	webhooks, err := h.s.GetWebhooks(r.Context(), userID)
+	if err != nil {
+		h.log(r).Error("Failed to get webhooks", zap.Error(err))
+		http.Error(w, "", http.StatusInternalServerError)
+		return
+	}
+
+	h.writeJSON(w, r, http.StatusOK, webhooks)
+}
+
+func (h *Handler) HandleCreateWebhook(w http.ResponseWriter, r *http.Request) {
+	userID, err := middleware.GetUserIDFromContext(r.Context())
+	if err != nil {
+		h.log(r).Error(cannotGetUserID, zap.Error(err))
+		http.Error(w, "", http.StatusInternalServerError)
+		return
+	}
+
+	var request models.HandleWebhookRequest
+	if err = json.NewDecoder(r.Body).Decode(&request); err != nil {
+		h.log(r).Error("Failed to unmarshal request", zap.Error(err))
+		http.Error(w, "", http.StatusBadRequest)
+		return
+	}
+
+	webhook, err := h.s.CreateWebhook(r.Context(), userID, request)
+	if err != nil {
+		if writeDestinationError(w, err) {
+			return
+		}
+
+		switch {
+		case errors.Is(err, service.ErrInvalidWebhook):
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		case errors.Is(err, service.ErrNotRegistered):
+			http.Error(w, "forbidden", http.StatusForbidden)
+		default:
+			h.log(r).Error("Failed to create webhook", zap.Error(err))
+			http.Error(w, "", http.StatusInternalServerError)
+		}
+
+		return
+	}
+
+	h.writeJSON(w, r, http.StatusCreated, webhook)
+}
+
+func (h *Handler) HandleDeleteWebhook(w http.ResponseWriter, r *http.Request) {
+	userID, err := middleware.GetUserIDFromContext(r.Context())
+	if err != nil {
+		h.log(r).Error(cannotGetUserID, zap.Error(err))
+		http.Error(w, "", http.StatusInternalServerError)
+		return
+	}
+
+	err = h.s.DeleteWebhook(r.Context(), userID, chi.URLParam(r, "webhookID"))
+	if err != nil {
+		if errors.Is(err, service.ErrInvalidID) || errors.Is(err, store.ErrWebhookNotFound) {
+			http.Error(w, "Webhook not found", http.StatusNotFound)
+			return
+		}
+
+		h.log(r).Error("Failed to delete webhook", zap.Error(err))
+		http.Error(w, "", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// HandleDeadDeliveries отдаёт события, которые не удалось доставить за все попытки.
+func (h *Handler) HandleDeadDeliveries(w http.ResponseWriter, r *http.Request) {
+	userID, err := middleware.GetUserIDFromContext(r.Context())
+	if err != nil {
+		h.log(r).Error(cannotGetUserID, zap.Error(err))
+		http.Error(w, "", http.StatusInternalServerError)
+		return
+	}
+
+	deliveries, err := h.s.GetDeadDeliveries(r.Context(), userID)
+	if err != nil {
+		h.log(r).Error("Failed to get dead deliveries", zap.Error(err))
+		http.Error(w, "", http.StatusInternalServerError)
+		return
+	}
+
+	h.writeJSON(w, r, http.StatusOK, deliveries)
+}
+
+func (h *Handler) HandleRetryDelivery(w http.ResponseWriter, r *http.Request) {
+	userID, err := middleware.GetUserIDFromContext(r.Context())
+	if err != nil {
+		h.log(r).Error(cannotGetUserID, zap.Error(err))
+		http.Error(w, "", http.StatusInternalServerError)
+		return
+	}
+
+	err = h.s.RetryDelivery(r.Context(), userID, chi.URLParam(r, "deliveryID"))
+	if err != nil {
+		if errors.Is(err, service.ErrInvalidID) || errors.Is(err, store.ErrDeliveryNotFound) {
+			http.Error(w, "Delivery not found", http.StatusNotFound)
+			return
+		}
+
+		h.log(r).Error("Failed to retry delivery", zap.Error(err))
+		http.Error(w, "", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusAccepted)
+}
@@ -0,0 +1,183 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/a-bondar/go-url-shortener/internal/app/middleware"
+	"github.com/a-bondar/go-url-shortener/internal/app/models"
+	"github.com/a-bondar/go-url-shortener/internal/app/service"
+	"github.com/a-bondar/go-url-shortener/internal/app/store"
+	"github.com/go-chi/chi/v5"
+	"go.uber.org/zap"
+)
+
+func (h *Handler) HandleCreateWorkspace(w http.ResponseWriter, r *http.Request) {
+	userID, err := middleware.GetUserIDFromContext(r.Context())
+	if err != nil {
+		h.log(r).Error(cannotGetUserID, zap.Error(err))
+		http.Error(w, "", http.StatusInternalServerError)
+		return
+	}
+
+	var request models.HandleCreateWorkspaceRequest
+	if err = json.NewDecoder(r.Body).Decode(&request); err != nil {
+		h.log(r).Error("Failed to unmarshal request", zap.Error(err))
+		http.Error(w, "", http.StatusBadRequest)
+		return
+	}
+
+	workspace, err := h.s.CreateWorkspace(r.Context(), userID, request.Name)
+	if err != nil {
+		if !writeWorkspaceError(w, err) {
+			h.log(r).Error("Failed to create workspace", zap.Error(err))
+			http.Error(w, "", http.StatusInternalServerError)
+		}
+
+		return
+	}
+
+	h.writeJSON(w, r, http.StatusCreated, workspace)
+}
+
+func (h *Handler) HandleWorkspaces(w http.ResponseWriter, r *http.Request) {
+	userID, err := middleware.GetUserIDFromContext(r.Context())
+	if err != nil {
+		h.log(r).Error(cannotGetUserID, zap.Error(err))
+		http.Error(w, "", http.StatusInternalServerError)
+		return
+	}
+
+	workspaces, err := h.s.GetWorkspaces(r.Context(), userID)
+	if err != nil {
+		h.log(r).Error("Failed to get workspaces", zap.Error(err))
+		http.Error(w, "", http.StatusInternalServerError)
+		return
+	}
+
+	h.writeJSON(w, r, http.StatusOK, workspaces)
+}
+
+func (h *Handler) HandleWorkspaceMembers(w http.ResponseWriter, r *http.Request) {
+	userID, err := middleware.GetUserIDFromContext(r.Context())
+	if err != nil {
+		h.log(r).Error(cannotGetUserID, zap.Error(err))
+		http.Error(w, "", http.StatusInternalServerError)
+		return
+	}
+
+	members, err := h.s.GetWorkspaceMembers(r.Context(), userID, chi.URLParam(r, "workspaceID"))
+	if err != nil {
+		if !writeWorkspaceError(w, err) {
+			h.log(r).Error("Failed to get workspace members", zap.Error(err))
+			http.Error(w, "", http.StatusInternalServerError)
+		}
+
+		return
+	}
+
+	h.writeJSON(w, r, http.StatusOK, members)
+}
+
+func (h *Handler) HandleSetWorkspaceMember(w http.ResponseWriter, r *http.Request) {
+	userID, err := middleware.GetUserIDFromContext(r.Context())
+	if err != nil {
+		h.log(r).Error(cannotGetUserID, zap.Error(err))
+		http.Error(w, "", http.StatusInternalServerError)
+		return
+	}
+
+	var request models.HandleSetMemberRequest
+	if err = json.NewDecoder(r.Body).Decode(&request); err != nil {
+		h.log(r).Error("Failed to unmarshal request", zap.Error(err))
+		http.Error(w, "", http.StatusBadRequest)
+		return
+	}
+
+	member, err := h.s.SetWorkspaceMember(r.Context(), userID,
+		chi.URLParam(r, "workspaceID"), chi.URLParam(r, "memberID"), request.Role)
+	if err != nil {
+		if !writeWorkspaceError(w, err) {
+			h.log(r).Error("Failed to set workspace member", zap.Error(err))
+			http.Error(w, "", http.StatusInternalServerError)
+		}
+
+		return
+	}
+
+	h.writeJSON(w, r, http.StatusOK, member)
+}
+
+func (h *Handler) HandleRemoveWorkspaceMember(w http.ResponseWriter, r *http.Request) {
+	userID, err := middleware.GetUserIDFromContext(r.Context())
+	if err != nil {
+		h.log(r).Error(cannotGetUserID, zap.Error(err))
+		http.Error(w, "", http.StatusInternalServerError)
+		return
+	}
+
+	err = h.s.RemoveWorkspaceMember(r.Context(), userID, chi.URLParam(r, "workspaceID"), chi.URLParam(r, "memberID"))
+	if err != nil {
+		if !writeWorkspaceError(w, err) {
+			h.log(r).Error("Failed to remove workspace member", zap.Error(err))
+			http.Error(w, "", http.StatusInternalServerError)
+		}
+
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// HandleTransfer передаёт ссылки другому пользователю или в рабочее пространство.
+func (h *Handler) HandleTransfer(w http.ResponseWriter, r *http.Request) {
+	userID, err := middleware.GetUserIDFromContext(r.Context())
+	if err != nil {
+		h.log(r).Error(cannotGetUserID, zap.Error(err))
+		http.Error(w, "", http.StatusInternalServerError)
+		return
+	}
+
+	var request models.HandleTransferRequest
+	if err = json.NewDecoder(r.Body).Decode(&request); err != nil {
+		h.log(r).Error("Failed to unmarshal request", zap.Error(err))
+		http.Error(w, "", http.StatusBadRequest)
+		return
+	}
+	request.Domain = linkDomain(r)
+
+	transferred, err := h.s.TransferURLs(r.Context(), userID, request)
+	if err != nil {
+		if !writeWorkspaceError(w, err) {
+			h.log(r).Error("Failed to transfer URLs", zap.Error(err))
+			http.Error(w, "", http.StatusInternalServerError)
+		}
+
+		return
+	}
+
+	h.writeJSON(w, r, http.StatusOK, models.HandleTransferResponse{Transferred: transferred})
+}
+
+// writeWorkspaceError отвечает клиенту на ожидаемые ошибки рабочих пространств.
+// Возвращает false, если ошибка неожиданная и ответ ещё не записан.
+func writeWorkspaceError(w http.ResponseWriter, err error) bool {
+	switch {
+	case errors.Is(err, service.ErrInvalidName), errors.Is(err, service.ErrUnknownRole),
+		errors.Is(err, service.ErrInvalidTransfer):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	case errors.Is(err, service.ErrNotRegistered), errors.Is(err, service.ErrForbiddenRole):
+		http.Error(w, "forbidden", http.StatusForbidden)
+	case errors.Is(err, service.ErrNoWorkspaceAccess), errors.Is(err, store.ErrWorkspaceNotFound):
+		http.Error(w, "workspace not found", http.StatusNotFound)
+	case errors.Is(err, service.ErrUnknownUser), errors.Is(err, store.ErrMemberNotFound):
+		http.Error(w, "user not found", http.StatusNotFound)
+	case errors.Is(err, service.ErrLastOwner):
+		http.Error(w, err.Error(), http.StatusConflict)
+	default:
+		return false
+	}
+
+	return true
+}
@@ -0,0 +1,80 @@
+// Package live раздаёт переходы по ссылкам подписчикам внутри процесса.
+//
+// Publish никогда не блокирует: у каждого подписчика свой буфер, и если он заполнен,
+// переход для этого подписчика отбрасывается и учитывается в TakeDropped.
+// Так медленный клиент не может задержать редирект.
+package live
+
+import (
+	"sync"
+	"sync/atomic"
+
+	"github.com/a-bondar/go-url-shortener/internal/app/models"
+)
+
+type Subscription struct {
+	key     models.LinkKey
+	clicks  chan models.LiveClick
+	dropped atomic.Int64
+}
+
+func (s *Subscription) Clicks() <-chan models.LiveClick {
+	return s.clicks
+}
+
+// TakeDropped возвращает число переходов, отброшенных с прошлого вызова, и обнуляет счётчик.
+func (s *Subscription) TakeDropped() int64 {
+	return s.dropped.Swap(0)
+}
+
+type Hub struct {
+	mu          sync.RWMutex
+	subscribers map[models.LinkKey]map[*Subscription]struct{}
+	bufferSize  int
+}
+
+func NewHub(bufferSize int) *Hub {
+	return &Hub{
+		subscribers: make(map[models.LinkKey]map[*Subscription]struct{}),
+		bufferSize:  bufferSize,
+	}
+}
+
+func (h *Hub) Subscribe(key models.LinkKey) *Subscription {
+	sub := &Subscription{key: key, clicks: make(chan models.LiveClick, h.bufferSize)}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.subscribers[key] == nil {
+		h.subscribers[key] = make(map[*Subscription]struct{})
+	}
+	h.subscribers[key][sub] = struct{}{}
+
+	return sub
+}
+
+// Unsubscribe отписывает клиента; вызывать, когда он отключился.
+func (h *Hub) Unsubscribe(sub *Subscription) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	delete(h.subscribers[sub.key], sub)
+	if len(h.subscribers[sub.key]) == 0 {
+		delete(h.subscribers, sub.key)
+	}
+}
+
+// Publish раздаёт переход подписчикам ссылки, не дожидаясь их.
+func (h *Hub) Publish(key models.LinkKey, click models.LiveClick) {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	for sub := range h.subscribers[key] {
+		select {
+		case sub.clicks <- click:
+		default:
+			sub.dropped.Add(1)
+		}
+	}
+}
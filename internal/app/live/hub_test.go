@@ -0,0 +1,28 @@
+package live
+
+import (
+	"testing"
+
+	"github.com/a-bondar/go-url-shortener/internal/app/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHubDropsForSlowSubscriber(t *testing.T) {
+	hub := NewHub(2)
+	key := models.LinkKey{ShortURL: "abc"}
+	sub := hub.Subscribe(key)
+	other := hub.Subscribe(models.LinkKey{Domain: "brand.example", ShortURL: "abc"})
+
+	for range 5 {
+		hub.Publish(key, models.LiveClick{Destination: "https://hello.world"})
+	}
+
+	assert.Len(t, sub.Clicks(), 2)
+	assert.Equal(t, int64(3), sub.TakeDropped())
+	assert.Equal(t, int64(0), sub.TakeDropped())
+	assert.Empty(t, other.Clicks())
+
+	hub.Unsubscribe(sub)
+	hub.Unsubscribe(other)
+	assert.Empty(t, hub.subscribers)
+}
@@ -1,11 +1,14 @@
 package logger
 
 import (
+	"context"
 	"fmt"
 
 	"go.uber.org/zap"
 )
 
+type contextKey struct{}
+
 func NewLogger() (*zap.Logger, error) {
 	logger, err := zap.NewProduction()
 
@@ -15,3 +18,17 @@ func NewLogger() (*zap.Logger, error) {
 
 	return logger, nil
 }
+
+// WithContext сохраняет в контексте логгер с полями запроса (request_id, trace_id).
+func WithContext(ctx context.Context, logger *zap.Logger) context.Context {
+	return context.WithValue(ctx, contextKey{}, logger)
+}
+
+// FromContext возвращает логгер запроса, а если его нет — fallback.
+func FromContext(ctx context.Context, fallback *zap.Logger) *zap.Logger {
+	if logger, ok := ctx.Value(contextKey{}).(*zap.Logger); ok {
+		return logger
+	}
+
+	return fallback
+}
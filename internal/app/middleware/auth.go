@@ -5,8 +5,11 @@ import (
 	"errors"
 	"fmt"
 	"net/http"
+	"slices"
+	"strings"
 	"time"
 
+	"github.com/a-bondar/go-url-shortener/internal/app/models"
 	"github.com/golang-jwt/jwt/v5"
 	"github.com/google/uuid"
 	"go.uber.org/zap"
@@ -14,21 +17,88 @@ import (
 
 type Claims struct {
 	jwt.RegisteredClaims
-	UserID string
+	UserID    string
+	SessionID string `json:",omitempty"`
+	Type      string `json:",omitempty"`
 }
 
 type contextKey int
 
-const userIDKey contextKey = iota
-const tokenExp = time.Hour * 24
+const (
+	userIDKey contextKey = iota
+	requestIDKey
+	scopesKey
+	sessionIDKey
+)
+
+const (
+	bearerPrefix   = "Bearer "
+	authCookieName = "auth_token"
+	// RefreshCookieName — cookie с refresh-токеном браузерной сессии.
+	RefreshCookieName = "refresh_token"
+	// AuthTokenHeader — заголовок ответа с только что выпущенным access-токеном.
+	AuthTokenHeader = "X-Auth-Token"
+	// RefreshTokenHeader — заголовок ответа с refresh-токеном той же сессии.
+	RefreshTokenHeader = "X-Refresh-Token"
+)
+
+// cookieScopes — права анонимной личности из cookie: свои ссылки, но не ключи.
+var cookieScopes = []string{models.ScopeLinksRead, models.ScopeLinksWrite}
+
+// APIKeyAuthenticator проверяет API-ключ из заголовка Authorization.
+type APIKeyAuthenticator interface {
+	AuthenticateAPIKey(ctx context.Context, rawKey string) (models.APIKey, error)
+}
+
+// SessionChecker сообщает, отозвана ли сессия, к которой привязаны токены, и ведёт учёт
+// использованных refresh-токенов.
+type SessionChecker interface {
+	IsSessionRevoked(ctx context.Context, sessionID string) (bool, error)
+	RevokeSession(ctx context.Context, sessionID string, userID string, expiresAt time.Time) error
+	ConsumeRefreshToken(ctx context.Context, tokenID string, userID string, expiresAt time.Time) (bool, error)
+}
+
+type Authenticator interface {
+	APIKeyAuthenticator
+	SessionChecker
+}
+
+const (
+	accessTokenExp = 15 * time.Minute
+	// RefreshTokenExp — срок жизни refresh-токена; каждое обновление сдвигает его заново.
+	RefreshTokenExp = 30 * 24 * time.Hour
+	// renewBefore — за сколько до истечения access-токена middleware выпускает новый.
+	renewBefore      = 5 * time.Minute
+	tokenTypeRefresh = "refresh"
+)
+
 const secretKey = "supersecretkey"
 
+var (
+	ErrInvalidToken   = errors.New("invalid token")
+	ErrSessionRevoked = errors.New("session is revoked")
+)
+
+// session — личность, к которой привязана пара токенов.
+type session struct {
+	userID    string
+	sessionID string
+}
+
 func CreateAccessToken(userID string) (string, error) {
+	return createToken(session{userID: userID, sessionID: uuid.NewString()}, "", accessTokenExp)
+}
+
+func createToken(sess session, tokenType string, exp time.Duration) (string, error) {
+	// Каждый токен получает свой идентификатор: по нему refresh-токен обменивается только один раз.
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, Claims{
 		RegisteredClaims: jwt.RegisteredClaims{
-			ExpiresAt: jwt.NewNumericDate(time.Now().Add(tokenExp)),
+			ID:        uuid.NewString(),
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(exp)),
 		},
-		UserID: userID,
+		UserID:    sess.userID,
+		SessionID: sess.sessionID,
+		Type:      tokenType,
 	})
 
 	tokenString, err := token.SignedString([]byte(secretKey))
@@ -39,6 +109,138 @@ func CreateAccessToken(userID string) (string, error) {
 	return tokenString, nil
 }
 
+// RefreshSession обменивает refresh-токен на новую пару токенов той же сессии.
+// Refresh-токен одноразовый: повторный обмен означает, что его украли, и отзывает всю сессию.
+func RefreshSession(ctx context.Context, checker SessionChecker, refreshToken string) (models.TokenPair, error) {
+	_, pair, err := refreshSession(ctx, checker, refreshToken)
+
+	return pair, err
+}
+
+func refreshSession(ctx context.Context, checker SessionChecker, refreshToken string) (session, models.TokenPair, error) {
+	claims, err := parseToken(refreshToken)
+	if err != nil {
+		return session{}, models.TokenPair{}, errors.Join(ErrInvalidToken, err)
+	}
+
+	if claims.Type != tokenTypeRefresh {
+		return session{}, models.TokenPair{}, fmt.Errorf("%w: not a refresh token", ErrInvalidToken)
+	}
+
+	if err = consumeRefreshToken(ctx, checker, claims); err != nil {
+		return session{}, models.TokenPair{}, err
+	}
+
+	return renewSession(ctx, checker, claims)
+}
+
+// consumeRefreshToken отмечает refresh-токен использованным. У токенов, выпущенных до появления
+// идентификаторов, отмечать нечего: после первого обмена они заменяются новыми.
+func consumeRefreshToken(ctx context.Context, checker SessionChecker, claims *Claims) error {
+	if claims.ID == "" || claims.ExpiresAt == nil {
+		return nil
+	}
+
+	fresh, err := checker.ConsumeRefreshToken(ctx, claims.ID, claims.UserID, claims.ExpiresAt.Time)
+	if err != nil {
+		return fmt.Errorf("failed to consume refresh token: %w", err)
+	}
+
+	if fresh {
+		return nil
+	}
+
+	if claims.SessionID != "" {
+		err = checker.RevokeSession(ctx, claims.SessionID, claims.UserID, time.Now().Add(RefreshTokenExp))
+		if err != nil {
+			return fmt.Errorf("failed to revoke session: %w", err)
+		}
+	}
+
+	return fmt.Errorf("%w: refresh token is reused", ErrSessionRevoked)
+}
+
+// renewSession перевыпускает оба токена, если сессия не отозвана.
+func renewSession(ctx context.Context, checker SessionChecker, claims *Claims) (session, models.TokenPair, error) {
+	sess, err := activeSession(ctx, checker, claims)
+	if err != nil {
+		return session{}, models.TokenPair{}, err
+	}
+
+	pair, err := issueTokens(sess)
+	if err != nil {
+		return session{}, models.TokenPair{}, err
+	}
+
+	return sess, pair, nil
+}
+
+// activeSession возвращает сессию токена, если она не отозвана.
+func activeSession(ctx context.Context, checker SessionChecker, claims *Claims) (session, error) {
+	sess := session{userID: claims.UserID, sessionID: claims.SessionID}
+	if sess.sessionID == "" {
+		// Токен выпущен до появления сессий — заводим для него новую.
+		sess.sessionID = uuid.NewString()
+	} else if err := checkSession(ctx, checker, sess.sessionID); err != nil {
+		return session{}, err
+	}
+
+	return sess, nil
+}
+
+func issueTokens(sess session) (models.TokenPair, error) {
+	accessToken, err := createToken(sess, "", accessTokenExp)
+	if err != nil {
+		return models.TokenPair{}, err
+	}
+
+	refreshToken, err := createToken(sess, tokenTypeRefresh, RefreshTokenExp)
+	if err != nil {
+		return models.TokenPair{}, err
+	}
+
+	return models.TokenPair{
+		AccessToken:     accessToken,
+		RefreshToken:    refreshToken,
+		AccessExpiresAt: time.Now().Add(accessTokenExp).UTC(),
+	}, nil
+}
+
+// SetSessionCookies отдаёт пару токенов браузеру в cookie, а остальным клиентам — в заголовках.
+func SetSessionCookies(w http.ResponseWriter, pair models.TokenPair) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     authCookieName,
+		Value:    pair.AccessToken,
+		Path:     "/",
+		Expires:  pair.AccessExpiresAt,
+		HttpOnly: true,
+	})
+	w.Header().Set(AuthTokenHeader, pair.AccessToken)
+
+	// При продлении access-токена refresh-токен не выпускается, у клиента остаётся прежний.
+	if pair.RefreshToken == "" {
+		return
+	}
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     RefreshCookieName,
+		Value:    pair.RefreshToken,
+		Path:     "/",
+		Expires:  time.Now().Add(RefreshTokenExp),
+		HttpOnly: true,
+	})
+	w.Header().Set(RefreshTokenHeader, pair.RefreshToken)
+}
+
+// ClearSessionCookies удаляет токены сессии у клиента.
+func ClearSessionCookies(w http.ResponseWriter) {
+	for _, name := range []string{authCookieName, RefreshCookieName} {
+		http.SetCookie(w, &http.Cookie{Name: name, Path: "/", MaxAge: -1, HttpOnly: true})
+	}
+	w.Header().Del(AuthTokenHeader)
+	w.Header().Del(RefreshTokenHeader)
+}
+
 func GetUserIDFromContext(ctx context.Context) (string, error) {
 	value := ctx.Value(userIDKey)
 	if value == nil {
@@ -53,7 +255,41 @@ func GetUserIDFromContext(ctx context.Context) (string, error) {
 	return userID, nil
 }
 
-func GetUserID(tokenString string) (userID string, err error) {
+func GetScopesFromContext(ctx context.Context) []string {
+	scopes, _ := ctx.Value(scopesKey).([]string)
+
+	return scopes
+}
+
+// GetSessionIDFromContext возвращает сессию токена; у запросов с API-ключом её нет.
+func GetSessionIDFromContext(ctx context.Context) string {
+	sessionID, _ := ctx.Value(sessionIDKey).(string)
+
+	return sessionID
+}
+
+// GetUserID возвращает личность access-токена, если его сессия не отозвана.
+func GetUserID(ctx context.Context, checker SessionChecker, tokenString string) (userID string, err error) {
+	claims, err := parseToken(tokenString)
+	if err != nil {
+		return "", errors.Join(ErrInvalidToken, err)
+	}
+
+	if claims.Type == tokenTypeRefresh {
+		return "", fmt.Errorf("%w: refresh token cannot be used for access", ErrInvalidToken)
+	}
+
+	if claims.SessionID != "" {
+		if err = checkSession(ctx, checker, claims.SessionID); err != nil {
+			return "", err
+		}
+	}
+
+	return claims.UserID, nil
+}
+
+// parseToken проверяет подпись токена. Для истёкшего токена claims возвращаются вместе с ошибкой.
+func parseToken(tokenString string) (*Claims, error) {
 	claims := &Claims{}
 	token, err := jwt.ParseWithClaims(
 		tokenString,
@@ -66,57 +302,176 @@ func GetUserID(tokenString string) (userID string, err error) {
 			return []byte(secretKey), nil
 		})
 	if err != nil {
-		return "", fmt.Errorf("unable to parse token: %w", err)
+		return claims, fmt.Errorf("unable to parse token: %w", err)
 	}
 	if !token.Valid {
-		return "", fmt.Errorf("token is not valid: %w", err)
+		return claims, fmt.Errorf("token is not valid: %w", err)
 	}
 
-	return claims.UserID, nil
+	return claims, nil
 }
 
-func WithAuth(logger *zap.Logger) func(h http.Handler) http.Handler {
+func WithAuth(logger *zap.Logger, authenticator Authenticator) func(h http.Handler) http.Handler {
 	return func(h http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			var userID string
-			cookie, err := r.Cookie("auth_token")
+			var (
+				sess   session
+				scopes = cookieScopes
+				pair   *models.TokenPair
+				err    error
+			)
+
+			if bearer, ok := strings.CutPrefix(r.Header.Get("Authorization"), bearerPrefix); ok {
+				sess, scopes, pair, err = bearerSession(r.Context(), strings.TrimSpace(bearer), authenticator)
+			} else {
+				sess, pair, err = cookieSession(r, authenticator)
+			}
+
 			if err != nil {
-				if !errors.Is(err, http.ErrNoCookie) {
-					logger.Error("Cannot get cookie", zap.Error(err))
-					http.Error(w, "", http.StatusInternalServerError)
+				if errors.Is(err, ErrInvalidToken) || errors.Is(err, ErrSessionRevoked) {
+					requestLogger(r, logger).Info("Cannot authenticate request", zap.Error(err))
+					// Иначе браузер так и будет присылать негодные токены
+					ClearSessionCookies(w)
+					http.Error(w, "unauthorized", http.StatusUnauthorized)
 					return
 				}
 
-				// Если нет куки с токеном
-				userID = uuid.New().String()
-				token, createTokenErr := CreateAccessToken(userID)
-				if createTokenErr != nil {
-					logger.Error("Cannot create access token", zap.Error(createTokenErr))
-					http.Error(w, "", http.StatusInternalServerError)
-					return
-				}
+				requestLogger(r, logger).Error("Cannot authenticate request", zap.Error(err))
+				http.Error(w, "", http.StatusInternalServerError)
+				return
+			}
 
-				// Сетим куку с токеном
-				http.SetCookie(w, &http.Cookie{
-					Name:     "auth_token",
-					Value:    token,
-					Expires:  time.Now().Add(tokenExp),
-					HttpOnly: true,
-				})
+			if pair != nil {
+				SetSessionCookies(w, *pair)
 			}
 
-			if userID == "" {
-				userID, err = GetUserID(cookie.Value)
-				if err != nil {
-					logger.Error("Cannot get userID", zap.Error(err))
-					http.Error(w, "", http.StatusUnauthorized)
-					return
-				}
+			// Прокидываем личность в контекст
+			ctx := context.WithValue(r.Context(), userIDKey, sess.userID)
+			ctx = context.WithValue(ctx, scopesKey, scopes)
+			if sess.sessionID != "" {
+				ctx = context.WithValue(ctx, sessionIDKey, sess.sessionID)
 			}
 
-			// Прокидываем userID из куки в контекст
-			ctx := context.WithValue(r.Context(), userIDKey, userID)
-			r = r.WithContext(ctx)
+			h.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+// bearerSession принимает в заголовке Authorization либо access-токен, либо API-ключ.
+// Близкий к истечению access-токен перевыпускается.
+func bearerSession(
+	ctx context.Context,
+	token string,
+	authenticator Authenticator,
+) (session, []string, *models.TokenPair, error) {
+	claims, err := parseToken(token)
+	if err == nil && claims.Type != tokenTypeRefresh {
+		sess, pair, err := renewIfExpiring(ctx, authenticator, claims)
+
+		return sess, cookieScopes, pair, err
+	}
+
+	if errors.Is(err, jwt.ErrTokenExpired) {
+		return session{}, nil, nil, errors.Join(ErrInvalidToken, err)
+	}
+
+	key, err := authenticator.AuthenticateAPIKey(ctx, token)
+	if err != nil {
+		return session{}, nil, nil, fmt.Errorf("%w: neither an access token nor an API key: %w", ErrInvalidToken, err)
+	}
+
+	return session{userID: key.UserID}, key.Scopes, nil, nil
+}
+
+// cookieSession достаёт личность из cookie. Истёкший access-токен обновляется по refresh-токену,
+// а клиенту без cookie выдаётся новая анонимная личность.
+func cookieSession(r *http.Request, checker SessionChecker) (session, *models.TokenPair, error) {
+	accessCookie, accessErr := r.Cookie(authCookieName)
+	if accessErr == nil {
+		claims, err := parseToken(accessCookie.Value)
+		switch {
+		case err == nil && claims.Type != tokenTypeRefresh:
+			return renewIfExpiring(r.Context(), checker, claims)
+		case !errors.Is(err, jwt.ErrTokenExpired):
+			return session{}, nil, errors.Join(ErrInvalidToken, err)
+		}
+	}
+
+	if refreshCookie, err := r.Cookie(RefreshCookieName); err == nil {
+		sess, pair, err := refreshSession(r.Context(), checker, refreshCookie.Value)
+		if err != nil {
+			return session{}, nil, err
+		}
+
+		return sess, &pair, nil
+	}
+
+	if accessErr == nil {
+		return session{}, nil, fmt.Errorf("%w: access token expired", ErrInvalidToken)
+	}
+
+	// Если нет куки с токеном
+	sess := session{userID: uuid.New().String(), sessionID: uuid.NewString()}
+	pair, err := issueTokens(sess)
+	if err != nil {
+		return session{}, nil, err
+	}
+
+	return sess, &pair, nil
+}
+
+// checkSession не пропускает токены отозванной сессии.
+func checkSession(ctx context.Context, checker SessionChecker, sessionID string) error {
+	revoked, err := checker.IsSessionRevoked(ctx, sessionID)
+	if err != nil {
+		return fmt.Errorf("failed to check session: %w", err)
+	}
+
+	if revoked {
+		return ErrSessionRevoked
+	}
+
+	return nil
+}
+
+// renewIfExpiring проверяет, что сессия access-токена не отозвана, и перевыпускает access-токен,
+// если ему осталось жить меньше renewBefore. Refresh-токен не выпускается: иначе каждое продление
+// оставляло бы ещё один действующий refresh-токен в обход одноразового обмена.
+func renewIfExpiring(ctx context.Context, checker SessionChecker, claims *Claims) (session, *models.TokenPair, error) {
+	sess := session{userID: claims.UserID, sessionID: claims.SessionID}
+	if claims.ExpiresAt == nil || time.Until(claims.ExpiresAt.Time) > renewBefore {
+		if sess.sessionID != "" {
+			if err := checkSession(ctx, checker, sess.sessionID); err != nil {
+				return session{}, nil, err
+			}
+		}
+
+		return sess, nil, nil
+	}
+
+	sess, err := activeSession(ctx, checker, claims)
+	if err != nil {
+		return session{}, nil, err
+	}
+
+	accessToken, err := createToken(sess, "", accessTokenExp)
+	if err != nil {
+		return session{}, nil, err
+	}
+
+	pair := models.TokenPair{AccessToken: accessToken, AccessExpiresAt: time.Now().Add(accessTokenExp).UTC()}
+
+	return sess, &pair, nil
+}
+
+// RequireScope пропускает запрос, только если у личности из контекста есть нужное право.
+func RequireScope(scope string) func(h http.Handler) http.Handler {
+	return func(h http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if !slices.Contains(GetScopesFromContext(r.Context()), scope) {
+				http.Error(w, "forbidden", http.StatusForbidden)
+				return
+			}
 
 			h.ServeHTTP(w, r)
 		})
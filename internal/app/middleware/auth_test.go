@@ -0,0 +1,86 @@
+package middleware
+
+import (
+	"context"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// sessionsStub хранит отозванные сессии и использованные refresh-токены в памяти.
+type sessionsStub struct {
+	revoked  sync.Map
+	consumed sync.Map
+}
+
+func (s *sessionsStub) IsSessionRevoked(_ context.Context, sessionID string) (bool, error) {
+	_, ok := s.revoked.Load(sessionID)
+	return ok, nil
+}
+
+func (s *sessionsStub) RevokeSession(_ context.Context, sessionID string, _ string, _ time.Time) error {
+	s.revoked.Store(sessionID, struct{}{})
+	return nil
+}
+
+func (s *sessionsStub) ConsumeRefreshToken(_ context.Context, tokenID string, _ string, _ time.Time) (bool, error) {
+	_, used := s.consumed.LoadOrStore(tokenID, struct{}{})
+	return !used, nil
+}
+
+func TestRenewIfExpiringIssuesOnlyAccessToken(t *testing.T) {
+	checker := &sessionsStub{}
+	sess := session{userID: "user-1", sessionID: "session-1"}
+
+	token, err := createToken(sess, "", renewBefore/2)
+	require.NoError(t, err)
+	claims, err := parseToken(token)
+	require.NoError(t, err)
+
+	renewed, pair, err := renewIfExpiring(context.Background(), checker, claims)
+	require.NoError(t, err)
+	require.NotNil(t, pair)
+	assert.Equal(t, sess, renewed)
+	assert.NotEmpty(t, pair.AccessToken)
+	assert.Empty(t, pair.RefreshToken, "renewal must not mint another refresh token")
+
+	w := httptest.NewRecorder()
+	SetSessionCookies(w, *pair)
+	assert.NotEmpty(t, w.Header().Get(AuthTokenHeader))
+	assert.Empty(t, w.Header().Get(RefreshTokenHeader))
+	for _, cookie := range w.Result().Cookies() {
+		assert.NotEqual(t, RefreshCookieName, cookie.Name)
+	}
+
+	require.NoError(t, checker.RevokeSession(context.Background(), sess.sessionID, sess.userID, time.Now()))
+	_, _, err = renewIfExpiring(context.Background(), checker, claims)
+	assert.ErrorIs(t, err, ErrSessionRevoked)
+}
+
+func TestGetUserIDChecksSession(t *testing.T) {
+	checker := &sessionsStub{}
+	sess := session{userID: "user-1", sessionID: "session-1"}
+
+	token, err := createToken(sess, "", accessTokenExp)
+	require.NoError(t, err)
+	refreshToken, err := createToken(sess, tokenTypeRefresh, RefreshTokenExp)
+	require.NoError(t, err)
+
+	userID, err := GetUserID(context.Background(), checker, token)
+	require.NoError(t, err)
+	assert.Equal(t, sess.userID, userID)
+
+	_, err = GetUserID(context.Background(), checker, refreshToken)
+	require.ErrorIs(t, err, ErrInvalidToken)
+
+	_, err = GetUserID(context.Background(), checker, "not-a-token")
+	require.ErrorIs(t, err, ErrInvalidToken)
+
+	require.NoError(t, checker.RevokeSession(context.Background(), sess.sessionID, sess.userID, time.Now()))
+	_, err = GetUserID(context.Background(), checker, token)
+	assert.ErrorIs(t, err, ErrSessionRevoked)
+}
@@ -17,6 +17,15 @@ func (w *gzipResponseWriter) Write(data []byte) (int, error) {
 	return w.Writer.Write(data) //nolint:wrapcheck // reimplement the interface and do not want to wrap the error
 }
 
+// FlushError выталкивает накопленные в gzip данные клиенту; без этого не работают потоковые ответы.
+func (w *gzipResponseWriter) FlushError() error {
+	if err := w.Writer.Flush(); err != nil {
+		return err //nolint:wrapcheck // reimplement the interface and do not want to wrap the error
+	}
+
+	return http.NewResponseController(w.ResponseWriter).Flush() //nolint:wrapcheck // same as above
+}
+
 func WithGzip(logger *zap.Logger) func(h http.Handler) http.Handler {
 	return func(h http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -24,14 +33,14 @@ func WithGzip(logger *zap.Logger) func(h http.Handler) http.Handler {
 				gzReader, err := gzip.NewReader(r.Body)
 
 				if err != nil {
-					logger.Error("Cannot create gzip reader", zap.Error(err))
+					requestLogger(r, logger).Error("Cannot create gzip reader", zap.Error(err))
 					http.Error(w, "", http.StatusInternalServerError)
 					return
 				}
 
 				defer func() {
 					if err := gzReader.Close(); err != nil {
-						logger.Error("Cannot close gzip reader")
+						requestLogger(r, logger).Error("Cannot close gzip reader")
 					}
 				}()
 
@@ -43,7 +52,7 @@ func WithGzip(logger *zap.Logger) func(h http.Handler) http.Handler {
 
 				defer func() {
 					if err := gzWriter.Close(); err != nil {
-						logger.Error("Cannot close gzip writer")
+						requestLogger(r, logger).Error("Cannot close gzip writer")
 					}
 				}()
 
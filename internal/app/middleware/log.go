@@ -31,6 +31,11 @@ func (r *loggingResponseWriter) WriteHeader(statusCode int) {
 	r.responseData.status = statusCode
 }
 
+// Unwrap даёт http.ResponseController добраться до исходного writer, например для Flush.
+func (r *loggingResponseWriter) Unwrap() http.ResponseWriter {
+	return r.ResponseWriter
+}
+
 func WithLogging(logger *zap.Logger) func(h http.Handler) http.Handler {
 	return func(h http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -53,7 +58,7 @@ func WithLogging(logger *zap.Logger) func(h http.Handler) http.Handler {
 				lrw.responseData.status = http.StatusOK
 			}
 
-			logger.Info("Request",
+			requestLogger(r, logger).Info("Request",
 				zap.String("method", r.Method),
 				zap.String("uri", r.RequestURI),
 				zap.Int("status", lrw.responseData.status),
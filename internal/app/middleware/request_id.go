@@ -0,0 +1,71 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"github.com/a-bondar/go-url-shortener/internal/app/logger"
+	"github.com/a-bondar/go-url-shortener/internal/app/tracing"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+)
+
+const (
+	RequestIDHeader    = "X-Request-ID"
+	maxRequestIDLength = 128
+)
+
+func GetRequestIDFromContext(ctx context.Context) (string, error) {
+	requestID, ok := ctx.Value(requestIDKey).(string)
+	if !ok {
+		return "", errors.New("request ID not found in context")
+	}
+
+	return requestID, nil
+}
+
+// WithRequestID берёт идентификатор запроса из X-Request-ID или генерирует новый,
+// возвращает его клиенту и кладёт в контекст логгер с request_id и trace_id,
+// чтобы все слои писали строки, которые можно связать между собой.
+func WithRequestID(l *zap.Logger) func(h http.Handler) http.Handler {
+	return func(h http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			requestID := r.Header.Get(RequestIDHeader)
+			if !isValidRequestID(requestID) {
+				requestID = uuid.NewString()
+			}
+
+			w.Header().Set(RequestIDHeader, requestID)
+
+			fields := []zap.Field{zap.String("request_id", requestID)}
+			if sc := tracing.SpanContextFromContext(r.Context()); sc.IsValid() {
+				fields = append(fields, zap.String("trace_id", sc.TraceID))
+			}
+
+			ctx := context.WithValue(r.Context(), requestIDKey, requestID)
+			ctx = logger.WithContext(ctx, l.With(fields...))
+
+			h.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+// isValidRequestID не пропускает в логи пустые, слишком длинные и непечатные значения.
+func isValidRequestID(requestID string) bool {
+	if requestID == "" || len(requestID) > maxRequestIDLength {
+		return false
+	}
+
+	for _, c := range requestID {
+		if c <= ' ' || c > '~' {
+			return false
+		}
+	}
+
+	return true
+}
+
+func requestLogger(r *http.Request, fallback *zap.Logger) *zap.Logger {
+	return logger.FromContext(r.Context(), fallback)
+}
@@ -0,0 +1,50 @@
+package middleware
+
+import (
+	"net/http"
+
+	"github.com/a-bondar/go-url-shortener/internal/app/tracing"
+	"github.com/go-chi/chi/v5"
+)
+
+type statusResponseWriter struct {
+	http.ResponseWriter
+	status int
+}
+
+func (w *statusResponseWriter) WriteHeader(statusCode int) {
+	w.ResponseWriter.WriteHeader(statusCode)
+	w.status = statusCode
+}
+
+func (w *statusResponseWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// WithTracing открывает серверный спан на каждый запрос, продолжая трассу
+// из заголовка traceparent, если клиент его прислал.
+func WithTracing() func(h http.Handler) http.Handler {
+	return func(h http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ctx := tracing.Extract(r.Context(), r.Header)
+			ctx, span := tracing.Start(ctx, r.Method)
+			defer span.End()
+
+			span.SetAttribute("http.method", r.Method)
+			span.SetAttribute("http.target", r.URL.Path)
+
+			sw := &statusResponseWriter{ResponseWriter: w, status: http.StatusOK}
+			h.ServeHTTP(sw, r.WithContext(ctx))
+
+			// Шаблон маршрута известен только после того, как chi выбрал обработчик.
+			if rctx := chi.RouteContext(r.Context()); rctx != nil {
+				if pattern := rctx.RoutePattern(); pattern != "" {
+					span.SetName(r.Method + " " + pattern)
+					span.SetAttribute("http.route", pattern)
+				}
+			}
+
+			span.SetAttribute("http.status_code", sw.status)
+		})
+	}
+}
@@ -0,0 +1,52 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/a-bondar/go-url-shortener/internal/app/models"
+	"github.com/go-chi/chi/v5"
+	"go.uber.org/zap"
+)
+
+// WorkspaceRoles сообщает роль пользователя в рабочем пространстве; пустая строка — не участник.
+type WorkspaceRoles interface {
+	GetWorkspaceRole(ctx context.Context, workspaceID string, userID string) (string, error)
+}
+
+// WithWorkspace пускает к ссылкам пространства из параметра маршрута workspaceID
+// участников с ролью не ниже required. Дальше по цепочке владельцем ссылок
+// в контексте выступает само пространство, поэтому обычные обработчики ссылок
+// работают с ним без изменений.
+func WithWorkspace(logger *zap.Logger, roles WorkspaceRoles, required string) func(h http.Handler) http.Handler {
+	return func(h http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			userID, err := GetUserIDFromContext(r.Context())
+			if err != nil {
+				requestLogger(r, logger).Error("Cannot get userID from context", zap.Error(err))
+				http.Error(w, "", http.StatusInternalServerError)
+				return
+			}
+
+			workspaceID := chi.URLParam(r, "workspaceID")
+			role, err := roles.GetWorkspaceRole(r.Context(), workspaceID, userID)
+			if err != nil {
+				requestLogger(r, logger).Error("Cannot get workspace role", zap.Error(err))
+				http.Error(w, "", http.StatusInternalServerError)
+				return
+			}
+
+			switch {
+			case role == "":
+				http.Error(w, "workspace not found", http.StatusNotFound)
+				return
+			case !models.RoleAllows(role, required):
+				http.Error(w, "forbidden", http.StatusForbidden)
+				return
+			}
+
+			ctx := context.WithValue(r.Context(), userIDKey, workspaceID)
+			h.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
@@ -1,7 +1,149 @@
 package models
 
+import (
+	"encoding/json"
+	"net/netip"
+	"net/url"
+	"time"
+)
+
+type LinkMeta struct {
+	Title string   `json:"title,omitempty"`
+	Tags  []string `json:"tags,omitempty"`
+	Note  string   `json:"note,omitempty"`
+}
+
+type LinkMetaPatch struct {
+	Title *string   `json:"title"`
+	Tags  *[]string `json:"tags"`
+	Note  *string   `json:"note"`
+}
+
+// ShortenOptions — необязательные настройки переадресации, задаваемые при создании ссылки.
+type ShortenOptions struct {
+	Password string `json:"password,omitempty"`
+	// Domain — один из настроенных доменов; пусто — основной домен сервиса.
+	Domain string `json:"domain,omitempty"`
+	// QueryParams и UTM добавляются к адресу назначения при каждом переходе.
+	QueryParams map[string]string `json:"query_params,omitempty"`
+	UTM         *UTMParams        `json:"utm,omitempty"`
+	// Targets делят трафик ссылки между адресами пропорционально весам; Sticky закрепляет
+	// за посетителем однажды выбранный адрес.
+	Targets []LinkTarget `json:"targets,omitempty"`
+	Sticky  bool         `json:"sticky,omitempty"`
+	// Rules переопределяют адрес назначения для отдельных устройств, языков и стран.
+	Rules []RoutingRule `json:"rules,omitempty"`
+	// MaxClicks — сколько раз можно перейти по ссылке; 0 — без ограничения.
+	MaxClicks int `json:"max_clicks,omitempty"`
+	// NotBefore — время, с которого ссылка начинает переадресовывать.
+	NotBefore *time.Time `json:"not_before,omitempty"`
+}
+
+// RoutingRule ведёт на URL посетителей, подходящих под все заданные условия.
+// Правила проверяются по порядку, срабатывает первое подходящее.
+type RoutingRule struct {
+	// Platform — одна из констант Platform*.
+	Platform string `json:"platform,omitempty"`
+	// Language — основной язык из Accept-Language: "de" подходит и для "de-AT".
+	Language string `json:"language,omitempty"`
+	// Country — код страны ISO 3166-1 alpha-2, определяется по IP через базу GeoIP.
+	Country string `json:"country,omitempty"`
+	URL     string `json:"url"`
+}
+
+// Платформы посетителя, определяемые по User-Agent.
+const (
+	PlatformIOS     = "ios"
+	PlatformAndroid = "android"
+	PlatformWindows = "windows"
+	PlatformMacOS   = "macos"
+	PlatformLinux   = "linux"
+)
+
+// LinkTarget — один из адресов A/B-ссылки.
+type LinkTarget struct {
+	URL    string `json:"url"`
+	Weight int    `json:"weight"`
+	Clicks int64  `json:"clicks"`
+}
+
+// UTMParams — метки кампании; непустые поля превращаются в параметры utm_*.
+type UTMParams struct {
+	Source   string `json:"source,omitempty"`
+	Medium   string `json:"medium,omitempty"`
+	Campaign string `json:"campaign,omitempty"`
+	Term     string `json:"term,omitempty"`
+	Content  string `json:"content,omitempty"`
+}
+
+// LinkOptions — настройки переадресации в том виде, в котором их хранит хранилище.
+type LinkOptions struct {
+	PasswordHash string `json:"password_hash,omitempty"`
+	// Domain — пространство имён короткого кода; пусто — основной домен.
+	Domain string `json:"domain,omitempty"`
+	// QueryParams — параметры по умолчанию для адреса назначения, включая utm_*.
+	QueryParams map[string]string `json:"query_params,omitempty"`
+	Targets     []LinkTarget      `json:"targets,omitempty"`
+	Sticky      bool              `json:"sticky,omitempty"`
+	Rules       []RoutingRule     `json:"rules,omitempty"`
+	// MaxClicks — ограничение переходов (0 — нет), ClicksLeft — сколько переходов осталось.
+	MaxClicks  int        `json:"max_clicks,omitempty"`
+	ClicksLeft int        `json:"clicks_left,omitempty"`
+	NotBefore  *time.Time `json:"not_before,omitempty"`
+}
+
+// Visit — то, что известно о переходе по короткой ссылке.
+type Visit struct {
+	Password string
+	// Host — домен, на который пришёл посетитель; по нему выбирается пространство имён ссылок.
+	Host string
+	// Query — параметры из адреса короткой ссылки, которые можно передать дальше.
+	Query url.Values
+	// Variant — ранее назначенный посетителю адрес A/B-ссылки (с 1), 0 — не назначен.
+	Variant int
+	// UserAgent, AcceptLanguage и IP нужны для правил переадресации ссылки.
+	UserAgent      string
+	AcceptLanguage string
+	IP             netip.Addr
+}
+
+// Redirect — куда перенаправить посетителя.
+type Redirect struct {
+	URL string
+	// Variant — выбранный адрес A/B-ссылки (с 1), 0 — у ссылки один адрес.
+	Variant int
+	Sticky  bool
+	Link    LinkKey
+}
+
+// LinkKey однозначно определяет ссылку: короткие коды уникальны только в пределах домена.
+type LinkKey struct {
+	Domain   string
+	ShortURL string
+}
+
+// LiveClick — переход по ссылке в потоке /live.
+type LiveClick struct {
+	At          time.Time `json:"at"`
+	Destination string    `json:"destination"`
+	Variant     int       `json:"variant,omitempty"`
+	Referrer    string    `json:"referrer,omitempty"`
+}
+
+// DestinationVerdict — решение политики адресов назначения о конкретном адресе.
+type DestinationVerdict struct {
+	Blocked bool   `json:"blocked"`
+	Reason  string `json:"reason,omitempty"`
+}
+
 type HandleShortenRequest struct {
 	URL string `json:"url"`
+	LinkMeta
+	ShortenOptions
+}
+
+type HandleUpdateURLRequest struct {
+	URL string `json:"url"`
 }
 
 type HandleShortenResponse struct {
@@ -28,11 +170,368 @@ type Data struct {
 	OriginalURL string `json:"original_url"`
 	UserID      string `json:"user_id"`
 	Deleted     bool   `json:"deleted"`
+	LinkMeta
+	LinkOptions
+	DeletedAt *time.Time    `json:"deleted_at,omitempty"`
+	Revisions []URLRevision `json:"revisions,omitempty"`
+}
+
+// URLRevision — предыдущий адрес назначения короткой ссылки.
+type URLRevision struct {
+	OriginalURL string    `json:"original_url"`
+	ChangedAt   time.Time `json:"changed_at"`
+}
+
+type URLsFilter struct {
+	Tag     string
+	Deleted bool
+}
+
+// LinkSearch — отбор ссылок всех владельцев для администрирования; пустые поля не ограничивают выборку.
+type LinkSearch struct {
+	UserID   string
+	ShortURL string
+	// Destination — подстрока адреса назначения.
+	Destination    string
+	IncludeDeleted bool
+	// Limit — 0 без ограничения.
+	Limit int
+	// After продолжает постраничное чтение: отбираются ссылки после этой позиции.
+	After *LinkCursor
+}
+
+// LinkCursor — позиция ссылки в выдаче SearchURLs, упорядоченной по домену, коду и владельцу.
+type LinkCursor struct {
+	Domain   string
+	ShortURL string
+	UserID   string
+}
+
+// StoreStats — сводка по содержимому хранилища.
+type StoreStats struct {
+	Links            int64 `json:"links"`
+	DeletedLinks     int64 `json:"deleted_links"`
+	Owners           int64 `json:"owners"`
+	Users            int64 `json:"users"`
+	APIKeys          int64 `json:"api_keys"`
+	Workspaces       int64 `json:"workspaces"`
+	Webhooks         int64 `json:"webhooks"`
+	QueuedDeliveries int64 `json:"queued_deliveries"`
+	DeadDeliveries   int64 `json:"dead_deliveries"`
+}
+
+// Виды записей при переносе данных между хранилищами.
+const (
+	RecordUsers      = "users"
+	RecordAPIKeys    = "api_keys"
+	RecordSessions   = "revoked_sessions"
+	RecordWorkspaces = "workspaces"
+	RecordMembers    = "workspace_members"
+	RecordLinks      = "links"
+	RecordWebhooks   = "webhooks"
+	RecordDeliveries = "webhook_deliveries"
+)
+
+// RecordKinds — все виды записей в порядке переноса: сначала те, на которые ссылаются остальные.
+var RecordKinds = []string{
+	RecordUsers, RecordAPIKeys, RecordSessions, RecordWorkspaces, RecordMembers,
+	RecordLinks, RecordWebhooks, RecordDeliveries,
+}
+
+// Record — одна запись хранилища при переносе; заполнено поле, соответствующее виду.
+type Record struct {
+	User      *User            `json:"user,omitempty"`
+	APIKey    *APIKey          `json:"api_key,omitempty"`
+	Session   *RevokedSession  `json:"session,omitempty"`
+	Workspace *Workspace       `json:"workspace,omitempty"`
+	Member    *WorkspaceMember `json:"member,omitempty"`
+	Link      *Data            `json:"link,omitempty"`
+	Webhook   *Webhook         `json:"webhook,omitempty"`
+	Delivery  *WebhookDelivery `json:"delivery,omitempty"`
+}
+
+// RecordPage — порция записей одного вида. Next — курсор следующей порции, пустой после последней.
+type RecordPage struct {
+	Records []Record
+	Next    string
+}
+
+// RecordsWriteResult — итог записи порции. Present — записи, которые уже были в хранилище,
+// например после прерванного переноса; Conflicts — записи, чей ключ занят другими данными.
+type RecordsWriteResult struct {
+	Written   int
+	Present   int
+	Conflicts []Record
 }
 
 type URLsPair struct {
 	ShortURL    string `json:"short_url"`
 	OriginalURL string `json:"original_url"`
+	LinkMeta
+	QueryParams map[string]string `json:"query_params,omitempty"`
+	Targets     []LinkTarget      `json:"targets,omitempty"`
+	Rules       []RoutingRule     `json:"rules,omitempty"`
+	MaxClicks   int               `json:"max_clicks,omitempty"`
+	// ClicksLeft задан только для ссылок с ограничением переходов.
+	ClicksLeft *int       `json:"clicks_left,omitempty"`
+	NotBefore  *time.Time `json:"not_before,omitempty"`
+	Protected  bool       `json:"protected,omitempty"`
+	DeletedAt  *time.Time `json:"deleted_at,omitempty"`
 }
 
 type HandleUserURLsResponse []URLsPair
+
+// LinkStats — статистика переходов по ссылке с разбивкой по адресам A/B-ссылки.
+type LinkStats struct {
+	ShortURL    string       `json:"short_url"`
+	OriginalURL string       `json:"original_url"`
+	Targets     []LinkTarget `json:"targets"`
+}
+
+type ComponentStatus struct {
+	Status string `json:"status"`
+	Error  string `json:"error,omitempty"`
+}
+
+type Readiness struct {
+	Status     string                     `json:"status"`
+	Components map[string]ComponentStatus `json:"components"`
+}
+
+const (
+	ScopeLinksRead      = "links:read"
+	ScopeLinksWrite     = "links:write"
+	ScopeKeysManage     = "keys:manage"
+	ScopeWebhooksManage = "webhooks:manage"
+)
+
+type User struct {
+	ID        string    `json:"id"`
+	Name      string    `json:"name"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// APIKey хранится только в виде хеша: сам ключ показывается один раз при создании.
+type APIKey struct {
+	ID        string     `json:"id"`
+	UserID    string     `json:"user_id"`
+	Name      string     `json:"name"`
+	Prefix    string     `json:"prefix"`
+	KeyHash   string     `json:"key_hash"`
+	Scopes    []string   `json:"scopes"`
+	CreatedAt time.Time  `json:"created_at"`
+	RevokedAt *time.Time `json:"revoked_at,omitempty"`
+}
+
+type HandleRegisterRequest struct {
+	Name string `json:"name"`
+}
+
+type HandleCreateAPIKeyRequest struct {
+	Name   string   `json:"name"`
+	Scopes []string `json:"scopes"`
+}
+
+type HandleAPIKeyResponse struct {
+	ID        string     `json:"id"`
+	Name      string     `json:"name"`
+	Prefix    string     `json:"prefix"`
+	Scopes    []string   `json:"scopes"`
+	CreatedAt time.Time  `json:"created_at"`
+	RevokedAt *time.Time `json:"revoked_at,omitempty"`
+	Key       string     `json:"key,omitempty"`
+}
+
+type HandleRegisterResponse struct {
+	UserID string               `json:"user_id"`
+	Name   string               `json:"name"`
+	APIKey HandleAPIKeyResponse `json:"api_key"`
+}
+
+type HandleClaimRequest struct {
+	Token string `json:"token"`
+}
+
+type HandleClaimResponse struct {
+	Claimed int64 `json:"claimed"`
+}
+
+// RevokedSession — отозванная сессия. Запись нужна, пока не истёк последний refresh-токен сессии.
+type RevokedSession struct {
+	ID        string    `json:"id"`
+	UserID    string    `json:"user_id"`
+	RevokedAt time.Time `json:"revoked_at"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+type HandleRefreshRequest struct {
+	RefreshToken string `json:"refresh_token"`
+}
+
+type TokenPair struct {
+	AccessToken     string    `json:"access_token"`
+	RefreshToken    string    `json:"refresh_token"`
+	AccessExpiresAt time.Time `json:"access_expires_at"`
+}
+
+// Роли участников рабочего пространства, от младшей к старшей.
+const (
+	RoleViewer = "viewer"
+	RoleEditor = "editor"
+	RoleOwner  = "owner"
+)
+
+var roleRanks = map[string]int{RoleViewer: 1, RoleEditor: 2, RoleOwner: 3}
+
+// IsKnownRole сообщает, существует ли такая роль.
+func IsKnownRole(role string) bool {
+	_, ok := roleRanks[role]
+
+	return ok
+}
+
+// RoleAllows сообщает, даёт ли роль права не ниже требуемой.
+func RoleAllows(role string, required string) bool {
+	return IsKnownRole(role) && roleRanks[role] >= roleRanks[required]
+}
+
+// Workspace — общее пространство команды. Ссылки пространства хранятся
+// с его ID на месте user_id, поэтому переживают уход любого участника.
+type Workspace struct {
+	ID        string    `json:"id"`
+	Name      string    `json:"name"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type WorkspaceMember struct {
+	WorkspaceID string    `json:"workspace_id"`
+	UserID      string    `json:"user_id"`
+	Role        string    `json:"role"`
+	AddedAt     time.Time `json:"added_at"`
+}
+
+type HandleCreateWorkspaceRequest struct {
+	Name string `json:"name"`
+}
+
+type HandleWorkspaceResponse struct {
+	ID        string    `json:"id"`
+	Name      string    `json:"name"`
+	Role      string    `json:"role"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type HandleSetMemberRequest struct {
+	Role string `json:"role"`
+}
+
+// HandleTransferRequest переносит ссылки из личного пространства (или FromWorkspaceID)
+// ровно одному получателю: пользователю или рабочему пространству.
+type HandleTransferRequest struct {
+	URLs            []string `json:"urls"`
+	FromWorkspaceID string   `json:"from_workspace_id,omitempty"`
+	ToUserID        string   `json:"to_user_id,omitempty"`
+	ToWorkspaceID   string   `json:"to_workspace_id,omitempty"`
+	// Domain — домен ссылок из параметра ?domain=, как у остальных операций со ссылками.
+	Domain string `json:"-"`
+}
+
+type HandleTransferResponse struct {
+	Transferred int64 `json:"transferred"`
+}
+
+// События жизненного цикла ссылок, на которые можно подписать вебхук.
+const (
+	EventLinkCreated = "link.created"
+	EventLinkDeleted = "link.deleted"
+	EventLinkClicked = "link.clicked"
+)
+
+// Webhook — адрес, на который отправляются события ссылок пользователя.
+// Secret хранится открыто: им подписывается каждая отправка.
+type Webhook struct {
+	ID        string    `json:"id"`
+	UserID    string    `json:"user_id"`
+	URL       string    `json:"url"`
+	Events    []string  `json:"events"`
+	Secret    string    `json:"secret"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// WebhookDelivery — событие в исходящей очереди одного вебхука.
+type WebhookDelivery struct {
+	ID            string          `json:"id"`
+	WebhookID     string          `json:"webhook_id"`
+	Event         string          `json:"event"`
+	Payload       json.RawMessage `json:"payload"`
+	Attempts      int             `json:"attempts"`
+	NextAttemptAt time.Time       `json:"next_attempt_at"`
+	LastError     string          `json:"last_error,omitempty"`
+	// Dead — попытки исчерпаны, доставка ждёт ручного повтора.
+	Dead      bool      `json:"dead"`
+	CreatedAt time.Time `json:"created_at"`
+	// URL и Secret заполняются из вебхука, когда доставка выбирается на отправку.
+	URL    string `json:"-"`
+	Secret string `json:"-"`
+}
+
+// WebhookEvent — тело запроса, которое получает вебхук.
+type WebhookEvent struct {
+	ID        string    `json:"id"`
+	Type      string    `json:"type"`
+	CreatedAt time.Time `json:"created_at"`
+	Data      any       `json:"data"`
+}
+
+// LinkEventData — данные событий link.created и link.clicked.
+type LinkEventData struct {
+	ShortURL    string `json:"short_url"`
+	OriginalURL string `json:"original_url"`
+	// Destination — куда на самом деле отправлен посетитель при link.clicked.
+	Destination string `json:"destination,omitempty"`
+}
+
+// LinksDeletedEventData — данные события link.deleted.
+type LinksDeletedEventData struct {
+	Domain string   `json:"domain,omitempty"`
+	Codes  []string `json:"codes"`
+}
+
+// Остальные изменения ссылок попадают только в поток событий outbox.
+const (
+	EventLinkUpdated  = "link.updated"
+	EventLinkRestored = "link.restored"
+	EventLinkPurged   = "link.purged"
+)
+
+// LinkEvent — запись outbox об изменении ссылки. ID растёт монотонно и служит курсором потока.
+type LinkEvent struct {
+	ID          int64     `json:"id"`
+	Type        string    `json:"type"`
+	ShortURL    string    `json:"short_url"`
+	Domain      string    `json:"domain,omitempty"`
+	UserID      string    `json:"user_id,omitempty"`
+	OriginalURL string    `json:"original_url"`
+	Deleted     bool      `json:"deleted"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+type HandleEventsResponse struct {
+	Events []LinkEvent `json:"events"`
+	// Next — значение after для следующего запроса.
+	Next int64 `json:"next"`
+}
+
+type HandleWebhookRequest struct {
+	URL    string   `json:"url"`
+	Events []string `json:"events"`
+}
+
+// HandleWebhookResponse показывает секрет только при создании вебхука.
+type HandleWebhookResponse struct {
+	ID        string    `json:"id"`
+	URL       string    `json:"url"`
+	Events    []string  `json:"events"`
+	Secret    string    `json:"secret,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
@@ -0,0 +1,74 @@
+package policy
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+
+	"github.com/a-bondar/go-url-shortener/internal/app/models"
+	"go.uber.org/zap"
+)
+
+// Checker — внешняя проверка репутации адреса (safe-browsing и подобные сервисы).
+// Ошибка означает, что проверить адрес не удалось, а не что он запрещён.
+type Checker interface {
+	Check(ctx context.Context, u *url.URL) (models.DestinationVerdict, error)
+}
+
+// HTTPChecker спрашивает сервис репутации: POST {"url": "..."} на endpoint,
+// в ответ ожидается {"blocked": true, "reason": "..."}.
+type HTTPChecker struct {
+	endpoint string
+	client   *http.Client
+	logger   *zap.Logger
+}
+
+func NewHTTPChecker(endpoint string, client *http.Client, logger *zap.Logger) *HTTPChecker {
+	return &HTTPChecker{
+		endpoint: endpoint,
+		client:   client,
+		logger:   logger,
+	}
+}
+
+type checkRequest struct {
+	URL string `json:"url"`
+}
+
+func (c *HTTPChecker) Check(ctx context.Context, u *url.URL) (models.DestinationVerdict, error) {
+	body, err := json.Marshal(checkRequest{URL: u.String()})
+	if err != nil {
+		return models.DestinationVerdict{}, fmt.Errorf("failed to marshal check request: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.endpoint, bytes.NewReader(body))
+	if err != nil {
+		return models.DestinationVerdict{}, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return models.DestinationVerdict{}, fmt.Errorf("failed to reach reputation checker: %w", err)
+	}
+
+	defer func() {
+		if err := resp.Body.Close(); err != nil {
+			c.logger.Error("Failed to close response body", zap.Error(err))
+		}
+	}()
+
+	if resp.StatusCode != http.StatusOK {
+		return models.DestinationVerdict{}, fmt.Errorf("reputation checker responded with status %d", resp.StatusCode)
+	}
+
+	var verdict models.DestinationVerdict
+	if err = json.NewDecoder(resp.Body).Decode(&verdict); err != nil {
+		return models.DestinationVerdict{}, fmt.Errorf("failed to decode reputation checker response: %w", err)
+	}
+
+	return verdict, nil
+}
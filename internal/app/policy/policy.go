@@ -0,0 +1,283 @@
+// Package policy решает, на какие адреса можно вести короткие ссылки.
+//
+// Правила читаются из файла построчно, пустые строки и строки с # пропускаются:
+//
+//	block evil.example        # домен и все его поддомены запрещены
+//	allow example.com         # если есть хоть одно allow, прочие домены запрещены
+//	regex ^https?://[^/]+/wp- # запрещены адреса, совпадающие с выражением
+//
+// Файл перечитывается при изменении, см. Policy.Watch.
+package policy
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/a-bondar/go-url-shortener/internal/app/models"
+	"go.uber.org/zap"
+)
+
+const (
+	ruleBlock = "block"
+	ruleAllow = "allow"
+	ruleRegex = "regex"
+
+	verdictCacheTTL  = 10 * time.Minute
+	verdictCacheSize = 10000
+)
+
+type rules struct {
+	blocked  []string
+	allowed  []string
+	patterns []*regexp.Regexp
+}
+
+type cachedVerdict struct {
+	verdict   models.DestinationVerdict
+	expiresAt time.Time
+}
+
+// Policy проверяет адреса по правилам из файла и, если задан, внешним Checker.
+type Policy struct {
+	path    string
+	checker Checker
+	logger  *zap.Logger
+
+	mu      sync.RWMutex
+	rules   rules
+	modTime time.Time
+
+	cacheMu  sync.Mutex
+	verdicts map[string]cachedVerdict
+}
+
+// New загружает правила из path (пустой путь — без правил) и подключает checker (может быть nil).
+func New(path string, checker Checker, logger *zap.Logger) (*Policy, error) {
+	p := &Policy{
+		path:     path,
+		checker:  checker,
+		logger:   logger,
+		verdicts: make(map[string]cachedVerdict),
+	}
+
+	if path != "" {
+		if err := p.Reload(); err != nil {
+			return nil, err
+		}
+	}
+
+	return p, nil
+}
+
+// Reload перечитывает файл правил. При ошибке остаются прежние правила.
+func (p *Policy) Reload() error {
+	info, err := os.Stat(p.path)
+	if err != nil {
+		return fmt.Errorf("failed to stat policy file: %w", err)
+	}
+
+	loaded, err := loadRules(p.path)
+	if err != nil {
+		return err
+	}
+
+	p.mu.Lock()
+	p.rules = loaded
+	p.modTime = info.ModTime()
+	p.mu.Unlock()
+
+	p.logger.Info("Destination policy loaded",
+		zap.String("path", p.path),
+		zap.Int("blocked", len(loaded.blocked)),
+		zap.Int("allowed", len(loaded.allowed)),
+		zap.Int("patterns", len(loaded.patterns)))
+
+	return nil
+}
+
+// Watch раз в interval проверяет время изменения файла и перечитывает его, пока не отменён ctx.
+func (p *Policy) Watch(ctx context.Context, interval time.Duration) {
+	if p.path == "" {
+		return
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			info, err := os.Stat(p.path)
+			if err != nil {
+				p.logger.Error("Failed to stat policy file", zap.Error(err))
+				continue
+			}
+
+			p.mu.RLock()
+			changed := !info.ModTime().Equal(p.modTime)
+			p.mu.RUnlock()
+
+			if !changed {
+				continue
+			}
+
+			if err = p.Reload(); err != nil {
+				p.logger.Error("Failed to reload destination policy, keeping previous rules", zap.Error(err))
+			}
+		}
+	}
+}
+
+// Check сообщает, запрещён ли адрес. Сначала применяются локальные правила,
+// затем внешний Checker. Если Checker недоступен, адрес пропускается:
+// сбой стороннего сервиса не должен ломать создание ссылок и переходы.
+func (p *Policy) Check(ctx context.Context, rawURL string) models.DestinationVerdict {
+	u, err := url.Parse(strings.TrimSpace(rawURL))
+	if err != nil {
+		return models.DestinationVerdict{}
+	}
+
+	if verdict := p.checkRules(u); verdict.Blocked {
+		return verdict
+	}
+
+	if p.checker == nil {
+		return models.DestinationVerdict{}
+	}
+
+	key := u.String()
+	if verdict, ok := p.cached(key); ok {
+		return verdict
+	}
+
+	verdict, err := p.checker.Check(ctx, u)
+	if err != nil {
+		p.logger.Warn("Reputation check failed, allowing destination", zap.String("url", key), zap.Error(err))
+		return models.DestinationVerdict{}
+	}
+
+	p.remember(key, verdict)
+
+	return verdict
+}
+
+func (p *Policy) checkRules(u *url.URL) models.DestinationVerdict {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	host := strings.TrimSuffix(strings.ToLower(u.Hostname()), ".")
+
+	for _, domain := range p.rules.blocked {
+		if matchesDomain(host, domain) {
+			return models.DestinationVerdict{Blocked: true, Reason: fmt.Sprintf("domain %s is blocked", domain)}
+		}
+	}
+
+	if len(p.rules.allowed) > 0 && !anyDomain(host, p.rules.allowed) {
+		return models.DestinationVerdict{Blocked: true, Reason: fmt.Sprintf("domain %s is not allowed", host)}
+	}
+
+	for _, pattern := range p.rules.patterns {
+		if pattern.MatchString(u.String()) {
+			return models.DestinationVerdict{Blocked: true, Reason: "destination matches a blocked pattern"}
+		}
+	}
+
+	return models.DestinationVerdict{}
+}
+
+func (p *Policy) cached(key string) (models.DestinationVerdict, bool) {
+	p.cacheMu.Lock()
+	defer p.cacheMu.Unlock()
+
+	entry, ok := p.verdicts[key]
+	if !ok || time.Now().After(entry.expiresAt) {
+		return models.DestinationVerdict{}, false
+	}
+
+	return entry.verdict, true
+}
+
+// remember кэширует ответ Checker. Кэш не вытесняет записи по одной,
+// а очищается целиком при переполнении: это проще и для такого размера достаточно.
+func (p *Policy) remember(key string, verdict models.DestinationVerdict) {
+	p.cacheMu.Lock()
+	defer p.cacheMu.Unlock()
+
+	if len(p.verdicts) >= verdictCacheSize {
+		p.verdicts = make(map[string]cachedVerdict)
+	}
+
+	p.verdicts[key] = cachedVerdict{verdict: verdict, expiresAt: time.Now().Add(verdictCacheTTL)}
+}
+
+func loadRules(path string) (rules, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return rules{}, fmt.Errorf("failed to read policy file: %w", err)
+	}
+
+	var res rules
+	for i, line := range strings.Split(string(content), "\n") {
+		lineNo := i + 1
+		if comment := strings.Index(line, " #"); comment >= 0 {
+			line = line[:comment]
+		}
+
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		kind, value, ok := strings.Cut(line, " ")
+		value = strings.TrimSpace(value)
+		if !ok || value == "" {
+			return rules{}, fmt.Errorf("policy file line %d: expected \"<rule> <value>\"", lineNo)
+		}
+
+		switch kind {
+		case ruleBlock:
+			res.blocked = append(res.blocked, normalizeDomain(value))
+		case ruleAllow:
+			res.allowed = append(res.allowed, normalizeDomain(value))
+		case ruleRegex:
+			pattern, err := regexp.Compile(value)
+			if err != nil {
+				return rules{}, fmt.Errorf("policy file line %d: %w", lineNo, err)
+			}
+
+			res.patterns = append(res.patterns, pattern)
+		default:
+			return rules{}, fmt.Errorf("policy file line %d: unknown rule %q", lineNo, kind)
+		}
+	}
+
+	return res, nil
+}
+
+func normalizeDomain(domain string) string {
+	return strings.TrimSuffix(strings.TrimPrefix(strings.ToLower(domain), "*."), ".")
+}
+
+// matchesDomain сообщает, совпадает ли host с domain или является его поддоменом.
+func matchesDomain(host string, domain string) bool {
+	return host == domain || strings.HasSuffix(host, "."+domain)
+}
+
+func anyDomain(host string, domains []string) bool {
+	for _, domain := range domains {
+		if matchesDomain(host, domain) {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,232 @@
+package policy
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/a-bondar/go-url-shortener/internal/app/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+// stubChecker запрещает адреса из blocked и считает обращения к себе.
+type stubChecker struct {
+	mu      sync.Mutex
+	blocked map[string]bool
+	err     error
+	calls   int
+}
+
+func (c *stubChecker) Check(_ context.Context, u *url.URL) (models.DestinationVerdict, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.calls++
+	if c.err != nil {
+		return models.DestinationVerdict{}, c.err
+	}
+
+	if c.blocked[u.String()] {
+		return models.DestinationVerdict{Blocked: true, Reason: "phishing"}, nil
+	}
+
+	return models.DestinationVerdict{}, nil
+}
+
+func (c *stubChecker) callCount() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.calls
+}
+
+func writeRules(t *testing.T, path string, content string) {
+	t.Helper()
+
+	require.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+}
+
+func TestLoadRules(t *testing.T) {
+	testCases := []struct {
+		name          string
+		content       string
+		expectedRules int
+		expectedErr   string
+	}{
+		{
+			name: "Rules with comments and blank lines",
+			content: `# destinations
+block Evil.Example.   # trailing dot and case are ignored
+allow *.example.com
+
+regex ^https?://[^/]+/wp-
+`,
+			expectedRules: 3,
+		},
+		{
+			name:        "Rule without value",
+			content:     "block",
+			expectedErr: "policy file line 1",
+		},
+		{
+			name:        "Unknown rule",
+			content:     "# header\ndeny evil.example",
+			expectedErr: `policy file line 2: unknown rule "deny"`,
+		},
+		{
+			name:        "Invalid regex",
+			content:     "regex ([",
+			expectedErr: "policy file line 1",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "policy.txt")
+			writeRules(t, path, tc.content)
+
+			loaded, err := loadRules(path)
+			if tc.expectedErr != "" {
+				assert.ErrorContains(t, err, tc.expectedErr)
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, []string{"evil.example"}, loaded.blocked)
+			assert.Equal(t, []string{"example.com"}, loaded.allowed)
+			assert.Len(t, loaded.patterns, tc.expectedRules-2)
+		})
+	}
+}
+
+func TestPolicyRules(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "policy.txt")
+	writeRules(t, path, `
+block bad.example.com
+allow example.com
+allow example.org
+regex ^https?://[^/]+/wp-
+`)
+
+	p, err := New(path, nil, zap.NewNop())
+	require.NoError(t, err)
+
+	testCases := []struct {
+		url     string
+		blocked bool
+		reason  string
+	}{
+		{url: "https://example.com/page"},
+		{url: "https://www.EXAMPLE.org./page"},
+		{url: "https://bad.example.com/", blocked: true, reason: "domain bad.example.com is blocked"},
+		{url: "https://deep.bad.example.com/", blocked: true, reason: "domain bad.example.com is blocked"},
+		{url: "https://notexample.com/", blocked: true, reason: "domain notexample.com is not allowed"},
+		{url: "https://example.com/wp-admin", blocked: true, reason: "destination matches a blocked pattern"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.url, func(t *testing.T) {
+			verdict := p.Check(context.Background(), tc.url)
+			assert.Equal(t, tc.blocked, verdict.Blocked)
+			assert.Equal(t, tc.reason, verdict.Reason)
+		})
+	}
+}
+
+func TestPolicyChecker(t *testing.T) {
+	checker := &stubChecker{blocked: map[string]bool{"https://phish.example": true}}
+	path := filepath.Join(t.TempDir(), "policy.txt")
+	writeRules(t, path, "block evil.example")
+
+	p, err := New(path, checker, zap.NewNop())
+	require.NoError(t, err)
+
+	ctx := context.Background()
+
+	// Адрес, запрещённый локально, внешнему сервису не отправляется.
+	assert.True(t, p.Check(ctx, "https://evil.example/").Blocked)
+	assert.Zero(t, checker.callCount())
+
+	for range 3 {
+		verdict := p.Check(ctx, "https://phish.example")
+		assert.Equal(t, models.DestinationVerdict{Blocked: true, Reason: "phishing"}, verdict)
+	}
+	assert.Equal(t, 1, checker.callCount(), "verdicts must be cached")
+
+	assert.False(t, p.Check(ctx, "https://fine.example").Blocked)
+	assert.Equal(t, 2, checker.callCount())
+
+	// Сбой сервиса пропускает адрес и не кэшируется.
+	checker.err = errors.New("unavailable")
+	assert.False(t, p.Check(ctx, "https://new.example").Blocked)
+	checker.err = nil
+	p.Check(ctx, "https://new.example")
+	assert.Equal(t, 4, checker.callCount())
+}
+
+func TestPolicyWatchReloadsRules(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "policy.txt")
+	writeRules(t, path, "block first.example")
+
+	p, err := New(path, nil, zap.NewNop())
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		p.Watch(ctx, 10*time.Millisecond)
+	}()
+	defer func() {
+		cancel()
+		<-done
+	}()
+
+	assert.True(t, p.Check(ctx, "https://first.example").Blocked)
+
+	writeRules(t, path, "block second.example")
+	require.NoError(t, os.Chtimes(path, time.Now(), time.Now().Add(time.Minute)))
+
+	assert.Eventually(t, func() bool {
+		return p.Check(ctx, "https://second.example").Blocked
+	}, time.Second, 10*time.Millisecond)
+	assert.False(t, p.Check(ctx, "https://first.example").Blocked)
+
+	// Файл с ошибкой не сбрасывает действующие правила.
+	writeRules(t, path, "deny third.example")
+	require.NoError(t, os.Chtimes(path, time.Now(), time.Now().Add(2*time.Minute)))
+
+	time.Sleep(50 * time.Millisecond)
+	assert.True(t, p.Check(ctx, "https://second.example").Blocked)
+}
+
+func TestHTTPChecker(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/ok":
+			_, _ = w.Write([]byte(`{"blocked": true, "reason": "malware"}`))
+		default:
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer ts.Close()
+
+	u, err := url.Parse("https://malware.example")
+	require.NoError(t, err)
+
+	verdict, err := NewHTTPChecker(ts.URL+"/ok", ts.Client(), zap.NewNop()).Check(context.Background(), u)
+	require.NoError(t, err)
+	assert.Equal(t, models.DestinationVerdict{Blocked: true, Reason: "malware"}, verdict)
+
+	_, err = NewHTTPChecker(ts.URL+"/down", ts.Client(), zap.NewNop()).Check(context.Background(), u)
+	assert.ErrorContains(t, err, "status 503")
+}
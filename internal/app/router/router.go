@@ -3,24 +3,99 @@ package router
 import (
 	"github.com/a-bondar/go-url-shortener/internal/app/handlers"
 	"github.com/a-bondar/go-url-shortener/internal/app/middleware"
+	"github.com/a-bondar/go-url-shortener/internal/app/models"
 	"github.com/go-chi/chi/v5"
 	"go.uber.org/zap"
 )
 
-func Router(h *handlers.Handler, logger *zap.Logger) chi.Router {
+// Access — проверки личности и прав, которые нужны middleware.
+type Access interface {
+	middleware.Authenticator
+	middleware.WorkspaceRoles
+}
+
+func Router(h *handlers.Handler, access Access, logger *zap.Logger) chi.Router {
 	r := chi.NewRouter()
 
+	r.Use(middleware.WithTracing())
+	r.Use(middleware.WithRequestID(logger))
 	r.Use(middleware.WithLogging(logger))
 	r.Use(middleware.WithGzip(logger))
-	r.Use(middleware.WithAuth(logger))
+	r.Use(middleware.WithAuth(logger, access))
 
-	r.Post("/", h.HandlePost)
 	r.Get("/{linkID}", h.HandleGet)
+	r.Post("/{linkID}", h.HandleGet)
 	r.Get("/ping", h.HandleDatabasePing)
-	r.Post("/api/shorten", h.HandleShorten)
-	r.Post("/api/shorten/batch", h.HandleShortenBatch)
-	r.Get("/api/user/urls", h.HandleUserURLs)
-	r.Delete("/api/user/urls", h.HandleDelete)
+	r.Get("/healthz", h.HandleLiveness)
+	r.Get("/readyz", h.HandleReadiness)
+	r.Post("/api/users", h.HandleRegister)
+	r.Post("/api/auth/refresh", h.HandleRefresh)
+	r.Post("/api/auth/logout", h.HandleLogout)
+	r.Get("/api/internal/events", h.HandleEvents)
+
+	r.Group(func(r chi.Router) {
+		r.Use(middleware.RequireScope(models.ScopeLinksWrite))
+
+		r.Post("/", h.HandlePost)
+		r.Post("/api/shorten", h.HandleShorten)
+		r.Post("/api/shorten/batch", h.HandleShortenBatch)
+		r.Delete("/api/user/urls", h.HandleDelete)
+		r.Post("/api/user/urls/restore", h.HandleRestore)
+		r.Patch("/api/user/urls/{linkID}", h.HandleUpdateURL)
+		r.Put("/api/user/urls/{linkID}", h.HandleUpdateDestination)
+		r.Post("/api/user/claim", h.HandleClaim)
+		r.Post("/api/user/urls/transfer", h.HandleTransfer)
+		r.Post("/api/workspaces", h.HandleCreateWorkspace)
+		r.Put("/api/workspaces/{workspaceID}/members/{memberID}", h.HandleSetWorkspaceMember)
+		r.Delete("/api/workspaces/{workspaceID}/members/{memberID}", h.HandleRemoveWorkspaceMember)
+
+		r.Group(func(r chi.Router) {
+			r.Use(middleware.WithWorkspace(logger, access, models.RoleEditor))
+
+			r.Post("/api/workspaces/{workspaceID}/shorten", h.HandleShorten)
+			r.Delete("/api/workspaces/{workspaceID}/urls", h.HandleDelete)
+			r.Post("/api/workspaces/{workspaceID}/urls/restore", h.HandleRestore)
+			r.Patch("/api/workspaces/{workspaceID}/urls/{linkID}", h.HandleUpdateURL)
+			r.Put("/api/workspaces/{workspaceID}/urls/{linkID}", h.HandleUpdateDestination)
+		})
+	})
+
+	r.Group(func(r chi.Router) {
+		r.Use(middleware.RequireScope(models.ScopeLinksRead))
+
+		r.Get("/api/user/urls", h.HandleUserURLs)
+		r.Get("/api/user/urls/{linkID}/revisions", h.HandleURLRevisions)
+		r.Get("/api/user/urls/{linkID}/stats", h.HandleURLStats)
+		r.Get("/api/user/urls/{linkID}/live", h.HandleLiveClicks)
+		r.Get("/api/workspaces", h.HandleWorkspaces)
+		r.Get("/api/workspaces/{workspaceID}/members", h.HandleWorkspaceMembers)
+
+		r.Group(func(r chi.Router) {
+			r.Use(middleware.WithWorkspace(logger, access, models.RoleViewer))
+
+			r.Get("/api/workspaces/{workspaceID}/urls", h.HandleUserURLs)
+			r.Get("/api/workspaces/{workspaceID}/urls/{linkID}/revisions", h.HandleURLRevisions)
+			r.Get("/api/workspaces/{workspaceID}/urls/{linkID}/stats", h.HandleURLStats)
+		})
+	})
+
+	r.Group(func(r chi.Router) {
+		r.Use(middleware.RequireScope(models.ScopeKeysManage))
+
+		r.Get("/api/user/keys", h.HandleAPIKeys)
+		r.Post("/api/user/keys", h.HandleCreateAPIKey)
+		r.Delete("/api/user/keys/{keyID}", h.HandleRevokeAPIKey)
+	})
+
+	r.Group(func(r chi.Router) {
+		r.Use(middleware.RequireScope(models.ScopeWebhooksManage))
+
+		r.Get("/api/user/webhooks", h.HandleWebhooks)
+		r.Post("/api/user/webhooks", h.HandleCreateWebhook)
+		r.Delete("/api/user/webhooks/{webhookID}", h.HandleDeleteWebhook)
+		r.Get("/api/user/webhooks/deliveries/dead", h.HandleDeadDeliveries)
+		r.Post("/api/user/webhooks/deliveries/{deliveryID}/retry", h.HandleRetryDelivery)
+	})
 
 	return r
 }
@@ -1,24 +1,37 @@
 package router
 
 import (
+	"bufio"
 	"bytes"
 	"context"
 	"errors"
 	"fmt"
+	"html/template"
 	"io"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/a-bondar/go-url-shortener/internal/app/handlers"
 	"github.com/a-bondar/go-url-shortener/internal/app/middleware"
 	"github.com/a-bondar/go-url-shortener/internal/app/models"
+	"github.com/a-bondar/go-url-shortener/internal/app/service"
+	"github.com/a-bondar/go-url-shortener/internal/app/store"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	"go.uber.org/zap"
 )
 
-const userID = "12345"
+const (
+	userID            = "12345"
+	viewerWorkspaceID = "ws-viewer"
+	editorWorkspaceID = "ws-editor"
+	brandDomain       = "go.brand.example"
+)
 
 func testRequest(t *testing.T, ts *httptest.Server, method, path string, body io.Reader) (*http.Response, string) {
 	t.Helper()
@@ -52,25 +65,165 @@ func testRequest(t *testing.T, ts *httptest.Server, method, path string, body io
 	return resp, string(respBody)
 }
 
-type serviceMock struct{}
+type serviceMock struct {
+	revokedSessions sync.Map
+	consumedTokens  sync.Map
+}
+
+func (s *serviceMock) SaveURL(
+	_ context.Context, fullURL string, _ string, _ models.LinkMeta, opts models.ShortenOptions) (string, error) {
+	if len(opts.Password) > 72 {
+		return "", service.ErrInvalidPassword
+	}
+
+	if _, ok := opts.QueryParams[""]; ok {
+		return "", service.ErrInvalidQueryParams
+	}
+
+	if opts.Domain != "" && opts.Domain != brandDomain {
+		return "", service.ErrUnknownDomain
+	}
+
+	if fullURL == "https://phish.example" {
+		return "", service.ErrDestinationBlocked
+	}
+
+	if fullURL == "http://localhost:8080/qw12qw" {
+		return "", service.ErrRedirectLoop
+	}
+
+	if fullURL == "https://trashed.example" {
+		return "", fmt.Errorf("failed to save URL: %w", store.ErrURLExists)
+	}
 
-func (s *serviceMock) SaveURL(_ context.Context, _ string, _ string) (string, error) {
 	return "http://localhost:8080/qw12qw", nil
 }
 
-func (s *serviceMock) GetURL(_ context.Context, shortURL string) (string, bool, error) {
-	if shortURL != "qw12qw" {
-		return "", false, errors.New("link not found")
+func (s *serviceMock) ResolveURL(_ context.Context, shortURL string, visit models.Visit) (models.Redirect, error) {
+	switch shortURL {
+	case "qw12qw":
+		if visit.Host == brandDomain {
+			return models.Redirect{URL: "https://brand.example/landing"}, nil
+		}
+
+		return models.Redirect{URL: "https://hello.world"}, nil
+	case "secret":
+		switch visit.Password {
+		case "":
+			return models.Redirect{}, service.ErrPasswordRequired
+		case "open-sesame":
+			return models.Redirect{URL: "https://hello.secret"}, nil
+		}
+
+		return models.Redirect{}, service.ErrWrongPassword
+	case "locked":
+		return models.Redirect{}, &service.AttemptsError{RetryAfter: 30 * time.Second}
+	case "blocked":
+		return models.Redirect{}, service.ErrDestinationBlocked
+	case "launch":
+		return models.Redirect{}, &service.NotYetActiveError{NotBefore: time.Now().Add(time.Hour)}
+	case "used":
+		return models.Redirect{}, service.ErrClicksExhausted
+	case "utm":
+		return models.Redirect{
+			URL:  "https://hello.world/?" + visit.Query.Encode(),
+			Link: models.LinkKey{ShortURL: shortURL},
+		}, nil
+	case "app":
+		switch {
+		case !visit.IP.IsLoopback():
+			return models.Redirect{}, errors.New("visitor IP is not passed")
+		case strings.Contains(visit.UserAgent, "iPhone"):
+			return models.Redirect{URL: "https://apps.apple.com/app"}, nil
+		case strings.HasPrefix(visit.AcceptLanguage, "de"):
+			return models.Redirect{URL: "https://app.example/de"}, nil
+		}
+
+		return models.Redirect{URL: "https://app.example"}, nil
+	case "split":
+		if visit.Variant == 2 {
+			return models.Redirect{URL: "https://b.example", Variant: 2, Sticky: true}, nil
+		}
+
+		return models.Redirect{URL: "https://a.example", Variant: 1, Sticky: true}, nil
 	}
 
-	return "https://hello.world", false, nil
+	return models.Redirect{}, store.ErrURLNotFound
 }
 
-func (s *serviceMock) GetURLs(_ context.Context, _ string) ([]models.URLsPair, error) {
+func (s *serviceMock) GetURLs(_ context.Context, ownerID string, _ models.URLsFilter) ([]models.URLsPair, error) {
+	if ownerID == viewerWorkspaceID {
+		return []models.URLsPair{{ShortURL: "http://localhost:8080/ws12ws", OriginalURL: "https://team.example"}}, nil
+	}
+
 	return nil, nil
 }
 
-func (s *serviceMock) DeleteURLs(_ context.Context, _ []string, _ string) error {
+func (s *serviceMock) UpdateURLMeta(
+	_ context.Context, domain string, shortURL string, _ string, patch models.LinkMetaPatch) (models.URLsPair, error) {
+	if domain != "" || shortURL != "qw12qw" {
+		return models.URLsPair{}, store.ErrURLNotFound
+	}
+
+	res := models.URLsPair{
+		ShortURL:    "http://localhost:8080/qw12qw",
+		OriginalURL: "https://hello.world",
+	}
+	if patch.Title != nil {
+		res.Title = *patch.Title
+	}
+	if patch.Tags != nil {
+		res.Tags = *patch.Tags
+	}
+
+	return res, nil
+}
+
+func (s *serviceMock) UpdateURL(
+	_ context.Context, domain string, shortURL string, _ string, fullURL string) (models.URLsPair, error) {
+	switch {
+	case fullURL == "":
+		return models.URLsPair{}, service.ErrInvalidURL
+	case domain == "" && shortURL == "ab12ab":
+		return models.URLsPair{}, service.ErrSeveralDestinations
+	case domain != "" || shortURL != "qw12qw":
+		return models.URLsPair{}, store.ErrURLNotFound
+	case fullURL == "https://taken.world":
+		return models.URLsPair{}, store.ErrURLExists
+	}
+
+	return models.URLsPair{ShortURL: "http://localhost:8080/qw12qw", OriginalURL: fullURL}, nil
+}
+
+func (s *serviceMock) GetURLRevisions(
+	_ context.Context, domain string, shortURL string, _ string) ([]models.URLRevision, error) {
+	if domain != "" || shortURL != "qw12qw" {
+		return nil, store.ErrURLNotFound
+	}
+
+	return []models.URLRevision{}, nil
+}
+
+func (s *serviceMock) GetURLStats(_ context.Context, _ string, shortURL string, _ string) (models.LinkStats, error) {
+	if shortURL != "split" {
+		return models.LinkStats{}, store.ErrURLNotFound
+	}
+
+	return models.LinkStats{
+		ShortURL:    "http://localhost:8080/split",
+		OriginalURL: "https://a.example",
+		Targets: []models.LinkTarget{
+			{URL: "https://a.example", Weight: 1, Clicks: 3},
+			{URL: "https://b.example", Weight: 1, Clicks: 5},
+		},
+	}, nil
+}
+
+func (s *serviceMock) DeleteURLs(_ context.Context, _ string, _ []string, _ string) error {
+	return nil
+}
+
+func (s *serviceMock) RestoreURLs(_ context.Context, _ string, _ []string, _ string) error {
 	return nil
 }
 
@@ -93,12 +246,180 @@ func (s *serviceMock) Ping(_ context.Context) error {
 	return nil
 }
 
+func (s *serviceMock) CheckReadiness(_ context.Context) (models.Readiness, error) {
+	return models.Readiness{
+		Status:     "ok",
+		Components: map[string]models.ComponentStatus{"store": {Status: "ok"}},
+	}, nil
+}
+
+func (s *serviceMock) RegisterUser(_ context.Context, name string) (models.HandleRegisterResponse, error) {
+	return models.HandleRegisterResponse{UserID: userID, Name: name}, nil
+}
+
+func (s *serviceMock) CreateAPIKey(
+	_ context.Context, _ string, name string, scopes []string) (models.HandleAPIKeyResponse, error) {
+	return models.HandleAPIKeyResponse{Name: name, Scopes: scopes, Key: "usk_new"}, nil
+}
+
+func (s *serviceMock) GetAPIKeys(_ context.Context, _ string) ([]models.HandleAPIKeyResponse, error) {
+	return []models.HandleAPIKeyResponse{}, nil
+}
+
+func (s *serviceMock) RevokeAPIKey(_ context.Context, _ string, _ string) error {
+	return nil
+}
+
+func (s *serviceMock) ClaimURLs(_ context.Context, _ string, _ string) (int64, error) {
+	return 1, nil
+}
+
+func (s *serviceMock) RevokeSession(_ context.Context, sessionID string, _ string, _ time.Time) error {
+	s.revokedSessions.Store(sessionID, struct{}{})
+	return nil
+}
+
+func (s *serviceMock) ConsumeRefreshToken(_ context.Context, tokenID string, _ string, _ time.Time) (bool, error) {
+	_, used := s.consumedTokens.LoadOrStore(tokenID, struct{}{})
+
+	return !used, nil
+}
+
+func (s *serviceMock) IsSessionRevoked(_ context.Context, sessionID string) (bool, error) {
+	_, ok := s.revokedSessions.Load(sessionID)
+	return ok, nil
+}
+
+func (s *serviceMock) CreateWorkspace(
+	_ context.Context, _ string, name string) (models.HandleWorkspaceResponse, error) {
+	return models.HandleWorkspaceResponse{ID: editorWorkspaceID, Name: name, Role: models.RoleOwner}, nil
+}
+
+func (s *serviceMock) GetWorkspaces(_ context.Context, _ string) ([]models.HandleWorkspaceResponse, error) {
+	return []models.HandleWorkspaceResponse{}, nil
+}
+
+func (s *serviceMock) GetWorkspaceMembers(
+	_ context.Context, _ string, workspaceID string) ([]models.WorkspaceMember, error) {
+	if workspaceID != viewerWorkspaceID && workspaceID != editorWorkspaceID {
+		return nil, service.ErrNoWorkspaceAccess
+	}
+
+	return []models.WorkspaceMember{}, nil
+}
+
+func (s *serviceMock) SetWorkspaceMember(
+	_ context.Context, _ string, workspaceID string, memberID string, role string) (models.WorkspaceMember, error) {
+	if workspaceID != editorWorkspaceID {
+		return models.WorkspaceMember{}, service.ErrForbiddenRole
+	}
+
+	return models.WorkspaceMember{WorkspaceID: workspaceID, UserID: memberID, Role: role}, nil
+}
+
+func (s *serviceMock) RemoveWorkspaceMember(_ context.Context, _ string, _ string, _ string) error {
+	return service.ErrLastOwner
+}
+
+func (s *serviceMock) TransferURLs(_ context.Context, _ string, request models.HandleTransferRequest) (int64, error) {
+	if len(request.URLs) == 0 {
+		return 0, service.ErrInvalidTransfer
+	}
+
+	// Ссылки мока есть только на основном домене.
+	if request.Domain != "" {
+		return 0, nil
+	}
+
+	return int64(len(request.URLs)), nil
+}
+
+func (s *serviceMock) CreateWebhook(
+	_ context.Context, _ string, request models.HandleWebhookRequest) (models.HandleWebhookResponse, error) {
+	if len(request.Events) == 0 {
+		return models.HandleWebhookResponse{}, service.ErrInvalidWebhook
+	}
+
+	return models.HandleWebhookResponse{URL: request.URL, Events: request.Events, Secret: "whsec_new"}, nil
+}
+
+func (s *serviceMock) GetWebhooks(_ context.Context, _ string) ([]models.HandleWebhookResponse, error) {
+	return []models.HandleWebhookResponse{}, nil
+}
+
+func (s *serviceMock) DeleteWebhook(_ context.Context, _ string, _ string) error {
+	return store.ErrWebhookNotFound
+}
+
+func (s *serviceMock) GetDeadDeliveries(_ context.Context, _ string) ([]models.WebhookDelivery, error) {
+	return []models.WebhookDelivery{}, nil
+}
+
+func (s *serviceMock) RetryDelivery(_ context.Context, _ string, _ string) error {
+	return nil
+}
+
+// WaitLinkEvents отдаёт два события; после них ждёт wait, как настоящий long-poll.
+func (s *serviceMock) WaitLinkEvents(
+	ctx context.Context, after int64, _ int, wait time.Duration) ([]models.LinkEvent, error) {
+	events := []models.LinkEvent{
+		{ID: 1, Type: models.EventLinkCreated, ShortURL: "abc"},
+		{ID: 2, Type: models.EventLinkDeleted, ShortURL: "abc", Deleted: true},
+	}
+	if after < 0 {
+		return nil, service.ErrInvalidEventsQuery
+	}
+
+	if after < int64(len(events)) {
+		return events[after:], nil
+	}
+
+	select {
+	case <-ctx.Done():
+	case <-time.After(wait):
+	}
+
+	return []models.LinkEvent{}, nil
+}
+
+func (s *serviceMock) GetLinkKey(_ context.Context, _ string, shortURL string, _ string) (models.LinkKey, error) {
+	if shortURL != "utm" {
+		return models.LinkKey{}, store.ErrURLNotFound
+	}
+
+	return models.LinkKey{ShortURL: shortURL}, nil
+}
+
+func (s *serviceMock) GetWorkspaceRole(_ context.Context, workspaceID string, _ string) (string, error) {
+	switch workspaceID {
+	case viewerWorkspaceID:
+		return models.RoleViewer, nil
+	case editorWorkspaceID:
+		return models.RoleEditor, nil
+	}
+
+	return "", nil
+}
+
+func (s *serviceMock) AuthenticateAPIKey(_ context.Context, rawKey string) (models.APIKey, error) {
+	switch rawKey {
+	case "usk_admin":
+		return models.APIKey{UserID: userID, Scopes: []string{
+			models.ScopeLinksRead, models.ScopeLinksWrite, models.ScopeKeysManage, models.ScopeWebhooksManage,
+		}}, nil
+	case "usk_readonly":
+		return models.APIKey{UserID: userID, Scopes: []string{models.ScopeLinksRead}}, nil
+	}
+
+	return models.APIKey{}, service.ErrInvalidAPIKey
+}
+
 func TestRouter(t *testing.T) {
 	logger := zap.NewNop()
 	svc := &serviceMock{}
 	h := handlers.NewHandler(svc, logger)
 
-	ts := httptest.NewServer(Router(h, logger))
+	ts := httptest.NewServer(Router(h, svc, logger))
 	defer ts.Close()
 
 	testCases := []struct {
@@ -141,8 +462,8 @@ func TestRouter(t *testing.T) {
 			expectedCode: http.StatusMethodNotAllowed,
 		},
 		{
-			name:         "POST method on \"/{linkID}\" is not allowed",
-			method:       http.MethodPost,
+			name:         "PUT method on \"/{linkID}\" is not allowed",
+			method:       http.MethodPut,
 			path:         "/121212",
 			expectedCode: http.StatusMethodNotAllowed,
 		},
@@ -154,6 +475,20 @@ func TestRouter(t *testing.T) {
 			expectedCode: http.StatusCreated,
 			expectedBody: `{"result": "http://localhost:8080/qw12qw"}`,
 		},
+		{
+			name:         "Status 409 if URL is held by a trashed link of another owner",
+			method:       http.MethodPost,
+			path:         "/api/shorten",
+			body:         `{"url": "https://trashed.example"}`,
+			expectedCode: http.StatusConflict,
+		},
+		{
+			name:         "Status 409 on plain text shortening of a URL held by a trashed link",
+			method:       http.MethodPost,
+			path:         "/",
+			body:         "https://trashed.example",
+			expectedCode: http.StatusConflict,
+		},
 		{
 			name:   "Status 201 if links was shortened successfully",
 			method: http.MethodPost,
@@ -169,6 +504,53 @@ func TestRouter(t *testing.T) {
 			path:         "/12131kjhjhjk",
 			expectedCode: http.StatusNotFound,
 		},
+		{
+			name:         "Status 451 if link destination is blocked",
+			method:       http.MethodGet,
+			path:         "/blocked",
+			expectedCode: http.StatusUnavailableForLegalReasons,
+		},
+		{
+			name:         "Status 410 if link clicks are exhausted",
+			method:       http.MethodGet,
+			path:         "/used",
+			expectedCode: http.StatusGone,
+		},
+		{
+			name:         "Status 400 if shorten domain is unknown",
+			method:       http.MethodPost,
+			path:         "/api/shorten",
+			body:         `{"url": "https://hello.world", "domain": "evil.example"}`,
+			expectedCode: http.StatusBadRequest,
+		},
+		{
+			name:         "Status 403 if destination is blocked on shorten",
+			method:       http.MethodPost,
+			path:         "/api/shorten",
+			body:         `{"url": "https://phish.example"}`,
+			expectedCode: http.StatusForbidden,
+		},
+		{
+			name:         "Status 400 if destination loops back to the shortener",
+			method:       http.MethodPost,
+			path:         "/",
+			body:         "http://localhost:8080/qw12qw",
+			expectedCode: http.StatusBadRequest,
+		},
+		{
+			name:             "Query string is passed to the service on redirect",
+			method:           http.MethodGet,
+			path:             "/utm?ref=x&q=a%20b",
+			expectedCode:     http.StatusTemporaryRedirect,
+			expectedLocation: "https://hello.world/?q=a+b&ref=x",
+		},
+		{
+			name:         "Status 400 if query params are invalid",
+			method:       http.MethodPost,
+			path:         "/api/shorten",
+			body:         `{"url": "https://hello.world", "query_params": {"": "x"}}`,
+			expectedCode: http.StatusBadRequest,
+		},
 		{
 			name:             "Status 307 if link was found successfully",
 			method:           http.MethodGet,
@@ -176,6 +558,156 @@ func TestRouter(t *testing.T) {
 			expectedCode:     http.StatusTemporaryRedirect,
 			expectedLocation: "https://hello.world",
 		},
+		{
+			name:         "Status 200 if link meta was updated successfully",
+			method:       http.MethodPatch,
+			path:         "/api/user/urls/qw12qw",
+			body:         `{"title": "Hello", "tags": ["docs"]}`,
+			expectedCode: http.StatusOK,
+			expectedBody: `{"short_url": "http://localhost:8080/qw12qw", "original_url": "https://hello.world",
+				"title": "Hello", "tags": ["docs"]}`,
+		},
+		{
+			name:         "Status 404 if updated link doesn't exist",
+			method:       http.MethodPatch,
+			path:         "/api/user/urls/12131kjhjhjk",
+			body:         `{"title": "Hello"}`,
+			expectedCode: http.StatusNotFound,
+		},
+		{
+			name:         "Status 200 if link destination was updated successfully",
+			method:       http.MethodPut,
+			path:         "/api/user/urls/qw12qw",
+			body:         `{"url": "https://new.world"}`,
+			expectedCode: http.StatusOK,
+			expectedBody: `{"short_url": "http://localhost:8080/qw12qw", "original_url": "https://new.world"}`,
+		},
+		{
+			name:         "Status 404 if link is on another domain",
+			method:       http.MethodPut,
+			path:         "/api/user/urls/qw12qw?domain=go.brand.example",
+			body:         `{"url": "https://new.world"}`,
+			expectedCode: http.StatusNotFound,
+		},
+		{
+			name:         "Status 409 if link has several destinations",
+			method:       http.MethodPut,
+			path:         "/api/user/urls/ab12ab",
+			body:         `{"url": "https://new.world"}`,
+			expectedCode: http.StatusConflict,
+		},
+		{
+			name:         "Status 409 if new destination is held by a trashed or foreign link",
+			method:       http.MethodPut,
+			path:         "/api/user/urls/qw12qw",
+			body:         `{"url": "https://taken.world"}`,
+			expectedCode: http.StatusConflict,
+		},
+		{
+			name:         "Status 400 if new destination is empty",
+			method:       http.MethodPut,
+			path:         "/api/user/urls/qw12qw",
+			body:         `{"url": ""}`,
+			expectedCode: http.StatusBadRequest,
+		},
+		{
+			name:         "Status 204 if links were restored",
+			method:       http.MethodPost,
+			path:         "/api/user/urls/restore",
+			body:         `["qw12qw"]`,
+			expectedCode: http.StatusNoContent,
+		},
+		{
+			name:         "Status 400 if restore request is malformed",
+			method:       http.MethodPost,
+			path:         "/api/user/urls/restore",
+			body:         `{"qw12qw": true}`,
+			expectedCode: http.StatusBadRequest,
+		},
+		{
+			name:         "Status 200 if viewer lists workspace links",
+			method:       http.MethodGet,
+			path:         "/api/workspaces/ws-viewer/urls",
+			expectedCode: http.StatusOK,
+			expectedBody: `[{"short_url": "http://localhost:8080/ws12ws", "original_url": "https://team.example"}]`,
+		},
+		{
+			name:         "Status 403 if viewer deletes workspace links",
+			method:       http.MethodDelete,
+			path:         "/api/workspaces/ws-viewer/urls",
+			body:         `["ws12ws"]`,
+			expectedCode: http.StatusForbidden,
+		},
+		{
+			name:         "Status 202 if editor deletes workspace links",
+			method:       http.MethodDelete,
+			path:         "/api/workspaces/ws-editor/urls",
+			body:         `["ws12ws"]`,
+			expectedCode: http.StatusAccepted,
+		},
+		{
+			name:         "Status 404 if user is not a workspace member",
+			method:       http.MethodGet,
+			path:         "/api/workspaces/ws-foreign/urls",
+			expectedCode: http.StatusNotFound,
+		},
+		{
+			name:         "Status 201 if workspace created",
+			method:       http.MethodPost,
+			path:         "/api/workspaces",
+			body:         `{"name": "team"}`,
+			expectedCode: http.StatusCreated,
+		},
+		{
+			name:         "Status 403 if non-owner sets member role",
+			method:       http.MethodPut,
+			path:         "/api/workspaces/ws-viewer/members/67890",
+			body:         `{"role": "editor"}`,
+			expectedCode: http.StatusForbidden,
+		},
+		{
+			name:         "Status 409 if last owner leaves workspace",
+			method:       http.MethodDelete,
+			path:         "/api/workspaces/ws-editor/members/12345",
+			expectedCode: http.StatusConflict,
+		},
+		{
+			name:         "Status 200 if links transferred",
+			method:       http.MethodPost,
+			path:         "/api/user/urls/transfer",
+			body:         `{"urls": ["qw12qw"], "to_workspace_id": "ws-editor"}`,
+			expectedCode: http.StatusOK,
+			expectedBody: `{"transferred": 1}`,
+		},
+		{
+			name:         "Status 200 if nothing to transfer on another domain",
+			method:       http.MethodPost,
+			path:         "/api/user/urls/transfer?domain=go.brand.example",
+			body:         `{"urls": ["qw12qw"], "to_workspace_id": "ws-editor"}`,
+			expectedCode: http.StatusOK,
+			expectedBody: `{"transferred": 0}`,
+		},
+		{
+			name:         "Status 400 if transfer has no links",
+			method:       http.MethodPost,
+			path:         "/api/user/urls/transfer",
+			body:         `{"to_workspace_id": "ws-editor"}`,
+			expectedCode: http.StatusBadRequest,
+		},
+		{
+			name:         "Status 200 on liveness probe",
+			method:       http.MethodGet,
+			path:         "/healthz",
+			expectedCode: http.StatusOK,
+			expectedBody: `{"status": "ok"}`,
+		},
+		{
+			name:         "Status 200 on readiness probe with components breakdown",
+			method:       http.MethodGet,
+			path:         "/readyz",
+			expectedCode: http.StatusOK,
+			expectedBody: `{"status": "ok", "components": {"store": {"status": "ok"}}}`,
+		},
 	}
 
 	for _, tc := range testCases {
@@ -200,3 +732,881 @@ func TestRouter(t *testing.T) {
 		})
 	}
 }
+
+func TestRouterRequestID(t *testing.T) {
+	logger := zap.NewNop()
+	svc := &serviceMock{}
+	h := handlers.NewHandler(svc, logger)
+
+	ts := httptest.NewServer(Router(h, svc, logger))
+	defer ts.Close()
+
+	testCases := []struct {
+		name      string
+		requestID string
+		generated bool
+	}{
+		{
+			name:      "Inbound request ID is returned as is",
+			requestID: "req-42",
+		},
+		{
+			name:      "Request ID is generated when header is missing",
+			generated: true,
+		},
+		{
+			name:      "Request ID is generated when inbound one is malformed",
+			requestID: "bad id",
+			generated: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			req, err := http.NewRequest(http.MethodGet, ts.URL+"/ping", http.NoBody)
+			require.NoError(t, err)
+
+			if tc.requestID != "" {
+				req.Header.Set(middleware.RequestIDHeader, tc.requestID)
+			}
+
+			resp, err := ts.Client().Do(req)
+			require.NoError(t, err)
+			require.NoError(t, resp.Body.Close())
+
+			got := resp.Header.Get(middleware.RequestIDHeader)
+			if tc.generated {
+				assert.NotEmpty(t, got)
+				assert.NotEqual(t, tc.requestID, got)
+				return
+			}
+
+			assert.Equal(t, tc.requestID, got)
+		})
+	}
+}
+
+func TestRouterAPIKeys(t *testing.T) {
+	logger := zap.NewNop()
+	svc := &serviceMock{}
+	h := handlers.NewHandler(svc, logger)
+
+	ts := httptest.NewServer(Router(h, svc, logger))
+	defer ts.Close()
+
+	testCases := []struct {
+		name          string
+		method        string
+		path          string
+		body          string
+		authorization string
+		withCookie    bool
+		expectedCode  int
+	}{
+		{
+			name:          "Status 200 if API key has keys scope",
+			method:        http.MethodGet,
+			path:          "/api/user/keys",
+			authorization: "Bearer usk_admin",
+			expectedCode:  http.StatusOK,
+		},
+		{
+			name:         "Status 403 if cookie identity manages keys",
+			method:       http.MethodGet,
+			path:         "/api/user/keys",
+			withCookie:   true,
+			expectedCode: http.StatusForbidden,
+		},
+		{
+			name:          "Status 403 if API key lacks write scope",
+			method:        http.MethodPost,
+			path:          "/api/shorten",
+			body:          `{"url": "https://hello.world"}`,
+			authorization: "Bearer usk_readonly",
+			expectedCode:  http.StatusForbidden,
+		},
+		{
+			name:          "Status 200 if read-only API key lists URLs",
+			method:        http.MethodGet,
+			path:          "/api/user/urls",
+			authorization: "Bearer usk_readonly",
+			expectedCode:  http.StatusOK,
+		},
+		{
+			name:          "Status 401 if API key is unknown",
+			method:        http.MethodGet,
+			path:          "/api/user/urls",
+			authorization: "Bearer usk_unknown",
+			expectedCode:  http.StatusUnauthorized,
+		},
+		{
+			name:          "Status 200 if JWT is sent as bearer token",
+			method:        http.MethodGet,
+			path:          "/api/user/urls",
+			authorization: "Bearer " + mustAccessToken(t),
+			expectedCode:  http.StatusOK,
+		},
+		{
+			name:          "Status 403 if bearer JWT manages keys",
+			method:        http.MethodGet,
+			path:          "/api/user/keys",
+			authorization: "Bearer " + mustAccessToken(t),
+			expectedCode:  http.StatusForbidden,
+		},
+		{
+			name:          "Status 401 if bearer token is malformed",
+			method:        http.MethodGet,
+			path:          "/api/user/urls",
+			authorization: "Bearer not-a-token",
+			expectedCode:  http.StatusUnauthorized,
+		},
+		{
+			name:          "Status 201 if webhook created",
+			method:        http.MethodPost,
+			path:          "/api/user/webhooks",
+			body:          `{"url": "https://hooks.example.com", "events": ["link.created"]}`,
+			authorization: "Bearer usk_admin",
+			expectedCode:  http.StatusCreated,
+		},
+		{
+			name:          "Status 400 if webhook has no events",
+			method:        http.MethodPost,
+			path:          "/api/user/webhooks",
+			body:          `{"url": "https://hooks.example.com"}`,
+			authorization: "Bearer usk_admin",
+			expectedCode:  http.StatusBadRequest,
+		},
+		{
+			name:          "Status 403 if API key lacks webhooks scope",
+			method:        http.MethodGet,
+			path:          "/api/user/webhooks",
+			authorization: "Bearer usk_readonly",
+			expectedCode:  http.StatusForbidden,
+		},
+		{
+			name:          "Status 404 if webhook is unknown",
+			method:        http.MethodDelete,
+			path:          "/api/user/webhooks/7a4b5f6e-0c1d-4e2f-8a9b-1c2d3e4f5a6b",
+			authorization: "Bearer usk_admin",
+			expectedCode:  http.StatusNotFound,
+		},
+		{
+			name:          "Status 202 if dead delivery is retried",
+			method:        http.MethodPost,
+			path:          "/api/user/webhooks/deliveries/0b1c2d3e-4f5a-4b6c-8d7e-9f0a1b2c3d4e/retry",
+			authorization: "Bearer usk_admin",
+			expectedCode:  http.StatusAccepted,
+		},
+		{
+			name:         "Status 201 if user registered",
+			method:       http.MethodPost,
+			path:         "/api/users",
+			body:         `{"name": "alice"}`,
+			expectedCode: http.StatusCreated,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			req, err := http.NewRequest(tc.method, ts.URL+tc.path, bytes.NewBufferString(tc.body))
+			require.NoError(t, err)
+
+			if tc.authorization != "" {
+				req.Header.Set("Authorization", tc.authorization)
+			}
+
+			if tc.withCookie {
+				token, err := middleware.CreateAccessToken(userID)
+				require.NoError(t, err)
+				req.AddCookie(&http.Cookie{Name: "auth_token", Value: token})
+			}
+
+			resp, err := ts.Client().Do(req)
+			require.NoError(t, err)
+			require.NoError(t, resp.Body.Close())
+
+			assert.Equal(t, tc.expectedCode, resp.StatusCode)
+		})
+	}
+}
+
+func TestRouterMintedTokenHeader(t *testing.T) {
+	logger := zap.NewNop()
+	svc := &serviceMock{}
+	h := handlers.NewHandler(svc, logger)
+
+	ts := httptest.NewServer(Router(h, svc, logger))
+	defer ts.Close()
+
+	resp, err := ts.Client().Get(ts.URL + "/api/user/urls")
+	require.NoError(t, err)
+	require.NoError(t, resp.Body.Close())
+
+	token := resp.Header.Get(middleware.AuthTokenHeader)
+	require.NotEmpty(t, token)
+
+	// Тот же токен работает как bearer без cookie
+	req, err := http.NewRequest(http.MethodGet, ts.URL+"/api/user/urls", http.NoBody)
+	require.NoError(t, err)
+	req.Header.Set("Authorization", "Bearer "+token)
+
+	resp, err = ts.Client().Do(req)
+	require.NoError(t, err)
+	require.NoError(t, resp.Body.Close())
+
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Empty(t, resp.Header.Get(middleware.AuthTokenHeader))
+}
+
+func mustAccessToken(t *testing.T) string {
+	t.Helper()
+
+	token, err := middleware.CreateAccessToken(userID)
+	require.NoError(t, err)
+
+	return token
+}
+
+func TestRouterSessions(t *testing.T) {
+	logger := zap.NewNop()
+	svc := &serviceMock{}
+	h := handlers.NewHandler(svc, logger)
+
+	ts := httptest.NewServer(Router(h, svc, logger))
+	defer ts.Close()
+
+	do := func(t *testing.T, method, path, authorization, body string) *http.Response {
+		t.Helper()
+
+		req, err := http.NewRequest(method, ts.URL+path, bytes.NewBufferString(body))
+		require.NoError(t, err)
+
+		if authorization != "" {
+			req.Header.Set("Authorization", authorization)
+		}
+
+		resp, err := ts.Client().Do(req)
+		require.NoError(t, err)
+		require.NoError(t, resp.Body.Close())
+
+		return resp
+	}
+
+	resp := do(t, http.MethodGet, "/api/user/urls", "", "")
+	accessToken := resp.Header.Get(middleware.AuthTokenHeader)
+	refreshToken := resp.Header.Get(middleware.RefreshTokenHeader)
+	require.NotEmpty(t, accessToken)
+	require.NotEmpty(t, refreshToken)
+
+	resp = do(t, http.MethodGet, "/api/user/urls", "Bearer "+refreshToken, "")
+	assert.Equal(t, http.StatusUnauthorized, resp.StatusCode, "refresh token must not grant access")
+
+	resp = do(t, http.MethodPost, "/api/auth/refresh", "", `{"refresh_token": "`+accessToken+`"}`)
+	assert.Equal(t, http.StatusUnauthorized, resp.StatusCode, "access token must not refresh")
+
+	resp = do(t, http.MethodPost, "/api/auth/refresh", "", `{"refresh_token": "`+refreshToken+`"}`)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	rotatedToken := resp.Header.Get(middleware.RefreshTokenHeader)
+	assert.NotEmpty(t, resp.Header.Get(middleware.AuthTokenHeader))
+	assert.NotEqual(t, refreshToken, rotatedToken, "refresh token must be rotated")
+
+	resp = do(t, http.MethodPost, "/api/auth/refresh", "", `{"refresh_token": "`+rotatedToken+`"}`)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	rotatedToken = resp.Header.Get(middleware.RefreshTokenHeader)
+
+	resp = do(t, http.MethodPost, "/api/auth/logout", "Bearer usk_admin", "")
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode, "API keys have no session")
+
+	resp = do(t, http.MethodPost, "/api/auth/logout", "Bearer "+accessToken, "")
+	assert.Equal(t, http.StatusNoContent, resp.StatusCode)
+
+	resp = do(t, http.MethodGet, "/api/user/urls", "Bearer "+accessToken, "")
+	assert.Equal(t, http.StatusUnauthorized, resp.StatusCode, "access token of a revoked session must not work")
+
+	resp = do(t, http.MethodPost, "/api/auth/refresh", "", `{"refresh_token": "`+rotatedToken+`"}`)
+	assert.Equal(t, http.StatusUnauthorized, resp.StatusCode, "revoked session must not refresh")
+}
+
+func TestRouterRefreshTokenReuse(t *testing.T) {
+	logger := zap.NewNop()
+	svc := &serviceMock{}
+	h := handlers.NewHandler(svc, logger)
+
+	ts := httptest.NewServer(Router(h, svc, logger))
+	defer ts.Close()
+
+	refresh := func(t *testing.T, refreshToken string) *http.Response {
+		t.Helper()
+
+		resp, err := ts.Client().Post(ts.URL+"/api/auth/refresh", "application/json",
+			bytes.NewBufferString(`{"refresh_token": "`+refreshToken+`"}`))
+		require.NoError(t, err)
+		require.NoError(t, resp.Body.Close())
+
+		return resp
+	}
+
+	resp, err := ts.Client().Get(ts.URL + "/api/user/urls")
+	require.NoError(t, err)
+	require.NoError(t, resp.Body.Close())
+	stolenToken := resp.Header.Get(middleware.RefreshTokenHeader)
+
+	resp = refresh(t, stolenToken)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	accessToken := resp.Header.Get(middleware.AuthTokenHeader)
+	rotatedToken := resp.Header.Get(middleware.RefreshTokenHeader)
+
+	// Повторный обмен уже использованного токена отзывает всю сессию.
+	assert.Equal(t, http.StatusUnauthorized, refresh(t, stolenToken).StatusCode)
+	assert.Equal(t, http.StatusUnauthorized, refresh(t, rotatedToken).StatusCode)
+
+	req, err := http.NewRequest(http.MethodGet, ts.URL+"/api/user/urls", http.NoBody)
+	require.NoError(t, err)
+	req.Header.Set("Authorization", "Bearer "+accessToken)
+
+	resp, err = ts.Client().Do(req)
+	require.NoError(t, err)
+	require.NoError(t, resp.Body.Close())
+	assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+}
+
+func TestRouterClaimChecksSession(t *testing.T) {
+	logger := zap.NewNop()
+	svc := &serviceMock{}
+	h := handlers.NewHandler(svc, logger)
+
+	ts := httptest.NewServer(Router(h, svc, logger))
+	defer ts.Close()
+
+	do := func(t *testing.T, method, path, authorization, body string) *http.Response {
+		t.Helper()
+
+		req, err := http.NewRequest(method, ts.URL+path, bytes.NewBufferString(body))
+		require.NoError(t, err)
+		req.Header.Set("Authorization", authorization)
+
+		resp, err := ts.Client().Do(req)
+		require.NoError(t, err)
+		require.NoError(t, resp.Body.Close())
+
+		return resp
+	}
+
+	resp, err := ts.Client().Get(ts.URL + "/api/user/urls")
+	require.NoError(t, err)
+	require.NoError(t, resp.Body.Close())
+	anonymousToken := resp.Header.Get(middleware.AuthTokenHeader)
+
+	claim := `{"token": "` + anonymousToken + `"}`
+	resp = do(t, http.MethodPost, "/api/user/claim", "Bearer "+mustAccessToken(t), claim)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+	resp = do(t, http.MethodPost, "/api/auth/logout", "Bearer "+anonymousToken, "")
+	require.Equal(t, http.StatusNoContent, resp.StatusCode)
+
+	resp = do(t, http.MethodPost, "/api/user/claim", "Bearer "+mustAccessToken(t), claim)
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode, "token of a revoked session must not claim links")
+}
+
+func TestRouterPasswordLinks(t *testing.T) {
+	logger := zap.NewNop()
+	svc := &serviceMock{}
+	h := handlers.NewHandler(svc, logger)
+
+	ts := httptest.NewServer(Router(h, svc, logger))
+	defer ts.Close()
+
+	ts.Client().CheckRedirect = func(req *http.Request, via []*http.Request) error {
+		return http.ErrUseLastResponse
+	}
+
+	testCases := []struct {
+		name             string
+		method           string
+		path             string
+		password         string
+		form             url.Values
+		expectedCode     int
+		expectedLocation string
+		expectedBody     string
+	}{
+		{
+			name:         "Status 401 with form if password is missing",
+			method:       http.MethodGet,
+			path:         "/secret",
+			expectedCode: http.StatusUnauthorized,
+			expectedBody: `<input type="password" name="password"`,
+		},
+		{
+			name:             "Redirect if header password is correct",
+			method:           http.MethodGet,
+			path:             "/secret",
+			password:         "open-sesame",
+			expectedCode:     http.StatusTemporaryRedirect,
+			expectedLocation: "https://hello.secret",
+		},
+		{
+			name:             "See other if form password is correct",
+			method:           http.MethodPost,
+			path:             "/secret",
+			form:             url.Values{"password": {"open-sesame"}},
+			expectedCode:     http.StatusSeeOther,
+			expectedLocation: "https://hello.secret",
+		},
+		{
+			name:         "Status 401 if form password is wrong",
+			method:       http.MethodPost,
+			path:         "/secret",
+			form:         url.Values{"password": {"guess"}},
+			expectedCode: http.StatusUnauthorized,
+			expectedBody: "Wrong password",
+		},
+		{
+			name:         "Status 429 if attempts are exhausted",
+			method:       http.MethodGet,
+			path:         "/locked",
+			password:     "guess",
+			expectedCode: http.StatusTooManyRequests,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var body io.Reader = http.NoBody
+			if tc.form != nil {
+				body = strings.NewReader(tc.form.Encode())
+			}
+
+			req, err := http.NewRequest(tc.method, ts.URL+tc.path, body)
+			require.NoError(t, err)
+
+			if tc.form != nil {
+				req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			}
+
+			if tc.password != "" {
+				req.Header.Set(handlers.LinkPasswordHeader, tc.password)
+			}
+
+			resp, err := ts.Client().Do(req)
+			require.NoError(t, err)
+
+			respBody, err := io.ReadAll(resp.Body)
+			require.NoError(t, err)
+			require.NoError(t, resp.Body.Close())
+
+			assert.Equal(t, tc.expectedCode, resp.StatusCode)
+			assert.Equal(t, tc.expectedLocation, resp.Header.Get("Location"))
+			assert.Contains(t, string(respBody), tc.expectedBody)
+
+			if tc.expectedCode == http.StatusTooManyRequests {
+				assert.Equal(t, "30", resp.Header.Get("Retry-After"))
+			}
+		})
+	}
+}
+
+func TestRouterDomainHost(t *testing.T) {
+	logger := zap.NewNop()
+	svc := &serviceMock{}
+	h := handlers.NewHandler(svc, logger)
+
+	ts := httptest.NewServer(Router(h, svc, logger))
+	defer ts.Close()
+
+	ts.Client().CheckRedirect = func(req *http.Request, via []*http.Request) error {
+		return http.ErrUseLastResponse
+	}
+
+	testCases := []struct {
+		name             string
+		host             string
+		expectedLocation string
+	}{
+		{
+			name:             "Link of the main domain",
+			expectedLocation: "https://hello.world",
+		},
+		{
+			name:             "Same code on a branded domain",
+			host:             brandDomain,
+			expectedLocation: "https://brand.example/landing",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			req, err := http.NewRequest(http.MethodGet, ts.URL+"/qw12qw", http.NoBody)
+			require.NoError(t, err)
+
+			if tc.host != "" {
+				req.Host = tc.host
+			}
+
+			resp, err := ts.Client().Do(req)
+			require.NoError(t, err)
+			require.NoError(t, resp.Body.Close())
+
+			assert.Equal(t, http.StatusTemporaryRedirect, resp.StatusCode)
+			assert.Equal(t, tc.expectedLocation, resp.Header.Get("Location"))
+		})
+	}
+}
+
+func TestRouterStickyVariant(t *testing.T) {
+	logger := zap.NewNop()
+	svc := &serviceMock{}
+	h := handlers.NewHandler(svc, logger)
+
+	ts := httptest.NewServer(Router(h, svc, logger))
+	defer ts.Close()
+
+	ts.Client().CheckRedirect = func(req *http.Request, via []*http.Request) error {
+		return http.ErrUseLastResponse
+	}
+
+	testCases := []struct {
+		name             string
+		cookie           string
+		expectedLocation string
+		expectedVariant  string
+	}{
+		{
+			name:             "Variant is assigned on the first visit",
+			expectedLocation: "https://a.example",
+			expectedVariant:  "1",
+		},
+		{
+			name:             "Assigned variant is kept",
+			cookie:           "2",
+			expectedLocation: "https://b.example",
+			expectedVariant:  "2",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			req, err := http.NewRequest(http.MethodGet, ts.URL+"/split", http.NoBody)
+			require.NoError(t, err)
+
+			if tc.cookie != "" {
+				req.AddCookie(&http.Cookie{Name: handlers.VariantCookieName, Value: tc.cookie})
+			}
+
+			resp, err := ts.Client().Do(req)
+			require.NoError(t, err)
+			require.NoError(t, resp.Body.Close())
+
+			assert.Equal(t, http.StatusTemporaryRedirect, resp.StatusCode)
+			assert.Equal(t, tc.expectedLocation, resp.Header.Get("Location"))
+
+			var variant *http.Cookie
+			for _, cookie := range resp.Cookies() {
+				if cookie.Name == handlers.VariantCookieName {
+					variant = cookie
+				}
+			}
+
+			require.NotNil(t, variant)
+			assert.Equal(t, tc.expectedVariant, variant.Value)
+			assert.Equal(t, "/split", variant.Path)
+		})
+	}
+}
+
+func TestRouterConditionalRedirect(t *testing.T) {
+	logger := zap.NewNop()
+	svc := &serviceMock{}
+	h := handlers.NewHandler(svc, logger)
+
+	ts := httptest.NewServer(Router(h, svc, logger))
+	defer ts.Close()
+
+	ts.Client().CheckRedirect = func(req *http.Request, via []*http.Request) error {
+		return http.ErrUseLastResponse
+	}
+
+	testCases := []struct {
+		name             string
+		userAgent        string
+		acceptLanguage   string
+		expectedLocation string
+	}{
+		{
+			name:             "Rule by platform",
+			userAgent:        "Mozilla/5.0 (iPhone; CPU iPhone OS 17_0 like Mac OS X)",
+			expectedLocation: "https://apps.apple.com/app",
+		},
+		{
+			name:             "Rule by language",
+			acceptLanguage:   "de-AT,de;q=0.9",
+			expectedLocation: "https://app.example/de",
+		},
+		{
+			name:             "Fallback destination",
+			userAgent:        "Mozilla/5.0 (X11; Linux x86_64)",
+			acceptLanguage:   "en-US",
+			expectedLocation: "https://app.example",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			req, err := http.NewRequest(http.MethodGet, ts.URL+"/app", http.NoBody)
+			require.NoError(t, err)
+
+			req.Header.Set("User-Agent", tc.userAgent)
+			req.Header.Set("Accept-Language", tc.acceptLanguage)
+
+			resp, err := ts.Client().Do(req)
+			require.NoError(t, err)
+			require.NoError(t, resp.Body.Close())
+
+			assert.Equal(t, http.StatusTemporaryRedirect, resp.StatusCode)
+			assert.Equal(t, tc.expectedLocation, resp.Header.Get("Location"))
+		})
+	}
+}
+
+func TestRouterPendingLink(t *testing.T) {
+	testCases := []struct {
+		name         string
+		page         *template.Template
+		expectedCode int
+		expectedBody string
+	}{
+		{
+			name:         "Status 404 without pending page",
+			expectedCode: http.StatusNotFound,
+			expectedBody: "Link not found",
+		},
+		{
+			name:         "Pending page before activation",
+			page:         template.Must(template.New("pending").Parse(`Coming {{.NotBefore.Year}}`)),
+			expectedCode: http.StatusServiceUnavailable,
+			expectedBody: fmt.Sprintf("Coming %d", time.Now().Add(time.Hour).Year()),
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			logger := zap.NewNop()
+			svc := &serviceMock{}
+			h := handlers.NewHandler(svc, logger)
+			if tc.page != nil {
+				h.SetPendingPage(tc.page)
+			}
+
+			ts := httptest.NewServer(Router(h, svc, logger))
+			defer ts.Close()
+
+			resp, err := ts.Client().Get(ts.URL + "/launch")
+			require.NoError(t, err)
+
+			body, err := io.ReadAll(resp.Body)
+			require.NoError(t, err)
+			require.NoError(t, resp.Body.Close())
+
+			assert.Equal(t, tc.expectedCode, resp.StatusCode)
+			assert.Contains(t, string(body), tc.expectedBody)
+		})
+	}
+}
+
+func TestRouterEvents(t *testing.T) {
+	logger := zap.NewNop()
+	svc := &serviceMock{}
+	h := handlers.NewHandler(svc, logger)
+	h.SetInternalToken("internal-secret")
+
+	ts := httptest.NewServer(Router(h, svc, logger))
+	defer ts.Close()
+
+	testCases := []struct {
+		name         string
+		query        string
+		token        string
+		expectedCode int
+		expectedBody string
+	}{
+		{
+			name:         "Status 401 without internal token",
+			expectedCode: http.StatusUnauthorized,
+		},
+		{
+			name:         "Events after cursor",
+			query:        "?after=1",
+			token:        "internal-secret",
+			expectedCode: http.StatusOK,
+			expectedBody: `"next":2`,
+		},
+		{
+			name:         "Empty long-poll keeps cursor",
+			query:        "?after=2&wait=10ms",
+			token:        "internal-secret",
+			expectedCode: http.StatusOK,
+			expectedBody: `{"events":[],"next":2}`,
+		},
+		{
+			name:         "Status 400 on invalid cursor",
+			query:        "?after=-1",
+			token:        "internal-secret",
+			expectedCode: http.StatusBadRequest,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			req, err := http.NewRequest(http.MethodGet, ts.URL+"/api/internal/events"+tc.query, http.NoBody)
+			require.NoError(t, err)
+			req.Header.Set(handlers.InternalTokenHeader, tc.token)
+
+			resp, err := ts.Client().Do(req)
+			require.NoError(t, err)
+
+			body, err := io.ReadAll(resp.Body)
+			require.NoError(t, err)
+			require.NoError(t, resp.Body.Close())
+
+			assert.Equal(t, tc.expectedCode, resp.StatusCode)
+			assert.Contains(t, string(body), tc.expectedBody)
+		})
+	}
+
+	t.Run("Stream resumes from Last-Event-ID", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, ts.URL+"/api/internal/events", http.NoBody)
+		require.NoError(t, err)
+		req.Header.Set(handlers.InternalTokenHeader, "internal-secret")
+		req.Header.Set("Accept", "text/event-stream")
+		req.Header.Set("Last-Event-ID", "1")
+
+		resp, err := ts.Client().Do(req)
+		require.NoError(t, err)
+		defer func() { _ = resp.Body.Close() }()
+
+		assert.Equal(t, "text/event-stream", resp.Header.Get("Content-Type"))
+
+		reader := bufio.NewReader(resp.Body)
+		for _, expected := range []string{"id: 2\n", "event: link.deleted\n"} {
+			line, err := reader.ReadString('\n')
+			require.NoError(t, err)
+			assert.Equal(t, expected, line)
+		}
+	})
+}
+
+func TestRouterLiveClicks(t *testing.T) {
+	logger := zap.NewNop()
+	svc := &serviceMock{}
+	h := handlers.NewHandler(svc, logger)
+
+	ts := httptest.NewServer(Router(h, svc, logger))
+	defer ts.Close()
+
+	ts.Client().CheckRedirect = func(req *http.Request, via []*http.Request) error {
+		return http.ErrUseLastResponse
+	}
+
+	token, err := middleware.CreateAccessToken(userID)
+	require.NoError(t, err)
+
+	t.Run("Status 404 for unknown link", func(t *testing.T) {
+		req, err := http.NewRequest(http.MethodGet, ts.URL+"/api/user/urls/missing/live", http.NoBody)
+		require.NoError(t, err)
+		req.AddCookie(&http.Cookie{Name: "auth_token", Value: token})
+
+		resp, err := ts.Client().Do(req)
+		require.NoError(t, err)
+		require.NoError(t, resp.Body.Close())
+
+		assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+	})
+
+	t.Run("Click is streamed to subscriber", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, ts.URL+"/api/user/urls/utm/live", http.NoBody)
+		require.NoError(t, err)
+		req.AddCookie(&http.Cookie{Name: "auth_token", Value: token})
+
+		resp, err := ts.Client().Do(req)
+		require.NoError(t, err)
+		defer func() { _ = resp.Body.Close() }()
+
+		reader := bufio.NewReader(resp.Body)
+		line, err := reader.ReadString('\n')
+		require.NoError(t, err)
+		assert.Equal(t, ": subscribed\n", line)
+
+		click, err := ts.Client().Get(ts.URL + "/utm?ref=launch")
+		require.NoError(t, err)
+		require.NoError(t, click.Body.Close())
+
+		for _, expected := range []string{"\n", "event: click\n"} {
+			line, err = reader.ReadString('\n')
+			require.NoError(t, err)
+			assert.Equal(t, expected, line)
+		}
+
+		line, err = reader.ReadString('\n')
+		require.NoError(t, err)
+		assert.Contains(t, line, `"destination":"https://hello.world/?ref=launch"`)
+	})
+}
+
+func TestRouterStreamsStopOnShutdown(t *testing.T) {
+	logger := zap.NewNop()
+	svc := &serviceMock{}
+	h := handlers.NewHandler(svc, logger)
+	h.SetInternalToken("internal-secret")
+
+	ts := httptest.NewUnstartedServer(Router(h, svc, logger))
+	ts.Config.RegisterOnShutdown(h.StopStreams)
+	ts.Start()
+	defer ts.Close()
+
+	req, err := http.NewRequest(http.MethodGet, ts.URL+"/api/internal/events?after=2", http.NoBody)
+	require.NoError(t, err)
+	req.Header.Set(handlers.InternalTokenHeader, "internal-secret")
+	req.Header.Set("Accept", "text/event-stream")
+
+	resp, err := ts.Client().Do(req)
+	require.NoError(t, err)
+	defer func() { _ = resp.Body.Close() }()
+
+	events := bufio.NewReader(resp.Body)
+	line, err := events.ReadString('\n')
+	require.NoError(t, err)
+	assert.Equal(t, ": keep-alive\n", line)
+
+	token, err := middleware.CreateAccessToken(userID)
+	require.NoError(t, err)
+
+	req, err = http.NewRequest(http.MethodGet, ts.URL+"/api/user/urls/utm/live", http.NoBody)
+	require.NoError(t, err)
+	req.AddCookie(&http.Cookie{Name: "auth_token", Value: token})
+
+	liveResp, err := ts.Client().Do(req)
+	require.NoError(t, err)
+	defer func() { _ = liveResp.Body.Close() }()
+
+	clicks := bufio.NewReader(liveResp.Body)
+	line, err = clicks.ReadString('\n')
+	require.NoError(t, err)
+	assert.Equal(t, ": subscribed\n", line)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	// Shutdown дождётся открытых потоков только если те завершатся сами.
+	require.NoError(t, ts.Config.Shutdown(ctx))
+
+	_, err = io.ReadAll(events)
+	assert.NoError(t, err)
+	_, err = io.ReadAll(clicks)
+	assert.NoError(t, err)
+}
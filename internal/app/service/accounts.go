@@ -0,0 +1,250 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/a-bondar/go-url-shortener/internal/app/models"
+	"github.com/a-bondar/go-url-shortener/internal/app/store"
+	"github.com/a-bondar/go-url-shortener/internal/app/tracing"
+	"github.com/google/uuid"
+)
+
+const (
+	apiKeyPrefix       = "usk_"
+	apiKeySecretSize   = 32
+	apiKeyDisplayChars = 12
+	maxNameLength      = 100
+)
+
+var (
+	ErrInvalidAPIKey  = errors.New("invalid API key")
+	ErrUnknownScope   = errors.New("unknown scope")
+	ErrInvalidName    = errors.New("invalid name")
+	ErrNotRegistered  = errors.New("user is not registered")
+	ErrAlreadyClaimed = errors.New("identity belongs to a registered user")
+)
+
+var (
+	knownScopes = []string{
+		models.ScopeLinksRead, models.ScopeLinksWrite, models.ScopeKeysManage, models.ScopeWebhooksManage,
+	}
+	defaultScopes = []string{models.ScopeLinksRead, models.ScopeLinksWrite}
+)
+
+// IsAPIKey отличает API-ключ от других bearer-токенов по префиксу.
+func IsAPIKey(token string) bool {
+	return strings.HasPrefix(token, apiKeyPrefix)
+}
+
+// RegisterUser заводит учётную запись и выпускает для неё первый ключ со всеми правами.
+func (s *Service) RegisterUser(ctx context.Context, name string) (models.HandleRegisterResponse, error) {
+	ctx, span := tracing.Start(ctx, "service.RegisterUser")
+	defer span.End()
+
+	name, err := normalizeName(name)
+	if err != nil {
+		return models.HandleRegisterResponse{}, err
+	}
+
+	user := models.User{
+		ID:        uuid.NewString(),
+		Name:      name,
+		CreatedAt: time.Now().UTC(),
+	}
+	if err = s.s.CreateUser(ctx, user); err != nil {
+		return models.HandleRegisterResponse{}, fmt.Errorf("failed to create user: %w", err)
+	}
+
+	key, err := s.issueAPIKey(ctx, user.ID, "default", knownScopes)
+	if err != nil {
+		return models.HandleRegisterResponse{}, err
+	}
+
+	return models.HandleRegisterResponse{
+		UserID: user.ID,
+		Name:   user.Name,
+		APIKey: key,
+	}, nil
+}
+
+func (s *Service) CreateAPIKey(
+	ctx context.Context,
+	userID string,
+	name string,
+	scopes []string,
+) (models.HandleAPIKeyResponse, error) {
+	ctx, span := tracing.Start(ctx, "service.CreateAPIKey")
+	defer span.End()
+
+	name, err := normalizeName(name)
+	if err != nil {
+		return models.HandleAPIKeyResponse{}, err
+	}
+
+	if len(scopes) == 0 {
+		scopes = defaultScopes
+	}
+
+	for _, scope := range scopes {
+		if !slices.Contains(knownScopes, scope) {
+			return models.HandleAPIKeyResponse{}, fmt.Errorf("%w: %q", ErrUnknownScope, scope)
+		}
+	}
+
+	if _, err = s.s.GetUser(ctx, userID); err != nil {
+		return models.HandleAPIKeyResponse{}, fmt.Errorf("%w: %w", ErrNotRegistered, err)
+	}
+
+	return s.issueAPIKey(ctx, userID, name, normalizeTags(scopes))
+}
+
+func (s *Service) GetAPIKeys(ctx context.Context, userID string) ([]models.HandleAPIKeyResponse, error) {
+	ctx, span := tracing.Start(ctx, "service.GetAPIKeys")
+	defer span.End()
+
+	keys, err := s.s.GetAPIKeys(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get API keys: %w", err)
+	}
+
+	resp := make([]models.HandleAPIKeyResponse, 0, len(keys))
+	for _, key := range keys {
+		resp = append(resp, toAPIKeyResponse(key, ""))
+	}
+
+	return resp, nil
+}
+
+func (s *Service) RevokeAPIKey(ctx context.Context, userID string, keyID string) error {
+	ctx, span := tracing.Start(ctx, "service.RevokeAPIKey")
+	defer span.End()
+
+	// Идентификаторы ключей — UUID; всё остальное заведомо не найдётся.
+	if _, err := uuid.Parse(keyID); err != nil {
+		return fmt.Errorf("%w: %w", ErrInvalidAPIKey, err)
+	}
+
+	if err := s.s.RevokeAPIKey(ctx, keyID, userID); err != nil {
+		return fmt.Errorf("failed to revoke API key: %w", err)
+	}
+
+	return nil
+}
+
+// AuthenticateAPIKey находит действующий ключ по его хешу.
+func (s *Service) AuthenticateAPIKey(ctx context.Context, rawKey string) (models.APIKey, error) {
+	ctx, span := tracing.Start(ctx, "service.AuthenticateAPIKey")
+	defer span.End()
+
+	if !IsAPIKey(rawKey) {
+		return models.APIKey{}, ErrInvalidAPIKey
+	}
+
+	key, err := s.s.GetAPIKeyByHash(ctx, hashAPIKey(rawKey))
+	if err != nil {
+		return models.APIKey{}, fmt.Errorf("%w: %w", ErrInvalidAPIKey, err)
+	}
+
+	if key.RevokedAt != nil {
+		return models.APIKey{}, fmt.Errorf("%w: key is revoked", ErrInvalidAPIKey)
+	}
+
+	return key, nil
+}
+
+// ClaimURLs переносит ссылки анонимной личности из cookie в учётную запись.
+func (s *Service) ClaimURLs(ctx context.Context, anonymousUserID string, userID string) (int64, error) {
+	ctx, span := tracing.Start(ctx, "service.ClaimURLs")
+	defer span.End()
+
+	if _, err := s.s.GetUser(ctx, userID); err != nil {
+		if errors.Is(err, store.ErrUserNotFound) {
+			return 0, fmt.Errorf("%w: %w", ErrNotRegistered, err)
+		}
+
+		return 0, fmt.Errorf("failed to get user: %w", err)
+	}
+
+	// Зарегистрированные учётные записи так забрать нельзя — только анонимные.
+	_, err := s.s.GetUser(ctx, anonymousUserID)
+	switch {
+	case err == nil:
+		return 0, ErrAlreadyClaimed
+	case !errors.Is(err, store.ErrUserNotFound):
+		return 0, fmt.Errorf("failed to get user: %w", err)
+	}
+
+	claimed, err := s.s.ClaimURLs(ctx, anonymousUserID, userID)
+	if err != nil {
+		return 0, fmt.Errorf("failed to claim URLs: %w", err)
+	}
+
+	return claimed, nil
+}
+
+func (s *Service) issueAPIKey(
+	ctx context.Context,
+	userID string,
+	name string,
+	scopes []string,
+) (models.HandleAPIKeyResponse, error) {
+	secret := make([]byte, apiKeySecretSize)
+	if _, err := rand.Read(secret); err != nil {
+		return models.HandleAPIKeyResponse{}, fmt.Errorf("failed to generate API key: %w", err)
+	}
+
+	rawKey := apiKeyPrefix + base64.RawURLEncoding.EncodeToString(secret)
+	key := models.APIKey{
+		ID:        uuid.NewString(),
+		UserID:    userID,
+		Name:      name,
+		Prefix:    rawKey[:apiKeyDisplayChars],
+		KeyHash:   hashAPIKey(rawKey),
+		Scopes:    slices.Clone(scopes),
+		CreatedAt: time.Now().UTC(),
+	}
+
+	if err := s.s.CreateAPIKey(ctx, key); err != nil {
+		return models.HandleAPIKeyResponse{}, fmt.Errorf("failed to save API key: %w", err)
+	}
+
+	return toAPIKeyResponse(key, rawKey), nil
+}
+
+// hashAPIKey — у ключа достаточно энтропии, поэтому медленный хеш не нужен,
+// а детерминированный SHA-256 позволяет искать ключ по индексу.
+func hashAPIKey(rawKey string) string {
+	sum := sha256.Sum256([]byte(rawKey))
+
+	return hex.EncodeToString(sum[:])
+}
+
+func toAPIKeyResponse(key models.APIKey, rawKey string) models.HandleAPIKeyResponse {
+	return models.HandleAPIKeyResponse{
+		ID:        key.ID,
+		Name:      key.Name,
+		Prefix:    key.Prefix,
+		Scopes:    key.Scopes,
+		CreatedAt: key.CreatedAt,
+		RevokedAt: key.RevokedAt,
+		Key:       rawKey,
+	}
+}
+
+func normalizeName(name string) (string, error) {
+	name = strings.TrimSpace(name)
+	if name == "" || len(name) > maxNameLength {
+		return "", fmt.Errorf("%w: must be 1-%d characters", ErrInvalidName, maxNameLength)
+	}
+
+	return name, nil
+}
@@ -0,0 +1,72 @@
+package service
+
+import (
+	"sync"
+	"time"
+)
+
+// attemptLimiter ограничивает число неудачных попыток на ключ в пределах окна.
+// Окно отсчитывается от первой неудачи и после истечения начинается заново.
+type attemptLimiter struct {
+	mu       sync.Mutex
+	limit    int
+	window   time.Duration
+	attempts map[string]*attemptWindow
+}
+
+type attemptWindow struct {
+	count int
+	start time.Time
+}
+
+func newAttemptLimiter(limit int, window time.Duration) *attemptLimiter {
+	return &attemptLimiter{
+		limit:    limit,
+		window:   window,
+		attempts: make(map[string]*attemptWindow),
+	}
+}
+
+// reserve заранее засчитывает попытку как неудачную, чтобы параллельные попытки не проскочили
+// лимит, пока идёт проверка. Если лимит исчерпан, возвращает, сколько ждать до следующей попытки.
+// Удачную попытку нужно вернуть через release.
+func (l *attemptLimiter) reserve(key string, now time.Time) (*attemptWindow, time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	w, ok := l.attempts[key]
+	if !ok || now.Sub(w.start) >= l.window {
+		w = &attemptWindow{start: now}
+		l.attempts[key] = w
+	}
+
+	if w.count >= l.limit {
+		return nil, w.start.Add(l.window).Sub(now)
+	}
+
+	w.count++
+
+	return w, 0
+}
+
+// release возвращает попытку, зарезервированную в окне w, если оно ещё действует.
+func (l *attemptLimiter) release(key string, w *attemptWindow) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.attempts[key] == w && w.count > 0 {
+		w.count--
+	}
+}
+
+// prune забывает истёкшие окна, чтобы карта не росла бесконечно.
+func (l *attemptLimiter) prune(now time.Time) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	for key, w := range l.attempts {
+		if now.Sub(w.start) >= l.window {
+			delete(l.attempts, key)
+		}
+	}
+}
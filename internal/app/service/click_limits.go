@@ -0,0 +1,55 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/a-bondar/go-url-shortener/internal/app/models"
+)
+
+var (
+	ErrInvalidMaxClicks = errors.New("invalid max clicks")
+	// ErrClicksExhausted — переходы по ссылке закончились. Если они кончились между чтением
+	// ссылки и списанием, хранилище вернёт свою ошибку store.ErrNoClicksLeft.
+	ErrClicksExhausted = errors.New("link has no clicks left")
+)
+
+// clickLimit проверяет ограничение переходов: 0 — без ограничения.
+func clickLimit(opts models.ShortenOptions) (int, error) {
+	if opts.MaxClicks < 0 {
+		return 0, fmt.Errorf("%w: must not be negative", ErrInvalidMaxClicks)
+	}
+
+	return opts.MaxClicks, nil
+}
+
+// consumeClick списывает переход у ссылки с ограничением. Хранилище списывает атомарно,
+// поэтому при одновременных переходах по одноразовой ссылке пройдёт только один.
+func (s *Service) consumeClick(ctx context.Context, data models.Data) error {
+	if data.MaxClicks == 0 {
+		return nil
+	}
+
+	if err := s.s.ConsumeClick(ctx, data.Domain, data.ShortURL); err != nil {
+		return fmt.Errorf("failed to consume click: %w", err)
+	}
+
+	return nil
+}
+
+// clicksLeft возвращает остаток переходов для списка ссылок; nil — ссылка без ограничения.
+func clicksLeft(data models.Data) *int {
+	if data.MaxClicks == 0 {
+		return nil
+	}
+
+	left := max(data.ClicksLeft, 0)
+
+	return &left
+}
+
+// exhausted сообщает, что переходы по ссылке уже закончились.
+func exhausted(data models.Data) bool {
+	return data.MaxClicks > 0 && data.ClicksLeft <= 0
+}
@@ -0,0 +1,49 @@
+package service
+
+import (
+	"context"
+	"testing"
+
+	"github.com/a-bondar/go-url-shortener/internal/app/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/bcrypt"
+)
+
+func TestResolveURLConsumesClicks(t *testing.T) {
+	ctx := context.Background()
+	s, st := newTestService(t)
+
+	hash, err := bcrypt.GenerateFromPassword([]byte("open-sesame"), bcrypt.MinCost)
+	require.NoError(t, err)
+
+	saveLink(t, st, "", "twice", "https://example.com/twice", models.LinkOptions{MaxClicks: 2, ClicksLeft: 2})
+	saveLink(t, st, "", "locked", "https://example.com/locked",
+		models.LinkOptions{MaxClicks: 1, ClicksLeft: 1, PasswordHash: string(hash)})
+	saveLink(t, st, "", "free", "https://example.com/free", models.LinkOptions{})
+
+	for range 2 {
+		res, err := s.ResolveURL(ctx, "twice", models.Visit{})
+		require.NoError(t, err)
+		assert.Equal(t, "https://example.com/twice", res.URL)
+	}
+	_, err = s.ResolveURL(ctx, "twice", models.Visit{})
+	assert.ErrorIs(t, err, ErrClicksExhausted)
+
+	// Неудачная попытка с неверным паролем не расходует переход.
+	_, err = s.ResolveURL(ctx, "locked", models.Visit{Password: "wrong"})
+	require.ErrorIs(t, err, ErrWrongPassword)
+
+	data, err := st.GetURL(ctx, "", "locked")
+	require.NoError(t, err)
+	assert.Equal(t, 1, data.ClicksLeft)
+
+	_, err = s.ResolveURL(ctx, "locked", models.Visit{Password: "open-sesame"})
+	require.NoError(t, err)
+
+	// Ссылка без ограничения у хранилища переходы не списывает.
+	for range 3 {
+		_, err = s.ResolveURL(ctx, "free", models.Visit{})
+		require.NoError(t, err)
+	}
+}
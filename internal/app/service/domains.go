@@ -0,0 +1,87 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/a-bondar/go-url-shortener/internal/app/config"
+)
+
+var ErrUnknownDomain = errors.New("unknown domain")
+
+// parseDomains сопоставляет хосты брендовых доменов с их базовыми URL.
+// Основной домен (ShortLinkBaseURL) хранится у ссылок пустой строкой и сюда не входит.
+func parseDomains(cfg *config.Config) map[string]string {
+	res := make(map[string]string, len(cfg.Domains))
+	for _, baseURL := range cfg.Domains {
+		u, err := url.Parse(baseURL)
+		if err != nil {
+			continue
+		}
+
+		res[strings.ToLower(u.Host)] = baseURL
+	}
+
+	return res
+}
+
+// domainKey проверяет домен из запроса на сокращение и возвращает его в том виде,
+// в котором он хранится у ссылки.
+func (s *Service) domainKey(requested string) (string, error) {
+	requested = strings.ToLower(strings.TrimSpace(requested))
+	if requested == "" {
+		return "", nil
+	}
+
+	if base, err := url.Parse(s.cfg.ShortLinkBaseURL); err == nil && strings.EqualFold(base.Host, requested) {
+		return "", nil
+	}
+
+	if _, ok := s.domains[requested]; !ok {
+		return "", fmt.Errorf("%w: %q", ErrUnknownDomain, requested)
+	}
+
+	return requested, nil
+}
+
+// lookupDomain приводит домен из запроса на управление ссылкой к виду, в котором он хранится.
+// Незнакомый домен не ошибка: ссылки могли остаться на домене, убранном из настроек.
+func (s *Service) lookupDomain(requested string) string {
+	domain, err := s.domainKey(requested)
+	if err != nil {
+		return strings.ToLower(strings.TrimSpace(requested))
+	}
+
+	return domain
+}
+
+// domainByHost выбирает пространство имён по заголовку Host. Незнакомые хосты
+// (основной домен, прокси, IP-адреса) обслуживаются как основной домен.
+func (s *Service) domainByHost(host string) string {
+	host = strings.ToLower(host)
+	if _, ok := s.domains[host]; ok {
+		return host
+	}
+
+	return ""
+}
+
+func (s *Service) buildURL(domain string, shortenURL string) (string, error) {
+	baseURL := s.cfg.ShortLinkBaseURL
+	if domain != "" {
+		var ok bool
+		// Домен могли убрать из настроек, а ссылки на нём остались — строим адрес по хосту.
+		if baseURL, ok = s.domains[domain]; !ok {
+			baseURL = "https://" + domain
+		}
+	}
+
+	res, err := url.JoinPath(baseURL, shortenURL)
+	if err != nil {
+		return "", fmt.Errorf(failedToBuildURLError, err)
+	}
+
+	return res, nil
+}
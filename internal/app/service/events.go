@@ -0,0 +1,58 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/a-bondar/go-url-shortener/internal/app/models"
+	"github.com/a-bondar/go-url-shortener/internal/app/tracing"
+)
+
+const (
+	maxEventsLimit = 1000
+	// maxEventsWait ограничивает long-poll, чтобы запрос не упирался в таймауты прокси.
+	maxEventsWait      = time.Minute
+	eventsPollInterval = 500 * time.Millisecond
+)
+
+var ErrInvalidEventsQuery = errors.New("invalid events query")
+
+// WaitLinkEvents возвращает события outbox с id больше after. Если их пока нет, ждёт новых
+// не дольше wait; пустой результат по истечении wait — не ошибка.
+func (s *Service) WaitLinkEvents(
+	ctx context.Context,
+	after int64,
+	limit int,
+	wait time.Duration,
+) ([]models.LinkEvent, error) {
+	ctx, span := tracing.Start(ctx, "service.WaitLinkEvents")
+	defer span.End()
+
+	if after < 0 || limit < 1 || limit > maxEventsLimit || wait < 0 || wait > maxEventsWait {
+		return nil, fmt.Errorf("%w: after must be >= 0, limit 1-%d, wait at most %s",
+			ErrInvalidEventsQuery, maxEventsLimit, maxEventsWait)
+	}
+
+	deadline := time.Now().Add(wait)
+	ticker := time.NewTicker(eventsPollInterval)
+	defer ticker.Stop()
+
+	for {
+		events, err := s.s.GetLinkEvents(ctx, after, limit)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get link events: %w", err)
+		}
+
+		if len(events) > 0 || !time.Now().Before(deadline) {
+			return events, nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return events, nil
+		case <-ticker.C:
+		}
+	}
+}
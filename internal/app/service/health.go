@@ -0,0 +1,71 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/a-bondar/go-url-shortener/internal/app/models"
+)
+
+const (
+	statusOK         = "ok"
+	statusFail       = "fail"
+	readinessTimeout = 2 * time.Second
+)
+
+var ErrNotReady = errors.New("service is not ready")
+
+var (
+	errCleanupStopped = errors.New("cleanup job is not running")
+	errShuttingDown   = errors.New("server is shutting down")
+)
+
+// BeginShutdown переводит сервис в состояние остановки: с этого момента
+// проверка готовности не проходит, и балансировщик перестаёт слать запросы.
+func (s *Service) BeginShutdown() {
+	s.shuttingDown.Store(true)
+}
+
+// CheckReadiness проверяет компоненты, без которых сервис не может обслуживать запросы.
+// Если хотя бы один из них не готов, вместе с отчётом возвращается ErrNotReady.
+func (s *Service) CheckReadiness(ctx context.Context) (models.Readiness, error) {
+	ctx, cancel := context.WithTimeout(ctx, readinessTimeout)
+	defer cancel()
+
+	res := models.Readiness{
+		Status:     statusOK,
+		Components: make(map[string]models.ComponentStatus),
+	}
+
+	check := func(name string, err error) {
+		if err == nil {
+			res.Components[name] = models.ComponentStatus{Status: statusOK}
+			return
+		}
+
+		res.Status = statusFail
+		res.Components[name] = models.ComponentStatus{Status: statusFail, Error: err.Error()}
+	}
+
+	check("store", s.Ping(ctx))
+	check("migrations", s.s.CheckMigrations(ctx))
+
+	var cleanupErr error
+	if !s.cleanupRunning.Load() {
+		cleanupErr = errCleanupStopped
+	}
+	check("cleanup", cleanupErr)
+
+	var shutdownErr error
+	if s.shuttingDown.Load() {
+		shutdownErr = errShuttingDown
+	}
+	check("shutdown", shutdownErr)
+
+	if res.Status != statusOK {
+		return res, ErrNotReady
+	}
+
+	return res, nil
+}
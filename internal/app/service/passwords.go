@@ -0,0 +1,84 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/a-bondar/go-url-shortener/internal/app/models"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	maxPasswordAttempts    = 5
+	passwordAttemptsWindow = 15 * time.Minute
+	// maxPasswordLength — bcrypt учитывает только первые 72 байта.
+	maxPasswordLength = 72
+)
+
+var (
+	ErrInvalidPassword  = errors.New("invalid password")
+	ErrPasswordRequired = errors.New("password required")
+	ErrWrongPassword    = errors.New("wrong password")
+	ErrTooManyAttempts  = errors.New("too many password attempts")
+)
+
+// AttemptsError сообщает, через сколько можно снова вводить пароль к ссылке.
+type AttemptsError struct {
+	RetryAfter time.Duration
+}
+
+func (e *AttemptsError) Error() string {
+	return fmt.Sprintf("%s, retry after %s", ErrTooManyAttempts, e.RetryAfter.Round(time.Second))
+}
+
+func (e *AttemptsError) Unwrap() error {
+	return ErrTooManyAttempts
+}
+
+func toLinkOptions(opts models.ShortenOptions) (models.LinkOptions, error) {
+	var res models.LinkOptions
+	if opts.Password == "" {
+		return res, nil
+	}
+
+	if len(opts.Password) > maxPasswordLength {
+		return res, fmt.Errorf("%w: must be at most %d bytes", ErrInvalidPassword, maxPasswordLength)
+	}
+
+	hash, err := bcrypt.GenerateFromPassword([]byte(opts.Password), bcrypt.DefaultCost)
+	if err != nil {
+		return res, fmt.Errorf("failed to hash password: %w", err)
+	}
+
+	res.PasswordHash = string(hash)
+
+	return res, nil
+}
+
+// checkPassword пропускает переход по защищённой ссылке только с верным паролем.
+// Неудачные попытки считаются по ссылке (домену и коду), а не по посетителю, чтобы перебор
+// с разных адресов упирался в тот же лимит.
+func (s *Service) checkPassword(data models.Data, password string) error {
+	if data.PasswordHash == "" {
+		return nil
+	}
+
+	if password == "" {
+		return ErrPasswordRequired
+	}
+
+	key := data.Domain + "/" + data.ShortURL
+	attempt, wait := s.passwordAttempts.reserve(key, time.Now())
+	if attempt == nil {
+		return &AttemptsError{RetryAfter: wait}
+	}
+
+	if err := bcrypt.CompareHashAndPassword([]byte(data.PasswordHash), []byte(password)); err != nil {
+		return ErrWrongPassword
+	}
+
+	s.passwordAttempts.release(key, attempt)
+
+	return nil
+}
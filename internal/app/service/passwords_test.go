@@ -0,0 +1,72 @@
+package service
+
+import (
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/a-bondar/go-url-shortener/internal/app/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/bcrypt"
+)
+
+func TestCheckPasswordLimitsParallelAttempts(t *testing.T) {
+	hash, err := bcrypt.GenerateFromPassword([]byte("open-sesame"), bcrypt.MinCost)
+	require.NoError(t, err)
+
+	s := &Service{passwordAttempts: newAttemptLimiter(maxPasswordAttempts, passwordAttemptsWindow)}
+	data := models.Data{ShortURL: "secret", LinkOptions: models.LinkOptions{PasswordHash: string(hash)}}
+
+	var (
+		wg      sync.WaitGroup
+		checked atomic.Int64
+	)
+	for range 50 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			if errors.Is(s.checkPassword(data, "wrong"), ErrWrongPassword) {
+				checked.Add(1)
+			}
+		}()
+	}
+	wg.Wait()
+
+	assert.Equal(t, int64(maxPasswordAttempts), checked.Load())
+
+	var attemptsErr *AttemptsError
+	require.ErrorAs(t, s.checkPassword(data, "open-sesame"), &attemptsErr)
+	assert.Positive(t, attemptsErr.RetryAfter)
+
+	// Тот же код на другом домене — другая ссылка со своим счётчиком.
+	data.Domain = "go.brand.example"
+	assert.NoError(t, s.checkPassword(data, "open-sesame"))
+}
+
+func TestAttemptLimiterReleasesSuccessfulAttempts(t *testing.T) {
+	l := newAttemptLimiter(2, time.Minute)
+	now := time.Now()
+
+	for range 5 {
+		attempt, wait := l.reserve("key", now)
+		require.NotNil(t, attempt)
+		assert.Zero(t, wait)
+		l.release("key", attempt)
+	}
+
+	for range 2 {
+		attempt, _ := l.reserve("key", now)
+		require.NotNil(t, attempt)
+	}
+
+	attempt, wait := l.reserve("key", now.Add(time.Second))
+	assert.Nil(t, attempt)
+	assert.Equal(t, time.Minute-time.Second, wait)
+
+	attempt, _ = l.reserve("key", now.Add(time.Minute))
+	assert.NotNil(t, attempt, "a new window starts after the old one expires")
+}
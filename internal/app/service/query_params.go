@@ -0,0 +1,106 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/a-bondar/go-url-shortener/internal/app/config"
+	"github.com/a-bondar/go-url-shortener/internal/app/models"
+)
+
+const maxQueryParams = 20
+
+var ErrInvalidQueryParams = errors.New("invalid query params")
+
+// queryParams собирает параметры по умолчанию для ссылки: явно заданные и метки UTM.
+// Метки UTM перекрывают одноимённые явные параметры.
+func queryParams(opts models.ShortenOptions) (map[string]string, error) {
+	res := make(map[string]string, len(opts.QueryParams))
+	for key, value := range opts.QueryParams {
+		key = strings.TrimSpace(key)
+		if key == "" {
+			return nil, fmt.Errorf("%w: empty parameter name", ErrInvalidQueryParams)
+		}
+
+		res[key] = value
+	}
+
+	if utm := opts.UTM; utm != nil {
+		for key, value := range map[string]string{
+			"utm_source":   utm.Source,
+			"utm_medium":   utm.Medium,
+			"utm_campaign": utm.Campaign,
+			"utm_term":     utm.Term,
+			"utm_content":  utm.Content,
+		} {
+			if value = strings.TrimSpace(value); value != "" {
+				res[key] = value
+			}
+		}
+	}
+
+	if len(res) > maxQueryParams {
+		return nil, fmt.Errorf("%w: at most %d parameters allowed", ErrInvalidQueryParams, maxQueryParams)
+	}
+
+	if len(res) == 0 {
+		return nil, nil
+	}
+
+	return res, nil
+}
+
+// destinationURL добавляет к адресу назначения параметры ссылки params и, по политике
+// слияния, параметры посетителя. Параметры, уже записанные в самом адресе назначения,
+// не перекрываются: их владелец ссылки задал явно.
+func (s *Service) destinationURL(destination string, params map[string]string, incoming url.Values) (string, error) {
+	if s.cfg.QueryMergePolicy == config.QueryMergeNone {
+		incoming = nil
+	}
+
+	if len(params) == 0 && len(incoming) == 0 {
+		return destination, nil
+	}
+
+	u, err := url.Parse(destination)
+	if err != nil {
+		return "", fmt.Errorf("failed to parse destination: %w", err)
+	}
+
+	// Исходную строку запроса не перекодируем: порядок, повторы и экранирование её
+	// параметров важны для некоторых адресов назначения. Дописываем только новые пары.
+	own := u.Query()
+	added := make(url.Values)
+
+	for key, value := range params {
+		if !own.Has(key) {
+			added.Set(key, value)
+		}
+	}
+
+	for key, values := range incoming {
+		if own.Has(key) {
+			continue
+		}
+
+		if _, fromLink := params[key]; fromLink && s.cfg.QueryMergePolicy != config.QueryMergeRequest {
+			continue
+		}
+
+		added[key] = values
+	}
+
+	if len(added) == 0 {
+		return destination, nil
+	}
+
+	if u.RawQuery == "" || strings.HasSuffix(u.RawQuery, "&") {
+		u.RawQuery += added.Encode()
+	} else {
+		u.RawQuery += "&" + added.Encode()
+	}
+
+	return u.String(), nil
+}
@@ -0,0 +1,78 @@
+package service
+
+import (
+	"net/url"
+	"testing"
+
+	"github.com/a-bondar/go-url-shortener/internal/app/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDestinationURL(t *testing.T) {
+	testCases := []struct {
+		name        string
+		policy      string
+		destination string
+		params      map[string]string
+		incoming    url.Values
+		expected    string
+	}{
+		{
+			name:        "Original query is kept as is",
+			policy:      config.QueryMergeLink,
+			destination: "https://a.example/p?b=2&a=1&a=0&sig=x%2By",
+			params:      map[string]string{"utm_source": "mail"},
+			expected:    "https://a.example/p?b=2&a=1&a=0&sig=x%2By&utm_source=mail",
+		},
+		{
+			name:        "Nothing added keeps destination untouched",
+			policy:      config.QueryMergeLink,
+			destination: "https://a.example/p?q=a+b&q=c",
+			params:      map[string]string{"q": "other"},
+			incoming:    url.Values{"q": {"visitor"}},
+			expected:    "https://a.example/p?q=a+b&q=c",
+		},
+		{
+			name:        "Destination without query",
+			policy:      config.QueryMergeLink,
+			destination: "https://a.example/p#top",
+			params:      map[string]string{"utm_source": "mail"},
+			incoming:    url.Values{"ref": {"x y"}},
+			expected:    "https://a.example/p?ref=x+y&utm_source=mail#top",
+		},
+		{
+			name:        "Link params win by link policy",
+			policy:      config.QueryMergeLink,
+			destination: "https://a.example/?x=1",
+			params:      map[string]string{"utm_source": "mail"},
+			incoming:    url.Values{"utm_source": {"visitor"}},
+			expected:    "https://a.example/?x=1&utm_source=mail",
+		},
+		{
+			name:        "Visitor params win by request policy",
+			policy:      config.QueryMergeRequest,
+			destination: "https://a.example/?x=1",
+			params:      map[string]string{"utm_source": "mail"},
+			incoming:    url.Values{"utm_source": {"visitor"}},
+			expected:    "https://a.example/?x=1&utm_source=visitor",
+		},
+		{
+			name:        "Visitor params are dropped by none policy",
+			policy:      config.QueryMergeNone,
+			destination: "https://a.example/?x=1",
+			incoming:    url.Values{"ref": {"visitor"}},
+			expected:    "https://a.example/?x=1",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			s := &Service{cfg: &config.Config{QueryMergePolicy: tc.policy}}
+
+			res, err := s.destinationURL(tc.destination, tc.params, tc.incoming)
+			require.NoError(t, err)
+			assert.Equal(t, tc.expected, res)
+		})
+	}
+}
@@ -0,0 +1,127 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"slices"
+	"strings"
+
+	"github.com/a-bondar/go-url-shortener/internal/app/models"
+)
+
+// maxRedirectChain — сколько наших ссылок подряд разворачивается, прежде чем цепочка считается слишком длинной.
+const maxRedirectChain = 5
+
+var (
+	ErrSelfReference = errors.New("destination points at the shortener itself")
+	ErrRedirectLoop  = errors.New("destination forms a redirect loop")
+)
+
+// resolveOwnLinks разворачивает адрес, ведущий на наш же сервис, в конечный адрес назначения,
+// чтобы короткие ссылки не образовывали цепочек, скрывающих настоящий адрес.
+// self — ссылка, адрес которой меняется (пустая для новой): попадание на неё в цепочке — петля.
+func (s *Service) resolveOwnLinks(ctx context.Context, fullURL string, self models.LinkKey) (string, error) {
+	visited := make([]string, 0, maxRedirectChain)
+
+	for range maxRedirectChain {
+		domain, linkID, ok := s.ownLinkID(fullURL)
+		if !ok {
+			return fullURL, nil
+		}
+
+		if linkID == "" {
+			return "", fmt.Errorf("%w: %q", ErrSelfReference, fullURL)
+		}
+
+		if slices.Contains(visited, domain+"/"+linkID) || (domain == self.Domain && linkID == self.ShortURL) {
+			return "", fmt.Errorf("%w: %q leads back to %s", ErrRedirectLoop, fullURL, linkID)
+		}
+		visited = append(visited, domain+"/"+linkID)
+
+		data, err := s.s.GetURL(ctx, domain, linkID)
+		if err != nil || data.Deleted {
+			return "", fmt.Errorf("%w: %q is not an active short link", ErrSelfReference, fullURL)
+		}
+
+		// Разворачивание обошло бы пароль, поэтому на защищённые ссылки ссылаться нельзя.
+		if data.PasswordHash != "" {
+			return "", fmt.Errorf("%w: %q is password protected", ErrSelfReference, fullURL)
+		}
+
+		// Так же нельзя обойти время запуска: адрес назначения раскрылся бы раньше срока.
+		if data.NotBefore != nil {
+			return "", fmt.Errorf("%w: %q is scheduled", ErrSelfReference, fullURL)
+		}
+
+		// Ссылка без ограничения на адрес ссылки с лимитом сняла бы этот лимит.
+		if data.MaxClicks > 0 {
+			return "", fmt.Errorf("%w: %q has a click limit", ErrSelfReference, fullURL)
+		}
+
+		// У A/B-ссылки и ссылки с правилами нет единственного конечного адреса, к которому можно свернуть цепочку.
+		if len(data.Targets) > 0 || len(data.Rules) > 0 {
+			return "", fmt.Errorf("%w: %q leads to several destinations", ErrSelfReference, fullURL)
+		}
+
+		fullURL = data.OriginalURL
+	}
+
+	if _, _, ok := s.ownLinkID(fullURL); ok {
+		return "", fmt.Errorf("%w: more than %d short links in a chain", ErrRedirectLoop, maxRedirectChain)
+	}
+
+	return fullURL, nil
+}
+
+// ownLinkID сообщает, ведёт ли адрес на наш сервис, и если да — на какую короткую ссылку какого домена.
+// Пустой linkID при ok означает адрес сервиса, не являющийся короткой ссылкой.
+func (s *Service) ownLinkID(rawURL string) (domain string, linkID string, ok bool) {
+	u, err := url.Parse(strings.TrimSpace(rawURL))
+	if err != nil || u.Host == "" {
+		return "", "", false
+	}
+
+	baseURL := s.cfg.ShortLinkBaseURL
+	if branded, isBranded := s.domains[strings.ToLower(u.Host)]; isBranded {
+		domain, baseURL = strings.ToLower(u.Host), branded
+	}
+
+	base, err := url.Parse(baseURL)
+	if err != nil {
+		return "", "", false
+	}
+
+	path := u.Path
+	switch {
+	case strings.EqualFold(u.Host, base.Host):
+		basePath := strings.TrimSuffix(base.Path, "/")
+		if path != basePath && !strings.HasPrefix(path, basePath+"/") {
+			return "", "", false
+		}
+
+		path = strings.TrimPrefix(path, basePath)
+	case !s.isAliasHost(u):
+		return "", "", false
+	}
+
+	linkID, _, _ = strings.Cut(strings.Trim(path, "/"), "/")
+
+	return domain, linkID, true
+}
+
+func (s *Service) isAliasHost(u *url.URL) bool {
+	for _, alias := range s.cfg.AliasDomains {
+		host := u.Hostname()
+		if strings.Contains(alias, ":") {
+			host = u.Host
+		}
+
+		if strings.EqualFold(host, alias) {
+			return true
+		}
+	}
+
+	return false
+}
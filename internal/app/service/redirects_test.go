@@ -0,0 +1,133 @@
+package service
+
+import (
+	"context"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/a-bondar/go-url-shortener/internal/app/config"
+	"github.com/a-bondar/go-url-shortener/internal/app/models"
+	"github.com/a-bondar/go-url-shortener/internal/app/store"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+const (
+	testUserID = "user-1"
+	testDomain = "go.brand.example"
+)
+
+// newTestService собирает сервис поверх хранилища в памяти с основным и брендовым доменами.
+func newTestService(t *testing.T) (*Service, store.Store) {
+	t.Helper()
+
+	st, err := store.NewStore(context.Background(), store.Config{}, zap.NewNop())
+	require.NoError(t, err)
+	t.Cleanup(st.Close)
+
+	cfg := &config.Config{
+		ShortLinkBaseURL: "http://localhost:8080",
+		AliasDomains:     []string{"sho.rt"},
+		Domains:          []string{"https://" + testDomain},
+	}
+
+	return NewService(st, cfg, zap.NewNop()), st
+}
+
+func saveLink(t *testing.T, st store.Store, domain string, shortURL string, fullURL string, opts models.LinkOptions) {
+	t.Helper()
+
+	opts.Domain = domain
+	_, err := st.SaveURL(context.Background(), fullURL, shortURL, testUserID, models.LinkMeta{}, opts)
+	require.NoError(t, err)
+}
+
+func TestResolveOwnLinks(t *testing.T) {
+	ctx := context.Background()
+	s, st := newTestService(t)
+
+	saveLink(t, st, "", "final", "https://example.com/landing", models.LinkOptions{})
+	saveLink(t, st, "", "hop", "http://localhost:8080/final", models.LinkOptions{})
+	saveLink(t, st, testDomain, "final", "https://brand.example/landing", models.LinkOptions{})
+	saveLink(t, st, "", "loop-a", "http://localhost:8080/loop-b", models.LinkOptions{})
+	saveLink(t, st, "", "loop-b", "http://localhost:8080/loop-a", models.LinkOptions{})
+	saveLink(t, st, "", "gone", "https://example.com/gone", models.LinkOptions{})
+	require.NoError(t, st.DeleteURLs(ctx, "", []string{"gone"}, testUserID))
+	saveLink(t, st, "", "locked", "https://example.com/secret", models.LinkOptions{PasswordHash: "hash"})
+	launch := time.Now().Add(time.Hour)
+	saveLink(t, st, "", "launch", "https://example.com/secret-launch", models.LinkOptions{NotBefore: &launch})
+	saveLink(t, st, "", "once", "https://example.com/once", models.LinkOptions{MaxClicks: 1, ClicksLeft: 1})
+	saveLink(t, st, "", "split", "https://example.com/a", models.LinkOptions{
+		Targets: []models.LinkTarget{
+			{URL: "https://example.com/a", Weight: 1},
+			{URL: "https://example.com/b", Weight: 1},
+		},
+	})
+
+	// Цепочка из maxRedirectChain+1 ссылок: chain0 -> chain1 -> ... -> внешний адрес.
+	for i := range maxRedirectChain + 1 {
+		next := "http://localhost:8080/chain" + strconv.Itoa(i+1)
+		if i == maxRedirectChain {
+			next = "https://example.com/end"
+		}
+		saveLink(t, st, "", "chain"+strconv.Itoa(i), next, models.LinkOptions{})
+	}
+
+	testCases := []struct {
+		name     string
+		fullURL  string
+		self     models.LinkKey
+		expected string
+		err      error
+	}{
+		{name: "External URL", fullURL: "https://example.com/page", expected: "https://example.com/page"},
+		{name: "Chain collapses", fullURL: "http://localhost:8080/hop", expected: "https://example.com/landing"},
+		{name: "Alias domain", fullURL: "https://sho.rt/final?x=1", expected: "https://example.com/landing"},
+		{
+			name:     "Branded domain has own codes",
+			fullURL:  "https://" + testDomain + "/final",
+			expected: "https://brand.example/landing",
+		},
+		{
+			name:     "Same code on another domain is not a loop",
+			fullURL:  "http://localhost:8080/final",
+			self:     models.LinkKey{Domain: testDomain, ShortURL: "final"},
+			expected: "https://example.com/landing",
+		},
+		{
+			name:    "Chain leads back to changed link",
+			fullURL: "http://localhost:8080/hop",
+			self:    models.LinkKey{ShortURL: "final"},
+			err:     ErrRedirectLoop,
+		},
+		{name: "Loop inside chain", fullURL: "http://localhost:8080/loop-a", err: ErrRedirectLoop},
+		{name: "Chain too long", fullURL: "http://localhost:8080/chain0", err: ErrRedirectLoop},
+		{
+			name:     "Chain at the limit",
+			fullURL:  "http://localhost:8080/chain1",
+			expected: "https://example.com/end",
+		},
+		{name: "Service root", fullURL: "http://localhost:8080/", err: ErrSelfReference},
+		{name: "Unknown code", fullURL: "http://localhost:8080/missing", err: ErrSelfReference},
+		{name: "Deleted link", fullURL: "http://localhost:8080/gone", err: ErrSelfReference},
+		{name: "Password protected link", fullURL: "http://localhost:8080/locked", err: ErrSelfReference},
+		{name: "Scheduled link", fullURL: "http://localhost:8080/launch", err: ErrSelfReference},
+		{name: "Click limited link", fullURL: "http://localhost:8080/once", err: ErrSelfReference},
+		{name: "Link with several destinations", fullURL: "http://localhost:8080/split", err: ErrSelfReference},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			res, err := s.resolveOwnLinks(ctx, tc.fullURL, tc.self)
+			if tc.err != nil {
+				assert.ErrorIs(t, err, tc.err)
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, tc.expected, res)
+		})
+	}
+}
@@ -0,0 +1,172 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/netip"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/a-bondar/go-url-shortener/internal/app/models"
+)
+
+const maxRoutingRules = 20
+
+var ErrInvalidRules = errors.New("invalid routing rules")
+
+var platforms = []string{
+	models.PlatformIOS, models.PlatformAndroid, models.PlatformWindows, models.PlatformMacOS, models.PlatformLinux,
+}
+
+// GeoLocator определяет страну посетителя по IP.
+type GeoLocator interface {
+	Country(ip netip.Addr) string
+}
+
+// SetGeoLocator включает правила по странам. Без него такие правила создать нельзя.
+func (s *Service) SetGeoLocator(locator GeoLocator) {
+	s.geo = locator
+}
+
+// routingRules приводит условия правил к каноническому виду и проверяет адреса так же,
+// как основной адрес назначения.
+func (s *Service) routingRules(ctx context.Context, rules []models.RoutingRule) ([]models.RoutingRule, error) {
+	if len(rules) > maxRoutingRules {
+		return nil, fmt.Errorf("%w: at most %d rules allowed", ErrInvalidRules, maxRoutingRules)
+	}
+
+	res := make([]models.RoutingRule, 0, len(rules))
+	for i, rule := range rules {
+		rule.Platform = strings.ToLower(strings.TrimSpace(rule.Platform))
+		rule.Language = strings.ToLower(strings.TrimSpace(rule.Language))
+		rule.Country = strings.ToUpper(strings.TrimSpace(rule.Country))
+		rule.URL = strings.TrimSpace(rule.URL)
+
+		switch {
+		case rule.Platform == "" && rule.Language == "" && rule.Country == "":
+			return nil, fmt.Errorf("%w: rule %d has no conditions", ErrInvalidRules, i+1)
+		case rule.Platform != "" && !slices.Contains(platforms, rule.Platform):
+			return nil, fmt.Errorf("%w: rule %d has unknown platform %q, expected one of %s",
+				ErrInvalidRules, i+1, rule.Platform, strings.Join(platforms, ", "))
+		case rule.Country != "" && len(rule.Country) != 2:
+			return nil, fmt.Errorf("%w: rule %d country must be a two-letter code", ErrInvalidRules, i+1)
+		case rule.Country != "" && s.geo == nil:
+			return nil, fmt.Errorf("%w: country rules need a GeoIP database", ErrInvalidRules)
+		case !isValidURL(rule.URL):
+			return nil, fmt.Errorf("%w: rule %d has invalid URL %q", ErrInvalidRules, i+1, rule.URL)
+		}
+
+		fullURL, err := s.resolveOwnLinks(ctx, rule.URL, models.LinkKey{})
+		if err != nil {
+			return nil, err
+		}
+
+		if err = s.checkDestination(ctx, fullURL); err != nil {
+			return nil, err
+		}
+
+		rule.URL = fullURL
+		res = append(res, rule)
+	}
+
+	if len(res) == 0 {
+		return nil, nil
+	}
+
+	return res, nil
+}
+
+// matchRule возвращает первое правило, под которое подходит посетитель.
+// Страна определяется, только если до правила с ней дошла очередь.
+func (s *Service) matchRule(rules []models.RoutingRule, visit models.Visit) (models.RoutingRule, bool) {
+	if len(rules) == 0 {
+		return models.RoutingRule{}, false
+	}
+
+	platform := detectPlatform(visit.UserAgent)
+	language := preferredLanguage(visit.AcceptLanguage)
+
+	var (
+		country       string
+		countryLooked bool
+	)
+
+	for _, rule := range rules {
+		if rule.Platform != "" && rule.Platform != platform {
+			continue
+		}
+
+		if rule.Language != "" && language != rule.Language && !strings.HasPrefix(language, rule.Language+"-") {
+			continue
+		}
+
+		if rule.Country != "" {
+			if !countryLooked && s.geo != nil {
+				country = s.geo.Country(visit.IP)
+			}
+			countryLooked = true
+
+			if rule.Country != country {
+				continue
+			}
+		}
+
+		return rule, true
+	}
+
+	return models.RoutingRule{}, false
+}
+
+// detectPlatform определяет операционную систему по User-Agent; пусто — не удалось.
+// Порядок важен: в User-Agent Android и iOS встречаются "Linux" и "Mac OS X".
+func detectPlatform(userAgent string) string {
+	switch {
+	case strings.Contains(userAgent, "iPhone"), strings.Contains(userAgent, "iPad"),
+		strings.Contains(userAgent, "iPod"):
+		return models.PlatformIOS
+	case strings.Contains(userAgent, "Android"):
+		return models.PlatformAndroid
+	case strings.Contains(userAgent, "Windows"):
+		return models.PlatformWindows
+	case strings.Contains(userAgent, "Macintosh"), strings.Contains(userAgent, "Mac OS X"):
+		return models.PlatformMacOS
+	case strings.Contains(userAgent, "Linux"), strings.Contains(userAgent, "X11"):
+		return models.PlatformLinux
+	}
+
+	return ""
+}
+
+// preferredLanguage возвращает язык с наибольшим весом из Accept-Language в нижнем регистре.
+func preferredLanguage(acceptLanguage string) string {
+	var (
+		best       string
+		bestWeight = 0.0
+	)
+
+	for _, part := range strings.Split(acceptLanguage, ",") {
+		tag, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		tag = strings.ToLower(strings.TrimSpace(tag))
+		if tag == "" || tag == "*" {
+			continue
+		}
+
+		weight := 1.0
+		if q, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			parsed, err := strconv.ParseFloat(q, 64)
+			if err != nil {
+				continue
+			}
+
+			weight = parsed
+		}
+
+		if weight > bestWeight {
+			best, bestWeight = tag, weight
+		}
+	}
+
+	return best
+}
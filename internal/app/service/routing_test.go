@@ -0,0 +1,173 @@
+package service
+
+import (
+	"net/netip"
+	"testing"
+
+	"github.com/a-bondar/go-url-shortener/internal/app/models"
+	"github.com/stretchr/testify/assert"
+)
+
+// geoStub отвечает одной страной и считает обращения.
+type geoStub struct {
+	country string
+	lookups int
+}
+
+func (g *geoStub) Country(_ netip.Addr) string {
+	g.lookups++
+
+	return g.country
+}
+
+func TestDetectPlatform(t *testing.T) {
+	testCases := []struct {
+		name      string
+		userAgent string
+		expected  string
+	}{
+		{
+			name:      "iPhone mentions Mac OS X",
+			userAgent: "Mozilla/5.0 (iPhone; CPU iPhone OS 17_4 like Mac OS X) AppleWebKit/605.1.15 Mobile/15E148",
+			expected:  models.PlatformIOS,
+		},
+		{
+			name:      "iPad",
+			userAgent: "Mozilla/5.0 (iPad; CPU OS 16_6 like Mac OS X) AppleWebKit/605.1.15",
+			expected:  models.PlatformIOS,
+		},
+		{
+			name:      "Android mentions Linux",
+			userAgent: "Mozilla/5.0 (Linux; Android 14; Pixel 8) AppleWebKit/537.36 Chrome/124.0 Mobile Safari/537.36",
+			expected:  models.PlatformAndroid,
+		},
+		{
+			name:      "Windows",
+			userAgent: "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 Chrome/124.0 Safari/537.36",
+			expected:  models.PlatformWindows,
+		},
+		{
+			name:      "macOS",
+			userAgent: "Mozilla/5.0 (Macintosh; Intel Mac OS X 14_4) AppleWebKit/605.1.15 Version/17.4 Safari/605.1.15",
+			expected:  models.PlatformMacOS,
+		},
+		{
+			name:      "Linux desktop",
+			userAgent: "Mozilla/5.0 (X11; Ubuntu; Linux x86_64; rv:125.0) Gecko/20100101 Firefox/125.0",
+			expected:  models.PlatformLinux,
+		},
+		{name: "Unknown client", userAgent: "curl/8.5.0", expected: ""},
+		{name: "Empty", userAgent: "", expected: ""},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.expected, detectPlatform(tc.userAgent))
+		})
+	}
+}
+
+func TestPreferredLanguage(t *testing.T) {
+	testCases := []struct {
+		name           string
+		acceptLanguage string
+		expected       string
+	}{
+		{name: "Single tag", acceptLanguage: "de", expected: "de"},
+		{name: "First of equal weights", acceptLanguage: "fr-CH, fr;q=1, en", expected: "fr-ch"},
+		{name: "Highest weight wins", acceptLanguage: "en;q=0.5, ru;q=0.9, de;q=0.7", expected: "ru"},
+		{name: "Implicit weight beats explicit", acceptLanguage: "en;q=0.9, pt-BR", expected: "pt-br"},
+		{name: "Wildcard is skipped", acceptLanguage: "*, es;q=0.1", expected: "es"},
+		{name: "Bad weight is skipped", acceptLanguage: "it;q=high, nl;q=0.2", expected: "nl"},
+		{name: "Zero weight is not acceptable", acceptLanguage: "ja;q=0", expected: ""},
+		{name: "Empty header", acceptLanguage: "", expected: ""},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.expected, preferredLanguage(tc.acceptLanguage))
+		})
+	}
+}
+
+func TestMatchRule(t *testing.T) {
+	const (
+		iPhone  = "Mozilla/5.0 (iPhone; CPU iPhone OS 17_4 like Mac OS X)"
+		windows = "Mozilla/5.0 (Windows NT 10.0; Win64; x64)"
+	)
+
+	rules := []models.RoutingRule{
+		{Platform: models.PlatformIOS, URL: "https://apps.example/ios"},
+		{Language: "en", URL: "https://a.example/en"},
+		{Country: "DE", URL: "https://a.example/de"},
+		{Platform: models.PlatformWindows, Country: "FR", URL: "https://a.example/fr-windows"},
+	}
+
+	testCases := []struct {
+		name     string
+		visit    models.Visit
+		country  string
+		expected string
+		lookups  int
+	}{
+		{
+			name:     "First matching rule wins without country lookup",
+			visit:    models.Visit{UserAgent: iPhone, AcceptLanguage: "en"},
+			country:  "DE",
+			expected: "https://apps.example/ios",
+		},
+		{
+			name:     "Language prefix matches regional tag",
+			visit:    models.Visit{UserAgent: windows, AcceptLanguage: "en-GB,de;q=0.5"},
+			country:  "DE",
+			expected: "https://a.example/en",
+		},
+		{
+			name:     "Language prefix needs a dash",
+			visit:    models.Visit{UserAgent: windows, AcceptLanguage: "eng"},
+			country:  "DE",
+			expected: "https://a.example/de",
+			lookups:  1,
+		},
+		{
+			name:     "Country is looked up once for several rules",
+			visit:    models.Visit{UserAgent: windows},
+			country:  "FR",
+			expected: "https://a.example/fr-windows",
+			lookups:  1,
+		},
+		{
+			name:    "No rule matches",
+			visit:   models.Visit{UserAgent: "curl/8.5.0"},
+			country: "FR",
+			lookups: 1,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			geo := &geoStub{country: tc.country}
+			s := &Service{geo: geo}
+
+			rule, ok := s.matchRule(rules, tc.visit)
+			assert.Equal(t, tc.expected != "", ok)
+			assert.Equal(t, tc.expected, rule.URL)
+			assert.Equal(t, tc.lookups, geo.lookups)
+		})
+	}
+}
+
+func TestMatchRuleWithoutGeoLocator(t *testing.T) {
+	s := &Service{}
+	rules := []models.RoutingRule{
+		{Country: "DE", URL: "https://a.example/de"},
+		{Language: "de", URL: "https://a.example/de-speaking"},
+	}
+
+	rule, ok := s.matchRule(rules, models.Visit{AcceptLanguage: "de-AT"})
+	assert.True(t, ok)
+	assert.Equal(t, "https://a.example/de-speaking", rule.URL)
+
+	_, ok = s.matchRule(nil, models.Visit{AcceptLanguage: "de-AT"})
+	assert.False(t, ok)
+}
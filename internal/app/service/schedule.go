@@ -0,0 +1,44 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/a-bondar/go-url-shortener/internal/app/models"
+)
+
+var ErrNotYetActive = errors.New("link is not active yet")
+
+// NotYetActiveError сообщает, когда ссылка начнёт переадресовывать.
+type NotYetActiveError struct {
+	NotBefore time.Time
+}
+
+func (e *NotYetActiveError) Error() string {
+	return fmt.Sprintf("%s, active from %s", ErrNotYetActive, e.NotBefore.Format(time.RFC3339))
+}
+
+func (e *NotYetActiveError) Unwrap() error {
+	return ErrNotYetActive
+}
+
+// checkActive не пускает по ссылке до назначенного времени запуска.
+func checkActive(data models.Data, now time.Time) error {
+	if data.NotBefore != nil && now.Before(*data.NotBefore) {
+		return &NotYetActiveError{NotBefore: *data.NotBefore}
+	}
+
+	return nil
+}
+
+// notBefore приводит время запуска к UTC; нулевое время означает, что ссылка активна сразу.
+func notBefore(opts models.ShortenOptions) *time.Time {
+	if opts.NotBefore == nil || opts.NotBefore.IsZero() {
+		return nil
+	}
+
+	res := opts.NotBefore.UTC()
+
+	return &res
+}
@@ -0,0 +1,38 @@
+package service
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/a-bondar/go-url-shortener/internal/app/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCheckActive(t *testing.T) {
+	start := time.Date(2026, time.March, 1, 9, 0, 0, 0, time.UTC)
+	data := models.Data{LinkOptions: models.LinkOptions{NotBefore: &start}}
+
+	err := checkActive(data, start.Add(-time.Second))
+	require.ErrorIs(t, err, ErrNotYetActive)
+
+	var notYet *NotYetActiveError
+	require.True(t, errors.As(err, &notYet))
+	assert.Equal(t, start, notYet.NotBefore)
+
+	assert.NoError(t, checkActive(data, start))
+	assert.NoError(t, checkActive(data, start.Add(time.Hour)))
+	assert.NoError(t, checkActive(models.Data{}, start))
+}
+
+func TestNotBefore(t *testing.T) {
+	assert.Nil(t, notBefore(models.ShortenOptions{}))
+	assert.Nil(t, notBefore(models.ShortenOptions{NotBefore: &time.Time{}}))
+
+	local := time.Date(2026, time.March, 1, 12, 0, 0, 0, time.FixedZone("MSK", 3*60*60))
+	res := notBefore(models.ShortenOptions{NotBefore: &local})
+	require.NotNil(t, res)
+	assert.Equal(t, time.UTC, res.Location())
+	assert.True(t, res.Equal(local))
+}
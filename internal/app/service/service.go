@@ -6,32 +6,99 @@ import (
 	"fmt"
 	"math/rand"
 	"net/url"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/a-bondar/go-url-shortener/internal/app/config"
+	"github.com/a-bondar/go-url-shortener/internal/app/logger"
 	"github.com/a-bondar/go-url-shortener/internal/app/models"
+	"github.com/a-bondar/go-url-shortener/internal/app/tracing"
 	"go.uber.org/zap"
 )
 
 type Store interface {
-	SaveURL(ctx context.Context, fullURL string, shortURL string, userID string) (string, error)
-	GetURL(ctx context.Context, shortURL string) (string, bool, error)
-	GetURLs(ctx context.Context, userID string) (map[string]string, error)
-	DeleteURLs(ctx context.Context, urls []string, userID string) error
-	CleanupDeletedURLs(ctx context.Context) error
+	SaveURL(ctx context.Context, fullURL string, shortURL string, userID string,
+		meta models.LinkMeta, opts models.LinkOptions) (string, error)
+	GetURL(ctx context.Context, domain string, shortURL string) (models.Data, error)
+	GetURLs(ctx context.Context, userID string, filter models.URLsFilter) ([]models.Data, error)
+	GetUserURL(ctx context.Context, domain string, shortURL string, userID string) (models.Data, error)
+	IncrementTargetClicks(ctx context.Context, domain string, shortURL string, target int) error
+	ConsumeClick(ctx context.Context, domain string, shortURL string) error
+	UpdateURLMeta(ctx context.Context,
+		domain string, shortURL string, userID string, patch models.LinkMetaPatch) (models.Data, error)
+	UpdateURL(ctx context.Context, domain string, shortURL string, userID string, fullURL string) (models.Data, error)
+	GetURLRevisions(ctx context.Context, domain string, shortURL string, userID string) ([]models.URLRevision, error)
+	DeleteURLs(ctx context.Context, domain string, urls []string, userID string) error
+	RestoreURLs(ctx context.Context, domain string, urls []string, userID string) error
+	CleanupDeletedURLs(ctx context.Context, deletedBefore time.Time) error
 	SaveURLsBatch(ctx context.Context, urls map[string]string, userID string) (map[string]string, error)
+	ClaimURLs(ctx context.Context, fromUserID string, toUserID string) (int64, error)
+	CreateUser(ctx context.Context, user models.User) error
+	GetUser(ctx context.Context, userID string) (models.User, error)
+	CreateAPIKey(ctx context.Context, key models.APIKey) error
+	GetAPIKeyByHash(ctx context.Context, keyHash string) (models.APIKey, error)
+	GetAPIKeys(ctx context.Context, userID string) ([]models.APIKey, error)
+	RevokeAPIKey(ctx context.Context, keyID string, userID string) error
+	RevokeSession(ctx context.Context, session models.RevokedSession) error
+	IsSessionRevoked(ctx context.Context, sessionID string) (bool, error)
+	ConsumeRefreshToken(ctx context.Context, token models.RevokedSession) (bool, error)
+	CleanupRevokedSessions(ctx context.Context, expiredBefore time.Time) error
+	CreateWorkspace(ctx context.Context, workspace models.Workspace, owner models.WorkspaceMember) error
+	GetUserWorkspaces(ctx context.Context, userID string) ([]models.HandleWorkspaceResponse, error)
+	GetWorkspaceMembers(ctx context.Context, workspaceID string) ([]models.WorkspaceMember, error)
+	SaveWorkspaceMember(ctx context.Context, member models.WorkspaceMember) error
+	RemoveWorkspaceMember(ctx context.Context, workspaceID string, userID string) error
+	TransferURLs(ctx context.Context,
+		domain string, urls []string, fromOwnerID string, toOwnerID string) (int64, error)
+	CreateWebhook(ctx context.Context, webhook models.Webhook) error
+	GetWebhooks(ctx context.Context, userID string) ([]models.Webhook, error)
+	DeleteWebhook(ctx context.Context, webhookID string, userID string) error
+	EnqueueDeliveries(ctx context.Context, deliveries []models.WebhookDelivery) error
+	GetDeadDeliveries(ctx context.Context, userID string) ([]models.WebhookDelivery, error)
+	RequeueDelivery(ctx context.Context, deliveryID string, userID string, now time.Time) error
+	GetLinkEvents(ctx context.Context, after int64, limit int) ([]models.LinkEvent, error)
 	Ping(ctx context.Context) error
+	CheckMigrations(ctx context.Context) error
+}
+
+// DestinationPolicy решает, можно ли вести короткую ссылку на адрес.
+type DestinationPolicy interface {
+	Check(ctx context.Context, rawURL string) models.DestinationVerdict
 }
 
 type Service struct {
-	s      Store
-	cfg    *config.Config
-	logger *zap.Logger
-	ticker *time.Ticker
+	s              Store
+	cfg            *config.Config
+	logger         *zap.Logger
+	ticker         *time.Ticker
+	cleanupDone    chan struct{}
+	cleanupStop    sync.Once
+	cleanupRunning atomic.Bool
+	shuttingDown   atomic.Bool
+	// passwordAttempts — неудачные попытки ввести пароль, по коротким ссылкам.
+	passwordAttempts *attemptLimiter
+	policy           DestinationPolicy
+	// domains — брендовые домены: хост -> базовый URL.
+	domains map[string]string
+	geo     GeoLocator
 }
 
 func NewService(s Store, cfg *config.Config, logger *zap.Logger) *Service {
-	return &Service{s: s, cfg: cfg, logger: logger}
+	return &Service{
+		s:                s,
+		cfg:              cfg,
+		logger:           logger,
+		passwordAttempts: newAttemptLimiter(maxPasswordAttempts, passwordAttemptsWindow),
+		domains:          parseDomains(cfg),
+	}
+}
+
+// SetDestinationPolicy включает проверку адресов назначения. Без неё разрешены любые адреса.
+func (s *Service) SetDestinationPolicy(policy DestinationPolicy) {
+	s.policy = policy
 }
 
 const cleanupInterval = 1 * time.Hour
@@ -42,7 +109,13 @@ const (
 	failedToBuildURLError = "failed to build URL: %w"
 )
 
-var ErrConflict = errors.New("data conflict")
+var (
+	ErrConflict   = errors.New("data conflict")
+	ErrInvalidURL = errors.New("invalid URL")
+	ErrURLDeleted = errors.New("URL is deleted")
+	// ErrDestinationBlocked — адрес назначения запрещён политикой.
+	ErrDestinationBlocked = errors.New("destination is blocked")
+)
 
 func generateRandomString(size int) string {
 	rnd := rand.New(rand.NewSource(time.Now().UnixNano()))
@@ -55,13 +128,14 @@ func generateRandomString(size int) string {
 	return string(b)
 }
 
-func (s *Service) shortenURL(ctx context.Context) (string, error) {
+// shortenURL подбирает код, свободный в пространстве имён домена.
+func (s *Service) shortenURL(ctx context.Context, domain string) (string, error) {
 	var shortenURL string
 
 	for range maxRetries {
 		shortenURL = generateRandomString(maxShortURLLength)
 
-		if _, _, err := s.s.GetURL(ctx, shortenURL); err != nil {
+		if _, err := s.s.GetURL(ctx, domain, shortenURL); err != nil {
 			break
 		}
 	}
@@ -73,36 +147,87 @@ func (s *Service) shortenURL(ctx context.Context) (string, error) {
 	return shortenURL, nil
 }
 
-func (s *Service) buildURL(shortenURL string) (string, error) {
-	res, err := url.JoinPath(s.cfg.ShortLinkBaseURL, shortenURL)
+func (s *Service) SaveURL(
+	ctx context.Context,
+	fullURL string,
+	userID string,
+	meta models.LinkMeta,
+	opts models.ShortenOptions,
+) (string, error) {
+	ctx, span := tracing.Start(ctx, "service.SaveURL")
+	defer span.End()
+
+	var targets []models.LinkTarget
+	if len(opts.Targets) > 0 {
+		var err error
+		if targets, err = s.linkTargets(ctx, opts.Targets); err != nil {
+			return "", err
+		}
+
+		// Основным адресом A/B-ссылки без явно заданного url считается первый вариант.
+		if strings.TrimSpace(fullURL) == "" {
+			fullURL = targets[0].URL
+		}
+	}
+
+	fullURL, err := s.resolveOwnLinks(ctx, fullURL, models.LinkKey{})
 	if err != nil {
-		return "", fmt.Errorf(failedToBuildURLError, err)
+		return "", err
 	}
 
-	return res, nil
-}
+	if err = s.checkDestination(ctx, fullURL); err != nil {
+		return "", err
+	}
 
-func (s *Service) SaveURL(ctx context.Context, fullURL string, userID string) (string, error) {
-	shortenURL, err := s.shortenURL(ctx)
+	linkOpts, err := toLinkOptions(opts)
+	if err != nil {
+		return "", err
+	}
+
+	linkOpts.Targets = targets
+	linkOpts.Sticky = opts.Sticky && len(targets) > 0
+
+	if linkOpts.Rules, err = s.routingRules(ctx, opts.Rules); err != nil {
+		return "", err
+	}
+
+	if linkOpts.MaxClicks, err = clickLimit(opts); err != nil {
+		return "", err
+	}
+	linkOpts.ClicksLeft = linkOpts.MaxClicks
+	linkOpts.NotBefore = notBefore(opts)
+
+	if linkOpts.Domain, err = s.domainKey(opts.Domain); err != nil {
+		return "", err
+	}
+
+	if linkOpts.QueryParams, err = queryParams(opts); err != nil {
+		return "", err
+	}
+
+	shortenURL, err := s.shortenURL(ctx, linkOpts.Domain)
 	if err != nil {
 		return "", fmt.Errorf("failed to generate unique short URL: %w", err)
 	}
 
-	resultedShortURL, err := s.s.SaveURL(ctx, fullURL, shortenURL, userID)
+	meta.Tags = normalizeTags(meta.Tags)
+	resultedShortURL, err := s.s.SaveURL(ctx, fullURL, shortenURL, userID, meta, linkOpts)
 	if err != nil {
 		return "", fmt.Errorf("failed to save URL: %w", err)
 	}
 
-	resURL, err := s.buildURL(resultedShortURL)
+	resURL, err := s.buildURL(linkOpts.Domain, resultedShortURL)
 	if err != nil {
 		return "", fmt.Errorf(failedToBuildURLError, err)
 	}
 
 	if shortenURL != resultedShortURL {
-		err = ErrConflict
+		return resURL, ErrConflict
 	}
 
-	return resURL, err
+	s.emitEvent(ctx, userID, models.EventLinkCreated, models.LinkEventData{ShortURL: resURL, OriginalURL: fullURL})
+
+	return resURL, nil
 }
 
 func (s *Service) SaveBatchURLs(
@@ -110,19 +235,31 @@ func (s *Service) SaveBatchURLs(
 	urls []models.OriginalURLCorrelation,
 	userID string,
 ) ([]models.ShortURLCorrelation, error) {
+	ctx, span := tracing.Start(ctx, "service.SaveBatchURLs")
+	defer span.End()
+
 	// Мапа для связи корреляционных идентификаторов и полных URL
 	fullURLbyCorrID := make(map[string]string)
 	urlsMap := make(map[string]string)
 
 	for _, URL := range urls {
-		shortURL, err := s.shortenURL(ctx)
+		fullURL, err := s.resolveOwnLinks(ctx, URL.OriginalURL, models.LinkKey{})
+		if err != nil {
+			return nil, fmt.Errorf("%w (correlation_id %s)", err, URL.CorrelationID)
+		}
+
+		if err = s.checkDestination(ctx, fullURL); err != nil {
+			return nil, fmt.Errorf("%w (correlation_id %s)", err, URL.CorrelationID)
+		}
+
+		shortURL, err := s.shortenURL(ctx, "")
 
 		if err != nil {
 			return nil, fmt.Errorf("failed to generate unique short URL: %w", err)
 		}
 
-		urlsMap[URL.OriginalURL] = shortURL
-		fullURLbyCorrID[URL.OriginalURL] = URL.CorrelationID
+		urlsMap[fullURL] = shortURL
+		fullURLbyCorrID[fullURL] = URL.CorrelationID
 	}
 
 	batchRes, err := s.s.SaveURLsBatch(ctx, urlsMap, userID)
@@ -132,7 +269,7 @@ func (s *Service) SaveBatchURLs(
 
 	resp := make([]models.ShortURLCorrelation, 0, len(batchRes))
 	for fullURL, shortURL := range batchRes {
-		resURL, err := s.buildURL(shortURL)
+		resURL, err := s.buildURL("", shortURL)
 		if err != nil {
 			return nil, fmt.Errorf(failedToBuildURLError, err)
 		}
@@ -141,48 +278,284 @@ func (s *Service) SaveBatchURLs(
 			CorrelationID: fullURLbyCorrID[fullURL],
 			ShortURL:      resURL,
 		})
+
+		// Уже сокращённые раньше адреса возвращаются со старым кодом — это не новые ссылки.
+		if shortURL == urlsMap[fullURL] {
+			s.emitEvent(ctx, userID, models.EventLinkCreated, models.LinkEventData{ShortURL: resURL, OriginalURL: fullURL})
+		}
 	}
 
 	return resp, nil
 }
 
-func (s *Service) GetURL(ctx context.Context, shortURL string) (string, bool, error) {
-	fullURL, deleted, err := s.s.GetURL(ctx, shortURL)
+// ResolveURL возвращает адрес, на который нужно перенаправить посетителя короткой ссылки.
+// Сначала проверяются правила переадресации ссылки; если ни одно не подошло, для A/B-ссылки
+// адрес выбирается по весам либо берётся закреплённый за посетителем visit.Variant.
+func (s *Service) ResolveURL(ctx context.Context, shortURL string, visit models.Visit) (models.Redirect, error) {
+	ctx, span := tracing.Start(ctx, "service.ResolveURL")
+	defer span.End()
+
+	data, err := s.s.GetURL(ctx, s.domainByHost(visit.Host), shortURL)
+	if err != nil {
+		return models.Redirect{}, fmt.Errorf("failed to get full URL: %w", err)
+	}
+
+	if data.Deleted {
+		return models.Redirect{}, ErrURLDeleted
+	}
+
+	if err = checkActive(data, time.Now()); err != nil {
+		return models.Redirect{}, err
+	}
+
+	if exhausted(data) {
+		return models.Redirect{}, ErrClicksExhausted
+	}
+
+	destination := data.OriginalURL
+	target := -1
+	if rule, ok := s.matchRule(data.Rules, visit); ok {
+		destination = rule.URL
+	} else if target = pickTarget(data.Targets, visit.Variant); target >= 0 {
+		destination = data.Targets[target].URL
+	}
+
+	// Правила могли ужесточиться уже после создания ссылки, поэтому проверяем и при переходе.
+	if err = s.checkDestination(ctx, destination); err != nil {
+		return models.Redirect{}, err
+	}
+
+	if err = s.checkPassword(data, visit.Password); err != nil {
+		return models.Redirect{}, err
+	}
+
+	resURL, err := s.destinationURL(destination, data.QueryParams, visit.Query)
+	if err != nil {
+		return models.Redirect{}, err
+	}
+
+	// Переход списывается последним, чтобы неудачные попытки не расходовали лимит.
+	if err = s.consumeClick(ctx, data); err != nil {
+		return models.Redirect{}, err
+	}
+
+	s.emitClick(ctx, data, resURL)
+
+	link := models.LinkKey{Domain: data.Domain, ShortURL: data.ShortURL}
+	if target < 0 {
+		return models.Redirect{URL: resURL, Link: link}, nil
+	}
+
+	s.countTargetClick(ctx, data, target)
+
+	return models.Redirect{URL: resURL, Variant: target + 1, Sticky: data.Sticky, Link: link}, nil
+}
+
+// GetLinkKey проверяет, что ссылка принадлежит пользователю, и возвращает её ключ.
+func (s *Service) GetLinkKey(ctx context.Context,
+	domain string, shortURL string, userID string) (models.LinkKey, error) {
+	ctx, span := tracing.Start(ctx, "service.GetLinkKey")
+	defer span.End()
+
+	data, err := s.s.GetUserURL(ctx, s.lookupDomain(domain), shortURL, userID)
 	if err != nil {
-		return "", false, fmt.Errorf("failed to get full URL: %w", err)
+		return models.LinkKey{}, fmt.Errorf("failed to get URL: %w", err)
 	}
 
-	return fullURL, deleted, nil
+	return models.LinkKey{Domain: data.Domain, ShortURL: data.ShortURL}, nil
 }
 
-func (s *Service) GetURLs(ctx context.Context, userID string) ([]models.URLsPair, error) {
-	userURLs, err := s.s.GetURLs(ctx, userID)
+func (s *Service) GetURLs(ctx context.Context, userID string, filter models.URLsFilter) ([]models.URLsPair, error) {
+	ctx, span := tracing.Start(ctx, "service.GetURLs")
+	defer span.End()
+
+	filter.Tag = strings.TrimSpace(filter.Tag)
+
+	userURLs, err := s.s.GetURLs(ctx, userID, filter)
 	if err != nil {
 		return nil, fmt.Errorf("%w", err)
 	}
 
 	resp := make([]models.URLsPair, 0, len(userURLs))
-	for shortURL, fullURL := range userURLs {
-		resURL, buildErr := s.buildURL(shortURL)
+	for _, data := range userURLs {
+		pair, buildErr := s.toURLsPair(data)
 		if buildErr != nil {
-			return nil, fmt.Errorf(failedToBuildURLError, buildErr)
+			return nil, buildErr
 		}
 
-		resp = append(resp, models.URLsPair{
-			ShortURL:    resURL,
-			OriginalURL: fullURL,
-		})
+		resp = append(resp, pair)
 	}
 
 	return resp, nil
 }
 
-func (s *Service) DeleteURLs(ctx context.Context, urls []string, userID string) error {
-	err := s.s.DeleteURLs(ctx, urls, userID)
+func (s *Service) UpdateURLMeta(
+	ctx context.Context,
+	domain string,
+	shortURL string,
+	userID string,
+	patch models.LinkMetaPatch,
+) (models.URLsPair, error) {
+	ctx, span := tracing.Start(ctx, "service.UpdateURLMeta")
+	defer span.End()
+
+	if patch.Tags != nil {
+		tags := normalizeTags(*patch.Tags)
+		patch.Tags = &tags
+	}
+
+	data, err := s.s.UpdateURLMeta(ctx, s.lookupDomain(domain), shortURL, userID, patch)
+	if err != nil {
+		return models.URLsPair{}, fmt.Errorf("failed to update URL meta: %w", err)
+	}
+
+	return s.toURLsPair(data)
+}
+
+func (s *Service) UpdateURL(
+	ctx context.Context,
+	domain string,
+	shortURL string,
+	userID string,
+	fullURL string,
+) (models.URLsPair, error) {
+	ctx, span := tracing.Start(ctx, "service.UpdateURL")
+	defer span.End()
+
+	fullURL = strings.TrimSpace(fullURL)
+	if !isValidURL(fullURL) {
+		return models.URLsPair{}, fmt.Errorf("%w: %q", ErrInvalidURL, fullURL)
+	}
+
+	domain = s.lookupDomain(domain)
+	current, err := s.s.GetUserURL(ctx, domain, shortURL, userID)
+	if err != nil {
+		return models.URLsPair{}, fmt.Errorf("failed to get URL: %w", err)
+	}
+
+	if len(current.Targets) > 0 || len(current.Rules) > 0 {
+		return models.URLsPair{}, ErrSeveralDestinations
+	}
+
+	fullURL, err = s.resolveOwnLinks(ctx, fullURL, models.LinkKey{Domain: domain, ShortURL: shortURL})
+	if err != nil {
+		return models.URLsPair{}, err
+	}
+
+	if err = s.checkDestination(ctx, fullURL); err != nil {
+		return models.URLsPair{}, err
+	}
+
+	data, err := s.s.UpdateURL(ctx, domain, shortURL, userID, fullURL)
+	if err != nil {
+		return models.URLsPair{}, fmt.Errorf("failed to update URL: %w", err)
+	}
+
+	return s.toURLsPair(data)
+}
+
+func (s *Service) GetURLRevisions(ctx context.Context,
+	domain string, shortURL string, userID string) ([]models.URLRevision, error) {
+	ctx, span := tracing.Start(ctx, "service.GetURLRevisions")
+	defer span.End()
+
+	revisions, err := s.s.GetURLRevisions(ctx, s.lookupDomain(domain), shortURL, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get URL revisions: %w", err)
+	}
+
+	if revisions == nil {
+		revisions = make([]models.URLRevision, 0)
+	}
+
+	return revisions, nil
+}
+
+func (s *Service) checkDestination(ctx context.Context, fullURL string) error {
+	if s.policy == nil {
+		return nil
+	}
+
+	verdict := s.policy.Check(ctx, fullURL)
+	if !verdict.Blocked {
+		return nil
+	}
+
+	logger.FromContext(ctx, s.logger).Info("Destination blocked by policy",
+		zap.String("url", fullURL), zap.String("reason", verdict.Reason))
+
+	return fmt.Errorf("%w: %s", ErrDestinationBlocked, verdict.Reason)
+}
+
+func isValidURL(rawURL string) bool {
+	u, err := url.ParseRequestURI(rawURL)
+	if err != nil {
+		return false
+	}
+
+	return u.Scheme != "" && u.Host != ""
+}
+
+func (s *Service) toURLsPair(data models.Data) (models.URLsPair, error) {
+	resURL, err := s.buildURL(data.Domain, data.ShortURL)
+	if err != nil {
+		return models.URLsPair{}, fmt.Errorf(failedToBuildURLError, err)
+	}
+
+	return models.URLsPair{
+		ShortURL:    resURL,
+		OriginalURL: data.OriginalURL,
+		LinkMeta:    data.LinkMeta,
+		QueryParams: data.QueryParams,
+		Targets:     data.Targets,
+		Rules:       data.Rules,
+		MaxClicks:   data.MaxClicks,
+		ClicksLeft:  clicksLeft(data),
+		NotBefore:   data.NotBefore,
+		Protected:   data.PasswordHash != "",
+		DeletedAt:   data.DeletedAt,
+	}, nil
+}
+
+// normalizeTags убирает пустые теги и дубликаты, сохраняя порядок.
+func normalizeTags(tags []string) []string {
+	res := make([]string, 0, len(tags))
+	for _, tag := range tags {
+		tag = strings.TrimSpace(tag)
+		if tag == "" || slices.Contains(res, tag) {
+			continue
+		}
+
+		res = append(res, tag)
+	}
+
+	return res
+}
+
+func (s *Service) DeleteURLs(ctx context.Context, domain string, urls []string, userID string) error {
+	ctx, span := tracing.Start(ctx, "service.DeleteURLs")
+	defer span.End()
+
+	domain = s.lookupDomain(domain)
+	err := s.s.DeleteURLs(ctx, domain, urls, userID)
 	if err != nil {
 		return fmt.Errorf("failed to delete urls: %w", err)
 	}
 
+	s.emitEvent(ctx, userID, models.EventLinkDeleted, models.LinksDeletedEventData{Domain: domain, Codes: urls})
+
+	return nil
+}
+
+func (s *Service) RestoreURLs(ctx context.Context, domain string, urls []string, userID string) error {
+	ctx, span := tracing.Start(ctx, "service.RestoreURLs")
+	defer span.End()
+
+	err := s.s.RestoreURLs(ctx, s.lookupDomain(domain), urls, userID)
+	if err != nil {
+		return fmt.Errorf("failed to restore urls: %w", err)
+	}
+
 	return nil
 }
 
@@ -196,17 +569,45 @@ func (s *Service) Ping(ctx context.Context) error {
 }
 
 func (s *Service) StartCleanupJob(ctx context.Context) {
-	s.ticker = time.NewTicker(cleanupInterval)
+	ticker := time.NewTicker(cleanupInterval)
+	done := make(chan struct{})
+	s.ticker = ticker
+	s.cleanupDone = done
+	s.cleanupRunning.Store(true)
 
 	go func() {
-		for range s.ticker.C {
-			if err := s.s.CleanupDeletedURLs(ctx); err != nil {
-				s.logger.Error("Failed to cleanup urls", zap.Error(err))
+		defer s.cleanupRunning.Store(false)
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-done:
+				return
+			case <-ticker.C:
+				deletedBefore := time.Now().Add(-s.cfg.DeletedURLsRetention)
+				if err := s.s.CleanupDeletedURLs(ctx, deletedBefore); err != nil {
+					s.logger.Error("Failed to cleanup urls", zap.Error(err))
+				}
+
+				if err := s.s.CleanupRevokedSessions(ctx, time.Now()); err != nil {
+					s.logger.Error("Failed to cleanup revoked sessions", zap.Error(err))
+				}
+
+				s.passwordAttempts.prune(time.Now())
 			}
 		}
 	}()
 }
 
+// StopCleanupJob останавливает фоновую очистку; повторный вызов ничего не делает.
 func (s *Service) StopCleanupJob() {
-	s.ticker.Stop()
+	s.cleanupStop.Do(func() {
+		if s.ticker == nil {
+			return
+		}
+
+		s.ticker.Stop()
+		close(s.cleanupDone)
+	})
 }
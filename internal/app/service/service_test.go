@@ -0,0 +1,46 @@
+package service
+
+import (
+	"context"
+	"testing"
+
+	"github.com/a-bondar/go-url-shortener/internal/app/models"
+	"github.com/a-bondar/go-url-shortener/internal/app/store"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUpdateURLRejectsTakenDestination(t *testing.T) {
+	ctx := context.Background()
+	s, st := newTestService(t)
+
+	saveLink(t, st, "", "mine", "https://example.com/mine", models.LinkOptions{})
+	saveLink(t, st, "", "trashed", "https://example.com/trashed", models.LinkOptions{})
+	require.NoError(t, st.DeleteURLs(ctx, "", []string{"trashed"}, testUserID))
+	_, err := st.SaveURL(ctx, "https://example.com/foreign", "foreign", "user-2", models.LinkMeta{}, models.LinkOptions{})
+	require.NoError(t, err)
+
+	testCases := []struct {
+		name    string
+		fullURL string
+	}{
+		{name: "Held by own trashed link", fullURL: "https://example.com/trashed"},
+		{name: "Held by another owner's link", fullURL: "https://example.com/foreign"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := s.UpdateURL(ctx, "", "mine", testUserID, tc.fullURL)
+			require.ErrorIs(t, err, store.ErrURLExists)
+
+			link, err := st.GetURL(ctx, "", "mine")
+			require.NoError(t, err)
+			assert.Equal(t, "https://example.com/mine", link.OriginalURL)
+		})
+	}
+
+	// Корзина при этом не чистится: удалённую ссылку по-прежнему можно восстановить.
+	trashed, err := st.GetURL(ctx, "", "trashed")
+	require.NoError(t, err)
+	assert.True(t, trashed.Deleted)
+}
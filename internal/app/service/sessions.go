@@ -0,0 +1,65 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/a-bondar/go-url-shortener/internal/app/models"
+	"github.com/a-bondar/go-url-shortener/internal/app/tracing"
+)
+
+// RevokeSession запрещает обновлять токены сессии. Запись хранится до expiresAt —
+// дольше refresh-токены сессии всё равно не живут.
+func (s *Service) RevokeSession(ctx context.Context, sessionID string, userID string, expiresAt time.Time) error {
+	ctx, span := tracing.Start(ctx, "service.RevokeSession")
+	defer span.End()
+
+	err := s.s.RevokeSession(ctx, models.RevokedSession{
+		ID:        sessionID,
+		UserID:    userID,
+		RevokedAt: time.Now().UTC(),
+		ExpiresAt: expiresAt.UTC(),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to revoke session: %w", err)
+	}
+
+	return nil
+}
+
+func (s *Service) IsSessionRevoked(ctx context.Context, sessionID string) (bool, error) {
+	ctx, span := tracing.Start(ctx, "service.IsSessionRevoked")
+	defer span.End()
+
+	revoked, err := s.s.IsSessionRevoked(ctx, sessionID)
+	if err != nil {
+		return false, fmt.Errorf("failed to check session: %w", err)
+	}
+
+	return revoked, nil
+}
+
+// ConsumeRefreshToken отмечает refresh-токен использованным. false означает, что его уже
+// обменивали: токен мог быть украден.
+func (s *Service) ConsumeRefreshToken(
+	ctx context.Context,
+	tokenID string,
+	userID string,
+	expiresAt time.Time,
+) (bool, error) {
+	ctx, span := tracing.Start(ctx, "service.ConsumeRefreshToken")
+	defer span.End()
+
+	fresh, err := s.s.ConsumeRefreshToken(ctx, models.RevokedSession{
+		ID:        tokenID,
+		UserID:    userID,
+		RevokedAt: time.Now().UTC(),
+		ExpiresAt: expiresAt.UTC(),
+	})
+	if err != nil {
+		return false, fmt.Errorf("failed to consume refresh token: %w", err)
+	}
+
+	return fresh, nil
+}
@@ -0,0 +1,132 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"strings"
+
+	"github.com/a-bondar/go-url-shortener/internal/app/logger"
+	"github.com/a-bondar/go-url-shortener/internal/app/models"
+	"github.com/a-bondar/go-url-shortener/internal/app/tracing"
+	"go.uber.org/zap"
+)
+
+const (
+	minLinkTargets = 2
+	maxLinkTargets = 10
+	// maxTargetWeight ограничивает вес адреса, чтобы сумма весов не переполнялась.
+	maxTargetWeight = 10000
+)
+
+var (
+	ErrInvalidTargets = errors.New("invalid link targets")
+	// ErrSeveralDestinations — у A/B-ссылки и ссылки с правилами нет одного адреса назначения,
+	// который можно заменить: переходы идут по адресам вариантов и правил.
+	ErrSeveralDestinations = errors.New("link has several destinations")
+)
+
+// linkTargets проверяет адреса A/B-ссылки так же, как одиночный адрес назначения.
+// Счётчики переходов из запроса не принимаются.
+func (s *Service) linkTargets(ctx context.Context, targets []models.LinkTarget) ([]models.LinkTarget, error) {
+	if len(targets) < minLinkTargets || len(targets) > maxLinkTargets {
+		return nil, fmt.Errorf("%w: from %d to %d targets allowed", ErrInvalidTargets, minLinkTargets, maxLinkTargets)
+	}
+
+	res := make([]models.LinkTarget, 0, len(targets))
+	for i, target := range targets {
+		if target.Weight <= 0 || target.Weight > maxTargetWeight {
+			return nil, fmt.Errorf("%w: target %d must have a weight from 1 to %d",
+				ErrInvalidTargets, i+1, maxTargetWeight)
+		}
+
+		fullURL := strings.TrimSpace(target.URL)
+		if !isValidURL(fullURL) {
+			return nil, fmt.Errorf("%w: target %d has invalid URL %q", ErrInvalidTargets, i+1, target.URL)
+		}
+
+		fullURL, err := s.resolveOwnLinks(ctx, fullURL, models.LinkKey{})
+		if err != nil {
+			return nil, err
+		}
+
+		if err = s.checkDestination(ctx, fullURL); err != nil {
+			return nil, err
+		}
+
+		res = append(res, models.LinkTarget{URL: fullURL, Weight: target.Weight})
+	}
+
+	return res, nil
+}
+
+// pickTarget выбирает адрес A/B-ссылки (с 0): закреплённый за посетителем, если он
+// ещё существует, иначе случайный пропорционально весам. -1 — у ссылки один адрес.
+func pickTarget(targets []models.LinkTarget, variant int) int {
+	if len(targets) == 0 {
+		return -1
+	}
+
+	if variant > 0 && variant <= len(targets) {
+		return variant - 1
+	}
+
+	total := 0
+	for _, target := range targets {
+		total += target.Weight
+	}
+
+	// Ссылки, сохранённые до ограничения весов, не должны ронять переход.
+	if total <= 0 {
+		return 0
+	}
+
+	n := rand.Intn(total)
+	for i, target := range targets {
+		if n < target.Weight {
+			return i
+		}
+
+		n -= target.Weight
+	}
+
+	return len(targets) - 1
+}
+
+// countTargetClick учитывает переход на выбранный адрес. Сбой учёта не должен
+// мешать переходу, поэтому ошибка только логируется.
+func (s *Service) countTargetClick(ctx context.Context, data models.Data, target int) {
+	if err := s.s.IncrementTargetClicks(ctx, data.Domain, data.ShortURL, target); err != nil {
+		logger.FromContext(ctx, s.logger).Error("Failed to count target click",
+			zap.String("short_url", data.ShortURL), zap.Int("target", target), zap.Error(err))
+	}
+}
+
+// GetURLStats возвращает переходы по ссылке владельца с разбивкой по адресам A/B-ссылки.
+func (s *Service) GetURLStats(ctx context.Context,
+	domain string, shortURL string, userID string) (models.LinkStats, error) {
+	ctx, span := tracing.Start(ctx, "service.GetURLStats")
+	defer span.End()
+
+	data, err := s.s.GetUserURL(ctx, s.lookupDomain(domain), shortURL, userID)
+	if err != nil {
+		return models.LinkStats{}, fmt.Errorf("failed to get URL: %w", err)
+	}
+
+	resURL, err := s.buildURL(data.Domain, data.ShortURL)
+	if err != nil {
+		return models.LinkStats{}, fmt.Errorf(failedToBuildURLError, err)
+	}
+
+	targets := data.Targets
+	if targets == nil {
+		targets = make([]models.LinkTarget, 0)
+	}
+
+	return models.LinkStats{
+		ShortURL:    resURL,
+		OriginalURL: data.OriginalURL,
+		Targets:     targets,
+	}, nil
+}
@@ -0,0 +1,76 @@
+package service
+
+import (
+	"context"
+	"math"
+	"testing"
+
+	"github.com/a-bondar/go-url-shortener/internal/app/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLinkTargetsRejectsWeights(t *testing.T) {
+	s := &Service{}
+
+	for _, weight := range []int{0, -1, maxTargetWeight + 1, math.MaxInt} {
+		_, err := s.linkTargets(context.Background(), []models.LinkTarget{
+			{URL: "https://a.example", Weight: weight},
+			{URL: "https://b.example", Weight: 1},
+		})
+		require.ErrorIs(t, err, ErrInvalidTargets, "weight %d", weight)
+	}
+}
+
+func TestPickTarget(t *testing.T) {
+	targets := []models.LinkTarget{
+		{URL: "https://a.example", Weight: 1},
+		{URL: "https://b.example", Weight: maxTargetWeight},
+	}
+
+	assert.Equal(t, -1, pickTarget(nil, 0))
+	assert.Equal(t, 0, pickTarget(targets, 1), "sticky variant is kept")
+
+	for range 100 {
+		i := pickTarget(targets, 0)
+		assert.GreaterOrEqual(t, i, 0)
+		assert.Less(t, i, len(targets))
+	}
+
+	overflow := []models.LinkTarget{
+		{URL: "https://a.example", Weight: math.MaxInt},
+		{URL: "https://b.example", Weight: math.MaxInt},
+	}
+	assert.NotPanics(t, func() {
+		pickTarget(overflow, 0)
+	})
+}
+
+func TestUpdateURLRejectsLinksWithSeveralDestinations(t *testing.T) {
+	ctx := context.Background()
+	s, st := newTestService(t)
+
+	saveLink(t, st, "", "ab", "https://example.com/a", models.LinkOptions{
+		Targets: []models.LinkTarget{
+			{URL: "https://example.com/a", Weight: 1},
+			{URL: "https://example.com/b", Weight: 1},
+		},
+	})
+	saveLink(t, st, "", "ruled", "https://example.com/fallback", models.LinkOptions{
+		Rules: []models.RoutingRule{{Language: "de", URL: "https://example.com/de"}},
+	})
+	saveLink(t, st, "", "plain", "https://example.com/plain", models.LinkOptions{})
+
+	for _, code := range []string{"ab", "ruled"} {
+		_, err := s.UpdateURL(ctx, "", code, testUserID, "https://example.com/new")
+		require.ErrorIs(t, err, ErrSeveralDestinations)
+
+		revisions, err := st.GetURLRevisions(ctx, "", code, testUserID)
+		require.NoError(t, err)
+		assert.Empty(t, revisions)
+	}
+
+	res, err := s.UpdateURL(ctx, "", "plain", testUserID, "https://example.com/new")
+	require.NoError(t, err)
+	assert.Equal(t, "https://example.com/new", res.OriginalURL)
+}
@@ -0,0 +1,239 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/a-bondar/go-url-shortener/internal/app/logger"
+	"github.com/a-bondar/go-url-shortener/internal/app/models"
+	"github.com/a-bondar/go-url-shortener/internal/app/tracing"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+)
+
+const (
+	webhookSecretPrefix = "whsec_"
+	webhookSecretSize   = 32
+	maxWebhooks         = 10
+)
+
+var (
+	ErrInvalidWebhook = errors.New("invalid webhook")
+	// ErrInvalidID — идентификатор вебхука или доставки заведомо не существует.
+	ErrInvalidID = errors.New("invalid identifier")
+)
+
+var knownEvents = []string{models.EventLinkCreated, models.EventLinkDeleted, models.EventLinkClicked}
+
+// CreateWebhook подписывает адрес на события ссылок пользователя. Секрет для проверки подписи
+// возвращается только здесь.
+func (s *Service) CreateWebhook(
+	ctx context.Context,
+	userID string,
+	request models.HandleWebhookRequest,
+) (models.HandleWebhookResponse, error) {
+	ctx, span := tracing.Start(ctx, "service.CreateWebhook")
+	defer span.End()
+
+	if !isValidURL(request.URL) {
+		return models.HandleWebhookResponse{}, fmt.Errorf("%w: url must be an absolute http(s) URL", ErrInvalidWebhook)
+	}
+
+	if err := s.checkDestination(ctx, request.URL); err != nil {
+		return models.HandleWebhookResponse{}, err
+	}
+
+	events := normalizeTags(request.Events)
+	if len(events) == 0 {
+		return models.HandleWebhookResponse{}, fmt.Errorf("%w: at least one event is required", ErrInvalidWebhook)
+	}
+
+	for _, event := range events {
+		if !slices.Contains(knownEvents, event) {
+			return models.HandleWebhookResponse{}, fmt.Errorf("%w: unknown event %q", ErrInvalidWebhook, event)
+		}
+	}
+
+	if _, err := s.s.GetUser(ctx, userID); err != nil {
+		return models.HandleWebhookResponse{}, fmt.Errorf("%w: %w", ErrNotRegistered, err)
+	}
+
+	existing, err := s.s.GetWebhooks(ctx, userID)
+	if err != nil {
+		return models.HandleWebhookResponse{}, fmt.Errorf("failed to get webhooks: %w", err)
+	}
+
+	if len(existing) >= maxWebhooks {
+		return models.HandleWebhookResponse{}, fmt.Errorf("%w: at most %d webhooks per user", ErrInvalidWebhook, maxWebhooks)
+	}
+
+	secret := make([]byte, webhookSecretSize)
+	if _, err = rand.Read(secret); err != nil {
+		return models.HandleWebhookResponse{}, fmt.Errorf("failed to generate webhook secret: %w", err)
+	}
+
+	webhook := models.Webhook{
+		ID:        uuid.NewString(),
+		UserID:    userID,
+		URL:       request.URL,
+		Events:    events,
+		Secret:    webhookSecretPrefix + base64.RawURLEncoding.EncodeToString(secret),
+		CreatedAt: time.Now().UTC(),
+	}
+
+	if err = s.s.CreateWebhook(ctx, webhook); err != nil {
+		return models.HandleWebhookResponse{}, fmt.Errorf("failed to save webhook: %w", err)
+	}
+
+	resp := toWebhookResponse(webhook)
+	resp.Secret = webhook.Secret
+
+	return resp, nil
+}
+
+func (s *Service) GetWebhooks(ctx context.Context, userID string) ([]models.HandleWebhookResponse, error) {
+	ctx, span := tracing.Start(ctx, "service.GetWebhooks")
+	defer span.End()
+
+	webhooks, err := s.s.GetWebhooks(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get webhooks: %w", err)
+	}
+
+	resp := make([]models.HandleWebhookResponse, 0, len(webhooks))
+	for _, webhook := range webhooks {
+		resp = append(resp, toWebhookResponse(webhook))
+	}
+
+	return resp, nil
+}
+
+// DeleteWebhook удаляет вебхук вместе с его неотправленными событиями.
+func (s *Service) DeleteWebhook(ctx context.Context, userID string, webhookID string) error {
+	ctx, span := tracing.Start(ctx, "service.DeleteWebhook")
+	defer span.End()
+
+	if _, err := uuid.Parse(webhookID); err != nil {
+		return fmt.Errorf("%w: %w", ErrInvalidID, err)
+	}
+
+	if err := s.s.DeleteWebhook(ctx, webhookID, userID); err != nil {
+		return fmt.Errorf("failed to delete webhook: %w", err)
+	}
+
+	return nil
+}
+
+// GetDeadDeliveries возвращает события, которые так и не удалось доставить на вебхуки пользователя.
+func (s *Service) GetDeadDeliveries(ctx context.Context, userID string) ([]models.WebhookDelivery, error) {
+	ctx, span := tracing.Start(ctx, "service.GetDeadDeliveries")
+	defer span.End()
+
+	deliveries, err := s.s.GetDeadDeliveries(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get dead deliveries: %w", err)
+	}
+
+	return deliveries, nil
+}
+
+// RetryDelivery возвращает недоставленное событие в очередь с обнулённым счётчиком попыток.
+func (s *Service) RetryDelivery(ctx context.Context, userID string, deliveryID string) error {
+	ctx, span := tracing.Start(ctx, "service.RetryDelivery")
+	defer span.End()
+
+	if _, err := uuid.Parse(deliveryID); err != nil {
+		return fmt.Errorf("%w: %w", ErrInvalidID, err)
+	}
+
+	if err := s.s.RequeueDelivery(ctx, deliveryID, userID, time.Now().UTC()); err != nil {
+		return fmt.Errorf("failed to requeue delivery: %w", err)
+	}
+
+	return nil
+}
+
+// emitEvent ставит событие в очередь каждого вебхука владельца, подписанного на него.
+// Операция со ссылкой к этому моменту уже выполнена, поэтому ошибки только логируются.
+func (s *Service) emitEvent(ctx context.Context, ownerID string, eventType string, data any) {
+	if err := s.enqueueEvent(ctx, ownerID, eventType, data); err != nil {
+		logger.FromContext(ctx, s.logger).Error("Failed to enqueue webhook event",
+			zap.String("event", eventType), zap.Error(err))
+	}
+}
+
+func (s *Service) enqueueEvent(ctx context.Context, ownerID string, eventType string, data any) error {
+	webhooks, err := s.s.GetWebhooks(ctx, ownerID)
+	if err != nil {
+		return fmt.Errorf("failed to get webhooks: %w", err)
+	}
+
+	now := time.Now().UTC()
+	var payload []byte
+	deliveries := make([]models.WebhookDelivery, 0, len(webhooks))
+	for _, webhook := range webhooks {
+		if !slices.Contains(webhook.Events, eventType) {
+			continue
+		}
+
+		// Все вебхуки получают одно и то же событие с общим идентификатором.
+		if payload == nil {
+			payload, err = json.Marshal(models.WebhookEvent{
+				ID:        uuid.NewString(),
+				Type:      eventType,
+				CreatedAt: now,
+				Data:      data,
+			})
+			if err != nil {
+				return fmt.Errorf("failed to marshal event: %w", err)
+			}
+		}
+
+		deliveries = append(deliveries, models.WebhookDelivery{
+			ID:            uuid.NewString(),
+			WebhookID:     webhook.ID,
+			Event:         eventType,
+			Payload:       payload,
+			NextAttemptAt: now,
+			CreatedAt:     now,
+		})
+	}
+
+	if len(deliveries) == 0 {
+		return nil
+	}
+
+	if err = s.s.EnqueueDeliveries(ctx, deliveries); err != nil {
+		return fmt.Errorf("failed to enqueue deliveries: %w", err)
+	}
+
+	return nil
+}
+
+func toWebhookResponse(webhook models.Webhook) models.HandleWebhookResponse {
+	return models.HandleWebhookResponse{
+		ID:        webhook.ID,
+		URL:       webhook.URL,
+		Events:    webhook.Events,
+		CreatedAt: webhook.CreatedAt,
+	}
+}
+
+func (s *Service) emitClick(ctx context.Context, data models.Data, destination string) {
+	shortURL, err := s.buildURL(data.Domain, data.ShortURL)
+	if err != nil {
+		shortURL = data.ShortURL
+	}
+
+	s.emitEvent(ctx, data.UserID, models.EventLinkClicked, models.LinkEventData{
+		ShortURL:    shortURL,
+		OriginalURL: data.OriginalURL,
+		Destination: destination,
+	})
+}
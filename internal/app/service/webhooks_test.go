@@ -0,0 +1,34 @@
+package service
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/a-bondar/go-url-shortener/internal/app/models"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+)
+
+// policyStub запрещает адреса, содержащие blocked.
+type policyStub struct {
+	blocked string
+}
+
+func (p policyStub) Check(_ context.Context, rawURL string) models.DestinationVerdict {
+	if strings.Contains(rawURL, p.blocked) {
+		return models.DestinationVerdict{Blocked: true, Reason: "blocked by test"}
+	}
+
+	return models.DestinationVerdict{}
+}
+
+func TestCreateWebhookChecksDestinationPolicy(t *testing.T) {
+	s := &Service{logger: zap.NewNop(), policy: policyStub{blocked: "evil.example"}}
+
+	_, err := s.CreateWebhook(context.Background(), "user-1", models.HandleWebhookRequest{
+		URL:    "https://evil.example/hook",
+		Events: []string{models.EventLinkCreated},
+	})
+	assert.ErrorIs(t, err, ErrDestinationBlocked)
+}
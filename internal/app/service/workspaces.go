@@ -0,0 +1,270 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/a-bondar/go-url-shortener/internal/app/models"
+	"github.com/a-bondar/go-url-shortener/internal/app/tracing"
+	"github.com/google/uuid"
+)
+
+var (
+	ErrNoWorkspaceAccess = errors.New("workspace not found")
+	ErrForbiddenRole     = errors.New("insufficient workspace role")
+	ErrUnknownRole       = errors.New("unknown role")
+	ErrUnknownUser       = errors.New("user not found")
+	ErrLastOwner         = errors.New("workspace must keep at least one owner")
+	ErrInvalidTransfer   = errors.New("invalid transfer")
+)
+
+// CreateWorkspace заводит рабочее пространство, владельцем которого становится его создатель.
+func (s *Service) CreateWorkspace(ctx context.Context, userID string, name string) (models.HandleWorkspaceResponse, error) {
+	ctx, span := tracing.Start(ctx, "service.CreateWorkspace")
+	defer span.End()
+
+	name, err := normalizeName(name)
+	if err != nil {
+		return models.HandleWorkspaceResponse{}, err
+	}
+
+	if _, err = s.s.GetUser(ctx, userID); err != nil {
+		return models.HandleWorkspaceResponse{}, fmt.Errorf("%w: %w", ErrNotRegistered, err)
+	}
+
+	now := time.Now().UTC()
+	workspace := models.Workspace{ID: uuid.NewString(), Name: name, CreatedAt: now}
+	owner := models.WorkspaceMember{WorkspaceID: workspace.ID, UserID: userID, Role: models.RoleOwner, AddedAt: now}
+
+	if err = s.s.CreateWorkspace(ctx, workspace, owner); err != nil {
+		return models.HandleWorkspaceResponse{}, fmt.Errorf("failed to create workspace: %w", err)
+	}
+
+	return models.HandleWorkspaceResponse{
+		ID:        workspace.ID,
+		Name:      workspace.Name,
+		Role:      owner.Role,
+		CreatedAt: workspace.CreatedAt,
+	}, nil
+}
+
+func (s *Service) GetWorkspaces(ctx context.Context, userID string) ([]models.HandleWorkspaceResponse, error) {
+	ctx, span := tracing.Start(ctx, "service.GetWorkspaces")
+	defer span.End()
+
+	workspaces, err := s.s.GetUserWorkspaces(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get workspaces: %w", err)
+	}
+
+	return workspaces, nil
+}
+
+// GetWorkspaceRole возвращает роль пользователя в пространстве или пустую строку, если он не участник.
+func (s *Service) GetWorkspaceRole(ctx context.Context, workspaceID string, userID string) (string, error) {
+	ctx, span := tracing.Start(ctx, "service.GetWorkspaceRole")
+	defer span.End()
+
+	workspaces, err := s.s.GetUserWorkspaces(ctx, userID)
+	if err != nil {
+		return "", fmt.Errorf("failed to get workspaces: %w", err)
+	}
+
+	for _, workspace := range workspaces {
+		if workspace.ID == workspaceID {
+			return workspace.Role, nil
+		}
+	}
+
+	return "", nil
+}
+
+func (s *Service) GetWorkspaceMembers(
+	ctx context.Context,
+	userID string,
+	workspaceID string,
+) ([]models.WorkspaceMember, error) {
+	ctx, span := tracing.Start(ctx, "service.GetWorkspaceMembers")
+	defer span.End()
+
+	if err := s.requireRole(ctx, workspaceID, userID, models.RoleViewer); err != nil {
+		return nil, err
+	}
+
+	members, err := s.s.GetWorkspaceMembers(ctx, workspaceID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get workspace members: %w", err)
+	}
+
+	return members, nil
+}
+
+// SetWorkspaceMember добавляет участника или меняет его роль. Доступно только владельцам.
+func (s *Service) SetWorkspaceMember(
+	ctx context.Context,
+	userID string,
+	workspaceID string,
+	memberID string,
+	role string,
+) (models.WorkspaceMember, error) {
+	ctx, span := tracing.Start(ctx, "service.SetWorkspaceMember")
+	defer span.End()
+
+	if !models.IsKnownRole(role) {
+		return models.WorkspaceMember{}, fmt.Errorf("%w: %q", ErrUnknownRole, role)
+	}
+
+	if err := s.requireRole(ctx, workspaceID, userID, models.RoleOwner); err != nil {
+		return models.WorkspaceMember{}, err
+	}
+
+	if _, err := uuid.Parse(memberID); err != nil {
+		return models.WorkspaceMember{}, fmt.Errorf("%w: %w", ErrUnknownUser, err)
+	}
+
+	if _, err := s.s.GetUser(ctx, memberID); err != nil {
+		return models.WorkspaceMember{}, fmt.Errorf("%w: %w", ErrUnknownUser, err)
+	}
+
+	if role != models.RoleOwner {
+		if err := s.ensureOtherOwner(ctx, workspaceID, memberID); err != nil {
+			return models.WorkspaceMember{}, err
+		}
+	}
+
+	member := models.WorkspaceMember{
+		WorkspaceID: workspaceID,
+		UserID:      memberID,
+		Role:        role,
+		AddedAt:     time.Now().UTC(),
+	}
+	if err := s.s.SaveWorkspaceMember(ctx, member); err != nil {
+		return models.WorkspaceMember{}, fmt.Errorf("failed to save workspace member: %w", err)
+	}
+
+	return member, nil
+}
+
+// RemoveWorkspaceMember исключает участника. Владелец исключает любого, остальные могут только выйти сами.
+func (s *Service) RemoveWorkspaceMember(ctx context.Context, userID string, workspaceID string, memberID string) error {
+	ctx, span := tracing.Start(ctx, "service.RemoveWorkspaceMember")
+	defer span.End()
+
+	required := models.RoleOwner
+	if memberID == userID {
+		required = models.RoleViewer
+	}
+
+	if err := s.requireRole(ctx, workspaceID, userID, required); err != nil {
+		return err
+	}
+
+	if err := s.ensureOtherOwner(ctx, workspaceID, memberID); err != nil {
+		return err
+	}
+
+	if err := s.s.RemoveWorkspaceMember(ctx, workspaceID, memberID); err != nil {
+		return fmt.Errorf("failed to remove workspace member: %w", err)
+	}
+
+	return nil
+}
+
+// TransferURLs передаёт ссылки из личного или рабочего пространства другому пользователю
+// либо в рабочее пространство. С обеих сторон от пространства нужны права редактора.
+func (s *Service) TransferURLs(ctx context.Context, userID string, request models.HandleTransferRequest) (int64, error) {
+	ctx, span := tracing.Start(ctx, "service.TransferURLs")
+	defer span.End()
+
+	if len(request.URLs) == 0 {
+		return 0, fmt.Errorf("%w: no URLs given", ErrInvalidTransfer)
+	}
+
+	if (request.ToUserID == "") == (request.ToWorkspaceID == "") {
+		return 0, fmt.Errorf("%w: exactly one of to_user_id and to_workspace_id is required", ErrInvalidTransfer)
+	}
+
+	fromOwnerID := userID
+	if request.FromWorkspaceID != "" {
+		if err := s.requireRole(ctx, request.FromWorkspaceID, userID, models.RoleEditor); err != nil {
+			return 0, err
+		}
+
+		fromOwnerID = request.FromWorkspaceID
+	}
+
+	toOwnerID := request.ToWorkspaceID
+	if toOwnerID != "" {
+		if err := s.requireRole(ctx, toOwnerID, userID, models.RoleEditor); err != nil {
+			return 0, err
+		}
+	} else {
+		toOwnerID = request.ToUserID
+		if _, err := uuid.Parse(toOwnerID); err != nil {
+			return 0, fmt.Errorf("%w: %w", ErrUnknownUser, err)
+		}
+
+		if _, err := s.s.GetUser(ctx, toOwnerID); err != nil {
+			return 0, fmt.Errorf("%w: %w", ErrUnknownUser, err)
+		}
+	}
+
+	if fromOwnerID == toOwnerID {
+		return 0, fmt.Errorf("%w: source and target are the same", ErrInvalidTransfer)
+	}
+
+	transferred, err := s.s.TransferURLs(ctx, s.lookupDomain(request.Domain), request.URLs, fromOwnerID, toOwnerID)
+	if err != nil {
+		return 0, fmt.Errorf("failed to transfer URLs: %w", err)
+	}
+
+	return transferred, nil
+}
+
+// requireRole проверяет, что у пользователя в пространстве есть роль не ниже требуемой.
+// Не участникам пространство не видно вовсе.
+func (s *Service) requireRole(ctx context.Context, workspaceID string, userID string, required string) error {
+	role, err := s.GetWorkspaceRole(ctx, workspaceID, userID)
+	if err != nil {
+		return err
+	}
+
+	if role == "" {
+		return ErrNoWorkspaceAccess
+	}
+
+	if !models.RoleAllows(role, required) {
+		return fmt.Errorf("%w: %s required", ErrForbiddenRole, required)
+	}
+
+	return nil
+}
+
+// ensureOtherOwner не даёт пространству остаться без владельца, когда memberID теряет эту роль.
+func (s *Service) ensureOtherOwner(ctx context.Context, workspaceID string, memberID string) error {
+	members, err := s.s.GetWorkspaceMembers(ctx, workspaceID)
+	if err != nil {
+		return fmt.Errorf("failed to get workspace members: %w", err)
+	}
+
+	memberIsOwner := false
+	owners := 0
+	for _, member := range members {
+		if member.Role != models.RoleOwner {
+			continue
+		}
+
+		owners++
+		if member.UserID == memberID {
+			memberIsOwner = true
+		}
+	}
+
+	if memberIsOwner && owners == 1 {
+		return ErrLastOwner
+	}
+
+	return nil
+}
@@ -5,8 +5,15 @@ import (
 	"embed"
 	"errors"
 	"fmt"
+	"io/fs"
+	"strconv"
+	"strings"
+	"time"
 
+	"github.com/a-bondar/go-url-shortener/internal/app/logger"
+	"github.com/a-bondar/go-url-shortener/internal/app/models"
 	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
 
 	"github.com/golang-migrate/migrate/v4"
 	_ "github.com/golang-migrate/migrate/v4/database/postgres"
@@ -15,28 +22,183 @@ import (
 	"go.uber.org/zap"
 )
 
+// Коды ошибок Postgres при нарушении ограничений уникальности и внешнего ключа.
+const (
+	uniqueViolationCode     = "23505"
+	foreignKeyViolationCode = "23503"
+)
+
+// Ключи advisory-блокировок: outboxLockKey упорядочивает запись в link_events,
+// migrationLockKey не даёт нескольким экземплярам накатывать миграции одновременно.
+const (
+	outboxLockKey    = 0x6c696e6b
+	migrationLockKey = 0x6d696772
+)
+
+// Паузы между попытками подключиться при старте: в docker-compose Postgres поднимается позже сервиса.
+const (
+	connectRetryBaseDelay = 500 * time.Millisecond
+	connectRetryMaxDelay  = 5 * time.Second
+)
+
+// errDBUnavailable — к БД не удалось подключиться; только такие ошибки повторяются при старте.
+var errDBUnavailable = errors.New("DB is unavailable")
+
 type DBStore struct {
 	logger *zap.Logger
 	pool   *pgxpool.Pool
+	// queryTimeout ограничивает каждый вызов метода хранилища; 0 — без ограничения.
+	queryTimeout time.Duration
 }
 
-func newDBStore(ctx context.Context, dsn string, logger *zap.Logger) (*DBStore, error) {
-	if err := runMigrations(dsn); err != nil {
-		return nil, fmt.Errorf("failed to run DB migrations: %w", err)
+func newDBStore(ctx context.Context, cfg Config, logger *zap.Logger) (*DBStore, error) {
+	if cfg.AutoMigrate {
+		err := retryUnavailable(ctx, cfg.DB.ConnectTimeout, logger, func() error {
+			return MigrateUp(ctx, cfg.DatabaseDSN)
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to run DB migrations: %w", err)
+		}
 	}
 
-	pool, err := initPool(ctx, dsn)
+	pool, err := initPool(ctx, cfg.DatabaseDSN, cfg.DB, logger)
 	if err != nil {
 		return nil, fmt.Errorf("unable to create connection pool: %w", err)
 	}
 
-	return &DBStore{pool: pool, logger: logger}, nil
+	// Пул подключается лениво, поэтому доступность БД проверяется явно.
+	err = retryUnavailable(ctx, cfg.DB.ConnectTimeout, logger, func() error {
+		if pingErr := pool.Ping(ctx); pingErr != nil {
+			return fmt.Errorf("%w: %w", errDBUnavailable, pingErr)
+		}
+
+		return nil
+	})
+	if err != nil {
+		pool.Close()
+		return nil, fmt.Errorf("failed to connect to the DB: %w", err)
+	}
+
+	s := &DBStore{pool: pool, logger: logger, queryTimeout: cfg.DB.QueryTimeout}
+
+	// Без автоматических миграций схему накатывают отдельно; до тех пор проверка готовности не проходит.
+	if !cfg.AutoMigrate {
+		if err = s.CheckMigrations(ctx); err != nil {
+			logger.Warn("DB schema does not match, the service stays not ready until migrations are applied",
+				zap.Error(err))
+		}
+	}
+
+	return s, nil
+}
+
+// retryUnavailable повторяет fn с растущей паузой, пока БД недоступна, но не дольше timeout.
+// Остальные ошибки, например в самих миграциях, возвращаются сразу.
+func retryUnavailable(ctx context.Context, timeout time.Duration, logger *zap.Logger, fn func() error) error {
+	deadline := time.Now().Add(timeout)
+	delay := connectRetryBaseDelay
+	for {
+		err := fn()
+		remaining := time.Until(deadline)
+		if err == nil || !errors.Is(err, errDBUnavailable) || remaining <= 0 {
+			return err
+		}
+
+		wait := min(delay, remaining)
+		logger.Warn("DB is unavailable, retrying", zap.Duration("delay", wait), zap.Error(err))
+
+		select {
+		case <-ctx.Done():
+			return errors.Join(err, ctx.Err())
+		case <-time.After(wait):
+		}
+
+		delay = min(delay*2, connectRetryMaxDelay)
+	}
+}
+
+// withTimeout ограничивает время одного вызова метода хранилища, чтобы зависшая БД
+// не держала запросы к сервису бесконечно.
+func (s *DBStore) withTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	if s.queryTimeout <= 0 {
+		return ctx, func() {}
+	}
+
+	return context.WithTimeout(ctx, s.queryTimeout)
 }
 
 //go:embed migrations/*.sql
 var migrationsDir embed.FS
 
-func runMigrations(dsn string) error {
+// MigrateUp применяет все встроенные миграции, которых ещё нет в БД.
+func MigrateUp(ctx context.Context, dsn string) error {
+	return withMigrate(ctx, dsn, func(m *migrate.Migrate) error {
+		return m.Up() //nolint:wrapcheck // wrapped by withMigrate
+	})
+}
+
+// MigrateDown откатывает последние steps миграций.
+func MigrateDown(ctx context.Context, dsn string, steps int) error {
+	return withMigrate(ctx, dsn, func(m *migrate.Migrate) error {
+		return m.Steps(-steps) //nolint:wrapcheck // wrapped by withMigrate
+	})
+}
+
+// MigrateTo приводит схему к версии version, применяя или откатывая миграции.
+func MigrateTo(ctx context.Context, dsn string, version uint) error {
+	return withMigrate(ctx, dsn, func(m *migrate.Migrate) error {
+		return m.Migrate(version) //nolint:wrapcheck // wrapped by withMigrate
+	})
+}
+
+// MigrateForce записывает version как текущую версию и снимает пометку dirty, ничего не применяя.
+// Нужна после упавшей миграции, когда схему поправили вручную.
+func MigrateForce(ctx context.Context, dsn string, version int) error {
+	return withMigrate(ctx, dsn, func(m *migrate.Migrate) error {
+		return m.Force(version) //nolint:wrapcheck // wrapped by withMigrate
+	})
+}
+
+// MigrationVersion возвращает текущую версию схемы; 0 — миграции ещё не применялись.
+func MigrationVersion(ctx context.Context, dsn string) (uint, bool, error) {
+	var (
+		version uint
+		dirty   bool
+	)
+	err := withMigrate(ctx, dsn, func(m *migrate.Migrate) error {
+		var err error
+		if version, dirty, err = m.Version(); errors.Is(err, migrate.ErrNilVersion) {
+			return nil
+		}
+
+		return err //nolint:wrapcheck // wrapped by withMigrate
+	})
+
+	return version, dirty, err
+}
+
+// withMigrate выполняет fn над встроенными миграциями; отсутствие изменений ошибкой не считается.
+// На всё время работы берётся сеансовая advisory-блокировка, поэтому экземпляры, запущенные
+// одновременно, накатывают миграции по очереди, а не наперегонки.
+func withMigrate(ctx context.Context, dsn string, fn func(m *migrate.Migrate) error) error {
+	conn, err := pgx.Connect(ctx, dsn)
+	if err != nil {
+		return fmt.Errorf("%w: %w", errDBUnavailable, err)
+	}
+
+	defer func() {
+		// Сеансовая блокировка снимается и при закрытии соединения.
+		_ = conn.Close(context.Background())
+	}()
+
+	if _, err = conn.Exec(ctx, "SELECT pg_advisory_lock($1)", migrationLockKey); err != nil {
+		return fmt.Errorf("failed to acquire migration lock: %w", err)
+	}
+
+	defer func() {
+		_, _ = conn.Exec(context.Background(), "SELECT pg_advisory_unlock($1)", migrationLockKey)
+	}()
+
 	d, err := iofs.New(migrationsDir, "migrations")
 	if err != nil {
 		return fmt.Errorf("failed to return an iofs driver: %w", err)
@@ -46,20 +208,70 @@ func runMigrations(dsn string) error {
 	if err != nil {
 		return fmt.Errorf("failed to get a new migrate instance: %w", err)
 	}
-	if err := m.Up(); err != nil {
-		if !errors.Is(err, migrate.ErrNoChange) {
-			return fmt.Errorf("failed to apply migrations to the DB: %w", err)
-		}
+
+	defer func() {
+		// Ошибки закрытия источника и соединения на результат миграции не влияют.
+		_, _ = m.Close()
+	}()
+
+	if err = fn(m); err != nil && !errors.Is(err, migrate.ErrNoChange) {
+		return fmt.Errorf("failed to apply migrations to the DB: %w", err)
 	}
+
 	return nil
 }
 
-func initPool(ctx context.Context, dsn string) (*pgxpool.Pool, error) {
+// LatestMigrationVersion возвращает версию последней встроенной миграции —
+// ту схему, с которой умеет работать бинарник.
+func LatestMigrationVersion() (uint, error) {
+	d, err := iofs.New(migrationsDir, "migrations")
+	if err != nil {
+		return 0, fmt.Errorf("failed to return an iofs driver: %w", err)
+	}
+
+	version, err := d.First()
+	if err != nil {
+		return 0, fmt.Errorf("failed to read first migration: %w", err)
+	}
+
+	for {
+		next, err := d.Next(version)
+		if err != nil {
+			if errors.Is(err, fs.ErrNotExist) {
+				return version, nil
+			}
+
+			return 0, fmt.Errorf("failed to read next migration: %w", err)
+		}
+
+		version = next
+	}
+}
+
+func initPool(ctx context.Context, dsn string, opts DBOptions, logger *zap.Logger) (*pgxpool.Pool, error) {
 	poolCfg, err := pgxpool.ParseConfig(dsn)
 	if err != nil {
 		return nil, fmt.Errorf("failed to parse DSN: %w", err)
 	}
 
+	poolCfg.ConnConfig.Tracer = &queryTracer{logger: logger}
+
+	if opts.MaxConns > 0 {
+		poolCfg.MaxConns = opts.MaxConns
+	}
+
+	if opts.MaxConnLifetime > 0 {
+		poolCfg.MaxConnLifetime = opts.MaxConnLifetime
+	}
+
+	if opts.MaxConnIdleTime > 0 {
+		poolCfg.MaxConnIdleTime = opts.MaxConnIdleTime
+	}
+
+	if opts.StatementTimeout > 0 {
+		poolCfg.ConnConfig.RuntimeParams["statement_timeout"] = strconv.FormatInt(opts.StatementTimeout.Milliseconds(), 10)
+	}
+
 	pool, err := pgxpool.NewWithConfig(ctx, poolCfg)
 	if err != nil {
 		return nil, fmt.Errorf("failed to initalize a connection pool: %w", err)
@@ -68,143 +280,1800 @@ func initPool(ctx context.Context, dsn string) (*pgxpool.Pool, error) {
 	return pool, nil
 }
 
-func (s *DBStore) SaveURL(ctx context.Context, fullURL string, shortURL string, userID string) (string, error) {
-	var resultShortURL string
+func (s *DBStore) SaveURL(ctx context.Context,
+	fullURL string, shortURL string, userID string, meta models.LinkMeta, opts models.LinkOptions) (string, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	tx, err := s.pool.Begin(ctx)
+	if err != nil {
+		return "", fmt.Errorf("failed to begin transaction: %w", err)
+	}
+
+	defer func() {
+		if err := tx.Rollback(ctx); err != nil && !errors.Is(err, pgx.ErrTxClosed) {
+			logger.FromContext(ctx, s.logger).Error("Failed to rollback transaction", zap.Error(err))
+		}
+	}()
+
+	var (
+		id             int
+		resultShortURL string
+		saved          bool
+		trashed        bool
+	)
+	// Удалённую ссылку того же владельца занимаем заново, чужую не трогаем.
 	query := `
 		WITH new_url AS (
-			INSERT INTO short_links(short_url, original_url, user_id)
-			VALUES ($1, $2, $3)
-			ON CONFLICT (original_url) DO
+			INSERT INTO short_links(short_url, original_url, user_id, title, tags, note, password_hash, domain,
+				query_params, sticky_targets, routing_rules, max_clicks, clicks_left, not_before)
+			VALUES ($1, $2, $3, $4, $5, $6, NULLIF($7, ''), $8, $9, $10, $11, $12, $12, $13)
+			ON CONFLICT (domain, original_url) DO
 			UPDATE SET
 				short_url = EXCLUDED.short_url,
-				deleted = FALSE
-			WHERE short_links.deleted = TRUE
-			RETURNING short_url
+				deleted = FALSE,
+				deleted_at = NULL,
+				title = EXCLUDED.title,
+				tags = EXCLUDED.tags,
+				note = EXCLUDED.note,
+				password_hash = EXCLUDED.password_hash,
+				query_params = EXCLUDED.query_params,
+				sticky_targets = EXCLUDED.sticky_targets,
+				routing_rules = EXCLUDED.routing_rules,
+				max_clicks = EXCLUDED.max_clicks,
+				clicks_left = EXCLUDED.clicks_left,
+				not_before = EXCLUDED.not_before
+			WHERE short_links.deleted = TRUE AND short_links.user_id IS NOT DISTINCT FROM EXCLUDED.user_id
+			RETURNING id, short_url
 		)
-		SELECT short_url FROM new_url
-		UNION
-		SELECT short_url FROM short_links WHERE original_url = $2
+		SELECT id, short_url, TRUE, FALSE FROM new_url
+		UNION ALL
+		SELECT id, short_url, FALSE, deleted FROM short_links
+		WHERE domain = $8 AND original_url = $2 AND NOT EXISTS (SELECT 1 FROM new_url)
 	`
-	err := s.pool.QueryRow(ctx, query, shortURL, fullURL, userID).Scan(&resultShortURL)
+	err = tx.
+		QueryRow(ctx, query, shortURL, fullURL, userID, meta.Title, tagsOrEmpty(meta.Tags), meta.Note,
+			opts.PasswordHash, opts.Domain, paramsOrEmpty(opts.QueryParams), opts.Sticky, rulesOrEmpty(opts.Rules),
+			opts.MaxClicks, opts.NotBefore).
+		Scan(&id, &resultShortURL, &saved, &trashed)
 	if err != nil {
 		return "", fmt.Errorf("failed to save URL: %w", err)
 	}
 
+	// Адрес занят ссылкой в корзине другого владельца: она остаётся за ним до очистки корзины.
+	if trashed {
+		return "", fmt.Errorf("%w", ErrURLExists)
+	}
+
+	// Ссылка на тот же адрес уже есть — её адреса A/B не трогаем.
+	if saved {
+		if err = saveTargets(ctx, tx, id, opts.Targets); err != nil {
+			return "", err
+		}
+
+		if err = recordEvents(ctx, tx, models.EventLinkCreated, []int{id}); err != nil {
+			return "", err
+		}
+	}
+
+	if err = tx.Commit(ctx); err != nil {
+		return "", fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
 	return resultShortURL, nil
 }
 
+// saveTargets заменяет адреса A/B-ссылки; при повторном использовании удалённой строки
+// её прежние адреса и счётчики сбрасываются.
+func saveTargets(ctx context.Context, tx pgx.Tx, linkID int, targets []models.LinkTarget) error {
+	if _, err := tx.Exec(ctx, "DELETE FROM short_link_targets WHERE short_link_id = $1", linkID); err != nil {
+		return fmt.Errorf("failed to remove link targets: %w", err)
+	}
+
+	for position, target := range targets {
+		_, err := tx.Exec(ctx,
+			"INSERT INTO short_link_targets (short_link_id, position, url, weight) VALUES ($1, $2, $3, $4)",
+			linkID, position, target.URL, target.Weight)
+		if err != nil {
+			return fmt.Errorf("failed to save link target: %w", err)
+		}
+	}
+
+	return nil
+}
+
 func (s *DBStore) SaveURLsBatch(ctx context.Context, urls map[string]string, userID string) (map[string]string, error) {
-	query := `INSERT INTO short_links (original_url, short_url, user_id) VALUES ($1, $2, $3)`
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	query := `INSERT INTO short_links (original_url, short_url, user_id) VALUES ($1, $2, $3) RETURNING id`
 	batch := &pgx.Batch{}
 	for fullURL, shortURL := range urls {
 		batch.Queue(query, fullURL, shortURL, userID)
 	}
 
-	results := s.pool.SendBatch(ctx, batch)
-	defer func() {
-		err := results.Close()
+	err := s.inTx(ctx, func(tx pgx.Tx) error {
+		ids, err := execBatchIDs(ctx, tx, batch)
 		if err != nil {
-			s.logger.Error("Failed to close batch", zap.Error(err))
+			return fmt.Errorf("unable to insert row: %w", err)
 		}
-	}()
 
-	res := make(map[string]string)
+		return recordEvents(ctx, tx, models.EventLinkCreated, ids)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	res := make(map[string]string, len(urls))
 	for fullURL, shortURL := range urls {
-		_, err := results.Exec()
+		res[fullURL] = shortURL
+	}
+
+	return res, nil
+}
+
+func (s *DBStore) UpdateURL(ctx context.Context,
+	domain string, shortURL string, userID string, fullURL string) (models.Data, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	tx, err := s.pool.Begin(ctx)
+	if err != nil {
+		return models.Data{}, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+
+	defer func() {
+		if err := tx.Rollback(ctx); err != nil && !errors.Is(err, pgx.ErrTxClosed) {
+			logger.FromContext(ctx, s.logger).Error("Failed to rollback transaction", zap.Error(err))
+		}
+	}()
+
+	var (
+		id         int
+		currentURL string
+	)
+	err = tx.QueryRow(ctx, `
+		SELECT id, original_url
+		FROM short_links
+		WHERE user_id = $1
+		AND short_url = $2
+		AND domain = $3
+		AND deleted = FALSE
+		FOR UPDATE
+	`, userID, shortURL, domain).Scan(&id, &currentURL)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return models.Data{}, fmt.Errorf("%w", ErrURLNotFound)
+		}
+
+		return models.Data{}, fmt.Errorf("failed to get URL: %w", err)
+	}
+
+	if currentURL != fullURL {
+		// Адрес может занимать и удалённая ссылка, в том числе чужая: она хранится до очистки
+		// корзины, поэтому изменение отклоняется, а не освобождает адрес.
+		_, err = tx.Exec(ctx, "UPDATE short_links SET original_url = $2 WHERE id = $1", id, fullURL)
 		if err != nil {
-			return nil, fmt.Errorf("unable to insert row: %w", err)
+			var pgErr *pgconn.PgError
+			if errors.As(err, &pgErr) && pgErr.Code == uniqueViolationCode {
+				return models.Data{}, fmt.Errorf("%w", ErrURLExists)
+			}
+
+			return models.Data{}, fmt.Errorf("failed to update URL: %w", err)
 		}
 
-		res[fullURL] = shortURL
+		_, err = tx.Exec(ctx,
+			"INSERT INTO short_link_revisions (short_link_id, original_url) VALUES ($1, $2)", id, currentURL)
+		if err != nil {
+			return models.Data{}, fmt.Errorf("failed to save URL revision: %w", err)
+		}
+
+		if err = recordEvents(ctx, tx, models.EventLinkUpdated, []int{id}); err != nil {
+			return models.Data{}, err
+		}
 	}
 
-	return res, nil
+	data, err := scanLink(tx.QueryRow(ctx, "SELECT "+linkColumns+" FROM short_links WHERE id = $1", id))
+	if err != nil {
+		return models.Data{}, fmt.Errorf("failed to get updated URL: %w", err)
+	}
+
+	if err = tx.Commit(ctx); err != nil {
+		return models.Data{}, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return data, nil
+}
+
+func (s *DBStore) GetURLRevisions(ctx context.Context,
+	domain string, shortURL string, userID string) ([]models.URLRevision, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	var id int
+	err := s.pool.
+		QueryRow(ctx, `
+			SELECT id FROM short_links
+			WHERE user_id = $1 AND short_url = $2 AND domain = $3 AND deleted = FALSE
+		`, userID, shortURL, domain).
+		Scan(&id)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, fmt.Errorf("%w", ErrURLNotFound)
+		}
+
+		return nil, fmt.Errorf("failed to get URL: %w", err)
+	}
+
+	rows, err := s.pool.Query(ctx, `
+		SELECT original_url, changed_at
+		FROM short_link_revisions
+		WHERE short_link_id = $1
+		ORDER BY id
+	`, id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get URL revisions: %w", err)
+	}
+
+	defer rows.Close()
+
+	revisions := make([]models.URLRevision, 0)
+	for rows.Next() {
+		var revision models.URLRevision
+
+		if err = rows.Scan(&revision.OriginalURL, &revision.ChangedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan row: %w", err)
+		}
+
+		revisions = append(revisions, revision)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error reading rows: %w", err)
+	}
+
+	return revisions, nil
 }
 
-func (s *DBStore) DeleteURLs(ctx context.Context, urls []string, userID string) error {
+func (s *DBStore) DeleteURLs(ctx context.Context, domain string, urls []string, userID string) error {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
 	query := `
         UPDATE short_links
-        SET deleted = TRUE
+        SET deleted = TRUE, deleted_at = NOW()
         WHERE user_id = $1
-        AND short_url = $2;
+        AND short_url = $2
+        AND domain = $3
+        AND deleted = FALSE
+        RETURNING id;
     `
 	batch := &pgx.Batch{}
 	for _, shortURL := range urls {
-		batch.Queue(query, userID, shortURL)
+		batch.Queue(query, userID, shortURL, domain)
 	}
 
-	err := s.pool.SendBatch(ctx, batch).Close()
-	if err != nil {
-		return fmt.Errorf("sendBatch error: %w", err)
-	}
+	return s.inTx(ctx, func(tx pgx.Tx) error {
+		ids, err := execBatchIDs(ctx, tx, batch)
+		if err != nil {
+			return fmt.Errorf("sendBatch error: %w", err)
+		}
 
-	return nil
+		return recordEvents(ctx, tx, models.EventLinkDeleted, ids)
+	})
 }
 
-func (s *DBStore) CleanupDeletedURLs(ctx context.Context) error {
-	_, err := s.pool.Exec(ctx, "DELETE FROM short_links WHERE deleted = true")
-	if err != nil {
-		return fmt.Errorf("failed to cleanup deleted urls: %w", err)
+func (s *DBStore) RestoreURLs(ctx context.Context, domain string, urls []string, userID string) error {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	// Ссылку не восстанавливаем, если её короткий адрес уже занят другой активной ссылкой.
+	query := `
+        UPDATE short_links
+        SET deleted = FALSE, deleted_at = NULL
+        WHERE user_id = $1
+        AND short_url = $2
+        AND domain = $3
+        AND deleted = TRUE
+        AND NOT EXISTS (
+            SELECT 1 FROM short_links active
+            WHERE active.domain = short_links.domain
+            AND active.short_url = $2
+            AND active.deleted = FALSE
+        )
+        RETURNING id;
+    `
+	batch := &pgx.Batch{}
+	for _, shortURL := range urls {
+		batch.Queue(query, userID, shortURL, domain)
 	}
 
-	return nil
+	return s.inTx(ctx, func(tx pgx.Tx) error {
+		ids, err := execBatchIDs(ctx, tx, batch)
+		if err != nil {
+			return fmt.Errorf("sendBatch error: %w", err)
+		}
+
+		return recordEvents(ctx, tx, models.EventLinkRestored, ids)
+	})
 }
 
-func (s *DBStore) GetURL(ctx context.Context, shortURL string) (string, bool, error) {
-	var (
-		originalURL string
-		deleted     bool
-	)
-	err := s.pool.
-		QueryRow(ctx, "SELECT original_url, deleted FROM short_links WHERE short_url = $1", shortURL).
-		Scan(&originalURL, &deleted)
-	if err != nil {
-		return "", false, fmt.Errorf("failed to get full URL: %w", err)
-	}
+func (s *DBStore) CleanupDeletedURLs(ctx context.Context, deletedBefore time.Time) error {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	return s.inTx(ctx, func(tx pgx.Tx) error {
+		rows, err := tx.Query(ctx, `
+			SELECT id FROM short_links
+			WHERE deleted = TRUE AND (deleted_at IS NULL OR deleted_at < $1)
+			FOR UPDATE
+		`, deletedBefore)
+		if err != nil {
+			return fmt.Errorf("failed to get deleted urls: %w", err)
+		}
+
+		ids, err := pgx.CollectRows(rows, pgx.RowTo[int])
+		if err != nil {
+			return fmt.Errorf("failed to scan deleted urls: %w", err)
+		}
+
+		// Событие пишется до удаления, пока строка ссылки ещё есть.
+		if err = recordEvents(ctx, tx, models.EventLinkPurged, ids); err != nil {
+			return err
+		}
 
-	return originalURL, deleted, nil
+		if _, err = tx.Exec(ctx, "DELETE FROM short_links WHERE id = ANY($1)", ids); err != nil {
+			return fmt.Errorf("failed to cleanup deleted urls: %w", err)
+		}
+
+		return nil
+	})
 }
 
-func (s *DBStore) GetURLs(ctx context.Context, userID string) (map[string]string, error) {
-	rows, err := s.pool.Query(ctx, "SELECT short_url, original_url FROM short_links WHERE user_id = $1", userID)
+// SearchURLs отбирает ссылки всех владельцев в порядке домена, кода и владельца.
+func (s *DBStore) SearchURLs(ctx context.Context, search models.LinkSearch) ([]models.Data, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	var after models.LinkCursor
+	if search.After != nil {
+		after = *search.After
+	}
+
+	rows, err := s.pool.Query(ctx, `
+		SELECT `+linkColumns+`
+		FROM short_links
+		WHERE ($1 = '' OR user_id::text = $1)
+		AND ($2 = '' OR short_url = $2)
+		AND strpos(original_url, $3) > 0
+		AND ($4 OR deleted = FALSE)
+		AND (NOT $6::boolean OR (domain, short_url, COALESCE(user_id::text, '')) > ($7, $8, $9))
+		ORDER BY domain, short_url, COALESCE(user_id::text, '')
+		LIMIT NULLIF($5, 0)
+	`, search.UserID, search.ShortURL, search.Destination, search.IncludeDeleted, search.Limit,
+		search.After != nil, after.Domain, after.ShortURL, after.UserID)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get user URLs: %w", err)
+		return nil, fmt.Errorf("failed to search URLs: %w", err)
 	}
 
 	defer rows.Close()
 
-	urls := make(map[string]string)
+	urls := make([]models.Data, 0)
 	for rows.Next() {
-		var shortURL, fullURL string
-
-		err = rows.Scan(&shortURL, &fullURL)
+		data, err := scanLink(rows)
 		if err != nil {
 			return nil, fmt.Errorf("failed to scan row: %w", err)
 		}
 
-		urls[shortURL] = fullURL
+		urls = append(urls, data)
 	}
 
 	if err = rows.Err(); err != nil {
 		return nil, fmt.Errorf("error reading rows: %w", err)
 	}
 
-	if len(urls) == 0 {
-		return nil, fmt.Errorf("%w: no URLs found for user", ErrUserHasNoURLs)
+	return urls, nil
+}
+
+func (s *DBStore) GetStats(ctx context.Context) (models.StoreStats, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	var stats models.StoreStats
+	err := s.pool.QueryRow(ctx, `
+		SELECT
+			(SELECT COUNT(*) FROM short_links WHERE deleted = FALSE),
+			(SELECT COUNT(*) FROM short_links WHERE deleted = TRUE),
+			(SELECT COUNT(DISTINCT user_id) FROM short_links),
+			(SELECT COUNT(*) FROM users),
+			(SELECT COUNT(*) FROM api_keys WHERE revoked_at IS NULL),
+			(SELECT COUNT(*) FROM workspaces),
+			(SELECT COUNT(*) FROM webhooks),
+			(SELECT COUNT(*) FROM webhook_deliveries WHERE dead = FALSE),
+			(SELECT COUNT(*) FROM webhook_deliveries WHERE dead = TRUE)
+	`).Scan(&stats.Links, &stats.DeletedLinks, &stats.Owners, &stats.Users, &stats.APIKeys, &stats.Workspaces,
+		&stats.Webhooks, &stats.QueuedDeliveries, &stats.DeadDeliveries)
+	if err != nil {
+		return models.StoreStats{}, fmt.Errorf("failed to get stats: %w", err)
 	}
 
-	return urls, nil
+	return stats, nil
 }
 
-func (s *DBStore) Ping(ctx context.Context) error {
-	err := s.pool.Ping(ctx)
+// recordTables — таблицы, в которых хранятся записи каждого вида.
+var recordTables = map[string]string{
+	models.RecordUsers:      "users",
+	models.RecordAPIKeys:    "api_keys",
+	models.RecordSessions:   "revoked_sessions",
+	models.RecordWorkspaces: "workspaces",
+	models.RecordMembers:    "workspace_members",
+	models.RecordLinks:      "short_links",
+	models.RecordWebhooks:   "webhooks",
+	models.RecordDeliveries: "webhook_deliveries",
+}
+
+func (s *DBStore) CountRecords(ctx context.Context, kind string) (int64, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	table, ok := recordTables[kind]
+	if !ok {
+		return 0, fmt.Errorf("%w %q", ErrUnknownRecordKind, kind)
+	}
+
+	var count int64
+	if err := s.pool.QueryRow(ctx, "SELECT COUNT(*) FROM "+table).Scan(&count); err != nil {
+		return 0, fmt.Errorf("failed to count %s: %w", kind, err)
+	}
+
+	return count, nil
+}
+
+// ReadRecords отдаёт записи вида kind в порядке первичного ключа; курсор after — ключ последней
+// записи предыдущей порции. Ссылки идут по id, поэтому с курсором читаются и удалённые дубли кода.
+func (s *DBStore) ReadRecords(ctx context.Context, kind string, after string, limit int) (models.RecordPage, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	// Пустой курсор — первая порция; NULLIF не даёт привести пустую строку к UUID.
+	const afterID = "WHERE $1 = '' OR id > NULLIF($1, '')::uuid ORDER BY id LIMIT $2"
+
+	switch kind {
+	case models.RecordUsers:
+		return s.readRecords(ctx, "SELECT id::text, name, created_at FROM users "+afterID,
+			func(row pgx.Row) (models.Record, string, error) {
+				var user models.User
+				err := row.Scan(&user.ID, &user.Name, &user.CreatedAt)
+				return models.Record{User: &user}, user.ID, err
+			}, limit, after)
+	case models.RecordAPIKeys:
+		return s.readRecords(ctx, "SELECT "+apiKeyColumns+" FROM api_keys "+afterID,
+			func(row pgx.Row) (models.Record, string, error) {
+				key, err := scanAPIKey(row)
+				return models.Record{APIKey: &key}, key.ID, err
+			}, limit, after)
+	case models.RecordSessions:
+		return s.readRecords(ctx, "SELECT id::text, user_id::text, revoked_at, expires_at FROM revoked_sessions "+afterID,
+			func(row pgx.Row) (models.Record, string, error) {
+				var session models.RevokedSession
+				err := row.Scan(&session.ID, &session.UserID, &session.RevokedAt, &session.ExpiresAt)
+				return models.Record{Session: &session}, session.ID, err
+			}, limit, after)
+	case models.RecordWorkspaces:
+		return s.readRecords(ctx, "SELECT id::text, name, created_at FROM workspaces "+afterID,
+			func(row pgx.Row) (models.Record, string, error) {
+				var workspace models.Workspace
+				err := row.Scan(&workspace.ID, &workspace.Name, &workspace.CreatedAt)
+				return models.Record{Workspace: &workspace}, workspace.ID, err
+			}, limit, after)
+	case models.RecordMembers:
+		workspaceID, userID, _ := strings.Cut(after, "/")
+		return s.readRecords(ctx, `
+			SELECT `+memberColumns+`
+			FROM workspace_members
+			WHERE $1 = '' OR (workspace_id, user_id) > (NULLIF($1, '')::uuid, NULLIF($2, '')::uuid)
+			ORDER BY workspace_id, user_id
+			LIMIT $3
+		`, func(row pgx.Row) (models.Record, string, error) {
+			member, err := scanMember(row)
+			return models.Record{Member: &member}, member.WorkspaceID + "/" + member.UserID, err
+		}, limit, workspaceID, userID)
+	case models.RecordLinks:
+		var afterLinkID int
+		if after != "" {
+			var err error
+			if afterLinkID, err = strconv.Atoi(after); err != nil {
+				return models.RecordPage{}, fmt.Errorf("invalid links cursor %q: %w", after, err)
+			}
+		}
+
+		return s.readRecords(ctx, `
+			SELECT `+linkColumns+`,
+				COALESCE((
+					SELECT json_agg(json_build_object('original_url', r.original_url, 'changed_at', r.changed_at)
+						ORDER BY r.id)
+					FROM short_link_revisions r
+					WHERE r.short_link_id = short_links.id
+				), '[]'),
+				id
+			FROM short_links
+			WHERE id > $1
+			ORDER BY id
+			LIMIT $2
+		`, func(row pgx.Row) (models.Record, string, error) {
+			var id int
+			var revisions []models.URLRevision
+			data, err := scanLink(row, &revisions, &id)
+			if len(revisions) > 0 {
+				data.Revisions = revisions
+			}
+			return models.Record{Link: &data}, strconv.Itoa(id), err
+		}, limit, afterLinkID)
+	case models.RecordWebhooks:
+		return s.readRecords(ctx, "SELECT id::text, user_id::text, url, events, secret, created_at FROM webhooks "+afterID,
+			func(row pgx.Row) (models.Record, string, error) {
+				var webhook models.Webhook
+				err := row.Scan(&webhook.ID, &webhook.UserID, &webhook.URL, &webhook.Events, &webhook.Secret,
+					&webhook.CreatedAt)
+				return models.Record{Webhook: &webhook}, webhook.ID, err
+			}, limit, after)
+	case models.RecordDeliveries:
+		return s.readRecords(ctx, `
+			SELECT `+deliveryColumns+`
+			FROM webhook_deliveries d
+			WHERE $1 = '' OR d.id > NULLIF($1, '')::uuid
+			ORDER BY d.id
+			LIMIT $2
+		`, func(row pgx.Row) (models.Record, string, error) {
+			delivery, err := scanDelivery(row)
+			return models.Record{Delivery: &delivery}, delivery.ID, err
+		}, limit, after)
+	default:
+		return models.RecordPage{}, fmt.Errorf("%w %q", ErrUnknownRecordKind, kind)
+	}
+}
+
+// readRecords выполняет query с аргументами курсора args и лимитом последним параметром и собирает
+// порцию; scan возвращает запись и её ключ для курсора следующей порции.
+func (s *DBStore) readRecords(ctx context.Context, query string,
+	scan func(row pgx.Row) (models.Record, string, error), limit int, args ...any) (models.RecordPage, error) {
+	rows, err := s.pool.Query(ctx, query, append(args, limit)...)
 	if err != nil {
-		return fmt.Errorf("failed to ping DB: %w", err)
+		return models.RecordPage{}, fmt.Errorf("failed to read records: %w", err)
 	}
 
-	return nil
+	defer rows.Close()
+
+	page := models.RecordPage{Records: make([]models.Record, 0)}
+	var last string
+	for rows.Next() {
+		rec, key, err := scan(rows)
+		if err != nil {
+			return models.RecordPage{}, fmt.Errorf("failed to scan row: %w", err)
+		}
+
+		page.Records = append(page.Records, rec)
+		last = key
+	}
+
+	if err = rows.Err(); err != nil {
+		return models.RecordPage{}, fmt.Errorf("error reading rows: %w", err)
+	}
+
+	// Полная порция — возможно, есть следующая; пустая следующая порция завершит чтение.
+	if len(page.Records) == limit {
+		page.Next = last
+	}
+
+	return page, nil
+}
+
+// WriteRecords сохраняет порцию в одной транзакции. Запись с занятым ключом не перезаписывается:
+// совпадающая считается перенесённой раньше, отличающаяся — конфликтом. Конфликтом считается
+// и запись, которая ссылается на отсутствующего пользователя, пространство или вебхук.
+func (s *DBStore) WriteRecords(ctx context.Context, records []models.Record) (models.RecordsWriteResult, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	var res models.RecordsWriteResult
+	err := s.inTx(ctx, func(tx pgx.Tx) error {
+		res = models.RecordsWriteResult{}
+		linkIDs := make([]int, 0)
+		for _, rec := range records {
+			var (
+				outcome writeOutcome
+				err     error
+			)
+			if rec.Link != nil {
+				var id int
+				if outcome, id, err = insertLinkRecord(ctx, tx, *rec.Link); outcome == outcomeWritten {
+					linkIDs = append(linkIDs, id)
+				}
+			} else {
+				outcome, err = insertRecord(ctx, tx, rec)
+			}
+
+			if err != nil {
+				return err
+			}
+
+			countOutcome(&res, outcome, rec)
+		}
+
+		return recordEvents(ctx, tx, models.EventLinkCreated, linkIDs)
+	})
+	if err != nil {
+		return models.RecordsWriteResult{}, err
+	}
+
+	return res, nil
+}
+
+// insertRecord вставляет сущность с её идентификатором. Вставка идёт под точкой сохранения,
+// чтобы нарушение внешнего ключа отменило только эту запись, а не всю порцию.
+func insertRecord(ctx context.Context, tx pgx.Tx, rec models.Record) (writeOutcome, error) {
+	var (
+		query string
+		args  []any
+	)
+	switch {
+	case rec.User != nil:
+		query = "INSERT INTO users (id, name, created_at) VALUES ($1, $2, $3) ON CONFLICT (id) DO NOTHING"
+		args = []any{rec.User.ID, rec.User.Name, rec.User.CreatedAt}
+	case rec.APIKey != nil:
+		query = `
+			INSERT INTO api_keys (id, user_id, name, prefix, key_hash, scopes, created_at, revoked_at)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+			ON CONFLICT (id) DO NOTHING
+		`
+		key := rec.APIKey
+		args = []any{key.ID, key.UserID, key.Name, key.Prefix, key.KeyHash, tagsOrEmpty(key.Scopes), key.CreatedAt,
+			key.RevokedAt}
+	case rec.Session != nil:
+		query = `
+			INSERT INTO revoked_sessions (id, user_id, revoked_at, expires_at)
+			VALUES ($1, $2, $3, $4)
+			ON CONFLICT (id) DO NOTHING
+		`
+		args = []any{rec.Session.ID, rec.Session.UserID, rec.Session.RevokedAt, rec.Session.ExpiresAt}
+	case rec.Workspace != nil:
+		query = "INSERT INTO workspaces (id, name, created_at) VALUES ($1, $2, $3) ON CONFLICT (id) DO NOTHING"
+		args = []any{rec.Workspace.ID, rec.Workspace.Name, rec.Workspace.CreatedAt}
+	case rec.Member != nil:
+		query = `
+			INSERT INTO workspace_members (workspace_id, user_id, role, added_at)
+			VALUES ($1, $2, $3, $4)
+			ON CONFLICT (workspace_id, user_id) DO NOTHING
+		`
+		args = []any{rec.Member.WorkspaceID, rec.Member.UserID, rec.Member.Role, rec.Member.AddedAt}
+	case rec.Webhook != nil:
+		query = `
+			INSERT INTO webhooks (id, user_id, url, events, secret, created_at)
+			VALUES ($1, $2, $3, $4, $5, $6)
+			ON CONFLICT (id) DO NOTHING
+		`
+		webhook := rec.Webhook
+		args = []any{webhook.ID, webhook.UserID, webhook.URL, tagsOrEmpty(webhook.Events), webhook.Secret,
+			webhook.CreatedAt}
+	case rec.Delivery != nil:
+		query = `
+			INSERT INTO webhook_deliveries (id, webhook_id, event, payload, attempts, next_attempt_at, last_error,
+				dead, created_at)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+			ON CONFLICT (id) DO NOTHING
+		`
+		delivery := rec.Delivery
+		args = []any{delivery.ID, delivery.WebhookID, delivery.Event, delivery.Payload, delivery.Attempts,
+			delivery.NextAttemptAt, delivery.LastError, delivery.Dead, delivery.CreatedAt}
+	default:
+		return outcomeConflict, fmt.Errorf("%w: record has no data", ErrUnknownRecordKind)
+	}
+
+	savepoint, err := tx.Begin(ctx)
+	if err != nil {
+		return outcomeConflict, fmt.Errorf("failed to create savepoint: %w", err)
+	}
+
+	tag, err := savepoint.Exec(ctx, query, args...)
+	if err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == foreignKeyViolationCode {
+			if err = savepoint.Rollback(ctx); err != nil {
+				return outcomeConflict, fmt.Errorf("failed to rollback to savepoint: %w", err)
+			}
+
+			return outcomeConflict, nil
+		}
+
+		return outcomeConflict, fmt.Errorf("failed to insert record: %w", err)
+	}
+
+	if err = savepoint.Commit(ctx); err != nil {
+		return outcomeConflict, fmt.Errorf("failed to release savepoint: %w", err)
+	}
+
+	if tag.RowsAffected() == 0 {
+		return outcomePresent, nil
+	}
+
+	return outcomeWritten, nil
+}
+
+// insertLinkRecord вставляет ссылку со всеми полями, адресами A/B со счётчиками и историей адресов.
+// Если код или адрес назначения в домене заняты, та же ссылка того же владельца считается
+// перенесённой раньше, любая другая — конфликтом.
+func insertLinkRecord(ctx context.Context, tx pgx.Tx, data models.Data) (writeOutcome, int, error) {
+	var id int
+	err := tx.QueryRow(ctx, `
+		INSERT INTO short_links (short_url, original_url, user_id, deleted, deleted_at, title, tags, note,
+			password_hash, domain, query_params, sticky_targets, routing_rules, max_clicks, clicks_left, not_before)
+		VALUES ($1, $2, NULLIF($3, '')::uuid, $4, $5, $6, $7, $8, NULLIF($9, ''), $10, $11, $12, $13, $14, $15, $16)
+		ON CONFLICT DO NOTHING
+		RETURNING id
+	`, data.ShortURL, data.OriginalURL, data.UserID, data.Deleted, data.DeletedAt, data.Title,
+		tagsOrEmpty(data.Tags), data.Note, data.PasswordHash, data.Domain, paramsOrEmpty(data.QueryParams),
+		data.Sticky, rulesOrEmpty(data.Rules), data.MaxClicks, data.ClicksLeft, data.NotBefore).
+		Scan(&id)
+	if errors.Is(err, pgx.ErrNoRows) {
+		var present bool
+		err = tx.QueryRow(ctx, `
+			SELECT EXISTS (
+				SELECT 1 FROM short_links
+				WHERE domain = $1 AND short_url = $2 AND original_url = $3
+				AND user_id IS NOT DISTINCT FROM NULLIF($4, '')::uuid
+			)
+		`, data.Domain, data.ShortURL, data.OriginalURL, data.UserID).Scan(&present)
+		if err != nil {
+			return outcomeConflict, 0, fmt.Errorf("failed to check link %s: %w", data.ShortURL, err)
+		}
+
+		if present {
+			return outcomePresent, 0, nil
+		}
+
+		return outcomeConflict, 0, nil
+	}
+
+	if err != nil {
+		return outcomeConflict, 0, fmt.Errorf("failed to insert link %s: %w", data.ShortURL, err)
+	}
+
+	for position, target := range data.Targets {
+		_, err = tx.Exec(ctx, `
+			INSERT INTO short_link_targets (short_link_id, position, url, weight, clicks)
+			VALUES ($1, $2, $3, $4, $5)
+		`, id, position, target.URL, target.Weight, target.Clicks)
+		if err != nil {
+			return outcomeConflict, 0, fmt.Errorf("failed to save link target: %w", err)
+		}
+	}
+
+	for _, revision := range data.Revisions {
+		_, err = tx.Exec(ctx,
+			"INSERT INTO short_link_revisions (short_link_id, original_url, changed_at) VALUES ($1, $2, $3)",
+			id, revision.OriginalURL, revision.ChangedAt)
+		if err != nil {
+			return outcomeConflict, 0, fmt.Errorf("failed to save link revision: %w", err)
+		}
+	}
+
+	return outcomeWritten, id, nil
+}
+
+// inTx выполняет fn в транзакции и фиксирует её, если fn завершилась без ошибки.
+func (s *DBStore) inTx(ctx context.Context, fn func(tx pgx.Tx) error) error {
+	tx, err := s.pool.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+
+	defer func() {
+		if err := tx.Rollback(ctx); err != nil && !errors.Is(err, pgx.ErrTxClosed) {
+			logger.FromContext(ctx, s.logger).Error("Failed to rollback transaction", zap.Error(err))
+		}
+	}()
+
+	if err = fn(tx); err != nil {
+		return err
+	}
+
+	if err = tx.Commit(ctx); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return nil
+}
+
+// execBatchIDs выполняет батч запросов с RETURNING id и собирает идентификаторы затронутых ссылок.
+func execBatchIDs(ctx context.Context, tx pgx.Tx, batch *pgx.Batch) ([]int, error) {
+	results := tx.SendBatch(ctx, batch)
+
+	ids := make([]int, 0, batch.Len())
+	var err error
+	for range batch.Len() {
+		var rows pgx.Rows
+		if rows, err = results.Query(); err != nil {
+			break
+		}
+
+		var queryIDs []int
+		if queryIDs, err = pgx.CollectRows(rows, pgx.RowTo[int]); err != nil {
+			break
+		}
+
+		ids = append(ids, queryIDs...)
+	}
+
+	if closeErr := results.Close(); err == nil {
+		err = closeErr
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("%w", err)
+	}
+
+	return ids, nil
+}
+
+// recordEvents пишет в outbox по событию на каждую ссылку из linkIDs в транзакции самого изменения.
+// Блокировка до конца транзакции упорядочивает фиксацию: иначе событие с меньшим id могло бы
+// стать видимым позже большего, и читатель с курсором after пропустил бы его. Её берут последней,
+// после блокировок строк, поэтому взаимных блокировок она не добавляет.
+// Цена — блокировка общая: транзакции, меняющие ссылки, фиксируются строго по одной, включая пакеты
+// WriteRecords при переносе shortenerctl copy. Пропускная способность записи ссылок ограничена
+// одной транзакцией за раз, и большой пул соединений (-db-max-conns) её не увеличивает; чтение не ждёт.
+func recordEvents(ctx context.Context, tx pgx.Tx, eventType string, linkIDs []int) error {
+	if len(linkIDs) == 0 {
+		return nil
+	}
+
+	if _, err := tx.Exec(ctx, "SELECT pg_advisory_xact_lock($1)", outboxLockKey); err != nil {
+		return fmt.Errorf("failed to lock link events: %w", err)
+	}
+
+	_, err := tx.Exec(ctx, `
+		INSERT INTO link_events (event_type, short_url, domain, user_id, original_url, deleted)
+		SELECT $1, short_url, domain, user_id, original_url, deleted
+		FROM short_links
+		WHERE id = ANY($2)
+		ORDER BY id
+	`, eventType, linkIDs)
+	if err != nil {
+		return fmt.Errorf("failed to record link events: %w", err)
+	}
+
+	return nil
+}
+
+func (s *DBStore) GetLinkEvents(ctx context.Context, after int64, limit int) ([]models.LinkEvent, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	rows, err := s.pool.Query(ctx, `
+		SELECT id, event_type, short_url, domain, COALESCE(user_id::text, ''), original_url, deleted, created_at
+		FROM link_events
+		WHERE id > $1
+		ORDER BY id
+		LIMIT $2
+	`, after, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get link events: %w", err)
+	}
+
+	defer rows.Close()
+
+	events := make([]models.LinkEvent, 0)
+	for rows.Next() {
+		var event models.LinkEvent
+
+		err = rows.Scan(&event.ID, &event.Type, &event.ShortURL, &event.Domain, &event.UserID,
+			&event.OriginalURL, &event.Deleted, &event.CreatedAt)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan row: %w", err)
+		}
+
+		events = append(events, event)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error reading rows: %w", err)
+	}
+
+	return events, nil
+}
+
+// linkColumns — колонки short_links в порядке, который ожидает scanLink.
+// Адреса A/B-ссылки собираются подзапросом в JSON, чтобы ссылка читалась одним запросом.
+const linkColumns = `short_url, original_url, COALESCE(user_id::text, ''), deleted, deleted_at,
+	title, tags, note, COALESCE(password_hash, ''), domain, query_params, sticky_targets, routing_rules,
+	max_clicks, clicks_left, not_before,
+	COALESCE((
+		SELECT json_agg(json_build_object('url', t.url, 'weight', t.weight, 'clicks', t.clicks) ORDER BY t.position)
+		FROM short_link_targets t
+		WHERE t.short_link_id = short_links.id
+	), '[]')`
+
+func scanLink(row pgx.Row, extra ...any) (models.Data, error) {
+	var data models.Data
+	dest := append([]any{&data.ShortURL, &data.OriginalURL, &data.UserID, &data.Deleted, &data.DeletedAt,
+		&data.Title, &data.Tags, &data.Note, &data.PasswordHash, &data.Domain, &data.QueryParams, &data.Sticky,
+		&data.Rules, &data.MaxClicks, &data.ClicksLeft, &data.NotBefore,
+		&data.Targets}, extra...)
+	err := row.Scan(dest...)
+
+	return data, err //nolint:wrapcheck // callers wrap the error with their own context
+}
+
+func (s *DBStore) GetURL(ctx context.Context, domain string, shortURL string) (models.Data, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	// Удалённая ссылка могла освободить короткий адрес для другой — действующая важнее.
+	data, err := scanLink(s.pool.QueryRow(ctx, `
+		SELECT `+linkColumns+`
+		FROM short_links
+		WHERE domain = $1 AND short_url = $2
+		ORDER BY deleted
+		LIMIT 1
+	`, domain, shortURL))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return models.Data{}, fmt.Errorf("%w", ErrURLNotFound)
+		}
+
+		return models.Data{}, fmt.Errorf("failed to get full URL: %w", err)
+	}
+
+	return data, nil
+}
+
+func (s *DBStore) GetUserURL(ctx context.Context,
+	domain string, shortURL string, userID string) (models.Data, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	data, err := scanLink(s.pool.QueryRow(ctx, `
+		SELECT `+linkColumns+`
+		FROM short_links
+		WHERE user_id = $1 AND short_url = $2 AND domain = $3 AND deleted = FALSE
+	`, userID, shortURL, domain))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return models.Data{}, fmt.Errorf("%w", ErrURLNotFound)
+		}
+
+		return models.Data{}, fmt.Errorf("failed to get URL: %w", err)
+	}
+
+	return data, nil
+}
+
+func (s *DBStore) IncrementTargetClicks(ctx context.Context, domain string, shortURL string, target int) error {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	tag, err := s.pool.Exec(ctx, `
+		UPDATE short_link_targets
+		SET clicks = clicks + 1
+		WHERE position = $3
+		AND short_link_id = (
+			SELECT id FROM short_links
+			WHERE domain = $1 AND short_url = $2 AND deleted = FALSE
+		)
+	`, domain, shortURL, target)
+	if err != nil {
+		return fmt.Errorf("failed to count target click: %w", err)
+	}
+
+	if tag.RowsAffected() == 0 {
+		return fmt.Errorf("%w", ErrURLNotFound)
+	}
+
+	return nil
+}
+
+// ConsumeClick списывает переход одним условным UPDATE: два одновременных перехода
+// по одноразовой ссылке не смогут оба увидеть оставшийся переход.
+func (s *DBStore) ConsumeClick(ctx context.Context, domain string, shortURL string) error {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	tag, err := s.pool.Exec(ctx, `
+		UPDATE short_links
+		SET clicks_left = CASE WHEN max_clicks > 0 THEN clicks_left - 1 ELSE clicks_left END
+		WHERE domain = $1 AND short_url = $2 AND deleted = FALSE
+		AND (max_clicks = 0 OR clicks_left > 0)
+	`, domain, shortURL)
+	if err != nil {
+		return fmt.Errorf("failed to consume click: %w", err)
+	}
+
+	if tag.RowsAffected() == 0 {
+		return fmt.Errorf("%w", ErrNoClicksLeft)
+	}
+
+	return nil
+}
+
+func (s *DBStore) GetURLs(ctx context.Context, userID string, filter models.URLsFilter) ([]models.Data, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	query := `
+		SELECT ` + linkColumns + `
+		FROM short_links
+		WHERE user_id = $1
+		AND deleted = $2
+		AND ($3::text = '' OR $3::text = ANY(tags))
+	`
+	rows, err := s.pool.Query(ctx, query, userID, filter.Deleted, filter.Tag)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get user URLs: %w", err)
+	}
+
+	defer rows.Close()
+
+	var urls []models.Data
+	for rows.Next() {
+		data, err := scanLink(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan row: %w", err)
+		}
+
+		urls = append(urls, data)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error reading rows: %w", err)
+	}
+
+	if len(urls) == 0 {
+		return nil, fmt.Errorf("%w: no URLs found for user", ErrUserHasNoURLs)
+	}
+
+	return urls, nil
+}
+
+func (s *DBStore) UpdateURLMeta(ctx context.Context,
+	domain string, shortURL string, userID string, patch models.LinkMetaPatch) (models.Data, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	query := `
+		UPDATE short_links
+		SET
+			title = COALESCE($3, title),
+			tags = COALESCE($4, tags),
+			note = COALESCE($5, note)
+		WHERE user_id = $1 AND short_url = $2 AND domain = $6 AND deleted = FALSE
+		RETURNING id`
+	var tags []string
+	if patch.Tags != nil {
+		tags = tagsOrEmpty(*patch.Tags)
+	}
+
+	var data models.Data
+	err := s.inTx(ctx, func(tx pgx.Tx) error {
+		var id int
+		err := tx.QueryRow(ctx, query, userID, shortURL, patch.Title, tags, patch.Note, domain).Scan(&id)
+		if err != nil {
+			if errors.Is(err, pgx.ErrNoRows) {
+				return fmt.Errorf("%w", ErrURLNotFound)
+			}
+
+			return fmt.Errorf("failed to update URL meta: %w", err)
+		}
+
+		if err = recordEvents(ctx, tx, models.EventLinkUpdated, []int{id}); err != nil {
+			return err
+		}
+
+		data, err = scanLink(tx.QueryRow(ctx, "SELECT "+linkColumns+" FROM short_links WHERE id = $1", id))
+		if err != nil {
+			return fmt.Errorf("failed to get updated URL: %w", err)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return models.Data{}, err
+	}
+
+	return data, nil
+}
+
+func (s *DBStore) ClaimURLs(ctx context.Context, fromUserID string, toUserID string) (int64, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	return s.changeOwner(ctx, "UPDATE short_links SET user_id = $2 WHERE user_id = $1 RETURNING id",
+		fromUserID, toUserID)
+}
+
+func (s *DBStore) CreateUser(ctx context.Context, user models.User) error {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	_, err := s.pool.Exec(ctx,
+		"INSERT INTO users (id, name, created_at) VALUES ($1, $2, $3)", user.ID, user.Name, user.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to create user: %w", err)
+	}
+
+	return nil
+}
+
+func (s *DBStore) GetUser(ctx context.Context, userID string) (models.User, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	user := models.User{ID: userID}
+	err := s.pool.
+		QueryRow(ctx, "SELECT name, created_at FROM users WHERE id = $1", userID).
+		Scan(&user.Name, &user.CreatedAt)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return models.User{}, fmt.Errorf("%w", ErrUserNotFound)
+		}
+
+		return models.User{}, fmt.Errorf("failed to get user: %w", err)
+	}
+
+	return user, nil
+}
+
+func (s *DBStore) CreateAPIKey(ctx context.Context, key models.APIKey) error {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	query := `
+		INSERT INTO api_keys (id, user_id, name, prefix, key_hash, scopes, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+	`
+	_, err := s.pool.Exec(ctx, query,
+		key.ID, key.UserID, key.Name, key.Prefix, key.KeyHash, tagsOrEmpty(key.Scopes), key.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to create API key: %w", err)
+	}
+
+	return nil
+}
+
+const apiKeyColumns = "id::text, user_id::text, name, prefix, key_hash, scopes, created_at, revoked_at"
+
+func scanAPIKey(row pgx.Row) (models.APIKey, error) {
+	var key models.APIKey
+	err := row.Scan(&key.ID, &key.UserID, &key.Name, &key.Prefix, &key.KeyHash,
+		&key.Scopes, &key.CreatedAt, &key.RevokedAt)
+
+	return key, err //nolint:wrapcheck // callers wrap the error with their own context
+}
+
+func (s *DBStore) GetAPIKeyByHash(ctx context.Context, keyHash string) (models.APIKey, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	key, err := scanAPIKey(s.pool.QueryRow(ctx, "SELECT "+apiKeyColumns+" FROM api_keys WHERE key_hash = $1", keyHash))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return models.APIKey{}, fmt.Errorf("%w", ErrAPIKeyNotFound)
+		}
+
+		return models.APIKey{}, fmt.Errorf("failed to get API key: %w", err)
+	}
+
+	return key, nil
+}
+
+func (s *DBStore) GetAPIKeys(ctx context.Context, userID string) ([]models.APIKey, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	rows, err := s.pool.Query(ctx,
+		"SELECT "+apiKeyColumns+" FROM api_keys WHERE user_id = $1 ORDER BY created_at", userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get API keys: %w", err)
+	}
+
+	defer rows.Close()
+
+	keys := make([]models.APIKey, 0)
+	for rows.Next() {
+		key, err := scanAPIKey(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan row: %w", err)
+		}
+
+		keys = append(keys, key)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error reading rows: %w", err)
+	}
+
+	return keys, nil
+}
+
+func (s *DBStore) RevokeAPIKey(ctx context.Context, keyID string, userID string) error {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	tag, err := s.pool.Exec(ctx,
+		"UPDATE api_keys SET revoked_at = COALESCE(revoked_at, NOW()) WHERE id = $1 AND user_id = $2", keyID, userID)
+	if err != nil {
+		return fmt.Errorf("failed to revoke API key: %w", err)
+	}
+
+	if tag.RowsAffected() == 0 {
+		return fmt.Errorf("%w", ErrAPIKeyNotFound)
+	}
+
+	return nil
+}
+
+func (s *DBStore) RevokeSession(ctx context.Context, session models.RevokedSession) error {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	query := `
+		INSERT INTO revoked_sessions (id, user_id, revoked_at, expires_at)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (id) DO NOTHING
+	`
+	_, err := s.pool.Exec(ctx, query, session.ID, session.UserID, session.RevokedAt, session.ExpiresAt)
+	if err != nil {
+		return fmt.Errorf("failed to revoke session: %w", err)
+	}
+
+	return nil
+}
+
+func (s *DBStore) IsSessionRevoked(ctx context.Context, sessionID string) (bool, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	var revoked bool
+	err := s.pool.
+		QueryRow(ctx, "SELECT EXISTS (SELECT 1 FROM revoked_sessions WHERE id = $1)", sessionID).
+		Scan(&revoked)
+	if err != nil {
+		return false, fmt.Errorf("failed to check session: %w", err)
+	}
+
+	return revoked, nil
+}
+
+// ConsumeRefreshToken хранит использованный refresh-токен вместе с отозванными сессиями;
+// вставка с ON CONFLICT не даёт двум параллельным обновлениям использовать один токен.
+func (s *DBStore) ConsumeRefreshToken(ctx context.Context, token models.RevokedSession) (bool, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	query := `
+		INSERT INTO revoked_sessions (id, user_id, revoked_at, expires_at)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (id) DO NOTHING
+	`
+	tag, err := s.pool.Exec(ctx, query, token.ID, token.UserID, token.RevokedAt, token.ExpiresAt)
+	if err != nil {
+		return false, fmt.Errorf("failed to consume refresh token: %w", err)
+	}
+
+	return tag.RowsAffected() == 1, nil
+}
+
+func (s *DBStore) CleanupRevokedSessions(ctx context.Context, expiredBefore time.Time) error {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	_, err := s.pool.Exec(ctx, "DELETE FROM revoked_sessions WHERE expires_at < $1", expiredBefore)
+	if err != nil {
+		return fmt.Errorf("failed to cleanup revoked sessions: %w", err)
+	}
+
+	return nil
+}
+
+func (s *DBStore) CreateWorkspace(ctx context.Context,
+	workspace models.Workspace, owner models.WorkspaceMember) error {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	tx, err := s.pool.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+
+	defer func() {
+		if err := tx.Rollback(ctx); err != nil && !errors.Is(err, pgx.ErrTxClosed) {
+			logger.FromContext(ctx, s.logger).Error("Failed to rollback transaction", zap.Error(err))
+		}
+	}()
+
+	_, err = tx.Exec(ctx, "INSERT INTO workspaces (id, name, created_at) VALUES ($1, $2, $3)",
+		workspace.ID, workspace.Name, workspace.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to create workspace: %w", err)
+	}
+
+	_, err = tx.Exec(ctx,
+		"INSERT INTO workspace_members (workspace_id, user_id, role, added_at) VALUES ($1, $2, $3, $4)",
+		owner.WorkspaceID, owner.UserID, owner.Role, owner.AddedAt)
+	if err != nil {
+		return fmt.Errorf("failed to add workspace owner: %w", err)
+	}
+
+	if err = tx.Commit(ctx); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return nil
+}
+
+func (s *DBStore) GetUserWorkspaces(ctx context.Context, userID string) ([]models.HandleWorkspaceResponse, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	query := `
+		SELECT w.id::text, w.name, m.role, w.created_at
+		FROM workspaces w
+		JOIN workspace_members m ON m.workspace_id = w.id
+		WHERE m.user_id = $1
+		ORDER BY w.created_at
+	`
+	rows, err := s.pool.Query(ctx, query, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get workspaces: %w", err)
+	}
+
+	defer rows.Close()
+
+	workspaces := make([]models.HandleWorkspaceResponse, 0)
+	for rows.Next() {
+		var workspace models.HandleWorkspaceResponse
+		if err = rows.Scan(&workspace.ID, &workspace.Name, &workspace.Role, &workspace.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan row: %w", err)
+		}
+
+		workspaces = append(workspaces, workspace)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error reading rows: %w", err)
+	}
+
+	return workspaces, nil
+}
+
+const memberColumns = "workspace_id::text, user_id::text, role, added_at"
+
+func scanMember(row pgx.Row) (models.WorkspaceMember, error) {
+	var member models.WorkspaceMember
+	err := row.Scan(&member.WorkspaceID, &member.UserID, &member.Role, &member.AddedAt)
+
+	return member, err //nolint:wrapcheck // callers wrap the error with their own context
+}
+
+func (s *DBStore) GetWorkspaceMembers(ctx context.Context, workspaceID string) ([]models.WorkspaceMember, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	rows, err := s.pool.Query(ctx,
+		"SELECT "+memberColumns+" FROM workspace_members WHERE workspace_id = $1 ORDER BY added_at", workspaceID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get workspace members: %w", err)
+	}
+
+	defer rows.Close()
+
+	members := make([]models.WorkspaceMember, 0)
+	for rows.Next() {
+		member, err := scanMember(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan row: %w", err)
+		}
+
+		members = append(members, member)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error reading rows: %w", err)
+	}
+
+	// У существующего пространства всегда есть хотя бы владелец.
+	if len(members) == 0 {
+		return nil, fmt.Errorf("%w", ErrWorkspaceNotFound)
+	}
+
+	return members, nil
+}
+
+func (s *DBStore) SaveWorkspaceMember(ctx context.Context, member models.WorkspaceMember) error {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	// При смене роли участник сохраняет исходную дату добавления.
+	query := `
+		INSERT INTO workspace_members (workspace_id, user_id, role, added_at)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (workspace_id, user_id) DO UPDATE SET role = EXCLUDED.role
+	`
+	_, err := s.pool.Exec(ctx, query, member.WorkspaceID, member.UserID, member.Role, member.AddedAt)
+	if err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == foreignKeyViolationCode {
+			return fmt.Errorf("%w", ErrWorkspaceNotFound)
+		}
+
+		return fmt.Errorf("failed to save workspace member: %w", err)
+	}
+
+	return nil
+}
+
+func (s *DBStore) RemoveWorkspaceMember(ctx context.Context, workspaceID string, userID string) error {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	tag, err := s.pool.Exec(ctx,
+		"DELETE FROM workspace_members WHERE workspace_id = $1 AND user_id = $2", workspaceID, userID)
+	if err != nil {
+		return fmt.Errorf("failed to remove workspace member: %w", err)
+	}
+
+	if tag.RowsAffected() == 0 {
+		return fmt.Errorf("%w", ErrMemberNotFound)
+	}
+
+	return nil
+}
+
+func (s *DBStore) TransferURLs(ctx context.Context,
+	domain string, urls []string, fromOwnerID string, toOwnerID string) (int64, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	return s.changeOwner(ctx, `
+		UPDATE short_links
+		SET user_id = $3
+		WHERE user_id = $1
+		AND short_url = ANY($2)
+		AND domain = $4
+		AND deleted = FALSE
+		RETURNING id
+	`, fromOwnerID, urls, toOwnerID, domain)
+}
+
+// changeOwner выполняет запрос смены владельца ссылок и пишет по ним события link.updated.
+func (s *DBStore) changeOwner(ctx context.Context, query string, args ...any) (int64, error) {
+	var changed int64
+	err := s.inTx(ctx, func(tx pgx.Tx) error {
+		rows, err := tx.Query(ctx, query, args...)
+		if err != nil {
+			return fmt.Errorf("failed to change URLs owner: %w", err)
+		}
+
+		ids, err := pgx.CollectRows(rows, pgx.RowTo[int])
+		if err != nil {
+			return fmt.Errorf("failed to change URLs owner: %w", err)
+		}
+
+		changed = int64(len(ids))
+
+		return recordEvents(ctx, tx, models.EventLinkUpdated, ids)
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	return changed, nil
+}
+
+func (s *DBStore) CreateWebhook(ctx context.Context, webhook models.Webhook) error {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	query := `
+		INSERT INTO webhooks (id, user_id, url, events, secret, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6)
+	`
+	_, err := s.pool.Exec(ctx, query,
+		webhook.ID, webhook.UserID, webhook.URL, tagsOrEmpty(webhook.Events), webhook.Secret, webhook.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to create webhook: %w", err)
+	}
+
+	return nil
+}
+
+func (s *DBStore) GetWebhooks(ctx context.Context, userID string) ([]models.Webhook, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	rows, err := s.pool.Query(ctx, `
+		SELECT id::text, user_id::text, url, events, secret, created_at
+		FROM webhooks
+		WHERE user_id = $1
+		ORDER BY created_at
+	`, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get webhooks: %w", err)
+	}
+
+	defer rows.Close()
+
+	webhooks := make([]models.Webhook, 0)
+	for rows.Next() {
+		var webhook models.Webhook
+		err = rows.Scan(&webhook.ID, &webhook.UserID, &webhook.URL, &webhook.Events, &webhook.Secret, &webhook.CreatedAt)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan row: %w", err)
+		}
+
+		webhooks = append(webhooks, webhook)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error reading rows: %w", err)
+	}
+
+	return webhooks, nil
+}
+
+// DeleteWebhook удаляет вебхук; его очередь удаляется каскадом.
+func (s *DBStore) DeleteWebhook(ctx context.Context, webhookID string, userID string) error {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	tag, err := s.pool.Exec(ctx, "DELETE FROM webhooks WHERE id = $1 AND user_id = $2", webhookID, userID)
+	if err != nil {
+		return fmt.Errorf("failed to delete webhook: %w", err)
+	}
+
+	if tag.RowsAffected() == 0 {
+		return fmt.Errorf("%w", ErrWebhookNotFound)
+	}
+
+	return nil
+}
+
+func (s *DBStore) EnqueueDeliveries(ctx context.Context, deliveries []models.WebhookDelivery) error {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	query := `
+		INSERT INTO webhook_deliveries (id, webhook_id, event, payload, next_attempt_at, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6)
+	`
+	batch := &pgx.Batch{}
+	for _, delivery := range deliveries {
+		batch.Queue(query, delivery.ID, delivery.WebhookID, delivery.Event, delivery.Payload,
+			delivery.NextAttemptAt, delivery.CreatedAt)
+	}
+
+	if err := s.pool.SendBatch(ctx, batch).Close(); err != nil {
+		return fmt.Errorf("failed to enqueue webhook deliveries: %w", err)
+	}
+
+	return nil
+}
+
+const deliveryColumns = `d.id::text, d.webhook_id::text, d.event, d.payload, d.attempts, d.next_attempt_at,
+	d.last_error, d.dead, d.created_at`
+
+func scanDelivery(row pgx.Row, extra ...any) (models.WebhookDelivery, error) {
+	var delivery models.WebhookDelivery
+	dest := append([]any{&delivery.ID, &delivery.WebhookID, &delivery.Event, &delivery.Payload, &delivery.Attempts,
+		&delivery.NextAttemptAt, &delivery.LastError, &delivery.Dead, &delivery.CreatedAt}, extra...)
+	err := row.Scan(dest...)
+
+	return delivery, err //nolint:wrapcheck // callers wrap the error with their own context
+}
+
+// ClaimDeliveries выбирает готовые к отправке доставки и откладывает их на lease.
+// SKIP LOCKED позволяет нескольким экземплярам сервиса разбирать очередь, не мешая друг другу.
+func (s *DBStore) ClaimDeliveries(ctx context.Context,
+	now time.Time, lease time.Duration, limit int) ([]models.WebhookDelivery, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	rows, err := s.pool.Query(ctx, `
+		UPDATE webhook_deliveries d
+		SET next_attempt_at = $2
+		FROM webhooks w
+		WHERE w.id = d.webhook_id
+		AND d.id IN (
+			SELECT id FROM webhook_deliveries
+			WHERE dead = FALSE AND next_attempt_at <= $1
+			ORDER BY next_attempt_at
+			LIMIT $3
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING `+deliveryColumns+`, w.url, w.secret
+	`, now, now.Add(lease), limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to claim webhook deliveries: %w", err)
+	}
+
+	defer rows.Close()
+
+	deliveries := make([]models.WebhookDelivery, 0)
+	for rows.Next() {
+		var url, secret string
+		delivery, err := scanDelivery(rows, &url, &secret)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan row: %w", err)
+		}
+
+		delivery.URL, delivery.Secret = url, secret
+		deliveries = append(deliveries, delivery)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error reading rows: %w", err)
+	}
+
+	return deliveries, nil
+}
+
+func (s *DBStore) UpdateDelivery(ctx context.Context, delivery models.WebhookDelivery) error {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	tag, err := s.pool.Exec(ctx, `
+		UPDATE webhook_deliveries
+		SET attempts = $2, next_attempt_at = $3, last_error = $4, dead = $5
+		WHERE id = $1
+	`, delivery.ID, delivery.Attempts, delivery.NextAttemptAt, delivery.LastError, delivery.Dead)
+	if err != nil {
+		return fmt.Errorf("failed to update webhook delivery: %w", err)
+	}
+
+	if tag.RowsAffected() == 0 {
+		return fmt.Errorf("%w", ErrDeliveryNotFound)
+	}
+
+	return nil
+}
+
+func (s *DBStore) DeleteDelivery(ctx context.Context, deliveryID string) error {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	if _, err := s.pool.Exec(ctx, "DELETE FROM webhook_deliveries WHERE id = $1", deliveryID); err != nil {
+		return fmt.Errorf("failed to delete webhook delivery: %w", err)
+	}
+
+	return nil
+}
+
+func (s *DBStore) GetDeadDeliveries(ctx context.Context, userID string) ([]models.WebhookDelivery, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	rows, err := s.pool.Query(ctx, `
+		SELECT `+deliveryColumns+`
+		FROM webhook_deliveries d
+		JOIN webhooks w ON w.id = d.webhook_id
+		WHERE w.user_id = $1 AND d.dead = TRUE
+		ORDER BY d.created_at
+	`, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get dead webhook deliveries: %w", err)
+	}
+
+	defer rows.Close()
+
+	deliveries := make([]models.WebhookDelivery, 0)
+	for rows.Next() {
+		delivery, err := scanDelivery(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan row: %w", err)
+		}
+
+		deliveries = append(deliveries, delivery)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error reading rows: %w", err)
+	}
+
+	return deliveries, nil
+}
+
+func (s *DBStore) RequeueDelivery(ctx context.Context, deliveryID string, userID string, now time.Time) error {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	tag, err := s.pool.Exec(ctx, `
+		UPDATE webhook_deliveries d
+		SET dead = FALSE, attempts = 0, next_attempt_at = $3
+		FROM webhooks w
+		WHERE w.id = d.webhook_id AND d.id = $1 AND w.user_id = $2 AND d.dead = TRUE
+	`, deliveryID, userID, now)
+	if err != nil {
+		return fmt.Errorf("failed to requeue webhook delivery: %w", err)
+	}
+
+	if tag.RowsAffected() == 0 {
+		return fmt.Errorf("%w", ErrDeliveryNotFound)
+	}
+
+	return nil
+}
+
+func (s *DBStore) Ping(ctx context.Context) error {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	err := s.pool.Ping(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to ping DB: %w", err)
+	}
+
+	return nil
+}
+
+func (s *DBStore) CheckMigrations(ctx context.Context) error {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	expected, err := LatestMigrationVersion()
+	if err != nil {
+		return err
+	}
+
+	var (
+		version int64
+		dirty   bool
+	)
+	err = s.pool.QueryRow(ctx, "SELECT version, dirty FROM schema_migrations LIMIT 1").Scan(&version, &dirty)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return fmt.Errorf("%w: no migrations applied, expected version %d", ErrSchemaMismatch, expected)
+		}
+
+		return fmt.Errorf("failed to get schema version: %w", err)
+	}
+
+	if dirty {
+		return fmt.Errorf("%w: version %d is dirty", ErrSchemaMismatch, version)
+	}
+
+	if version != int64(expected) {
+		return fmt.Errorf("%w: version %d, expected %d", ErrSchemaMismatch, version, expected)
+	}
+
+	return nil
 }
 
 func (s *DBStore) Close() {
 	s.pool.Close()
 }
+
+// tagsOrEmpty заменяет nil на пустой срез, иначе pgx передаст NULL в NOT NULL колонку.
+func tagsOrEmpty(tags []string) []string {
+	if tags == nil {
+		return []string{}
+	}
+
+	return tags
+}
+
+// paramsOrEmpty заменяет nil на пустой объект по той же причине, что и tagsOrEmpty.
+func paramsOrEmpty(params map[string]string) map[string]string {
+	if params == nil {
+		return map[string]string{}
+	}
+
+	return params
+}
+
+// rulesOrEmpty заменяет nil на пустой массив по той же причине, что и tagsOrEmpty.
+func rulesOrEmpty(rules []models.RoutingRule) []models.RoutingRule {
+	if rules == nil {
+		return []models.RoutingRule{}
+	}
+
+	return rules
+}
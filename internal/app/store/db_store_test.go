@@ -0,0 +1,267 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/golang-migrate/migrate/v4"
+	"github.com/jackc/pgx/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestRetryUnavailable(t *testing.T) {
+	unavailable := func(calls *int, failures int) func() error {
+		return func() error {
+			*calls++
+			if *calls <= failures {
+				return errDBUnavailable
+			}
+
+			return nil
+		}
+	}
+
+	t.Run("Retries until DB is available", func(t *testing.T) {
+		calls := 0
+		err := retryUnavailable(context.Background(), time.Minute, zap.NewNop(), unavailable(&calls, 1))
+		require.NoError(t, err)
+		assert.Equal(t, 2, calls)
+	})
+
+	t.Run("Other errors are not retried", func(t *testing.T) {
+		calls := 0
+		migrationErr := errors.New("bad migration")
+		err := retryUnavailable(context.Background(), time.Minute, zap.NewNop(), func() error {
+			calls++
+			return migrationErr
+		})
+		require.ErrorIs(t, err, migrationErr)
+		assert.Equal(t, 1, calls)
+	})
+
+	t.Run("Gives up after timeout", func(t *testing.T) {
+		calls := 0
+		start := time.Now()
+		err := retryUnavailable(context.Background(), 50*time.Millisecond, zap.NewNop(), unavailable(&calls, 100))
+		require.ErrorIs(t, err, errDBUnavailable)
+		assert.Equal(t, 2, calls, "the wait is cut to the remaining timeout")
+		assert.Less(t, time.Since(start), connectRetryBaseDelay)
+	})
+
+	t.Run("Stops when context is canceled", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		calls := 0
+		err := retryUnavailable(ctx, time.Minute, zap.NewNop(), unavailable(&calls, 100))
+		require.ErrorIs(t, err, errDBUnavailable)
+		require.ErrorIs(t, err, context.Canceled)
+		assert.Equal(t, 1, calls)
+	})
+}
+
+func TestInitPoolOptions(t *testing.T) {
+	// Пул подключается лениво, поэтому настройки проверяются без запущенной БД.
+	const dsn = "postgres://shortener@127.0.0.1:1/shortener"
+
+	t.Run("Options override pool defaults", func(t *testing.T) {
+		pool, err := initPool(context.Background(), dsn, DBOptions{
+			MaxConns:         7,
+			MaxConnLifetime:  time.Hour,
+			MaxConnIdleTime:  time.Minute,
+			StatementTimeout: 1500 * time.Millisecond,
+		}, zap.NewNop())
+		require.NoError(t, err)
+		defer pool.Close()
+
+		cfg := pool.Config()
+		assert.Equal(t, int32(7), cfg.MaxConns)
+		assert.Equal(t, time.Hour, cfg.MaxConnLifetime)
+		assert.Equal(t, time.Minute, cfg.MaxConnIdleTime)
+		assert.Equal(t, "1500", cfg.ConnConfig.RuntimeParams["statement_timeout"])
+		assert.IsType(t, &queryTracer{}, cfg.ConnConfig.Tracer)
+	})
+
+	t.Run("Zero options keep DSN settings", func(t *testing.T) {
+		pool, err := initPool(context.Background(), dsn+"?pool_max_conns=3", DBOptions{}, zap.NewNop())
+		require.NoError(t, err)
+		defer pool.Close()
+
+		cfg := pool.Config()
+		assert.Equal(t, int32(3), cfg.MaxConns)
+		assert.NotContains(t, cfg.ConnConfig.RuntimeParams, "statement_timeout")
+	})
+
+	t.Run("Invalid DSN", func(t *testing.T) {
+		_, err := initPool(context.Background(), "postgres://%zz", DBOptions{}, zap.NewNop())
+		assert.ErrorContains(t, err, "failed to parse DSN")
+	})
+}
+
+// testDSN возвращает DSN тестовой БД из TEST_DATABASE_DSN; без него тесты с БД пропускаются.
+// Схема public этой БД пересоздаётся, поэтому указывать рабочую базу нельзя.
+func testDSN(t *testing.T) string {
+	t.Helper()
+
+	dsn := os.Getenv("TEST_DATABASE_DSN")
+	if dsn == "" {
+		t.Skip("TEST_DATABASE_DSN is not set")
+	}
+
+	return dsn
+}
+
+// resetSchema удаляет все таблицы тестовой БД, чтобы миграции накатывались с нуля.
+func resetSchema(t *testing.T, dsn string) {
+	t.Helper()
+
+	conn, err := pgx.Connect(context.Background(), dsn)
+	require.NoError(t, err)
+	defer conn.Close(context.Background())
+
+	_, err = conn.Exec(context.Background(), "DROP SCHEMA public CASCADE; CREATE SCHEMA public")
+	require.NoError(t, err)
+}
+
+func newTestDBStore(t *testing.T, dsn string) *DBStore {
+	t.Helper()
+
+	pool, err := initPool(context.Background(), dsn, DBOptions{}, zap.NewNop())
+	require.NoError(t, err)
+	t.Cleanup(pool.Close)
+
+	return &DBStore{pool: pool, logger: zap.NewNop()}
+}
+
+func TestWithMigrateUnavailableDB(t *testing.T) {
+	const dsn = "postgres://shortener@127.0.0.1:1/shortener"
+
+	calls := 0
+	err := withMigrate(context.Background(), dsn, func(_ *migrate.Migrate) error {
+		calls++
+		return nil
+	})
+	require.ErrorIs(t, err, errDBUnavailable)
+	assert.Zero(t, calls)
+}
+
+func TestMigrations(t *testing.T) {
+	ctx := context.Background()
+	dsn := testDSN(t)
+	resetSchema(t, dsn)
+
+	latest, err := LatestMigrationVersion()
+	require.NoError(t, err)
+
+	version, dirty, err := MigrationVersion(ctx, dsn)
+	require.NoError(t, err)
+	assert.Zero(t, version, "nothing is applied yet")
+	assert.False(t, dirty)
+
+	require.NoError(t, MigrateUp(ctx, dsn))
+	require.NoError(t, MigrateUp(ctx, dsn), "no change is not an error")
+
+	version, dirty, err = MigrationVersion(ctx, dsn)
+	require.NoError(t, err)
+	assert.Equal(t, latest, version)
+	assert.False(t, dirty)
+
+	s := newTestDBStore(t, dsn)
+	require.NoError(t, s.CheckMigrations(ctx))
+
+	t.Run("Version mismatch", func(t *testing.T) {
+		require.NoError(t, MigrateDown(ctx, dsn, 1))
+
+		version, _, err := MigrationVersion(ctx, dsn)
+		require.NoError(t, err)
+		assert.Equal(t, latest-1, version)
+
+		err = s.CheckMigrations(ctx)
+		require.ErrorIs(t, err, ErrSchemaMismatch)
+		assert.ErrorContains(t, err, "expected")
+
+		require.NoError(t, MigrateTo(ctx, dsn, latest))
+		require.NoError(t, s.CheckMigrations(ctx))
+	})
+
+	t.Run("Dirty version", func(t *testing.T) {
+		_, err := s.pool.Exec(ctx, "UPDATE schema_migrations SET dirty = TRUE")
+		require.NoError(t, err)
+
+		err = s.CheckMigrations(ctx)
+		require.ErrorIs(t, err, ErrSchemaMismatch)
+		assert.ErrorContains(t, err, "dirty")
+
+		_, dirty, err := MigrationVersion(ctx, dsn)
+		require.NoError(t, err)
+		assert.True(t, dirty)
+
+		err = MigrateUp(ctx, dsn)
+		var dirtyErr migrate.ErrDirty
+		require.ErrorAs(t, err, &dirtyErr, "a dirty schema is not migrated until forced")
+
+		require.NoError(t, MigrateForce(ctx, dsn, int(latest)))
+		require.NoError(t, s.CheckMigrations(ctx))
+	})
+
+	t.Run("Migration error is wrapped", func(t *testing.T) {
+		fnErr := errors.New("boom")
+		err := withMigrate(ctx, dsn, func(_ *migrate.Migrate) error { return fnErr })
+		require.ErrorIs(t, err, fnErr)
+		assert.ErrorContains(t, err, "failed to apply migrations")
+	})
+}
+
+func TestMigrationsWaitForLock(t *testing.T) {
+	ctx := context.Background()
+	dsn := testDSN(t)
+	resetSchema(t, dsn)
+
+	// Блокировку держит «другой экземпляр», пока сам накатывает миграции.
+	conn, err := pgx.Connect(ctx, dsn)
+	require.NoError(t, err)
+	defer conn.Close(ctx)
+
+	_, err = conn.Exec(ctx, "SELECT pg_advisory_lock($1)", migrationLockKey)
+	require.NoError(t, err)
+
+	done := make(chan error, 1)
+	go func() {
+		done <- MigrateUp(ctx, dsn)
+	}()
+
+	select {
+	case err := <-done:
+		t.Fatalf("migrations ran while the lock was held: %v", err)
+	case <-time.After(200 * time.Millisecond):
+	}
+
+	_, err = conn.Exec(ctx, "SELECT pg_advisory_unlock($1)", migrationLockKey)
+	require.NoError(t, err)
+
+	select {
+	case err := <-done:
+		require.NoError(t, err)
+	case <-time.After(time.Minute):
+		t.Fatal("migrations did not run after the lock was released")
+	}
+
+	t.Run("Canceled while waiting", func(t *testing.T) {
+		_, err := conn.Exec(ctx, "SELECT pg_advisory_lock($1)", migrationLockKey)
+		require.NoError(t, err)
+		defer func() {
+			_, _ = conn.Exec(ctx, "SELECT pg_advisory_unlock($1)", migrationLockKey)
+		}()
+
+		waitCtx, cancel := context.WithTimeout(ctx, 100*time.Millisecond)
+		defer cancel()
+
+		err = MigrateUp(waitCtx, dsn)
+		assert.ErrorContains(t, err, "failed to acquire migration lock")
+	})
+}
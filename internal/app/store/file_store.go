@@ -4,25 +4,78 @@ import (
 	"bufio"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"os"
+	"sync"
+	"time"
 
 	"github.com/a-bondar/go-url-shortener/internal/app/models"
 	"github.com/google/uuid"
 )
 
+const fileModeOwnerReadWrite = 0o600
+
+// Типы записей журнала. Ссылки пишутся без поля type,
+// поэтому файлы, созданные до появления других сущностей, читаются как есть.
+const (
+	recordTypeLink           = ""
+	recordTypeUser           = "user"
+	recordTypeAPIKey         = "api_key"
+	recordTypeRevokedSession = "revoked_session"
+	recordTypeWorkspace      = "workspace"
+	recordTypeMember         = "workspace_member"
+	recordTypeTargetClick    = "target_click"
+	recordTypeClick          = "click"
+	recordTypeWebhook        = "webhook"
+	recordTypeDelivery       = "webhook_delivery"
+	recordTypeDeliveryDone   = "webhook_delivery_done"
+)
+
+type fileRecord struct {
+	Type           string                  `json:"type,omitempty"`
+	User           *models.User            `json:"user,omitempty"`
+	APIKey         *models.APIKey          `json:"api_key,omitempty"`
+	RevokedSession *models.RevokedSession  `json:"revoked_session,omitempty"`
+	Workspace      *models.Workspace       `json:"workspace,omitempty"`
+	Member         *models.WorkspaceMember `json:"workspace_member,omitempty"`
+	TargetClick    *targetClick            `json:"target_click,omitempty"`
+	Click          *linkClick              `json:"click,omitempty"`
+	Webhook        *models.Webhook         `json:"webhook,omitempty"`
+	Delivery       *models.WebhookDelivery `json:"webhook_delivery,omitempty"`
+	// DeliveryID — доставленное событие, которое больше не нужно восстанавливать.
+	DeliveryID string `json:"delivery_id,omitempty"`
+}
+
+// targetClick — переход на один из адресов A/B-ссылки. Переходы частые,
+// поэтому в журнал пишется только приращение, а не вся ссылка.
+type targetClick struct {
+	Domain   string `json:"domain,omitempty"`
+	ShortURL string `json:"short_url"`
+	Target   int    `json:"target"`
+}
+
+// linkClick — списанный переход у ссылки с ограничением переходов.
+type linkClick struct {
+	Domain   string `json:"domain,omitempty"`
+	ShortURL string `json:"short_url"`
+}
+
 type fileStore struct {
 	inMemoryStore *inMemoryStore
 	fName         string
+	// mu удерживается от изменения в памяти до записи в журнал: изменения попадают в файл
+	// в том же порядке, а снимок при перезаписи файла не смешивается с чужими изменениями.
+	mu sync.Mutex
 }
 
-func newFileStore(ctx context.Context, fName string) (*fileStore, error) {
+func newFileStore(fName string) (*fileStore, error) {
 	store := &fileStore{
 		inMemoryStore: newInMemoryStore(),
 		fName:         fName,
 	}
 
-	err := store.loadFromFile(ctx)
+	err := store.loadFromFile()
 	if err != nil {
 		return nil, err
 	}
@@ -30,13 +83,17 @@ func newFileStore(ctx context.Context, fName string) (*fileStore, error) {
 	return store, nil
 }
 
-func (s *fileStore) SaveURL(ctx context.Context, fullURL string, shortURL string, userID string) (string, error) {
-	savedShortURL, err := s.inMemoryStore.SaveURL(ctx, fullURL, shortURL, userID)
+func (s *fileStore) SaveURL(ctx context.Context,
+	fullURL string, shortURL string, userID string, meta models.LinkMeta, opts models.LinkOptions) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	savedShortURL, err := s.inMemoryStore.SaveURL(ctx, fullURL, shortURL, userID, meta, opts)
 	if err != nil {
 		return "", err
 	}
 
-	err = s.writeToFile(fullURL, savedShortURL, userID, false)
+	err = s.writeRecord(linkKey{domain: opts.Domain, shortURL: savedShortURL}, userID)
 	if err != nil {
 		return "", err
 	}
@@ -46,15 +103,18 @@ func (s *fileStore) SaveURL(ctx context.Context, fullURL string, shortURL string
 
 func (s *fileStore) SaveURLsBatch(
 	ctx context.Context, urls map[string]string, userID string) (map[string]string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
 	res := make(map[string]string)
 
 	for fullURL, shortURL := range urls {
-		savedShortURL, err := s.inMemoryStore.SaveURL(ctx, fullURL, shortURL, userID)
+		savedShortURL, err := s.inMemoryStore.SaveURL(ctx, fullURL, shortURL, userID, models.LinkMeta{}, models.LinkOptions{})
 		if err != nil {
 			return nil, err
 		}
 
-		err = s.writeToFile(fullURL, savedShortURL, userID, false)
+		err = s.writeRecord(linkKey{shortURL: savedShortURL}, userID)
 		if err != nil {
 			return nil, err
 		}
@@ -65,39 +125,168 @@ func (s *fileStore) SaveURLsBatch(
 	return res, nil
 }
 
-func (s *fileStore) GetURL(ctx context.Context, shortURL string) (string, bool, error) {
-	return s.inMemoryStore.GetURL(ctx, shortURL)
+func (s *fileStore) GetURL(ctx context.Context, domain string, shortURL string) (models.Data, error) {
+	return s.inMemoryStore.GetURL(ctx, domain, shortURL)
 }
 
-func (s *fileStore) GetURLs(ctx context.Context, userID string) (map[string]string, error) {
-	return s.inMemoryStore.GetURLs(ctx, userID)
+func (s *fileStore) GetURLs(ctx context.Context, userID string, filter models.URLsFilter) ([]models.Data, error) {
+	return s.inMemoryStore.GetURLs(ctx, userID, filter)
 }
 
-func (s *fileStore) DeleteURLs(ctx context.Context, urls []string, userID string) error {
-	return s.inMemoryStore.DeleteURLs(ctx, urls, userID)
+func (s *fileStore) GetUserURL(ctx context.Context,
+	domain string, shortURL string, userID string) (models.Data, error) {
+	return s.inMemoryStore.GetUserURL(ctx, domain, shortURL, userID)
 }
 
-func (s *fileStore) CleanupDeletedURLs(ctx context.Context) error {
-	return s.inMemoryStore.CleanupDeletedURLs(ctx)
+func (s *fileStore) IncrementTargetClicks(ctx context.Context, domain string, shortURL string, target int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.inMemoryStore.IncrementTargetClicks(ctx, domain, shortURL, target); err != nil {
+		return err
+	}
+
+	return s.writeEntity(fileRecord{
+		Type:        recordTypeTargetClick,
+		TargetClick: &targetClick{Domain: domain, ShortURL: shortURL, Target: target},
+	})
 }
 
-func (s *fileStore) writeToFile(fullURL string, shortURL string, userID string, deleted bool) error {
-	data := models.Data{
-		UUID:        uuid.NewString(),
-		ShortURL:    shortURL,
-		OriginalURL: fullURL,
-		UserID:      userID,
-		Deleted:     deleted,
+func (s *fileStore) ConsumeClick(ctx context.Context, domain string, shortURL string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.inMemoryStore.ConsumeClick(ctx, domain, shortURL); err != nil {
+		return err
 	}
 
-	dataToJSON, err := json.Marshal(data)
+	return s.writeEntity(fileRecord{
+		Type:  recordTypeClick,
+		Click: &linkClick{Domain: domain, ShortURL: shortURL},
+	})
+}
+
+func (s *fileStore) UpdateURLMeta(ctx context.Context,
+	domain string, shortURL string, userID string, patch models.LinkMetaPatch) (models.Data, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	data, err := s.inMemoryStore.UpdateURLMeta(ctx, domain, shortURL, userID, patch)
 	if err != nil {
-		return fmt.Errorf("failed to marshal data: %w", err)
+		return models.Data{}, err
 	}
 
-	dataToJSON = append(dataToJSON, '\n')
+	if err = s.writeToFile(data); err != nil {
+		return models.Data{}, err
+	}
+
+	return data, nil
+}
+
+func (s *fileStore) UpdateURL(ctx context.Context,
+	domain string, shortURL string, userID string, fullURL string) (models.Data, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	data, err := s.inMemoryStore.UpdateURL(ctx, domain, shortURL, userID, fullURL)
+	if err != nil {
+		return models.Data{}, err
+	}
+
+	if err = s.writeToFile(data); err != nil {
+		return models.Data{}, err
+	}
+
+	return data, nil
+}
+
+func (s *fileStore) GetURLRevisions(ctx context.Context,
+	domain string, shortURL string, userID string) ([]models.URLRevision, error) {
+	return s.inMemoryStore.GetURLRevisions(ctx, domain, shortURL, userID)
+}
+
+func (s *fileStore) DeleteURLs(ctx context.Context, domain string, urls []string, userID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.inMemoryStore.DeleteURLs(ctx, domain, urls, userID); err != nil {
+		return err
+	}
+
+	return s.writeRecords(domain, urls, userID)
+}
+
+func (s *fileStore) RestoreURLs(ctx context.Context, domain string, urls []string, userID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.inMemoryStore.RestoreURLs(ctx, domain, urls, userID); err != nil {
+		return err
+	}
+
+	return s.writeRecords(domain, urls, userID)
+}
 
-	const fileModeOwnerReadWrite = 0o600
+func (s *fileStore) CleanupDeletedURLs(ctx context.Context, deletedBefore time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.inMemoryStore.CleanupDeletedURLs(ctx, deletedBefore); err != nil {
+		return err
+	}
+
+	// Удалённые записи нельзя вычеркнуть из журнала, поэтому файл перезаписывается целиком.
+	return s.rewriteFile()
+}
+
+// writeRecords сохраняет состояние тех ссылок пользователя, которые есть в хранилище.
+func (s *fileStore) writeRecords(domain string, urls []string, userID string) error {
+	s.inMemoryStore.mu.RLock()
+	links := s.inMemoryStore.find(domain, urls, userID)
+	s.inMemoryStore.mu.RUnlock()
+
+	for _, data := range links {
+		if err := s.writeToFile(data); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// writeRecord дописывает в файл актуальное состояние короткой ссылки.
+// При загрузке последняя запись для ссылки перекрывает предыдущие.
+func (s *fileStore) writeRecord(key linkKey, userID string) error {
+	s.inMemoryStore.mu.RLock()
+	data, ok := s.inMemoryStore.get(key, userID)
+	s.inMemoryStore.mu.RUnlock()
+
+	if !ok {
+		return fmt.Errorf("%w", ErrURLNotFound)
+	}
+
+	return s.writeToFile(data)
+}
+
+func (s *fileStore) writeToFile(data models.Data) error {
+	line, err := encodeRecord(data)
+	if err != nil {
+		return err
+	}
+
+	return s.appendLines(line)
+}
+
+func (s *fileStore) writeEntity(rec fileRecord) error {
+	line, err := encodeEntity(rec)
+	if err != nil {
+		return err
+	}
+
+	return s.appendLines(line)
+}
+
+func (s *fileStore) appendLines(lines ...[]byte) error {
 	file, err := os.OpenFile(s.fName, os.O_APPEND|os.O_CREATE|os.O_WRONLY, fileModeOwnerReadWrite)
 	if err != nil {
 		return fmt.Errorf("failed to open file: %w", err)
@@ -110,14 +299,172 @@ func (s *fileStore) writeToFile(fullURL string, shortURL string, userID string,
 		}
 	}(file)
 
-	if _, err := file.Write(dataToJSON); err != nil {
-		return fmt.Errorf("failed to write data to file: %w", err)
+	for _, line := range lines {
+		if _, err := file.Write(line); err != nil {
+			return fmt.Errorf("failed to write data to file: %w", err)
+		}
 	}
 
 	return nil
 }
 
-func (s *fileStore) loadFromFile(ctx context.Context) error {
+// rewriteFile заменяет журнал снимком текущего состояния хранилища.
+func (s *fileStore) rewriteFile() error {
+	lines, err := s.snapshot()
+	if err != nil {
+		return err
+	}
+
+	tmpName := s.fName + ".tmp"
+	file, err := os.OpenFile(tmpName, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, fileModeOwnerReadWrite)
+	if err != nil {
+		return fmt.Errorf("failed to open file: %w", err)
+	}
+
+	w := bufio.NewWriter(file)
+	for _, line := range lines {
+		if _, err = w.Write(line); err != nil {
+			return errors.Join(fmt.Errorf("failed to write data to file: %w", err), file.Close())
+		}
+	}
+
+	if err = w.Flush(); err != nil {
+		return errors.Join(fmt.Errorf("failed to write data to file: %w", err), file.Close())
+	}
+
+	if err = file.Close(); err != nil {
+		return fmt.Errorf("failed to close file: %w", err)
+	}
+
+	if err = os.Rename(tmpName, s.fName); err != nil {
+		return fmt.Errorf("failed to replace file: %w", err)
+	}
+
+	return nil
+}
+
+// snapshot кодирует все сущности хранилища в строки журнала.
+func (s *fileStore) snapshot() ([][]byte, error) {
+	s.inMemoryStore.mu.RLock()
+	defer s.inMemoryStore.mu.RUnlock()
+
+	var lines [][]byte
+
+	for _, user := range s.inMemoryStore.users {
+		line, err := encodeEntity(fileRecord{Type: recordTypeUser, User: &user})
+		if err != nil {
+			return nil, err
+		}
+		lines = append(lines, line)
+	}
+
+	for _, key := range s.inMemoryStore.apiKeys {
+		line, err := encodeEntity(fileRecord{Type: recordTypeAPIKey, APIKey: key})
+		if err != nil {
+			return nil, err
+		}
+		lines = append(lines, line)
+	}
+
+	for _, session := range s.inMemoryStore.sessions {
+		line, err := encodeEntity(fileRecord{Type: recordTypeRevokedSession, RevokedSession: &session})
+		if err != nil {
+			return nil, err
+		}
+		lines = append(lines, line)
+	}
+
+	for _, workspace := range s.inMemoryStore.workspaces {
+		line, err := encodeEntity(fileRecord{Type: recordTypeWorkspace, Workspace: &workspace})
+		if err != nil {
+			return nil, err
+		}
+		lines = append(lines, line)
+	}
+
+	for _, members := range s.inMemoryStore.members {
+		for _, member := range members {
+			line, err := encodeEntity(fileRecord{Type: recordTypeMember, Member: &member})
+			if err != nil {
+				return nil, err
+			}
+			lines = append(lines, line)
+		}
+	}
+
+	webhooks, deliveries := s.inMemoryStore.webhookEntities()
+	for _, webhook := range webhooks {
+		line, err := encodeEntity(fileRecord{Type: recordTypeWebhook, Webhook: &webhook})
+		if err != nil {
+			return nil, err
+		}
+		lines = append(lines, line)
+	}
+
+	for _, delivery := range deliveries {
+		line, err := encodeEntity(fileRecord{Type: recordTypeDelivery, Delivery: &delivery})
+		if err != nil {
+			return nil, err
+		}
+		lines = append(lines, line)
+	}
+
+	for _, data := range s.inMemoryStore.all() {
+		line, err := encodeRecord(data)
+		if err != nil {
+			return nil, err
+		}
+		lines = append(lines, line)
+	}
+
+	return lines, nil
+}
+
+func encodeRecord(data models.Data) ([]byte, error) {
+	data.UUID = uuid.NewString()
+
+	dataToJSON, err := json.Marshal(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal data: %w", err)
+	}
+
+	return append(dataToJSON, '\n'), nil
+}
+
+func encodeEntity(rec fileRecord) ([]byte, error) {
+	recToJSON, err := json.Marshal(rec)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal %s: %w", rec.Type, err)
+	}
+
+	return append(recToJSON, '\n'), nil
+}
+
+// encodeStoreRecord кодирует перенесённую из другого хранилища запись в строку журнала.
+func encodeStoreRecord(rec models.Record) ([]byte, error) {
+	switch {
+	case rec.Link != nil:
+		return encodeRecord(*rec.Link)
+	case rec.User != nil:
+		return encodeEntity(fileRecord{Type: recordTypeUser, User: rec.User})
+	case rec.APIKey != nil:
+		return encodeEntity(fileRecord{Type: recordTypeAPIKey, APIKey: rec.APIKey})
+	case rec.Session != nil:
+		return encodeEntity(fileRecord{Type: recordTypeRevokedSession, RevokedSession: rec.Session})
+	case rec.Workspace != nil:
+		return encodeEntity(fileRecord{Type: recordTypeWorkspace, Workspace: rec.Workspace})
+	case rec.Member != nil:
+		return encodeEntity(fileRecord{Type: recordTypeMember, Member: rec.Member})
+	case rec.Webhook != nil:
+		return encodeEntity(fileRecord{Type: recordTypeWebhook, Webhook: rec.Webhook})
+	case rec.Delivery != nil:
+		return encodeEntity(fileRecord{Type: recordTypeDelivery, Delivery: rec.Delivery})
+	default:
+		return nil, fmt.Errorf("%w: record has no data", ErrUnknownRecordKind)
+	}
+}
+
+func (s *fileStore) loadFromFile() error {
 	file, err := os.Open(s.fName)
 	if err != nil {
 		if os.IsNotExist(err) {
@@ -136,25 +483,432 @@ func (s *fileStore) loadFromFile(ctx context.Context) error {
 
 	scanner := bufio.NewScanner(file)
 	for scanner.Scan() {
-		var data models.Data
+		if err := s.loadLine(scanner.Bytes()); err != nil {
+			return err
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("failed to scan file: %w", err)
+	}
+
+	return nil
+}
+
+func (s *fileStore) loadLine(line []byte) error {
+	var rec fileRecord
+	if err := json.Unmarshal(line, &rec); err != nil {
+		return fmt.Errorf("failed to unmarshal data: %w", err)
+	}
 
-		if err := json.Unmarshal(scanner.Bytes(), &data); err != nil {
+	switch rec.Type {
+	case recordTypeLink:
+		var data models.Data
+		if err := json.Unmarshal(line, &data); err != nil {
 			return fmt.Errorf("failed to unmarshal data: %w", err)
 		}
 
-		if _, err := s.inMemoryStore.SaveURL(ctx, data.OriginalURL, data.ShortURL, data.UserID); err != nil {
-			return fmt.Errorf("failed to save URL: %w", err)
+		s.inMemoryStore.put(data)
+	case recordTypeUser:
+		if rec.User != nil {
+			s.inMemoryStore.users[rec.User.ID] = *rec.User
+		}
+	case recordTypeAPIKey:
+		if rec.APIKey != nil {
+			s.inMemoryStore.apiKeys[rec.APIKey.ID] = rec.APIKey
+		}
+	case recordTypeRevokedSession:
+		if rec.RevokedSession != nil {
+			s.inMemoryStore.sessions[rec.RevokedSession.ID] = *rec.RevokedSession
+		}
+	case recordTypeWorkspace:
+		if rec.Workspace != nil {
+			s.inMemoryStore.workspaces[rec.Workspace.ID] = *rec.Workspace
+		}
+	case recordTypeMember:
+		if rec.Member != nil {
+			members, ok := s.inMemoryStore.members[rec.Member.WorkspaceID]
+			if !ok {
+				members = make(map[string]models.WorkspaceMember)
+				s.inMemoryStore.members[rec.Member.WorkspaceID] = members
+			}
+			members[rec.Member.UserID] = *rec.Member
+		}
+	case recordTypeTargetClick:
+		if rec.TargetClick != nil {
+			key := linkKey{domain: rec.TargetClick.Domain, shortURL: rec.TargetClick.ShortURL}
+			s.inMemoryStore.incrementTargetClicks(key, rec.TargetClick.Target)
+		}
+	case recordTypeClick:
+		if rec.Click != nil {
+			// Ошибка означает, что ссылку с тех пор удалили: списывать уже не у чего.
+			_ = s.inMemoryStore.consumeClick(linkKey{domain: rec.Click.Domain, shortURL: rec.Click.ShortURL})
+		}
+	case recordTypeWebhook:
+		if rec.Webhook != nil {
+			s.inMemoryStore.webhooks[rec.Webhook.ID] = *rec.Webhook
+		}
+	case recordTypeDelivery:
+		if rec.Delivery != nil {
+			s.inMemoryStore.deliveries[rec.Delivery.ID] = *rec.Delivery
+		}
+	case recordTypeDeliveryDone:
+		delete(s.inMemoryStore.deliveries, rec.DeliveryID)
+	default:
+		return fmt.Errorf("unknown record type %q", rec.Type)
+	}
+
+	return nil
+}
+
+func (s *fileStore) ClaimURLs(ctx context.Context, fromUserID string, toUserID string) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	claimed, err := s.inMemoryStore.ClaimURLs(ctx, fromUserID, toUserID)
+	if err != nil || claimed == 0 {
+		return claimed, err
+	}
+
+	// Записи старого владельца остались бы в журнале, поэтому файл перезаписывается целиком.
+	return claimed, s.rewriteFile()
+}
+
+func (s *fileStore) CreateUser(ctx context.Context, user models.User) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.inMemoryStore.CreateUser(ctx, user); err != nil {
+		return err
+	}
+
+	return s.writeEntity(fileRecord{Type: recordTypeUser, User: &user})
+}
+
+func (s *fileStore) GetUser(ctx context.Context, userID string) (models.User, error) {
+	return s.inMemoryStore.GetUser(ctx, userID)
+}
+
+func (s *fileStore) CreateAPIKey(ctx context.Context, key models.APIKey) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.inMemoryStore.CreateAPIKey(ctx, key); err != nil {
+		return err
+	}
+
+	return s.writeEntity(fileRecord{Type: recordTypeAPIKey, APIKey: &key})
+}
+
+func (s *fileStore) GetAPIKeyByHash(ctx context.Context, keyHash string) (models.APIKey, error) {
+	return s.inMemoryStore.GetAPIKeyByHash(ctx, keyHash)
+}
+
+func (s *fileStore) GetAPIKeys(ctx context.Context, userID string) ([]models.APIKey, error) {
+	return s.inMemoryStore.GetAPIKeys(ctx, userID)
+}
+
+func (s *fileStore) RevokeAPIKey(ctx context.Context, keyID string, userID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.inMemoryStore.RevokeAPIKey(ctx, keyID, userID); err != nil {
+		return err
+	}
+
+	s.inMemoryStore.mu.RLock()
+	key := *s.inMemoryStore.apiKeys[keyID]
+	s.inMemoryStore.mu.RUnlock()
+
+	return s.writeEntity(fileRecord{Type: recordTypeAPIKey, APIKey: &key})
+}
+
+func (s *fileStore) RevokeSession(ctx context.Context, session models.RevokedSession) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if revoked, _ := s.inMemoryStore.IsSessionRevoked(ctx, session.ID); revoked {
+		return nil
+	}
+
+	if err := s.inMemoryStore.RevokeSession(ctx, session); err != nil {
+		return err
+	}
+
+	return s.writeEntity(fileRecord{Type: recordTypeRevokedSession, RevokedSession: &session})
+}
+
+func (s *fileStore) IsSessionRevoked(ctx context.Context, sessionID string) (bool, error) {
+	return s.inMemoryStore.IsSessionRevoked(ctx, sessionID)
+}
+
+func (s *fileStore) ConsumeRefreshToken(ctx context.Context, token models.RevokedSession) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	fresh, err := s.inMemoryStore.ConsumeRefreshToken(ctx, token)
+	if err != nil || !fresh {
+		return fresh, err
+	}
+
+	return true, s.writeEntity(fileRecord{Type: recordTypeRevokedSession, RevokedSession: &token})
+}
+
+func (s *fileStore) CleanupRevokedSessions(ctx context.Context, expiredBefore time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	before := s.sessionsCount()
+	if err := s.inMemoryStore.CleanupRevokedSessions(ctx, expiredBefore); err != nil {
+		return err
+	}
+
+	if s.sessionsCount() == before {
+		return nil
+	}
+
+	return s.rewriteFile()
+}
+
+func (s *fileStore) sessionsCount() int {
+	s.inMemoryStore.mu.RLock()
+	defer s.inMemoryStore.mu.RUnlock()
+
+	return len(s.inMemoryStore.sessions)
+}
+
+func (s *fileStore) CreateWorkspace(ctx context.Context,
+	workspace models.Workspace, owner models.WorkspaceMember) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.inMemoryStore.CreateWorkspace(ctx, workspace, owner); err != nil {
+		return err
+	}
+
+	if err := s.writeEntity(fileRecord{Type: recordTypeWorkspace, Workspace: &workspace}); err != nil {
+		return err
+	}
+
+	return s.writeEntity(fileRecord{Type: recordTypeMember, Member: &owner})
+}
+
+func (s *fileStore) GetUserWorkspaces(ctx context.Context, userID string) ([]models.HandleWorkspaceResponse, error) {
+	return s.inMemoryStore.GetUserWorkspaces(ctx, userID)
+}
+
+func (s *fileStore) GetWorkspaceMembers(ctx context.Context, workspaceID string) ([]models.WorkspaceMember, error) {
+	return s.inMemoryStore.GetWorkspaceMembers(ctx, workspaceID)
+}
+
+func (s *fileStore) SaveWorkspaceMember(ctx context.Context, member models.WorkspaceMember) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.inMemoryStore.SaveWorkspaceMember(ctx, member); err != nil {
+		return err
+	}
+
+	s.inMemoryStore.mu.RLock()
+	member = s.inMemoryStore.members[member.WorkspaceID][member.UserID]
+	s.inMemoryStore.mu.RUnlock()
+
+	return s.writeEntity(fileRecord{Type: recordTypeMember, Member: &member})
+}
+
+func (s *fileStore) RemoveWorkspaceMember(ctx context.Context, workspaceID string, userID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.inMemoryStore.RemoveWorkspaceMember(ctx, workspaceID, userID); err != nil {
+		return err
+	}
+
+	// Запись об участнике осталась бы в журнале, поэтому файл перезаписывается целиком.
+	return s.rewriteFile()
+}
+
+func (s *fileStore) TransferURLs(ctx context.Context,
+	domain string, urls []string, fromOwnerID string, toOwnerID string) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	transferred, err := s.inMemoryStore.TransferURLs(ctx, domain, urls, fromOwnerID, toOwnerID)
+	if err != nil || transferred == 0 {
+		return transferred, err
+	}
+
+	// Записи прежнего владельца остались бы в журнале, поэтому файл перезаписывается целиком.
+	return transferred, s.rewriteFile()
+}
+
+func (s *fileStore) CreateWebhook(ctx context.Context, webhook models.Webhook) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.inMemoryStore.CreateWebhook(ctx, webhook); err != nil {
+		return err
+	}
+
+	return s.writeEntity(fileRecord{Type: recordTypeWebhook, Webhook: &webhook})
+}
+
+func (s *fileStore) GetWebhooks(ctx context.Context, userID string) ([]models.Webhook, error) {
+	return s.inMemoryStore.GetWebhooks(ctx, userID)
+}
+
+func (s *fileStore) DeleteWebhook(ctx context.Context, webhookID string, userID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.inMemoryStore.DeleteWebhook(ctx, webhookID, userID); err != nil {
+		return err
+	}
+
+	// Вебхук и его очередь остались бы в журнале, поэтому файл перезаписывается целиком.
+	return s.rewriteFile()
+}
+
+func (s *fileStore) EnqueueDeliveries(ctx context.Context, deliveries []models.WebhookDelivery) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.inMemoryStore.EnqueueDeliveries(ctx, deliveries); err != nil {
+		return err
+	}
+
+	lines := make([][]byte, 0, len(deliveries))
+	for _, delivery := range deliveries {
+		line, err := encodeEntity(fileRecord{Type: recordTypeDelivery, Delivery: &delivery})
+		if err != nil {
+			return err
 		}
+		lines = append(lines, line)
 	}
 
-	if err := scanner.Err(); err != nil {
-		return fmt.Errorf("failed to scan file: %w", err)
+	return s.appendLines(lines...)
+}
+
+// ClaimDeliveries не пишет в журнал: после перезапуска выбранные, но не отправленные
+// доставки снова окажутся в очереди, то есть событие будет доставлено хотя бы раз.
+func (s *fileStore) ClaimDeliveries(ctx context.Context,
+	now time.Time, lease time.Duration, limit int) ([]models.WebhookDelivery, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.inMemoryStore.ClaimDeliveries(ctx, now, lease, limit)
+}
+
+func (s *fileStore) UpdateDelivery(ctx context.Context, delivery models.WebhookDelivery) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.inMemoryStore.UpdateDelivery(ctx, delivery); err != nil {
+		return err
 	}
 
-	return nil
+	return s.writeDelivery(delivery.ID)
+}
+
+func (s *fileStore) DeleteDelivery(ctx context.Context, deliveryID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.inMemoryStore.DeleteDelivery(ctx, deliveryID); err != nil {
+		return err
+	}
+
+	return s.writeEntity(fileRecord{Type: recordTypeDeliveryDone, DeliveryID: deliveryID})
+}
+
+func (s *fileStore) GetDeadDeliveries(ctx context.Context, userID string) ([]models.WebhookDelivery, error) {
+	return s.inMemoryStore.GetDeadDeliveries(ctx, userID)
+}
+
+func (s *fileStore) RequeueDelivery(ctx context.Context, deliveryID string, userID string, now time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.inMemoryStore.RequeueDelivery(ctx, deliveryID, userID, now); err != nil {
+		return err
+	}
+
+	return s.writeDelivery(deliveryID)
+}
+
+// writeDelivery дописывает текущее состояние доставки; если её уже удалили, писать нечего.
+func (s *fileStore) writeDelivery(deliveryID string) error {
+	s.inMemoryStore.mu.RLock()
+	delivery, ok := s.inMemoryStore.delivery(deliveryID)
+	s.inMemoryStore.mu.RUnlock()
+
+	if !ok {
+		return nil
+	}
+
+	return s.writeEntity(fileRecord{Type: recordTypeDelivery, Delivery: &delivery})
+}
+
+func (s *fileStore) SearchURLs(ctx context.Context, search models.LinkSearch) ([]models.Data, error) {
+	return s.inMemoryStore.SearchURLs(ctx, search)
+}
+
+func (s *fileStore) GetStats(ctx context.Context) (models.StoreStats, error) {
+	return s.inMemoryStore.GetStats(ctx)
+}
+
+func (s *fileStore) ReadRecords(ctx context.Context,
+	kind string, after string, limit int) (models.RecordPage, error) {
+	return s.inMemoryStore.ReadRecords(ctx, kind, after, limit)
+}
+
+func (s *fileStore) CountRecords(ctx context.Context, kind string) (int64, error) {
+	return s.inMemoryStore.CountRecords(ctx, kind)
+}
+
+// WriteRecords дописывает в журнал только сохранённые записи, всю порцию за одно открытие файла.
+func (s *fileStore) WriteRecords(_ context.Context, records []models.Record) (models.RecordsWriteResult, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var lines [][]byte
+	s.inMemoryStore.mu.Lock()
+	res, err := s.inMemoryStore.putRecords(records, func(rec models.Record) error {
+		line, err := encodeStoreRecord(rec)
+		if err != nil {
+			return err
+		}
+		lines = append(lines, line)
+
+		return nil
+	})
+	s.inMemoryStore.mu.Unlock()
+
+	if err != nil {
+		return res, err
+	}
+
+	return res, s.appendLines(lines...)
+}
+
+func (s *fileStore) GetLinkEvents(_ context.Context, _ int64, _ int) ([]models.LinkEvent, error) {
+	return nil, fmt.Errorf("%w", ErrEventsUnsupported)
 }
 
 func (s *fileStore) Ping(_ context.Context) error {
+	file, err := os.OpenFile(s.fName, os.O_APPEND|os.O_CREATE|os.O_WRONLY, fileModeOwnerReadWrite)
+	if err != nil {
+		return fmt.Errorf("failed to open file: %w", err)
+	}
+
+	if err = file.Close(); err != nil {
+		return fmt.Errorf("failed to close file: %w", err)
+	}
+
+	return nil
+}
+
+func (s *fileStore) CheckMigrations(_ context.Context) error {
 	return nil
 }
 
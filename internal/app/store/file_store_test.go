@@ -0,0 +1,93 @@
+package store
+
+import (
+	"context"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/a-bondar/go-url-shortener/internal/app/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFileStoreJournalKeepsConcurrentChanges(t *testing.T) {
+	ctx := context.Background()
+	fName := filepath.Join(t.TempDir(), "storage.json")
+
+	s, err := newFileStore(fName)
+	require.NoError(t, err)
+
+	_, err = s.SaveURL(ctx, "https://limited.example", "lim", testUserID, models.LinkMeta{},
+		models.LinkOptions{MaxClicks: 20, ClicksLeft: 20})
+	require.NoError(t, err)
+
+	var wg sync.WaitGroup
+	for i := range 15 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			assert.NoError(t, s.ConsumeClick(ctx, "", "lim"))
+			if i%5 == 0 {
+				// Перезапись файла не должна терять списания, сделанные параллельно.
+				assert.NoError(t, s.CleanupDeletedURLs(ctx, time.Now()))
+			}
+		}()
+	}
+	wg.Wait()
+
+	reloaded, err := newFileStore(fName)
+	require.NoError(t, err)
+
+	data, err := reloaded.GetURL(ctx, "", "lim")
+	require.NoError(t, err)
+	assert.Equal(t, 5, data.ClicksLeft)
+}
+
+func TestFileStoreReloadsLinkChanges(t *testing.T) {
+	ctx := context.Background()
+	fName := filepath.Join(t.TempDir(), "storage.json")
+	start := time.Date(2026, time.March, 1, 9, 0, 0, 0, time.UTC)
+
+	s, err := newFileStore(fName)
+	require.NoError(t, err)
+
+	_, err = s.SaveURL(ctx, "https://brand.example", "ab", testUserID, models.LinkMeta{}, models.LinkOptions{
+		Domain: testDomain,
+		Targets: []models.LinkTarget{
+			{URL: "https://brand.example/a", Weight: 1},
+			{URL: "https://brand.example/b", Weight: 3},
+		},
+		MaxClicks:  3,
+		ClicksLeft: 3,
+		NotBefore:  &start,
+	})
+	require.NoError(t, err)
+	_, err = s.SaveURL(ctx, "https://main.example", "ab", testUserID, models.LinkMeta{}, models.LinkOptions{})
+	require.NoError(t, err)
+
+	require.NoError(t, s.IncrementTargetClicks(ctx, testDomain, "ab", 1))
+	require.NoError(t, s.ConsumeClick(ctx, testDomain, "ab"))
+	_, err = s.UpdateURL(ctx, testDomain, "ab", testUserID, "https://brand.example/new")
+	require.NoError(t, err)
+	require.NoError(t, s.DeleteURLs(ctx, "", []string{"ab"}, testUserID))
+
+	reloaded, err := newFileStore(fName)
+	require.NoError(t, err)
+
+	brand, err := reloaded.GetURL(ctx, testDomain, "ab")
+	require.NoError(t, err)
+	assert.Equal(t, "https://brand.example/new", brand.OriginalURL)
+	assert.Equal(t, 2, brand.ClicksLeft)
+	assert.Equal(t, []int64{0, 1}, []int64{brand.Targets[0].Clicks, brand.Targets[1].Clicks})
+	require.NotNil(t, brand.NotBefore)
+	assert.True(t, start.Equal(*brand.NotBefore))
+	assert.False(t, brand.Deleted)
+	require.Len(t, brand.Revisions, 1)
+
+	main, err := reloaded.GetURL(ctx, "", "ab")
+	require.NoError(t, err)
+	assert.True(t, main.Deleted)
+}
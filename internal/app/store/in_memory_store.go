@@ -1,121 +1,1279 @@
 package store
 
 import (
+	"cmp"
 	"context"
 	"fmt"
+	"maps"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/a-bondar/go-url-shortener/internal/app/models"
 )
 
 type ShortURLData struct {
 	FullURL string
 	Deleted bool
+	models.LinkMeta
+	models.LinkOptions
+	DeletedAt *time.Time
+	Revisions []models.URLRevision
+}
+
+// linkKey определяет короткую ссылку: коды уникальны в пределах домена.
+type linkKey struct {
+	domain   string
+	shortURL string
 }
 
 type inMemoryStore struct {
-	m map[string]map[string]*ShortURLData
+	m        map[string]map[linkKey]*ShortURLData
+	users    map[string]models.User
+	apiKeys  map[string]*models.APIKey
+	sessions map[string]models.RevokedSession
+	// workspaces и members (workspaceID -> userID -> участник) — рабочие пространства команд.
+	workspaces map[string]models.Workspace
+	members    map[string]map[string]models.WorkspaceMember
+	webhooks   map[string]models.Webhook
+	deliveries map[string]models.WebhookDelivery
+	// mu защищает все данные хранилища. Его берут экспортируемые методы,
+	// вспомогательные методы со строчной буквы вызываются под ним.
+	mu sync.RWMutex
 }
 
 func newInMemoryStore() *inMemoryStore {
 	return &inMemoryStore{
-		m: make(map[string]map[string]*ShortURLData),
+		m:          make(map[string]map[linkKey]*ShortURLData),
+		users:      make(map[string]models.User),
+		apiKeys:    make(map[string]*models.APIKey),
+		sessions:   make(map[string]models.RevokedSession),
+		workspaces: make(map[string]models.Workspace),
+		members:    make(map[string]map[string]models.WorkspaceMember),
+		webhooks:   make(map[string]models.Webhook),
+		deliveries: make(map[string]models.WebhookDelivery),
 	}
 }
 
-func (s *inMemoryStore) SaveURL(_ context.Context, fullURL string, shortURL string, userID string) (string, error) {
-	userURLs, ok := s.m[userID]
-	if !ok {
-		// У пользователя еще нет данных, создаем пустой map
-		userURLs = make(map[string]*ShortURLData)
-		s.m[userID] = userURLs
-	}
+func (s *inMemoryStore) SaveURL(_ context.Context,
+	fullURL string, shortURL string, userID string, meta models.LinkMeta, opts models.LinkOptions) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	userURLs := s.userURLs(userID)
+	key := linkKey{domain: opts.Domain, shortURL: shortURL}
 
 	// Проверка на конфликт с учетом флага deleted
-	for currentShortURL, currentShortURLData := range userURLs {
-		if currentShortURLData.FullURL == fullURL {
+	for currentKey, currentShortURLData := range userURLs {
+		if currentKey.domain == opts.Domain && currentShortURLData.FullURL == fullURL {
 			if currentShortURLData.Deleted {
-				userURLs[shortURL] = &ShortURLData{FullURL: fullURL, Deleted: false}
+				userURLs[key] = &ShortURLData{
+					FullURL: fullURL, Deleted: false, LinkMeta: copyMeta(meta), LinkOptions: copyOptions(opts),
+				}
 				return shortURL, nil
 			} else {
-				return currentShortURL, nil
+				return currentKey.shortURL, nil
 			}
 		}
 	}
 
-	userURLs[shortURL] = &ShortURLData{FullURL: fullURL, Deleted: false}
+	userURLs[key] = &ShortURLData{
+		FullURL: fullURL, Deleted: false, LinkMeta: copyMeta(meta), LinkOptions: copyOptions(opts),
+	}
 
 	return shortURL, nil
 }
 
-func (s *inMemoryStore) GetURL(_ context.Context, shortURL string) (string, bool, error) {
-	for _, userURLs := range s.m {
-		if shortURLData, ok := userURLs[shortURL]; ok {
-			return shortURLData.FullURL, shortURLData.Deleted, nil
+func (s *inMemoryStore) GetURL(_ context.Context, domain string, shortURL string) (models.Data, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var (
+		deleted models.Data
+		found   bool
+	)
+	key := linkKey{domain: domain, shortURL: shortURL}
+	for userID, userURLs := range s.m {
+		shortURLData, ok := userURLs[key]
+		if !ok {
+			continue
+		}
+
+		// Удалённая ссылка могла освободить короткий адрес для другой — действующая важнее.
+		if !shortURLData.Deleted {
+			return shortURLData.toData(key, userID), nil
 		}
+
+		deleted, found = shortURLData.toData(key, userID), true
+	}
+
+	if !found {
+		return models.Data{}, fmt.Errorf("%w", ErrURLNotFound)
 	}
 
-	return "", false, fmt.Errorf("%w", ErrURLNotFound)
+	return deleted, nil
 }
 
-func (s *inMemoryStore) GetURLs(_ context.Context, userID string) (map[string]string, error) {
+func (s *inMemoryStore) GetURLs(_ context.Context, userID string, filter models.URLsFilter) ([]models.Data, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
 	userURLs, ok := s.m[userID]
 	if !ok {
 		return nil, fmt.Errorf("%w", ErrUserHasNoURLs)
 	}
 
-	res := make(map[string]string)
-	for shortURL, shortURLData := range userURLs {
-		res[shortURL] = shortURLData.FullURL
+	res := make([]models.Data, 0, len(userURLs))
+	for key, shortURLData := range userURLs {
+		if shortURLData.Deleted != filter.Deleted {
+			continue
+		}
+
+		if filter.Tag != "" && !slices.Contains(shortURLData.Tags, filter.Tag) {
+			continue
+		}
+
+		res = append(res, shortURLData.toData(key, userID))
+	}
+
+	if len(res) == 0 {
+		return nil, fmt.Errorf("%w", ErrUserHasNoURLs)
 	}
 
 	return res, nil
 }
 
-func (s *inMemoryStore) DeleteURLs(_ context.Context, urls []string, userID string) error {
+func (s *inMemoryStore) GetUserURL(_ context.Context,
+	domain string, shortURL string, userID string) (models.Data, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	key, userURL, ok := findActiveURL(s.m[userID], domain, shortURL)
+	if !ok {
+		return models.Data{}, fmt.Errorf("%w", ErrURLNotFound)
+	}
+
+	return userURL.toData(key, userID), nil
+}
+
+func (s *inMemoryStore) IncrementTargetClicks(_ context.Context, domain string, shortURL string, target int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !s.incrementTargetClicks(linkKey{domain: domain, shortURL: shortURL}, target) {
+		return fmt.Errorf("%w", ErrURLNotFound)
+	}
+
+	return nil
+}
+
+// incrementTargetClicks засчитывает переход на адрес target действующей ссылки.
+func (s *inMemoryStore) incrementTargetClicks(key linkKey, target int) bool {
+	for _, userURLs := range s.m {
+		shortURLData, ok := userURLs[key]
+		if !ok || shortURLData.Deleted || target < 0 || target >= len(shortURLData.Targets) {
+			continue
+		}
+
+		shortURLData.Targets[target].Clicks++
+
+		return true
+	}
+
+	return false
+}
+
+func (s *inMemoryStore) ConsumeClick(_ context.Context, domain string, shortURL string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.consumeClick(linkKey{domain: domain, shortURL: shortURL})
+}
+
+// consumeClick списывает переход у действующей ссылки. У ссылки без ограничения списывать нечего.
+func (s *inMemoryStore) consumeClick(key linkKey) error {
+	for _, userURLs := range s.m {
+		shortURLData, ok := userURLs[key]
+		if !ok || shortURLData.Deleted {
+			continue
+		}
+
+		if shortURLData.MaxClicks == 0 {
+			return nil
+		}
+
+		if shortURLData.ClicksLeft <= 0 {
+			return fmt.Errorf("%w", ErrNoClicksLeft)
+		}
+
+		shortURLData.ClicksLeft--
+
+		return nil
+	}
+
+	return fmt.Errorf("%w", ErrURLNotFound)
+}
+
+func (s *inMemoryStore) UpdateURLMeta(_ context.Context,
+	domain string, shortURL string, userID string, patch models.LinkMetaPatch) (models.Data, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key, userURL, ok := findActiveURL(s.m[userID], domain, shortURL)
+	if !ok {
+		return models.Data{}, fmt.Errorf("%w", ErrURLNotFound)
+	}
+
+	if patch.Title != nil {
+		userURL.Title = *patch.Title
+	}
+
+	if patch.Tags != nil {
+		userURL.Tags = slices.Clone(*patch.Tags)
+	}
+
+	if patch.Note != nil {
+		userURL.Note = *patch.Note
+	}
+
+	return userURL.toData(key, userID), nil
+}
+
+func (s *inMemoryStore) UpdateURL(_ context.Context,
+	domain string, shortURL string, userID string, fullURL string) (models.Data, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key, userURL, ok := findActiveURL(s.m[userID], domain, shortURL)
+	if !ok {
+		return models.Data{}, fmt.Errorf("%w", ErrURLNotFound)
+	}
+
+	if userURL.FullURL == fullURL {
+		return userURL.toData(key, userID), nil
+	}
+
+	// Как и в БД, адрес в домене занимает любая ссылка, включая удалённые и чужие.
+	for _, urls := range s.m {
+		for currentKey, currentShortURLData := range urls {
+			if currentKey.domain == key.domain && currentShortURLData.FullURL == fullURL {
+				return models.Data{}, fmt.Errorf("%w", ErrURLExists)
+			}
+		}
+	}
+
+	userURL.Revisions = append(userURL.Revisions, models.URLRevision{
+		OriginalURL: userURL.FullURL,
+		ChangedAt:   time.Now(),
+	})
+	userURL.FullURL = fullURL
+
+	return userURL.toData(key, userID), nil
+}
+
+func (s *inMemoryStore) GetURLRevisions(_ context.Context,
+	domain string, shortURL string, userID string) ([]models.URLRevision, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	_, userURL, ok := findActiveURL(s.m[userID], domain, shortURL)
+	if !ok {
+		return nil, fmt.Errorf("%w", ErrURLNotFound)
+	}
+
+	return slices.Clone(userURL.Revisions), nil
+}
+
+func (s *inMemoryStore) DeleteURLs(_ context.Context, domain string, urls []string, userID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
 	userURLs, ok := s.m[userID]
 	if !ok {
 		return fmt.Errorf("%w", ErrUserHasNoURLs)
 	}
 
-	for _, url := range urls {
-		if userURL, ok := userURLs[url]; ok {
+	now := time.Now()
+	for key, userURL := range userURLs {
+		if key.domain == domain && !userURL.Deleted && slices.Contains(urls, key.shortURL) {
 			userURL.Deleted = true
+			userURL.DeletedAt = &now
 		}
 	}
 
 	return nil
 }
 
-func (s *inMemoryStore) CleanupDeletedURLs(_ context.Context) error {
-	for _, userURLs := range s.m {
-		for shortURL, shortURLData := range userURLs {
-			if shortURLData.Deleted {
-				delete(userURLs, shortURL)
-			}
+func (s *inMemoryStore) RestoreURLs(_ context.Context, domain string, urls []string, userID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	userURLs, ok := s.m[userID]
+	if !ok {
+		return fmt.Errorf("%w", ErrUserHasNoURLs)
+	}
+
+	// Как и в БД, ссылку не восстанавливаем, если её код уже занят действующей ссылкой другого владельца.
+	for key, userURL := range userURLs {
+		if key.domain != domain || !userURL.Deleted || !slices.Contains(urls, key.shortURL) ||
+			hasActiveURL(userURLs, key.domain, userURL.FullURL) || s.codeTaken(key) {
+			continue
 		}
+
+		userURL.Deleted = false
+		userURL.DeletedAt = nil
 	}
 
 	return nil
 }
 
-func (s *inMemoryStore) SaveURLsBatch(_ context.Context,
-	urls map[string]string, userID string) (map[string]string, error) {
+// codeTaken сообщает, есть ли у кого-нибудь действующая ссылка с этим доменом и кодом.
+func (s *inMemoryStore) codeTaken(key linkKey) bool {
+	for _, userURLs := range s.m {
+		if shortURLData, ok := userURLs[key]; ok && !shortURLData.Deleted {
+			return true
+		}
+	}
+
+	return false
+}
+
+// hasActiveURL сообщает, есть ли у пользователя неудалённая ссылка на fullURL в домене.
+// Такая ссылка появляется, если после удаления тот же адрес сократили заново.
+func hasActiveURL(userURLs map[linkKey]*ShortURLData, domain string, fullURL string) bool {
+	for key, shortURLData := range userURLs {
+		if key.domain == domain && !shortURLData.Deleted && shortURLData.FullURL == fullURL {
+			return true
+		}
+	}
+
+	return false
+}
+
+// findActiveURL ищет неудалённую ссылку пользователя по домену и коду.
+func findActiveURL(userURLs map[linkKey]*ShortURLData, domain string, shortURL string) (linkKey, *ShortURLData, bool) {
+	key := linkKey{domain: domain, shortURL: shortURL}
+	shortURLData, ok := userURLs[key]
+	if !ok || shortURLData.Deleted {
+		return linkKey{}, nil, false
+	}
+
+	return key, shortURLData, true
+}
+
+// userURLs возвращает ссылки владельца, заводя для него пустой map при необходимости.
+func (s *inMemoryStore) userURLs(userID string) map[linkKey]*ShortURLData {
 	userURLs, ok := s.m[userID]
 	if !ok {
-		// У пользователя еще нет данных, создаем пустой map
-		userURLs = make(map[string]*ShortURLData)
+		userURLs = make(map[linkKey]*ShortURLData)
 		s.m[userID] = userURLs
 	}
 
+	return userURLs
+}
+
+func (s *inMemoryStore) CleanupDeletedURLs(_ context.Context, deletedBefore time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, userURLs := range s.m {
+		for key, shortURLData := range userURLs {
+			if shortURLData.Deleted && (shortURLData.DeletedAt == nil || shortURLData.DeletedAt.Before(deletedBefore)) {
+				delete(userURLs, key)
+			}
+		}
+	}
+
+	return nil
+}
+
+func (s *inMemoryStore) SaveURLsBatch(_ context.Context,
+	urls map[string]string, userID string) (map[string]string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	userURLs := s.userURLs(userID)
+
 	res := make(map[string]string)
 	for fullURL, shortURL := range urls {
-		userURLs[shortURL] = &ShortURLData{FullURL: fullURL, Deleted: false}
+		userURLs[linkKey{shortURL: shortURL}] = &ShortURLData{FullURL: fullURL, Deleted: false}
 		res[fullURL] = shortURL
 	}
 
 	return res, nil
 }
 
+func (s *inMemoryStore) ClaimURLs(_ context.Context, fromUserID string, toUserID string) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	fromURLs, ok := s.m[fromUserID]
+	if !ok || fromUserID == toUserID {
+		return 0, nil
+	}
+
+	// Ссылка с тем же доменом и кодом уже может быть у владельца, например удалённая, код которой
+	// заняли заново. Её не перезаписываем: такая ссылка остаётся у анонимной личности.
+	toURLs := s.userURLs(toUserID)
+
+	var claimed int64
+	for key, shortURLData := range fromURLs {
+		if _, ok := toURLs[key]; ok {
+			continue
+		}
+
+		toURLs[key] = shortURLData
+		delete(fromURLs, key)
+		claimed++
+	}
+
+	if len(fromURLs) == 0 {
+		delete(s.m, fromUserID)
+	}
+
+	return claimed, nil
+}
+
+func (s *inMemoryStore) CreateUser(_ context.Context, user models.User) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.users[user.ID] = user
+
+	return nil
+}
+
+func (s *inMemoryStore) GetUser(_ context.Context, userID string) (models.User, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	user, ok := s.users[userID]
+	if !ok {
+		return models.User{}, fmt.Errorf("%w", ErrUserNotFound)
+	}
+
+	return user, nil
+}
+
+func (s *inMemoryStore) CreateAPIKey(_ context.Context, key models.APIKey) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key.Scopes = slices.Clone(key.Scopes)
+	s.apiKeys[key.ID] = &key
+
+	return nil
+}
+
+func (s *inMemoryStore) GetAPIKeyByHash(_ context.Context, keyHash string) (models.APIKey, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for _, key := range s.apiKeys {
+		if key.KeyHash == keyHash {
+			return copyAPIKey(key), nil
+		}
+	}
+
+	return models.APIKey{}, fmt.Errorf("%w", ErrAPIKeyNotFound)
+}
+
+func (s *inMemoryStore) GetAPIKeys(_ context.Context, userID string) ([]models.APIKey, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	res := make([]models.APIKey, 0)
+	for _, key := range s.apiKeys {
+		if key.UserID == userID {
+			res = append(res, copyAPIKey(key))
+		}
+	}
+
+	slices.SortFunc(res, func(a, b models.APIKey) int {
+		return a.CreatedAt.Compare(b.CreatedAt)
+	})
+
+	return res, nil
+}
+
+func (s *inMemoryStore) RevokeAPIKey(_ context.Context, keyID string, userID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key, ok := s.apiKeys[keyID]
+	if !ok || key.UserID != userID {
+		return fmt.Errorf("%w", ErrAPIKeyNotFound)
+	}
+
+	if key.RevokedAt == nil {
+		now := time.Now()
+		key.RevokedAt = &now
+	}
+
+	return nil
+}
+
+func (s *inMemoryStore) RevokeSession(_ context.Context, session models.RevokedSession) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.sessions[session.ID]; !ok {
+		s.sessions[session.ID] = session
+	}
+
+	return nil
+}
+
+func (s *inMemoryStore) IsSessionRevoked(_ context.Context, sessionID string) (bool, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	_, ok := s.sessions[sessionID]
+
+	return ok, nil
+}
+
+// ConsumeRefreshToken хранит использованный refresh-токен вместе с отозванными сессиями:
+// его идентификатор тоже UUID и тоже живёт до истечения токена.
+func (s *inMemoryStore) ConsumeRefreshToken(_ context.Context, token models.RevokedSession) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.sessions[token.ID]; ok {
+		return false, nil
+	}
+
+	s.sessions[token.ID] = token
+
+	return true, nil
+}
+
+func (s *inMemoryStore) CleanupRevokedSessions(_ context.Context, expiredBefore time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for id, session := range s.sessions {
+		if session.ExpiresAt.Before(expiredBefore) {
+			delete(s.sessions, id)
+		}
+	}
+
+	return nil
+}
+
+func (s *inMemoryStore) CreateWorkspace(_ context.Context,
+	workspace models.Workspace, owner models.WorkspaceMember) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.workspaces[workspace.ID] = workspace
+	s.members[workspace.ID] = map[string]models.WorkspaceMember{owner.UserID: owner}
+
+	return nil
+}
+
+func (s *inMemoryStore) GetUserWorkspaces(_ context.Context, userID string) ([]models.HandleWorkspaceResponse, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	res := make([]models.HandleWorkspaceResponse, 0)
+	for workspaceID, members := range s.members {
+		member, ok := members[userID]
+		if !ok {
+			continue
+		}
+
+		workspace := s.workspaces[workspaceID]
+		res = append(res, models.HandleWorkspaceResponse{
+			ID:        workspace.ID,
+			Name:      workspace.Name,
+			Role:      member.Role,
+			CreatedAt: workspace.CreatedAt,
+		})
+	}
+
+	slices.SortFunc(res, func(a, b models.HandleWorkspaceResponse) int {
+		return a.CreatedAt.Compare(b.CreatedAt)
+	})
+
+	return res, nil
+}
+
+func (s *inMemoryStore) GetWorkspaceMembers(_ context.Context, workspaceID string) ([]models.WorkspaceMember, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	members, ok := s.members[workspaceID]
+	if !ok {
+		return nil, fmt.Errorf("%w", ErrWorkspaceNotFound)
+	}
+
+	res := make([]models.WorkspaceMember, 0, len(members))
+	for _, member := range members {
+		res = append(res, member)
+	}
+
+	slices.SortFunc(res, func(a, b models.WorkspaceMember) int {
+		return a.AddedAt.Compare(b.AddedAt)
+	})
+
+	return res, nil
+}
+
+func (s *inMemoryStore) SaveWorkspaceMember(_ context.Context, member models.WorkspaceMember) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	members, ok := s.members[member.WorkspaceID]
+	if !ok {
+		return fmt.Errorf("%w", ErrWorkspaceNotFound)
+	}
+
+	// При смене роли участник сохраняет исходную дату добавления.
+	if existing, ok := members[member.UserID]; ok {
+		member.AddedAt = existing.AddedAt
+	}
+	members[member.UserID] = member
+
+	return nil
+}
+
+func (s *inMemoryStore) RemoveWorkspaceMember(_ context.Context, workspaceID string, userID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.members[workspaceID][userID]; !ok {
+		return fmt.Errorf("%w", ErrMemberNotFound)
+	}
+
+	delete(s.members[workspaceID], userID)
+
+	return nil
+}
+
+func (s *inMemoryStore) TransferURLs(_ context.Context,
+	domain string, urls []string, fromOwnerID string, toOwnerID string) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	fromURLs, ok := s.m[fromOwnerID]
+	if !ok || fromOwnerID == toOwnerID {
+		return 0, nil
+	}
+
+	// Как и при ClaimURLs, ссылку с тем же доменом и кодом у нового владельца не перезаписываем:
+	// такая ссылка остаётся у прежнего и в число переданных не входит.
+	toURLs := s.userURLs(toOwnerID)
+
+	var transferred int64
+	for key, shortURLData := range fromURLs {
+		if shortURLData.Deleted || key.domain != domain || !slices.Contains(urls, key.shortURL) {
+			continue
+		}
+
+		if _, ok := toURLs[key]; ok {
+			continue
+		}
+
+		toURLs[key] = shortURLData
+		delete(fromURLs, key)
+		transferred++
+	}
+
+	return transferred, nil
+}
+
+func (s *inMemoryStore) CreateWebhook(_ context.Context, webhook models.Webhook) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	webhook.Events = slices.Clone(webhook.Events)
+	s.webhooks[webhook.ID] = webhook
+
+	return nil
+}
+
+func (s *inMemoryStore) GetWebhooks(_ context.Context, userID string) ([]models.Webhook, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	res := make([]models.Webhook, 0)
+	for _, webhook := range s.webhooks {
+		if webhook.UserID == userID {
+			webhook.Events = slices.Clone(webhook.Events)
+			res = append(res, webhook)
+		}
+	}
+
+	slices.SortFunc(res, func(a, b models.Webhook) int {
+		return a.CreatedAt.Compare(b.CreatedAt)
+	})
+
+	return res, nil
+}
+
+// DeleteWebhook удаляет вебхук вместе с его очередью, включая недоставленные события.
+func (s *inMemoryStore) DeleteWebhook(_ context.Context, webhookID string, userID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	webhook, ok := s.webhooks[webhookID]
+	if !ok || webhook.UserID != userID {
+		return fmt.Errorf("%w", ErrWebhookNotFound)
+	}
+
+	delete(s.webhooks, webhookID)
+	for id, delivery := range s.deliveries {
+		if delivery.WebhookID == webhookID {
+			delete(s.deliveries, id)
+		}
+	}
+
+	return nil
+}
+
+func (s *inMemoryStore) EnqueueDeliveries(_ context.Context, deliveries []models.WebhookDelivery) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, delivery := range deliveries {
+		s.deliveries[delivery.ID] = delivery
+	}
+
+	return nil
+}
+
+// ClaimDeliveries выбирает готовые к отправке доставки и откладывает их на lease,
+// чтобы следующий проход не взял их повторно, пока идёт отправка.
+func (s *inMemoryStore) ClaimDeliveries(_ context.Context,
+	now time.Time, lease time.Duration, limit int) ([]models.WebhookDelivery, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	due := make([]models.WebhookDelivery, 0)
+	for _, delivery := range s.deliveries {
+		if !delivery.Dead && !delivery.NextAttemptAt.After(now) {
+			due = append(due, delivery)
+		}
+	}
+
+	slices.SortFunc(due, func(a, b models.WebhookDelivery) int {
+		return a.NextAttemptAt.Compare(b.NextAttemptAt)
+	})
+
+	res := make([]models.WebhookDelivery, 0, min(len(due), limit))
+	for _, delivery := range due[:min(len(due), limit)] {
+		claimed := delivery
+		claimed.NextAttemptAt = now.Add(lease)
+		s.deliveries[delivery.ID] = claimed
+
+		webhook := s.webhooks[delivery.WebhookID]
+		delivery.URL, delivery.Secret = webhook.URL, webhook.Secret
+		res = append(res, delivery)
+	}
+
+	return res, nil
+}
+
+func (s *inMemoryStore) UpdateDelivery(_ context.Context, delivery models.WebhookDelivery) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.deliveries[delivery.ID]; !ok {
+		return fmt.Errorf("%w", ErrDeliveryNotFound)
+	}
+
+	delivery.URL, delivery.Secret = "", ""
+	s.deliveries[delivery.ID] = delivery
+
+	return nil
+}
+
+// DeleteDelivery убирает доставленное событие из очереди.
+func (s *inMemoryStore) DeleteDelivery(_ context.Context, deliveryID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.deliveries, deliveryID)
+
+	return nil
+}
+
+func (s *inMemoryStore) GetDeadDeliveries(_ context.Context, userID string) ([]models.WebhookDelivery, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	res := make([]models.WebhookDelivery, 0)
+	for _, delivery := range s.deliveries {
+		if delivery.Dead && s.webhooks[delivery.WebhookID].UserID == userID {
+			res = append(res, delivery)
+		}
+	}
+
+	slices.SortFunc(res, func(a, b models.WebhookDelivery) int {
+		return a.CreatedAt.Compare(b.CreatedAt)
+	})
+
+	return res, nil
+}
+
+// RequeueDelivery возвращает доставку из списка недоставленных в очередь с нуля попыток.
+func (s *inMemoryStore) RequeueDelivery(_ context.Context, deliveryID string, userID string, now time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delivery, ok := s.deliveries[deliveryID]
+	if !ok || !delivery.Dead || s.webhooks[delivery.WebhookID].UserID != userID {
+		return fmt.Errorf("%w", ErrDeliveryNotFound)
+	}
+
+	delivery.Dead = false
+	delivery.Attempts = 0
+	delivery.NextAttemptAt = now
+	s.deliveries[deliveryID] = delivery
+
+	return nil
+}
+
+// SearchURLs отбирает ссылки всех владельцев в порядке домена, кода и владельца.
+func (s *inMemoryStore) SearchURLs(_ context.Context, search models.LinkSearch) ([]models.Data, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	res := make([]models.Data, 0)
+	for userID, userURLs := range s.m {
+		if search.UserID != "" && userID != search.UserID {
+			continue
+		}
+
+		for key, shortURLData := range userURLs {
+			switch {
+			case shortURLData.Deleted && !search.IncludeDeleted,
+				search.ShortURL != "" && key.shortURL != search.ShortURL,
+				!strings.Contains(shortURLData.FullURL, search.Destination):
+				continue
+			}
+
+			res = append(res, shortURLData.toData(key, userID))
+		}
+	}
+
+	position := func(data models.Data) models.LinkCursor {
+		return models.LinkCursor{Domain: data.Domain, ShortURL: data.ShortURL, UserID: data.UserID}
+	}
+	slices.SortFunc(res, func(a, b models.Data) int {
+		return compareCursors(position(a), position(b))
+	})
+
+	if search.After != nil {
+		first := slices.IndexFunc(res, func(data models.Data) bool {
+			return compareCursors(position(data), *search.After) > 0
+		})
+		if first < 0 {
+			first = len(res)
+		}
+		res = res[first:]
+	}
+
+	if search.Limit > 0 && len(res) > search.Limit {
+		res = res[:search.Limit]
+	}
+
+	return res, nil
+}
+
+func compareCursors(a, b models.LinkCursor) int {
+	return cmp.Or(
+		cmp.Compare(a.Domain, b.Domain),
+		cmp.Compare(a.ShortURL, b.ShortURL),
+		cmp.Compare(a.UserID, b.UserID),
+	)
+}
+
+func (s *inMemoryStore) GetStats(_ context.Context) (models.StoreStats, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var stats models.StoreStats
+	for _, userURLs := range s.m {
+		if len(userURLs) > 0 {
+			stats.Owners++
+		}
+
+		for _, shortURLData := range userURLs {
+			if shortURLData.Deleted {
+				stats.DeletedLinks++
+			} else {
+				stats.Links++
+			}
+		}
+	}
+
+	stats.Users = int64(len(s.users))
+	for _, key := range s.apiKeys {
+		if key.RevokedAt == nil {
+			stats.APIKeys++
+		}
+	}
+	stats.Workspaces = int64(len(s.workspaces))
+
+	stats.Webhooks = int64(len(s.webhooks))
+	for _, delivery := range s.deliveries {
+		if delivery.Dead {
+			stats.DeadDeliveries++
+		} else {
+			stats.QueuedDeliveries++
+		}
+	}
+
+	return stats, nil
+}
+
+// recordEntry — запись вместе с ключом, по которому её упорядочивает курсор переноса.
+type recordEntry struct {
+	key    string
+	record models.Record
+}
+
+// ReadRecords отдаёт записи вида kind с ключом больше after. Ключ — идентификатор сущности,
+// у ссылки — владелец, домен и код: в памяти они однозначно её определяют.
+func (s *inMemoryStore) ReadRecords(_ context.Context,
+	kind string, after string, limit int) (models.RecordPage, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	entries, err := s.recordEntries(kind)
+	if err != nil {
+		return models.RecordPage{}, err
+	}
+
+	entries = slices.DeleteFunc(entries, func(entry recordEntry) bool {
+		return entry.key <= after
+	})
+	slices.SortFunc(entries, func(a, b recordEntry) int {
+		return cmp.Compare(a.key, b.key)
+	})
+
+	page := models.RecordPage{Records: make([]models.Record, 0, min(len(entries), limit))}
+	for _, entry := range entries[:min(len(entries), limit)] {
+		page.Records = append(page.Records, entry.record)
+	}
+
+	if len(entries) > limit {
+		page.Next = entries[limit-1].key
+	}
+
+	return page, nil
+}
+
+func (s *inMemoryStore) CountRecords(_ context.Context, kind string) (int64, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	entries, err := s.recordEntries(kind)
+	if err != nil {
+		return 0, err
+	}
+
+	return int64(len(entries)), nil
+}
+
+// recordEntries собирает копии всех записей вида kind.
+func (s *inMemoryStore) recordEntries(kind string) ([]recordEntry, error) {
+	var entries []recordEntry
+	switch kind {
+	case models.RecordUsers:
+		for id, user := range s.users {
+			entries = append(entries, recordEntry{key: id, record: models.Record{User: &user}})
+		}
+	case models.RecordAPIKeys:
+		for id, key := range s.apiKeys {
+			apiKey := copyAPIKey(key)
+			entries = append(entries, recordEntry{key: id, record: models.Record{APIKey: &apiKey}})
+		}
+	case models.RecordSessions:
+		for id, session := range s.sessions {
+			entries = append(entries, recordEntry{key: id, record: models.Record{Session: &session}})
+		}
+	case models.RecordWorkspaces:
+		for id, workspace := range s.workspaces {
+			entries = append(entries, recordEntry{key: id, record: models.Record{Workspace: &workspace}})
+		}
+	case models.RecordMembers:
+		for workspaceID, members := range s.members {
+			for userID, member := range members {
+				entries = append(entries, recordEntry{
+					key:    workspaceID + "\x00" + userID,
+					record: models.Record{Member: &member},
+				})
+			}
+		}
+	case models.RecordLinks:
+		for userID, userURLs := range s.m {
+			for key, shortURLData := range userURLs {
+				data := shortURLData.toData(key, userID)
+				entries = append(entries, recordEntry{
+					key:    userID + "\x00" + key.domain + "\x00" + key.shortURL,
+					record: models.Record{Link: &data},
+				})
+			}
+		}
+	case models.RecordWebhooks:
+		webhooks, _ := s.webhookEntities()
+		for _, webhook := range webhooks {
+			webhook.Events = slices.Clone(webhook.Events)
+			entries = append(entries, recordEntry{key: webhook.ID, record: models.Record{Webhook: &webhook}})
+		}
+	case models.RecordDeliveries:
+		_, deliveries := s.webhookEntities()
+		for _, delivery := range deliveries {
+			entries = append(entries, recordEntry{key: delivery.ID, record: models.Record{Delivery: &delivery}})
+		}
+	default:
+		return nil, fmt.Errorf("%w %q", ErrUnknownRecordKind, kind)
+	}
+
+	return entries, nil
+}
+
+// WriteRecords сохраняет записи как есть. Запись с уже занятым ключом не перезаписывается:
+// совпадающая считается перенесённой раньше, отличающаяся — конфликтом.
+func (s *inMemoryStore) WriteRecords(_ context.Context, records []models.Record) (models.RecordsWriteResult, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.putRecords(records, nil)
+}
+
+// putRecords сохраняет записи и передаёт каждую сохранённую в saved, если он задан.
+func (s *inMemoryStore) putRecords(records []models.Record,
+	saved func(rec models.Record) error) (models.RecordsWriteResult, error) {
+	var res models.RecordsWriteResult
+	for _, rec := range records {
+		outcome, err := s.putRecord(rec)
+		if err != nil {
+			return res, err
+		}
+
+		if outcome == outcomeWritten && saved != nil {
+			if err = saved(rec); err != nil {
+				return res, err
+			}
+		}
+
+		countOutcome(&res, outcome, rec)
+	}
+
+	return res, nil
+}
+
+func (s *inMemoryStore) putRecord(rec models.Record) (writeOutcome, error) {
+	switch {
+	case rec.User != nil:
+		if _, ok := s.users[rec.User.ID]; ok {
+			return outcomePresent, nil
+		}
+		s.users[rec.User.ID] = *rec.User
+	case rec.APIKey != nil:
+		if _, ok := s.apiKeys[rec.APIKey.ID]; ok {
+			return outcomePresent, nil
+		}
+		key := copyAPIKey(rec.APIKey)
+		s.apiKeys[key.ID] = &key
+	case rec.Session != nil:
+		if _, ok := s.sessions[rec.Session.ID]; ok {
+			return outcomePresent, nil
+		}
+		s.sessions[rec.Session.ID] = *rec.Session
+	case rec.Workspace != nil:
+		if _, ok := s.workspaces[rec.Workspace.ID]; ok {
+			return outcomePresent, nil
+		}
+		s.workspaces[rec.Workspace.ID] = *rec.Workspace
+	case rec.Member != nil:
+		members, ok := s.members[rec.Member.WorkspaceID]
+		if !ok {
+			members = make(map[string]models.WorkspaceMember)
+			s.members[rec.Member.WorkspaceID] = members
+		}
+		if _, ok = members[rec.Member.UserID]; ok {
+			return outcomePresent, nil
+		}
+		members[rec.Member.UserID] = *rec.Member
+	case rec.Link != nil:
+		return s.putLink(*rec.Link), nil
+	case rec.Webhook != nil, rec.Delivery != nil:
+		return s.putWebhookRecord(rec), nil
+	default:
+		return outcomeConflict, fmt.Errorf("%w: record has no data", ErrUnknownRecordKind)
+	}
+
+	return outcomeWritten, nil
+}
+
+// putLink восстанавливает ссылку, если это не нарушит уникальность: код в домене
+// и адрес назначения у владельца могут принадлежать только одной действующей ссылке.
+func (s *inMemoryStore) putLink(data models.Data) writeOutcome {
+	key := linkKey{domain: data.Domain, shortURL: data.ShortURL}
+	if current, ok := s.m[data.UserID][key]; ok {
+		if current.FullURL == data.OriginalURL {
+			return outcomePresent
+		}
+
+		return outcomeConflict
+	}
+
+	if !data.Deleted {
+		if hasActiveURL(s.m[data.UserID], key.domain, data.OriginalURL) {
+			return outcomeConflict
+		}
+
+		for _, userURLs := range s.m {
+			if other, ok := userURLs[key]; ok && !other.Deleted {
+				return outcomeConflict
+			}
+		}
+	}
+
+	s.put(data)
+
+	return outcomeWritten
+}
+
+func (s *inMemoryStore) putWebhookRecord(rec models.Record) writeOutcome {
+	if rec.Webhook != nil {
+		if _, ok := s.webhooks[rec.Webhook.ID]; ok {
+			return outcomePresent
+		}
+
+		webhook := *rec.Webhook
+		webhook.Events = slices.Clone(webhook.Events)
+		s.webhooks[webhook.ID] = webhook
+
+		return outcomeWritten
+	}
+
+	if _, ok := s.deliveries[rec.Delivery.ID]; ok {
+		return outcomePresent
+	}
+
+	s.deliveries[rec.Delivery.ID] = *rec.Delivery
+
+	return outcomeWritten
+}
+
+func (s *inMemoryStore) GetLinkEvents(_ context.Context, _ int64, _ int) ([]models.LinkEvent, error) {
+	return nil, fmt.Errorf("%w", ErrEventsUnsupported)
+}
+
+// delivery возвращает доставку из очереди для записи в файл.
+func (s *inMemoryStore) delivery(deliveryID string) (models.WebhookDelivery, bool) {
+	delivery, ok := s.deliveries[deliveryID]
+
+	return delivery, ok
+}
+
+// webhookEntities возвращает копии вебхуков и очереди для снимка журнала.
+func (s *inMemoryStore) webhookEntities() ([]models.Webhook, []models.WebhookDelivery) {
+	webhooks := make([]models.Webhook, 0, len(s.webhooks))
+	for _, webhook := range s.webhooks {
+		webhooks = append(webhooks, webhook)
+	}
+
+	deliveries := make([]models.WebhookDelivery, 0, len(s.deliveries))
+	for _, delivery := range s.deliveries {
+		deliveries = append(deliveries, delivery)
+	}
+
+	return webhooks, deliveries
+}
+
 func (s *inMemoryStore) Ping(_ context.Context) error {
 	return nil
 }
 
+func (s *inMemoryStore) CheckMigrations(_ context.Context) error {
+	return nil
+}
+
 func (s *inMemoryStore) Close() {}
+
+// get возвращает запись пользователя в формате, пригодном для сохранения в файл.
+func (s *inMemoryStore) get(key linkKey, userID string) (models.Data, bool) {
+	userURL, ok := s.m[userID][key]
+	if !ok {
+		return models.Data{}, false
+	}
+
+	return userURL.toData(key, userID), true
+}
+
+// find возвращает записи пользователя с любым из кодов urls на домене.
+func (s *inMemoryStore) find(domain string, urls []string, userID string) []models.Data {
+	var res []models.Data
+	for key, userURL := range s.m[userID] {
+		if key.domain == domain && slices.Contains(urls, key.shortURL) {
+			res = append(res, userURL.toData(key, userID))
+		}
+	}
+
+	return res
+}
+
+// all возвращает все записи хранилища.
+func (s *inMemoryStore) all() []models.Data {
+	var res []models.Data
+	for userID, userURLs := range s.m {
+		for key, shortURLData := range userURLs {
+			res = append(res, shortURLData.toData(key, userID))
+		}
+	}
+
+	return res
+}
+
+// put восстанавливает запись как есть, перезаписывая предыдущее состояние короткой ссылки.
+func (s *inMemoryStore) put(data models.Data) {
+	key := linkKey{domain: data.Domain, shortURL: data.ShortURL}
+	s.userURLs(data.UserID)[key] = &ShortURLData{
+		FullURL:     data.OriginalURL,
+		Deleted:     data.Deleted,
+		LinkMeta:    copyMeta(data.LinkMeta),
+		LinkOptions: copyOptions(data.LinkOptions),
+		DeletedAt:   data.DeletedAt,
+		Revisions:   slices.Clone(data.Revisions),
+	}
+}
+
+func (d *ShortURLData) toData(key linkKey, userID string) models.Data {
+	return models.Data{
+		ShortURL:    key.shortURL,
+		OriginalURL: d.FullURL,
+		UserID:      userID,
+		Deleted:     d.Deleted,
+		LinkMeta:    copyMeta(d.LinkMeta),
+		LinkOptions: copyOptions(d.LinkOptions),
+		DeletedAt:   d.DeletedAt,
+		Revisions:   slices.Clone(d.Revisions),
+	}
+}
+
+func copyAPIKey(key *models.APIKey) models.APIKey {
+	res := *key
+	res.Scopes = slices.Clone(key.Scopes)
+
+	return res
+}
+
+func copyMeta(meta models.LinkMeta) models.LinkMeta {
+	meta.Tags = slices.Clone(meta.Tags)
+	return meta
+}
+
+func copyOptions(opts models.LinkOptions) models.LinkOptions {
+	opts.QueryParams = maps.Clone(opts.QueryParams)
+	opts.Targets = slices.Clone(opts.Targets)
+	opts.Rules = slices.Clone(opts.Rules)
+	return opts
+}
@@ -0,0 +1,283 @@
+package store
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/a-bondar/go-url-shortener/internal/app/models"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	testUserID = "user-1"
+	testDomain = "go.brand.example"
+)
+
+func TestInMemoryStoreScopesLinkChangesToDomain(t *testing.T) {
+	ctx := context.Background()
+	s := newInMemoryStore()
+
+	_, err := s.SaveURL(ctx, "https://main.example", "abc", testUserID, models.LinkMeta{}, models.LinkOptions{})
+	require.NoError(t, err)
+	_, err = s.SaveURL(ctx, "https://brand.example", "abc", testUserID, models.LinkMeta{},
+		models.LinkOptions{Domain: testDomain})
+	require.NoError(t, err)
+
+	updated, err := s.UpdateURL(ctx, testDomain, "abc", testUserID, "https://brand.example/new")
+	require.NoError(t, err)
+	assert.Equal(t, testDomain, updated.Domain)
+
+	main, err := s.GetUserURL(ctx, "", "abc", testUserID)
+	require.NoError(t, err)
+	assert.Equal(t, "https://main.example", main.OriginalURL)
+
+	require.NoError(t, s.DeleteURLs(ctx, "", []string{"abc"}, testUserID))
+
+	main, err = s.GetURL(ctx, "", "abc")
+	require.NoError(t, err)
+	assert.True(t, main.Deleted)
+
+	brand, err := s.GetURL(ctx, testDomain, "abc")
+	require.NoError(t, err)
+	assert.False(t, brand.Deleted)
+
+	_, err = s.GetUserURL(ctx, "", "abc", testUserID)
+	require.ErrorIs(t, err, ErrURLNotFound)
+
+	require.NoError(t, s.RestoreURLs(ctx, "", []string{"abc"}, testUserID))
+	_, err = s.GetUserURL(ctx, "", "abc", testUserID)
+	assert.NoError(t, err)
+}
+
+func TestInMemoryStoreTransferURLsScopedToDomain(t *testing.T) {
+	ctx := context.Background()
+	s := newInMemoryStore()
+
+	_, err := s.SaveURL(ctx, "https://main.example", "abc", testUserID, models.LinkMeta{}, models.LinkOptions{})
+	require.NoError(t, err)
+	_, err = s.SaveURL(ctx, "https://brand.example", "abc", testUserID, models.LinkMeta{},
+		models.LinkOptions{Domain: testDomain})
+	require.NoError(t, err)
+
+	transferred, err := s.TransferURLs(ctx, testDomain, []string{"abc"}, testUserID, "user-2")
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), transferred)
+
+	_, err = s.GetUserURL(ctx, "", "abc", testUserID)
+	require.NoError(t, err)
+	_, err = s.GetUserURL(ctx, testDomain, "abc", "user-2")
+	assert.NoError(t, err)
+}
+
+func TestInMemoryStoreRestoreSkipsTakenCode(t *testing.T) {
+	ctx := context.Background()
+	s := newInMemoryStore()
+
+	_, err := s.SaveURL(ctx, "https://a.example", "abc", testUserID, models.LinkMeta{}, models.LinkOptions{})
+	require.NoError(t, err)
+	require.NoError(t, s.DeleteURLs(ctx, "", []string{"abc"}, testUserID))
+
+	_, err = s.SaveURL(ctx, "https://b.example", "abc", "user-2", models.LinkMeta{}, models.LinkOptions{})
+	require.NoError(t, err)
+
+	require.NoError(t, s.RestoreURLs(ctx, "", []string{"abc"}, testUserID))
+
+	active, err := s.GetURL(ctx, "", "abc")
+	require.NoError(t, err)
+	assert.Equal(t, "user-2", active.UserID)
+
+	_, err = s.GetUserURL(ctx, "", "abc", testUserID)
+	assert.ErrorIs(t, err, ErrURLNotFound)
+}
+
+func TestInMemoryStoreConsumeClickIsAtomic(t *testing.T) {
+	ctx := context.Background()
+	s := newInMemoryStore()
+
+	_, err := s.SaveURL(ctx, "https://limited.example", "lim", testUserID, models.LinkMeta{},
+		models.LinkOptions{MaxClicks: 10, ClicksLeft: 10})
+	require.NoError(t, err)
+
+	var (
+		wg       sync.WaitGroup
+		consumed atomic.Int64
+	)
+	for range 50 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			if s.ConsumeClick(ctx, "", "lim") == nil {
+				consumed.Add(1)
+			}
+
+			// Чтение и другие изменения идут параллельно списаниям под тем же замком.
+			_, _ = s.GetURL(ctx, "", "lim")
+			_ = s.CreateWebhook(ctx, models.Webhook{ID: uuid.NewString(), UserID: testUserID})
+		}()
+	}
+	wg.Wait()
+
+	assert.Equal(t, int64(10), consumed.Load())
+	assert.ErrorIs(t, s.ConsumeClick(ctx, "", "lim"), ErrNoClicksLeft)
+}
+
+func TestInMemoryStoreSearchURLsPages(t *testing.T) {
+	ctx := context.Background()
+	s := newInMemoryStore()
+
+	for _, code := range []string{"c", "a", "b"} {
+		_, err := s.SaveURL(ctx, "https://"+code+".example", code, testUserID, models.LinkMeta{}, models.LinkOptions{})
+		require.NoError(t, err)
+	}
+	_, err := s.SaveURL(ctx, "https://brand.example", "a", testUserID, models.LinkMeta{},
+		models.LinkOptions{Domain: testDomain})
+	require.NoError(t, err)
+
+	var codes []string
+	search := models.LinkSearch{Limit: 2}
+	for {
+		links, err := s.SearchURLs(ctx, search)
+		require.NoError(t, err)
+		if len(links) == 0 {
+			break
+		}
+
+		for _, link := range links {
+			codes = append(codes, link.Domain+"/"+link.ShortURL)
+		}
+
+		last := links[len(links)-1]
+		search.After = &models.LinkCursor{Domain: last.Domain, ShortURL: last.ShortURL, UserID: last.UserID}
+	}
+
+	assert.Equal(t, []string{"/a", "/b", "/c", testDomain + "/a"}, codes)
+}
+
+func TestInMemoryStoreUpdateURLKeepsDestinationsUnique(t *testing.T) {
+	ctx := context.Background()
+	s := newInMemoryStore()
+
+	for code, fullURL := range map[string]string{"own": "https://a.example", "trash": "https://trash.example"} {
+		_, err := s.SaveURL(ctx, fullURL, code, testUserID, models.LinkMeta{}, models.LinkOptions{})
+		require.NoError(t, err)
+	}
+	_, err := s.SaveURL(ctx, "https://other.example", "other", "user-2", models.LinkMeta{}, models.LinkOptions{})
+	require.NoError(t, err)
+	_, err = s.SaveURL(ctx, "https://brand.example", "brand", testUserID, models.LinkMeta{},
+		models.LinkOptions{Domain: testDomain})
+	require.NoError(t, err)
+	require.NoError(t, s.DeleteURLs(ctx, "", []string{"trash"}, testUserID))
+
+	_, err = s.UpdateURL(ctx, "", "own", testUserID, "https://other.example")
+	require.ErrorIs(t, err, ErrURLExists)
+	_, err = s.UpdateURL(ctx, "", "own", testUserID, "https://trash.example")
+	require.ErrorIs(t, err, ErrURLExists)
+
+	// Адрес занят только в своём домене.
+	updated, err := s.UpdateURL(ctx, "", "own", testUserID, "https://brand.example")
+	require.NoError(t, err)
+	assert.Equal(t, "https://brand.example", updated.OriginalURL)
+	require.Len(t, updated.Revisions, 1)
+	assert.Equal(t, "https://a.example", updated.Revisions[0].OriginalURL)
+
+	_, err = s.UpdateURL(ctx, "", "trash", testUserID, "https://new.example")
+	assert.ErrorIs(t, err, ErrURLNotFound)
+}
+
+func TestInMemoryStoreConsumeRefreshTokenOnce(t *testing.T) {
+	ctx := context.Background()
+	s := newInMemoryStore()
+	token := models.RevokedSession{ID: "token-1", UserID: testUserID, ExpiresAt: time.Now().Add(time.Hour)}
+
+	var consumed atomic.Int64
+	var wg sync.WaitGroup
+	for range 20 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			if ok, err := s.ConsumeRefreshToken(ctx, token); err == nil && ok {
+				consumed.Add(1)
+			}
+		}()
+	}
+	wg.Wait()
+
+	assert.Equal(t, int64(1), consumed.Load())
+
+	revoked, err := s.IsSessionRevoked(ctx, token.ID)
+	require.NoError(t, err)
+	assert.True(t, revoked)
+}
+
+func TestInMemoryStoreClaimDeliveriesLeases(t *testing.T) {
+	ctx := context.Background()
+	s := newInMemoryStore()
+	now := time.Now()
+
+	require.NoError(t, s.CreateWebhook(ctx, models.Webhook{
+		ID: "hook-1", UserID: testUserID, URL: "https://hooks.example/", Secret: "whsec_test",
+	}))
+	require.NoError(t, s.EnqueueDeliveries(ctx, []models.WebhookDelivery{
+		{ID: "late", WebhookID: "hook-1", NextAttemptAt: now.Add(-time.Second)},
+		{ID: "early", WebhookID: "hook-1", NextAttemptAt: now.Add(-time.Minute)},
+		{ID: "future", WebhookID: "hook-1", NextAttemptAt: now.Add(time.Minute)},
+	}))
+
+	claimed, err := s.ClaimDeliveries(ctx, now, time.Hour, 1)
+	require.NoError(t, err)
+	require.Len(t, claimed, 1)
+	assert.Equal(t, "early", claimed[0].ID)
+	assert.Equal(t, "https://hooks.example/", claimed[0].URL)
+	assert.Equal(t, "whsec_test", claimed[0].Secret)
+
+	// Взятая доставка до конца аренды не выдаётся повторно.
+	claimed, err = s.ClaimDeliveries(ctx, now, time.Hour, 10)
+	require.NoError(t, err)
+	require.Len(t, claimed, 1)
+	assert.Equal(t, "late", claimed[0].ID)
+
+	claimed, err = s.ClaimDeliveries(ctx, now.Add(2*time.Hour), time.Hour, 10)
+	require.NoError(t, err)
+	assert.Len(t, claimed, 3)
+}
+
+func TestInMemoryStoreTransferURLsKeepsCollidingLinks(t *testing.T) {
+	ctx := context.Background()
+	s := newInMemoryStore()
+
+	for code, fullURL := range map[string]string{"abc": "https://a.example", "def": "https://d.example"} {
+		_, err := s.SaveURL(ctx, fullURL, code, testUserID, models.LinkMeta{}, models.LinkOptions{})
+		require.NoError(t, err)
+	}
+
+	// У нового владельца в корзине ссылка с тем же кодом и историей адресов.
+	_, err := s.SaveURL(ctx, "https://old.example", "abc", "user-2", models.LinkMeta{}, models.LinkOptions{})
+	require.NoError(t, err)
+	_, err = s.UpdateURL(ctx, "", "abc", "user-2", "https://older.example")
+	require.NoError(t, err)
+	require.NoError(t, s.DeleteURLs(ctx, "", []string{"abc"}, "user-2"))
+
+	transferred, err := s.TransferURLs(ctx, "", []string{"abc", "def"}, testUserID, "user-2")
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), transferred)
+
+	kept, err := s.GetUserURL(ctx, "", "abc", testUserID)
+	require.NoError(t, err)
+	assert.Equal(t, "https://a.example", kept.OriginalURL)
+
+	trashed, err := s.GetURLs(ctx, "user-2", models.URLsFilter{Deleted: true})
+	require.NoError(t, err)
+	require.Len(t, trashed, 1)
+	assert.Equal(t, "https://older.example", trashed[0].OriginalURL)
+	assert.Len(t, trashed[0].Revisions, 1)
+
+	_, err = s.GetUserURL(ctx, "", "def", "user-2")
+	assert.NoError(t, err)
+}
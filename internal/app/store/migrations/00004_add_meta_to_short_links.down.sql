@@ -0,0 +1,10 @@
+BEGIN TRANSACTION;
+
+DROP INDEX IF EXISTS short_links_tags_idx;
+
+ALTER TABLE short_links
+    DROP COLUMN title,
+    DROP COLUMN tags,
+    DROP COLUMN note;
+
+COMMIT;
\ No newline at end of file
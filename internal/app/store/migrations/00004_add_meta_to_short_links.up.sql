@@ -0,0 +1,11 @@
+BEGIN TRANSACTION;
+
+ALTER TABLE short_links
+    ADD COLUMN title TEXT NOT NULL DEFAULT '',
+    ADD COLUMN tags  TEXT[] NOT NULL DEFAULT '{}',
+    ADD COLUMN note  TEXT NOT NULL DEFAULT '';
+
+CREATE INDEX short_links_tags_idx
+    ON short_links USING GIN (tags);
+
+COMMIT;
\ No newline at end of file
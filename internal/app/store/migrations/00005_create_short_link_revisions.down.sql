@@ -0,0 +1,5 @@
+BEGIN TRANSACTION;
+
+DROP TABLE short_link_revisions;
+
+COMMIT;
\ No newline at end of file
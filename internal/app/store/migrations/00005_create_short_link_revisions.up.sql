@@ -0,0 +1,14 @@
+BEGIN TRANSACTION;
+
+CREATE TABLE short_link_revisions
+(
+    id            INT PRIMARY KEY GENERATED ALWAYS AS IDENTITY,
+    short_link_id INT          NOT NULL REFERENCES short_links (id) ON DELETE CASCADE,
+    original_url  VARCHAR(255) NOT NULL,
+    changed_at    TIMESTAMPTZ  NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX short_link_revisions_short_link_id_idx
+    ON short_link_revisions (short_link_id);
+
+COMMIT;
\ No newline at end of file
@@ -0,0 +1,6 @@
+BEGIN TRANSACTION;
+
+ALTER TABLE short_links
+    DROP COLUMN deleted_at;
+
+COMMIT;
\ No newline at end of file
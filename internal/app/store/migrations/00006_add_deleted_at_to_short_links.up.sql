@@ -0,0 +1,10 @@
+BEGIN TRANSACTION;
+
+ALTER TABLE short_links
+    ADD COLUMN deleted_at TIMESTAMPTZ;
+
+UPDATE short_links
+SET deleted_at = NOW()
+WHERE deleted = TRUE;
+
+COMMIT;
\ No newline at end of file
@@ -0,0 +1,7 @@
+BEGIN TRANSACTION;
+
+DROP TABLE api_keys;
+
+DROP TABLE users;
+
+COMMIT;
\ No newline at end of file
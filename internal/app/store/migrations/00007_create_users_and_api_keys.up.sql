@@ -0,0 +1,25 @@
+BEGIN TRANSACTION;
+
+CREATE TABLE users
+(
+    id         UUID PRIMARY KEY,
+    name       TEXT        NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE TABLE api_keys
+(
+    id         UUID PRIMARY KEY,
+    user_id    UUID        NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    name       TEXT        NOT NULL,
+    prefix     TEXT        NOT NULL,
+    key_hash   TEXT        NOT NULL UNIQUE,
+    scopes     TEXT[]      NOT NULL DEFAULT '{}',
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    revoked_at TIMESTAMPTZ
+);
+
+CREATE INDEX api_keys_user_id_idx
+    ON api_keys (user_id);
+
+COMMIT;
\ No newline at end of file
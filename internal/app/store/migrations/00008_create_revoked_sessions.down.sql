@@ -0,0 +1,5 @@
+BEGIN TRANSACTION;
+
+DROP TABLE revoked_sessions;
+
+COMMIT;
\ No newline at end of file
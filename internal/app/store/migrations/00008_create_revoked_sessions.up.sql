@@ -0,0 +1,14 @@
+BEGIN TRANSACTION;
+
+CREATE TABLE revoked_sessions
+(
+    id         UUID PRIMARY KEY,
+    user_id    UUID        NOT NULL,
+    revoked_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    expires_at TIMESTAMPTZ NOT NULL
+);
+
+CREATE INDEX revoked_sessions_expires_at_idx
+    ON revoked_sessions (expires_at);
+
+COMMIT;
\ No newline at end of file
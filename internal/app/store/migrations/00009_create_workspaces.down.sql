@@ -0,0 +1,7 @@
+BEGIN TRANSACTION;
+
+DROP TABLE workspace_members;
+
+DROP TABLE workspaces;
+
+COMMIT;
\ No newline at end of file
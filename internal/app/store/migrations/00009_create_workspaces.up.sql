@@ -0,0 +1,22 @@
+BEGIN TRANSACTION;
+
+CREATE TABLE workspaces
+(
+    id         UUID PRIMARY KEY,
+    name       TEXT        NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE TABLE workspace_members
+(
+    workspace_id UUID        NOT NULL REFERENCES workspaces (id) ON DELETE CASCADE,
+    user_id      UUID        NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    role         TEXT        NOT NULL CHECK (role IN ('owner', 'editor', 'viewer')),
+    added_at     TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (workspace_id, user_id)
+);
+
+CREATE INDEX workspace_members_user_id_idx
+    ON workspace_members (user_id);
+
+COMMIT;
\ No newline at end of file
@@ -0,0 +1,6 @@
+BEGIN TRANSACTION;
+
+ALTER TABLE short_links
+    ADD COLUMN password_hash TEXT;
+
+COMMIT;
\ No newline at end of file
@@ -0,0 +1,33 @@
+BEGIN TRANSACTION;
+
+-- Без домена адреса и коды снова должны быть уникальны во всей таблице.
+-- Если одинаковые ссылки есть на нескольких доменах, откат останавливается,
+-- а лишние ссылки нужно удалить вручную.
+DO $$
+BEGIN
+    IF EXISTS (SELECT 1 FROM short_links GROUP BY original_url HAVING COUNT(*) > 1) THEN
+        RAISE EXCEPTION 'cannot drop link domains: the same original_url is shortened on several domains';
+    END IF;
+
+    IF EXISTS (SELECT 1 FROM short_links WHERE deleted = FALSE GROUP BY short_url HAVING COUNT(*) > 1) THEN
+        RAISE EXCEPTION 'cannot drop link domains: the same short_url is active on several domains';
+    END IF;
+END
+$$;
+
+ALTER TABLE short_links
+    DROP CONSTRAINT short_links_domain_original_url_key;
+
+ALTER TABLE short_links
+    ADD CONSTRAINT short_links_original_url_key UNIQUE (original_url);
+
+DROP INDEX unique_short_url_when_not_deleted;
+
+CREATE UNIQUE INDEX unique_short_url_when_not_deleted
+    ON short_links (short_url)
+    WHERE deleted = FALSE;
+
+ALTER TABLE short_links
+    DROP COLUMN domain;
+
+COMMIT;
\ No newline at end of file
@@ -0,0 +1,18 @@
+BEGIN TRANSACTION;
+
+ALTER TABLE short_links
+    ADD COLUMN domain TEXT NOT NULL DEFAULT '';
+
+DROP INDEX unique_short_url_when_not_deleted;
+
+CREATE UNIQUE INDEX unique_short_url_when_not_deleted
+    ON short_links (domain, short_url)
+    WHERE deleted = FALSE;
+
+ALTER TABLE short_links
+    DROP CONSTRAINT short_links_original_url_key;
+
+ALTER TABLE short_links
+    ADD CONSTRAINT short_links_domain_original_url_key UNIQUE (domain, original_url);
+
+COMMIT;
\ No newline at end of file
@@ -0,0 +1,6 @@
+BEGIN TRANSACTION;
+
+ALTER TABLE short_links
+    DROP COLUMN query_params;
+
+COMMIT;
\ No newline at end of file
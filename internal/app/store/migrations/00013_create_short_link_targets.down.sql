@@ -0,0 +1,8 @@
+BEGIN TRANSACTION;
+
+DROP TABLE short_link_targets;
+
+ALTER TABLE short_links
+    DROP COLUMN sticky_targets;
+
+COMMIT;
\ No newline at end of file
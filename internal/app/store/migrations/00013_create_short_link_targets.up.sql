@@ -0,0 +1,16 @@
+BEGIN TRANSACTION;
+
+ALTER TABLE short_links
+    ADD COLUMN sticky_targets BOOLEAN NOT NULL DEFAULT FALSE;
+
+CREATE TABLE short_link_targets
+(
+    short_link_id INT    NOT NULL REFERENCES short_links (id) ON DELETE CASCADE,
+    position      INT    NOT NULL,
+    url           TEXT   NOT NULL,
+    weight        INT    NOT NULL CHECK (weight > 0),
+    clicks        BIGINT NOT NULL DEFAULT 0,
+    PRIMARY KEY (short_link_id, position)
+);
+
+COMMIT;
\ No newline at end of file
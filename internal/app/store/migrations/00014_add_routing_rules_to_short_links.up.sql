@@ -0,0 +1,6 @@
+BEGIN TRANSACTION;
+
+ALTER TABLE short_links
+    ADD COLUMN routing_rules JSONB NOT NULL DEFAULT '[]';
+
+COMMIT;
\ No newline at end of file
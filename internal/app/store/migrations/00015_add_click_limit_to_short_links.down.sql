@@ -0,0 +1,7 @@
+BEGIN TRANSACTION;
+
+ALTER TABLE short_links
+    DROP COLUMN max_clicks,
+    DROP COLUMN clicks_left;
+
+COMMIT;
\ No newline at end of file
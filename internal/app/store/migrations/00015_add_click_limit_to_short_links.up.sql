@@ -0,0 +1,7 @@
+BEGIN TRANSACTION;
+
+ALTER TABLE short_links
+    ADD COLUMN max_clicks INTEGER NOT NULL DEFAULT 0 CHECK (max_clicks >= 0),
+    ADD COLUMN clicks_left INTEGER NOT NULL DEFAULT 0;
+
+COMMIT;
\ No newline at end of file
@@ -0,0 +1,6 @@
+BEGIN TRANSACTION;
+
+ALTER TABLE short_links
+    DROP COLUMN not_before;
+
+COMMIT;
\ No newline at end of file
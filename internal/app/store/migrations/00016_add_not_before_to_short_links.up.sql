@@ -0,0 +1,6 @@
+BEGIN TRANSACTION;
+
+ALTER TABLE short_links
+    ADD COLUMN not_before TIMESTAMPTZ;
+
+COMMIT;
\ No newline at end of file
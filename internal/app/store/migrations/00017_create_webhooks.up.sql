@@ -0,0 +1,36 @@
+BEGIN TRANSACTION;
+
+CREATE TABLE webhooks
+(
+    id         UUID PRIMARY KEY,
+    user_id    UUID        NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    url        TEXT        NOT NULL,
+    events     TEXT[]      NOT NULL,
+    secret     TEXT        NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX webhooks_user_id_idx
+    ON webhooks (user_id);
+
+CREATE TABLE webhook_deliveries
+(
+    id              UUID PRIMARY KEY,
+    webhook_id      UUID        NOT NULL REFERENCES webhooks (id) ON DELETE CASCADE,
+    event           TEXT        NOT NULL,
+    payload         JSONB       NOT NULL,
+    attempts        INTEGER     NOT NULL DEFAULT 0,
+    next_attempt_at TIMESTAMPTZ NOT NULL,
+    last_error      TEXT        NOT NULL DEFAULT '',
+    dead            BOOLEAN     NOT NULL DEFAULT FALSE,
+    created_at      TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX webhook_deliveries_due_idx
+    ON webhook_deliveries (next_attempt_at)
+    WHERE dead = FALSE;
+
+CREATE INDEX webhook_deliveries_webhook_id_idx
+    ON webhook_deliveries (webhook_id);
+
+COMMIT;
\ No newline at end of file
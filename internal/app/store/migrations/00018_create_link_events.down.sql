@@ -0,0 +1,5 @@
+BEGIN TRANSACTION;
+
+DROP TABLE link_events;
+
+COMMIT;
\ No newline at end of file
@@ -0,0 +1,15 @@
+BEGIN TRANSACTION;
+
+CREATE TABLE link_events
+(
+    id           BIGSERIAL PRIMARY KEY,
+    event_type   TEXT        NOT NULL,
+    short_url    TEXT        NOT NULL,
+    domain       TEXT        NOT NULL,
+    user_id      UUID,
+    original_url TEXT        NOT NULL,
+    deleted      BOOLEAN     NOT NULL,
+    created_at   TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+COMMIT;
\ No newline at end of file
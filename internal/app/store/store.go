@@ -3,38 +3,135 @@ package store
 import (
 	"context"
 	"errors"
+	"time"
 
+	"github.com/a-bondar/go-url-shortener/internal/app/models"
 	"go.uber.org/zap"
 )
 
 var (
-	ErrUserHasNoURLs = errors.New("user has no URLs")
-	ErrURLNotFound   = errors.New("URL not found for the given short URL")
+	ErrUserHasNoURLs     = errors.New("user has no URLs")
+	ErrURLNotFound       = errors.New("URL not found for the given short URL")
+	ErrURLExists         = errors.New("original URL is already shortened")
+	ErrSchemaMismatch    = errors.New("DB schema version does not match the application")
+	ErrUserNotFound      = errors.New("user not found")
+	ErrAPIKeyNotFound    = errors.New("API key not found")
+	ErrWorkspaceNotFound = errors.New("workspace not found")
+	ErrMemberNotFound    = errors.New("workspace member not found")
+	ErrNoClicksLeft      = errors.New("short URL has no clicks left")
+	ErrWebhookNotFound   = errors.New("webhook not found")
+	ErrDeliveryNotFound  = errors.New("webhook delivery not found")
+	// ErrEventsUnsupported — поток событий ведётся только в БД, где он пишется в транзакции изменения.
+	ErrEventsUnsupported = errors.New("link events require the database store")
+	ErrUnknownRecordKind = errors.New("unknown record kind")
 )
 
 type Config struct {
 	DatabaseDSN     string
 	FileStoragePath string
+	// AutoMigrate накатывает миграции БД при открытии хранилища.
+	AutoMigrate bool
+	DB          DBOptions
+}
+
+// DBOptions — настройки пула соединений и таймаутов БД; нулевые значения оставляют умолчания pgx
+// и отключают соответствующее ограничение.
+type DBOptions struct {
+	MaxConns        int32
+	MaxConnLifetime time.Duration
+	MaxConnIdleTime time.Duration
+	// StatementTimeout прерывает запрос на стороне Postgres, QueryTimeout — ожидание ответа
+	// в одном вызове метода хранилища.
+	StatementTimeout time.Duration
+	QueryTimeout     time.Duration
+	// ConnectTimeout — сколько при старте повторять подключение и миграции, пока БД недоступна.
+	ConnectTimeout time.Duration
 }
 
 type Store interface {
-	SaveURL(ctx context.Context, fullURL string, shortURL string, userID string) (string, error)
-	GetURL(ctx context.Context, shortURL string) (string, bool, error)
-	GetURLs(ctx context.Context, userID string) (map[string]string, error)
-	DeleteURLs(ctx context.Context, urls []string, userID string) error
-	CleanupDeletedURLs(ctx context.Context) error
+	SaveURL(ctx context.Context, fullURL string, shortURL string, userID string,
+		meta models.LinkMeta, opts models.LinkOptions) (string, error)
+	GetURL(ctx context.Context, domain string, shortURL string) (models.Data, error)
+	GetURLs(ctx context.Context, userID string, filter models.URLsFilter) ([]models.Data, error)
+	GetUserURL(ctx context.Context, domain string, shortURL string, userID string) (models.Data, error)
+	IncrementTargetClicks(ctx context.Context, domain string, shortURL string, target int) error
+	ConsumeClick(ctx context.Context, domain string, shortURL string) error
+	UpdateURLMeta(ctx context.Context,
+		domain string, shortURL string, userID string, patch models.LinkMetaPatch) (models.Data, error)
+	UpdateURL(ctx context.Context, domain string, shortURL string, userID string, fullURL string) (models.Data, error)
+	GetURLRevisions(ctx context.Context, domain string, shortURL string, userID string) ([]models.URLRevision, error)
+	DeleteURLs(ctx context.Context, domain string, urls []string, userID string) error
+	RestoreURLs(ctx context.Context, domain string, urls []string, userID string) error
+	CleanupDeletedURLs(ctx context.Context, deletedBefore time.Time) error
 	SaveURLsBatch(ctx context.Context, urls map[string]string, userID string) (map[string]string, error)
+	ClaimURLs(ctx context.Context, fromUserID string, toUserID string) (int64, error)
+	CreateUser(ctx context.Context, user models.User) error
+	GetUser(ctx context.Context, userID string) (models.User, error)
+	CreateAPIKey(ctx context.Context, key models.APIKey) error
+	GetAPIKeyByHash(ctx context.Context, keyHash string) (models.APIKey, error)
+	GetAPIKeys(ctx context.Context, userID string) ([]models.APIKey, error)
+	RevokeAPIKey(ctx context.Context, keyID string, userID string) error
+	RevokeSession(ctx context.Context, session models.RevokedSession) error
+	IsSessionRevoked(ctx context.Context, sessionID string) (bool, error)
+	// ConsumeRefreshToken отмечает refresh-токен использованным и сообщает, не был ли он использован раньше.
+	ConsumeRefreshToken(ctx context.Context, token models.RevokedSession) (bool, error)
+	CleanupRevokedSessions(ctx context.Context, expiredBefore time.Time) error
+	CreateWorkspace(ctx context.Context, workspace models.Workspace, owner models.WorkspaceMember) error
+	GetUserWorkspaces(ctx context.Context, userID string) ([]models.HandleWorkspaceResponse, error)
+	GetWorkspaceMembers(ctx context.Context, workspaceID string) ([]models.WorkspaceMember, error)
+	SaveWorkspaceMember(ctx context.Context, member models.WorkspaceMember) error
+	RemoveWorkspaceMember(ctx context.Context, workspaceID string, userID string) error
+	TransferURLs(ctx context.Context,
+		domain string, urls []string, fromOwnerID string, toOwnerID string) (int64, error)
+	CreateWebhook(ctx context.Context, webhook models.Webhook) error
+	GetWebhooks(ctx context.Context, userID string) ([]models.Webhook, error)
+	DeleteWebhook(ctx context.Context, webhookID string, userID string) error
+	EnqueueDeliveries(ctx context.Context, deliveries []models.WebhookDelivery) error
+	ClaimDeliveries(ctx context.Context, now time.Time, lease time.Duration, limit int) ([]models.WebhookDelivery, error)
+	UpdateDelivery(ctx context.Context, delivery models.WebhookDelivery) error
+	DeleteDelivery(ctx context.Context, deliveryID string) error
+	GetDeadDeliveries(ctx context.Context, userID string) ([]models.WebhookDelivery, error)
+	RequeueDelivery(ctx context.Context, deliveryID string, userID string, now time.Time) error
+	GetLinkEvents(ctx context.Context, after int64, limit int) ([]models.LinkEvent, error)
+	SearchURLs(ctx context.Context, search models.LinkSearch) ([]models.Data, error)
+	GetStats(ctx context.Context) (models.StoreStats, error)
+	// ReadRecords, WriteRecords и CountRecords переносят данные между хранилищами как есть,
+	// с идентификаторами, владельцами, флагами удаления и счётчиками.
+	ReadRecords(ctx context.Context, kind string, after string, limit int) (models.RecordPage, error)
+	WriteRecords(ctx context.Context, records []models.Record) (models.RecordsWriteResult, error)
+	CountRecords(ctx context.Context, kind string) (int64, error)
 	Ping(ctx context.Context) error
+	CheckMigrations(ctx context.Context) error
 	Close()
 }
 
+// writeOutcome — что стало с записью при переносе.
+type writeOutcome int
+
+const (
+	outcomeWritten writeOutcome = iota
+	outcomePresent
+	outcomeConflict
+)
+
+func countOutcome(res *models.RecordsWriteResult, outcome writeOutcome, rec models.Record) {
+	switch outcome {
+	case outcomeWritten:
+		res.Written++
+	case outcomePresent:
+		res.Present++
+	case outcomeConflict:
+		res.Conflicts = append(res.Conflicts, rec)
+	}
+}
+
 func NewStore(ctx context.Context, cfg Config, logger *zap.Logger) (Store, error) {
 	if cfg.DatabaseDSN != "" {
-		return newDBStore(ctx, cfg.DatabaseDSN, logger)
+		return newDBStore(ctx, cfg, logger)
 	}
 
 	if cfg.FileStoragePath != "" {
-		return newFileStore(ctx, cfg.FileStoragePath)
+		return newFileStore(cfg.FileStoragePath)
 	}
 
 	return newInMemoryStore(), nil
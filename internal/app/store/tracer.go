@@ -0,0 +1,73 @@
+package store
+
+import (
+	"context"
+	"strings"
+
+	"github.com/a-bondar/go-url-shortener/internal/app/logger"
+	"github.com/a-bondar/go-url-shortener/internal/app/tracing"
+	"github.com/jackc/pgx/v5"
+	"go.uber.org/zap"
+)
+
+// queryTracer открывает спан на каждый запрос и батч pgx и пишет их в лог
+// с идентификаторами запроса из контекста.
+type queryTracer struct {
+	logger *zap.Logger
+}
+
+func (t *queryTracer) TraceQueryStart(ctx context.Context, _ *pgx.Conn, data pgx.TraceQueryStartData) context.Context {
+	ctx, span := tracing.Start(ctx, "pgx.query")
+	span.SetAttribute("db.system", "postgresql")
+	span.SetAttribute("db.statement", compactSQL(data.SQL))
+
+	return ctx
+}
+
+func (t *queryTracer) TraceQueryEnd(ctx context.Context, _ *pgx.Conn, data pgx.TraceQueryEndData) {
+	t.end(ctx, data.CommandTag.RowsAffected(), data.Err)
+}
+
+func (t *queryTracer) TraceBatchStart(ctx context.Context, _ *pgx.Conn, data pgx.TraceBatchStartData) context.Context {
+	ctx, span := tracing.Start(ctx, "pgx.batch")
+	span.SetAttribute("db.system", "postgresql")
+	span.SetAttribute("db.batch_size", data.Batch.Len())
+
+	return ctx
+}
+
+func (t *queryTracer) TraceBatchQuery(ctx context.Context, _ *pgx.Conn, data pgx.TraceBatchQueryData) {
+	if data.Err == nil {
+		return
+	}
+
+	if span := tracing.SpanFromContext(ctx); span != nil {
+		span.RecordError(data.Err)
+	}
+
+	logger.FromContext(ctx, t.logger).Debug("Batch query failed",
+		zap.String("sql", compactSQL(data.SQL)), zap.Error(data.Err))
+}
+
+func (t *queryTracer) TraceBatchEnd(ctx context.Context, _ *pgx.Conn, data pgx.TraceBatchEndData) {
+	t.end(ctx, 0, data.Err)
+}
+
+func (t *queryTracer) end(ctx context.Context, rowsAffected int64, err error) {
+	span := tracing.SpanFromContext(ctx)
+	if span == nil {
+		return
+	}
+
+	span.SetAttribute("db.rows_affected", rowsAffected)
+	span.RecordError(err)
+	span.End()
+
+	logger.FromContext(ctx, t.logger).Debug("DB operation finished",
+		zap.String("span_id", span.SpanContext().SpanID), zap.Int64("rows_affected", rowsAffected), zap.Error(err))
+}
+
+// compactSQL схлопывает переводы строк и отступы многострочных запросов.
+func compactSQL(sql string) string {
+	return strings.Join(strings.Fields(sql), " ")
+}
@@ -0,0 +1,176 @@
+package tracing
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// StdoutExporter пишет каждый спан отдельной JSON-строкой.
+type StdoutExporter struct {
+	mu     sync.Mutex
+	enc    *json.Encoder
+	logger *zap.Logger
+}
+
+func NewStdoutExporter(w io.Writer, logger *zap.Logger) *StdoutExporter {
+	return &StdoutExporter{enc: json.NewEncoder(w), logger: logger}
+}
+
+func (e *StdoutExporter) ExportSpan(span SpanData) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if err := e.enc.Encode(span); err != nil {
+		e.logger.Error("Failed to export span", zap.Error(err))
+	}
+}
+
+const (
+	httpExporterQueueSize = 1024
+	httpExporterBatchSize = 100
+	httpExporterInterval  = 5 * time.Second
+)
+
+// HTTPExporter копит спаны и отправляет их пачками POST-запросом с JSON-массивом.
+// Подходит для локального коллектора, который принимает спаны по HTTP.
+type HTTPExporter struct {
+	endpoint string
+	client   *http.Client
+	logger   *zap.Logger
+	queue    chan SpanData
+	// stop закрывается при остановке вместо queue: спаны могут завершаться и после неё,
+	// а отправка в закрытый канал привела бы к панике.
+	stop     chan struct{}
+	stopOnce sync.Once
+	done     chan struct{}
+}
+
+func NewHTTPExporter(endpoint string, client *http.Client, logger *zap.Logger) *HTTPExporter {
+	e := &HTTPExporter{
+		endpoint: endpoint,
+		client:   client,
+		logger:   logger,
+		queue:    make(chan SpanData, httpExporterQueueSize),
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+
+	go e.run()
+
+	return e
+}
+
+// ExportSpan не блокирует вызывающего: если очередь переполнена или экспорт остановлен,
+// спан отбрасывается.
+func (e *HTTPExporter) ExportSpan(span SpanData) {
+	select {
+	case <-e.stop:
+		return
+	default:
+	}
+
+	select {
+	case <-e.stop:
+	case e.queue <- span:
+	default:
+		e.logger.Warn("Span queue is full, dropping span", zap.String("name", span.Name))
+	}
+}
+
+// Shutdown отправляет накопленные спаны и останавливает фоновую отправку.
+func (e *HTTPExporter) Shutdown(ctx context.Context) error {
+	e.stopOnce.Do(func() {
+		close(e.stop)
+	})
+
+	select {
+	case <-e.done:
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("failed to flush spans: %w", ctx.Err())
+	}
+}
+
+func (e *HTTPExporter) run() {
+	defer close(e.done)
+
+	ticker := time.NewTicker(httpExporterInterval)
+	defer ticker.Stop()
+
+	batch := make([]SpanData, 0, httpExporterBatchSize)
+	flush := func() {
+		if len(batch) == 0 {
+			return
+		}
+
+		if err := e.send(batch); err != nil {
+			e.logger.Error("Failed to export spans", zap.Error(err), zap.Int("count", len(batch)))
+		}
+		batch = batch[:0]
+	}
+
+	add := func(span SpanData) {
+		batch = append(batch, span)
+		if len(batch) >= httpExporterBatchSize {
+			flush()
+		}
+	}
+
+	for {
+		select {
+		case span := <-e.queue:
+			add(span)
+		case <-ticker.C:
+			flush()
+		case <-e.stop:
+			// Отправляем всё, что успело попасть в очередь до остановки.
+			for {
+				select {
+				case span := <-e.queue:
+					add(span)
+				default:
+					flush()
+					return
+				}
+			}
+		}
+	}
+}
+
+func (e *HTTPExporter) send(batch []SpanData) error {
+	body, err := json.Marshal(batch)
+	if err != nil {
+		return fmt.Errorf("failed to marshal spans: %w", err)
+	}
+
+	req, err := http.NewRequest(http.MethodPost, e.endpoint, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := e.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to send spans: %w", err)
+	}
+
+	defer func() {
+		if err := resp.Body.Close(); err != nil {
+			e.logger.Error("Failed to close response body", zap.Error(err))
+		}
+	}()
+
+	if resp.StatusCode >= http.StatusBadRequest {
+		return fmt.Errorf("collector responded with status %d", resp.StatusCode)
+	}
+
+	return nil
+}
@@ -0,0 +1,46 @@
+package tracing
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestHTTPExporterShutdown(t *testing.T) {
+	var (
+		mu       sync.Mutex
+		received []SpanData
+	)
+	collector := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var batch []SpanData
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&batch))
+
+		mu.Lock()
+		received = append(received, batch...)
+		mu.Unlock()
+	}))
+	defer collector.Close()
+
+	e := NewHTTPExporter(collector.URL, collector.Client(), zap.NewNop())
+	e.ExportSpan(SpanData{Name: "before"})
+
+	require.NoError(t, e.Shutdown(context.Background()))
+	require.NoError(t, e.Shutdown(context.Background()))
+
+	// Спаны, которые завершаются после остановки, отбрасываются без паники.
+	assert.NotPanics(t, func() {
+		e.ExportSpan(SpanData{Name: "after"})
+	})
+
+	mu.Lock()
+	defer mu.Unlock()
+	require.Len(t, received, 1)
+	assert.Equal(t, "before", received[0].Name)
+}
@@ -0,0 +1,81 @@
+package tracing
+
+import (
+	"context"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// TraceparentHeader — заголовок W3C Trace Context.
+const TraceparentHeader = "traceparent"
+
+const (
+	traceparentVersion = "00"
+	traceparentSampled = "01"
+	traceparentParts   = 4
+	invalidVersion     = "ff"
+)
+
+// Extract достаёт родительский спан из заголовка traceparent, если он корректен.
+func Extract(ctx context.Context, header http.Header) context.Context {
+	sc, ok := ParseTraceparent(header.Get(TraceparentHeader))
+	if !ok {
+		return ctx
+	}
+
+	return ContextWithRemoteSpanContext(ctx, sc)
+}
+
+// Inject записывает активный спан из ctx в заголовок traceparent.
+func Inject(ctx context.Context, header http.Header) {
+	sc := SpanContextFromContext(ctx)
+	if !sc.IsValid() {
+		return
+	}
+
+	header.Set(TraceparentHeader, FormatTraceparent(sc))
+}
+
+func FormatTraceparent(sc SpanContext) string {
+	return fmt.Sprintf("%s-%s-%s-%s", traceparentVersion, sc.TraceID, sc.SpanID, traceparentSampled)
+}
+
+// ParseTraceparent разбирает значение вида 00-<trace-id>-<parent-id>-<flags>.
+func ParseTraceparent(value string) (SpanContext, bool) {
+	parts := strings.Split(strings.TrimSpace(value), "-")
+	if len(parts) < traceparentParts {
+		return SpanContext{}, false
+	}
+
+	version, traceID, spanID, flags := parts[0], parts[1], parts[2], parts[3]
+	if !isHex(version, 1) || version == invalidVersion || !isHex(flags, 1) {
+		return SpanContext{}, false
+	}
+
+	// Версия 00 не допускает дополнительных полей.
+	if version == traceparentVersion && len(parts) != traceparentParts {
+		return SpanContext{}, false
+	}
+
+	if !isHex(traceID, traceIDSize) || !isHex(spanID, spanIDSize) || isZero(traceID) || isZero(spanID) {
+		return SpanContext{}, false
+	}
+
+	return SpanContext{TraceID: traceID, SpanID: spanID}, true
+}
+
+func isHex(s string, size int) bool {
+	if len(s) != size*2 || strings.ToLower(s) != s {
+		return false
+	}
+
+	_, err := hex.DecodeString(s)
+
+	return err == nil
+}
+
+func isZero(s string) bool {
+	return strings.Trim(s, "0") == ""
+}
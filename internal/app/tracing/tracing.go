@@ -0,0 +1,164 @@
+package tracing
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	traceIDSize = 16
+	spanIDSize  = 8
+)
+
+// SpanContext — идентификаторы, которые передаются между сервисами в заголовке traceparent.
+type SpanContext struct {
+	TraceID string
+	SpanID  string
+}
+
+func (sc SpanContext) IsValid() bool {
+	return sc.TraceID != "" && sc.SpanID != ""
+}
+
+// SpanData — завершённый спан в том виде, в котором он уходит в экспортёр.
+type SpanData struct {
+	TraceID      string         `json:"trace_id"`
+	SpanID       string         `json:"span_id"`
+	ParentSpanID string         `json:"parent_span_id,omitempty"`
+	Name         string         `json:"name"`
+	Start        time.Time      `json:"start"`
+	End          time.Time      `json:"end"`
+	Attributes   map[string]any `json:"attributes,omitempty"`
+	Error        string         `json:"error,omitempty"`
+}
+
+type Span struct {
+	mu    sync.Mutex
+	data  SpanData
+	ended bool
+}
+
+type Exporter interface {
+	ExportSpan(span SpanData)
+}
+
+type exporterHolder struct {
+	exporter Exporter
+}
+
+var globalExporter atomic.Pointer[exporterHolder]
+
+// SetExporter задаёт экспортёр для всех спанов процесса. nil отключает экспорт,
+// при этом идентификаторы трассировки продолжают прокидываться через контекст.
+func SetExporter(e Exporter) {
+	globalExporter.Store(&exporterHolder{exporter: e})
+}
+
+type spanKey struct{}
+
+type remoteKey struct{}
+
+// Start открывает дочерний спан текущего спана из ctx. Если спана в контексте нет,
+// родителем становится удалённый спан из traceparent, а без него начинается новая трасса.
+func Start(ctx context.Context, name string) (context.Context, *Span) {
+	parent := SpanContextFromContext(ctx)
+
+	span := &Span{data: SpanData{
+		TraceID:      parent.TraceID,
+		SpanID:       newID(spanIDSize),
+		ParentSpanID: parent.SpanID,
+		Name:         name,
+		Start:        time.Now(),
+	}}
+	if span.data.TraceID == "" {
+		span.data.TraceID = newID(traceIDSize)
+	}
+
+	return context.WithValue(ctx, spanKey{}, span), span
+}
+
+// SpanFromContext возвращает активный спан или nil.
+func SpanFromContext(ctx context.Context) *Span {
+	span, _ := ctx.Value(spanKey{}).(*Span)
+
+	return span
+}
+
+// SpanContextFromContext возвращает идентификаторы активного спана или удалённого родителя.
+func SpanContextFromContext(ctx context.Context) SpanContext {
+	if span := SpanFromContext(ctx); span != nil {
+		return span.SpanContext()
+	}
+
+	if sc, ok := ctx.Value(remoteKey{}).(SpanContext); ok {
+		return sc
+	}
+
+	return SpanContext{}
+}
+
+// ContextWithRemoteSpanContext запоминает родителя, пришедшего из другого сервиса.
+func ContextWithRemoteSpanContext(ctx context.Context, sc SpanContext) context.Context {
+	return context.WithValue(ctx, remoteKey{}, sc)
+}
+
+func (s *Span) SpanContext() SpanContext {
+	return SpanContext{TraceID: s.data.TraceID, SpanID: s.data.SpanID}
+}
+
+func (s *Span) SetName(name string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.data.Name = name
+}
+
+func (s *Span) SetAttribute(key string, value any) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.data.Attributes == nil {
+		s.data.Attributes = make(map[string]any)
+	}
+	s.data.Attributes[key] = value
+}
+
+func (s *Span) RecordError(err error) {
+	if err == nil {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.data.Error = err.Error()
+}
+
+// End завершает спан и отдаёт его экспортёру. Повторные вызовы ничего не делают.
+func (s *Span) End() {
+	s.mu.Lock()
+	if s.ended {
+		s.mu.Unlock()
+		return
+	}
+	s.ended = true
+	s.data.End = time.Now()
+	data := s.data
+	s.mu.Unlock()
+
+	if holder := globalExporter.Load(); holder != nil && holder.exporter != nil {
+		holder.exporter.ExportSpan(data)
+	}
+}
+
+func newID(size int) string {
+	b := make([]byte, size)
+	// Ошибка означает недоступный источник энтропии; трассировка не должна ронять запрос.
+	_, _ = rand.Read(b)
+
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,56 @@
+package webhooks
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"syscall"
+	"time"
+)
+
+const (
+	dialTimeout         = 5 * time.Second
+	tlsHandshakeTimeout = 5 * time.Second
+)
+
+// ErrForbiddenAddress — адрес вебхука ведёт во внутреннюю сеть сервиса.
+var ErrForbiddenAddress = errors.New("webhook address is not public")
+
+// NewClient возвращает HTTP-клиент для отправки вебхуков. Адрес получателя проверяется
+// при каждом подключении, уже после разрешения имени: так его не обойти ни DNS-записью,
+// указывающей внутрь, ни переадресацией. Прокси не используется — он подключался бы сам.
+func NewClient() *http.Client {
+	dialer := &net.Dialer{
+		Timeout: dialTimeout,
+		Control: checkDialAddress,
+	}
+
+	return &http.Client{
+		Transport: &http.Transport{
+			DialContext:         dialer.DialContext,
+			TLSHandshakeTimeout: tlsHandshakeTimeout,
+		},
+	}
+}
+
+func checkDialAddress(_ string, address string, _ syscall.RawConn) error {
+	addrPort, err := netip.ParseAddrPort(address)
+	if err != nil {
+		return fmt.Errorf("failed to parse webhook address: %w", err)
+	}
+
+	if !isPublicAddr(addrPort.Addr()) {
+		return fmt.Errorf("%w: %s", ErrForbiddenAddress, addrPort.Addr())
+	}
+
+	return nil
+}
+
+// isPublicAddr отсекает петлевые, частные, локальные для канала и прочие служебные адреса.
+func isPublicAddr(addr netip.Addr) bool {
+	addr = addr.Unmap()
+
+	return addr.IsGlobalUnicast() && !addr.IsPrivate() && !addr.IsLoopback() && !addr.IsLinkLocalUnicast()
+}
@@ -0,0 +1,214 @@
+// Package webhooks доставляет события из исходящей очереди на адреса вебхуков.
+//
+// Каждый запрос подписывается секретом вебхука: заголовок X-Webhook-Signature
+// содержит "sha256=" и HMAC-SHA256 от строки "<X-Webhook-Timestamp>.<тело запроса>".
+// Неудачные доставки повторяются с экспоненциальной задержкой, а после MaxAttempts
+// попыток помечаются недоставленными и ждут ручного повтора.
+package webhooks
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/a-bondar/go-url-shortener/internal/app/models"
+	"go.uber.org/zap"
+)
+
+const (
+	HeaderEvent     = "X-Webhook-Event"
+	HeaderDelivery  = "X-Webhook-Delivery"
+	HeaderTimestamp = "X-Webhook-Timestamp"
+	HeaderSignature = "X-Webhook-Signature"
+
+	signaturePrefix = "sha256="
+	// maxDrainBody — сколько байт ответа дочитывается, чтобы соединение вернулось в пул.
+	maxDrainBody = 4 << 10
+)
+
+// Store — исходящая очередь, которую разбирает Dispatcher.
+type Store interface {
+	ClaimDeliveries(ctx context.Context, now time.Time, lease time.Duration, limit int) ([]models.WebhookDelivery, error)
+	UpdateDelivery(ctx context.Context, delivery models.WebhookDelivery) error
+	DeleteDelivery(ctx context.Context, deliveryID string) error
+}
+
+type Options struct {
+	PollInterval time.Duration
+	BatchSize    int
+	MaxAttempts  int
+	// BaseBackoff удваивается с каждой неудачной попыткой, но не превышает MaxBackoff.
+	BaseBackoff time.Duration
+	MaxBackoff  time.Duration
+	// Timeout ограничивает одну отправку; 0 — ограничение задаёт только клиент.
+	Timeout time.Duration
+	// Lease — на сколько выбранные доставки скрываются от других проходов, пока идёт отправка.
+	// Должен превышать BatchSize × Timeout: иначе доставки из конца пачки возьмёт следующий проход.
+	Lease time.Duration
+}
+
+func DefaultOptions() Options {
+	opts := Options{
+		PollInterval: time.Second,
+		BatchSize:    50,
+		MaxAttempts:  8,
+		BaseBackoff:  30 * time.Second,
+		MaxBackoff:   time.Hour,
+		Timeout:      10 * time.Second,
+	}
+	opts.Lease = time.Duration(opts.BatchSize)*opts.Timeout + time.Minute
+
+	return opts
+}
+
+type Dispatcher struct {
+	store  Store
+	client *http.Client
+	logger *zap.Logger
+	opts   Options
+}
+
+func NewDispatcher(store Store, client *http.Client, logger *zap.Logger, opts Options) *Dispatcher {
+	return &Dispatcher{
+		store:  store,
+		client: client,
+		logger: logger,
+		opts:   opts,
+	}
+}
+
+// Run разбирает очередь раз в PollInterval, пока не отменён ctx.
+func (d *Dispatcher) Run(ctx context.Context) {
+	ticker := time.NewTicker(d.opts.PollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if _, err := d.DeliverDue(ctx); err != nil {
+				d.logger.Error("Failed to deliver webhooks", zap.Error(err))
+			}
+		}
+	}
+}
+
+// DeliverDue делает один проход по очереди и возвращает число доставленных событий.
+func (d *Dispatcher) DeliverDue(ctx context.Context) (int, error) {
+	claimedAt := time.Now()
+	deliveries, err := d.store.ClaimDeliveries(ctx, claimedAt, d.opts.Lease, d.opts.BatchSize)
+	if err != nil {
+		return 0, fmt.Errorf("failed to claim deliveries: %w", err)
+	}
+
+	delivered := 0
+	for _, delivery := range deliveries {
+		// Остаток пачки после истечения аренды мог забрать другой проход; он вернётся в следующий раз.
+		if time.Since(claimedAt) >= d.opts.Lease {
+			break
+		}
+
+		sendErr := d.send(ctx, delivery)
+		if sendErr == nil {
+			delivered++
+			if err = d.store.DeleteDelivery(ctx, delivery.ID); err != nil {
+				return delivered, fmt.Errorf("failed to complete delivery %s: %w", delivery.ID, err)
+			}
+
+			continue
+		}
+
+		if err = d.store.UpdateDelivery(ctx, d.failed(delivery, sendErr, time.Now())); err != nil {
+			return delivered, fmt.Errorf("failed to reschedule delivery %s: %w", delivery.ID, err)
+		}
+	}
+
+	return delivered, nil
+}
+
+// failed засчитывает неудачную попытку и назначает следующую либо переводит доставку в недоставленные.
+func (d *Dispatcher) failed(delivery models.WebhookDelivery, sendErr error, now time.Time) models.WebhookDelivery {
+	delivery.Attempts++
+	delivery.LastError = sendErr.Error()
+
+	if delivery.Attempts >= d.opts.MaxAttempts {
+		delivery.Dead = true
+		d.logger.Warn("Webhook delivery moved to dead letters",
+			zap.String("delivery_id", delivery.ID), zap.String("webhook_id", delivery.WebhookID), zap.Error(sendErr))
+
+		return delivery
+	}
+
+	delivery.NextAttemptAt = now.Add(d.backoff(delivery.Attempts))
+
+	return delivery
+}
+
+func (d *Dispatcher) backoff(attempts int) time.Duration {
+	wait := d.opts.BaseBackoff
+	for i := 1; i < attempts && wait < d.opts.MaxBackoff; i++ {
+		wait *= 2
+	}
+
+	return min(wait, d.opts.MaxBackoff)
+}
+
+func (d *Dispatcher) send(ctx context.Context, delivery models.WebhookDelivery) error {
+	if d.opts.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, d.opts.Timeout)
+		defer cancel()
+	}
+
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, delivery.URL, bytes.NewReader(delivery.Payload))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(HeaderEvent, delivery.Event)
+	req.Header.Set(HeaderDelivery, delivery.ID)
+	req.Header.Set(HeaderTimestamp, timestamp)
+	req.Header.Set(HeaderSignature, Sign(delivery.Secret, timestamp, delivery.Payload))
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to reach webhook: %w", err)
+	}
+
+	defer func() {
+		if err := resp.Body.Close(); err != nil {
+			d.logger.Error("Failed to close response body", zap.Error(err))
+		}
+	}()
+
+	// Тело ответа в ошибку не попадает: last_error видит владелец вебхука, а ответить мог
+	// сервис, к которому у него нет доступа.
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, maxDrainBody))
+
+	if resp.StatusCode < http.StatusOK || resp.StatusCode >= http.StatusMultipleChoices {
+		return fmt.Errorf("webhook responded with status %d", resp.StatusCode)
+	}
+
+	return nil
+}
+
+// Sign вычисляет значение заголовка X-Webhook-Signature; получатель проверяет его так же.
+func Sign(secret string, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+
+	return signaturePrefix + hex.EncodeToString(mac.Sum(nil))
+}
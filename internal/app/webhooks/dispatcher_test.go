@@ -0,0 +1,189 @@
+package webhooks
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/a-bondar/go-url-shortener/internal/app/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+type queueMock struct {
+	mu         sync.Mutex
+	deliveries map[string]models.WebhookDelivery
+}
+
+func (q *queueMock) ClaimDeliveries(
+	_ context.Context, now time.Time, lease time.Duration, limit int) ([]models.WebhookDelivery, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	var due []models.WebhookDelivery
+	for id, delivery := range q.deliveries {
+		if delivery.Dead || delivery.NextAttemptAt.After(now) || len(due) == limit {
+			continue
+		}
+
+		delivery.NextAttemptAt = now.Add(lease)
+		q.deliveries[id] = delivery
+		due = append(due, delivery)
+	}
+
+	return due, nil
+}
+
+func (q *queueMock) UpdateDelivery(_ context.Context, delivery models.WebhookDelivery) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	q.deliveries[delivery.ID] = delivery
+	return nil
+}
+
+func (q *queueMock) DeleteDelivery(_ context.Context, deliveryID string) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	delete(q.deliveries, deliveryID)
+	return nil
+}
+
+func TestDispatcher(t *testing.T) {
+	const secret = "whsec_test"
+	payload := []byte(`{"type":"link.created"}`)
+
+	var mu sync.Mutex
+	failing := true
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(r.Body)
+		require.NoError(t, err)
+
+		assert.Equal(t, models.EventLinkCreated, r.Header.Get(HeaderEvent))
+		assert.Equal(t, "d1", r.Header.Get(HeaderDelivery))
+		assert.Equal(t, Sign(secret, r.Header.Get(HeaderTimestamp), body), r.Header.Get(HeaderSignature))
+
+		mu.Lock()
+		defer mu.Unlock()
+		if failing {
+			w.WriteHeader(http.StatusInternalServerError)
+			_, _ = w.Write([]byte("internal token leaked"))
+		}
+	}))
+	defer receiver.Close()
+
+	queue := &queueMock{deliveries: map[string]models.WebhookDelivery{
+		"d1": {ID: "d1", Event: models.EventLinkCreated, Payload: payload, URL: receiver.URL, Secret: secret},
+	}}
+	opts := DefaultOptions()
+	opts.MaxAttempts = 2
+	opts.BaseBackoff = 0
+	d := NewDispatcher(queue, receiver.Client(), zap.NewNop(), opts)
+
+	delivered, err := d.DeliverDue(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, 0, delivered)
+	assert.Equal(t, 1, queue.deliveries["d1"].Attempts)
+	assert.Contains(t, queue.deliveries["d1"].LastError, "status 500")
+	assert.NotContains(t, queue.deliveries["d1"].LastError, "leaked", "response body must not be stored")
+
+	mu.Lock()
+	failing = false
+	mu.Unlock()
+
+	delivered, err = d.DeliverDue(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, 1, delivered)
+	assert.Empty(t, queue.deliveries)
+}
+
+func TestDispatcherDeadLetter(t *testing.T) {
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer receiver.Close()
+
+	queue := &queueMock{deliveries: map[string]models.WebhookDelivery{
+		"d1": {ID: "d1", URL: receiver.URL},
+	}}
+	opts := DefaultOptions()
+	opts.MaxAttempts = 3
+	opts.BaseBackoff = 0
+	d := NewDispatcher(queue, receiver.Client(), zap.NewNop(), opts)
+
+	for range 5 {
+		_, err := d.DeliverDue(context.Background())
+		require.NoError(t, err)
+	}
+
+	assert.True(t, queue.deliveries["d1"].Dead)
+	assert.Equal(t, 3, queue.deliveries["d1"].Attempts)
+}
+
+func TestDispatcherBackoff(t *testing.T) {
+	d := NewDispatcher(nil, nil, zap.NewNop(), Options{BaseBackoff: time.Second, MaxBackoff: 10 * time.Second})
+
+	assert.Equal(t, time.Second, d.backoff(1))
+	assert.Equal(t, 4*time.Second, d.backoff(3))
+	assert.Equal(t, 10*time.Second, d.backoff(8))
+}
+
+func TestDispatcherRejectsInternalAddresses(t *testing.T) {
+	var hits atomic.Int64
+	receiver := httptest.NewServer(http.HandlerFunc(func(_ http.ResponseWriter, _ *http.Request) {
+		hits.Add(1)
+	}))
+	defer receiver.Close()
+
+	queue := &queueMock{deliveries: map[string]models.WebhookDelivery{
+		"d1": {ID: "d1", URL: receiver.URL},
+	}}
+	d := NewDispatcher(queue, NewClient(), zap.NewNop(), DefaultOptions())
+
+	delivered, err := d.DeliverDue(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, 0, delivered)
+	assert.Zero(t, hits.Load())
+	assert.Contains(t, queue.deliveries["d1"].LastError, ErrForbiddenAddress.Error())
+}
+
+func TestIsPublicAddr(t *testing.T) {
+	testCases := []struct {
+		addr   string
+		public bool
+	}{
+		{addr: "93.184.216.34", public: true},
+		{addr: "2606:2800:220:1:248:1893:25c8:1946", public: true},
+		{addr: "127.0.0.1"},
+		{addr: "::1"},
+		{addr: "10.1.2.3"},
+		{addr: "172.16.0.1"},
+		{addr: "192.168.1.1"},
+		{addr: "169.254.169.254"},
+		{addr: "fe80::1"},
+		{addr: "fd00::1"},
+		{addr: "0.0.0.0"},
+		{addr: "::ffff:127.0.0.1"},
+		{addr: "224.0.0.1"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.addr, func(t *testing.T) {
+			assert.Equal(t, tc.public, isPublicAddr(netip.MustParseAddr(tc.addr)))
+		})
+	}
+}
+
+func TestDefaultLeaseCoversBatch(t *testing.T) {
+	opts := DefaultOptions()
+
+	assert.Greater(t, opts.Lease, time.Duration(opts.BatchSize)*opts.Timeout)
+}